@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"github.com/Sirupsen/logrus"
+	"errors"
+
 	"github.com/hms58/genkit/generator"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,18 +13,15 @@ var clientCmd = &cobra.Command{
 	Use:     "client",
 	Short:   "Generate simple client lib",
 	Aliases: []string{"c"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			logrus.Error("You must provide a name for the service")
-			return
+			return errors.New("You must provide a name for the service")
 		}
 		g := generator.NewGenerateClient(
 			args[0],
 			viper.GetString("g_c_transport"),
 		)
-		if err := g.Generate(); err != nil {
-			logrus.Error(err)
-		}
+		return g.Generate()
 	},
 }
 
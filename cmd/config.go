@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the project configuration file",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write the current effective settings to " + utils.ConfigFileName,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := utils.ConfigSource()
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		if err := fs.Get().WriteFile(utils.ConfigFileName, s, false); err != nil {
+			logrus.Error(err)
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the current effective settings",
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := utils.ConfigSource()
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		if viper.ConfigFileUsed() != "" {
+			fmt.Printf("# loaded from %s\n", viper.ConfigFileUsed())
+		}
+		fmt.Print(s)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
-	"github.com/Sirupsen/logrus"
+	"errors"
+	"fmt"
+
 	"github.com/hms58/genkit/generator"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,14 +14,19 @@ var initserviceCmd = &cobra.Command{
 	Use:     "service",
 	Short:   "Initiate a service",
 	Aliases: []string{"s"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			logrus.Error("You must provide a name for the service")
-			return
+			return errors.New("You must provide a name for the service")
+		}
+		if prune := viper.GetString("g_s_prune"); prune != "" && !validPruneMode(prune) {
+			return fmt.Errorf("Prune mode `%s` not supported, use one of %v", prune, generator.PruneModes)
+		}
+		if viper.GetBool("gk_grpc_gateway") && !viper.GetBool("g_s_dgd") {
+			return errors.New("the gRPC gateway (--gateway or gk_grpc_gateway) is only supported with --dgd")
 		}
 		if viper.GetString("g_s_transport") == "grpc" {
 			if !checkProtoc() {
-				return
+				return errNoProtoc
 			}
 		}
 		var emw, smw bool
@@ -41,7 +48,7 @@ var initserviceCmd = &cobra.Command{
 				methods,
 			)
 		} else {
-			g = generator.NewGenerateServiceDdg(
+			g = generator.NewGenerateService(
 				args[0],
 				viper.GetString("g_s_transport"),
 				smw,
@@ -51,9 +58,7 @@ var initserviceCmd = &cobra.Command{
 			)
 		}
 
-		if err := g.Generate(); err != nil {
-			logrus.Error(err)
-		}
+		return g.Generate()
 	},
 }
 
@@ -66,10 +71,28 @@ func init() {
 	initserviceCmd.Flags().Bool("svc-mdw", false, "If set a default Logging and Instrumental middleware will be created and attached to the service")
 	initserviceCmd.Flags().Bool("endpoint-mdw", false, "If set a default Logging and Tracking middleware will be created and attached to the endpoint")
 	initserviceCmd.Flags().Bool("dgd", false, "use dgd template rpc")
+	initserviceCmd.Flags().String("prune", "", "Move (orphan) or delete (delete) the files of methods removed from the service")
+	initserviceCmd.Flags().Lookup("prune").NoOptDefVal = generator.PruneOrphan
+	initserviceCmd.Flags().String("from-proto", "", "Add the rpcs of the service declared in this proto file to the service interface")
+	initserviceCmd.Flags().Bool("grpc-health", false, "Serve the gRPC health checking and server reflection services (gk_grpc_health)")
+	initserviceCmd.Flags().Bool("gateway", false, "Serve the gRPC rpcs as HTTP/JSON routes with --dgd, see the google.api.http option (gk_grpc_gateway)")
 	viper.BindPFlag("g_s_transport", initserviceCmd.Flags().Lookup("transport"))
 	viper.BindPFlag("g_s_dmw", initserviceCmd.Flags().Lookup("dmw"))
 	viper.BindPFlag("g_s_gorilla", initserviceCmd.Flags().Lookup("gorilla"))
 	viper.BindPFlag("g_s_svc_mdw", initserviceCmd.Flags().Lookup("svc-mdw"))
 	viper.BindPFlag("g_s_endpoint_mdw", initserviceCmd.Flags().Lookup("endpoint-mdw"))
 	viper.BindPFlag("g_s_dgd", initserviceCmd.Flags().Lookup("dgd"))
+	viper.BindPFlag("g_s_prune", initserviceCmd.Flags().Lookup("prune"))
+	viper.BindPFlag("g_s_from_proto", initserviceCmd.Flags().Lookup("from-proto"))
+	viper.BindPFlag("gk_grpc_health", initserviceCmd.Flags().Lookup("grpc-health"))
+	viper.BindPFlag("gk_grpc_gateway", initserviceCmd.Flags().Lookup("gateway"))
+}
+
+func validPruneMode(mode string) bool {
+	for _, v := range generator.PruneModes {
+		if v == mode {
+			return true
+		}
+	}
+	return false
 }
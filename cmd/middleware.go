@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/hms58/genkit/generator"
 	"github.com/spf13/cobra"
@@ -12,15 +14,13 @@ var middlewareCmd = &cobra.Command{
 	Use:     "middleware",
 	Aliases: []string{"m", "mdw"},
 	Short:   "Generate middleware",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			logrus.Error("You must provide a name for the middleware")
-			return
+			return errors.New("You must provide a name for the middleware")
 		}
 		sn := viper.GetString("g_m_service")
 		if sn == "" {
-			logrus.Error("You must provide the name of the service")
-			return
+			return errors.New("You must provide the name of the service")
 		}
 		g := generator.NewGenerateMiddleware(
 			args[0],
@@ -28,7 +28,7 @@ var middlewareCmd = &cobra.Command{
 			viper.GetBool("g_m_endpoint"),
 		)
 		if err := g.Generate(); err != nil {
-			logrus.Error(err)
+			return err
 		}
 		if viper.GetBool("g_m_endpoint") {
 			logrus.Info("Do not forget to append your endpoint middleware to your service middlewares")
@@ -39,6 +39,7 @@ var middlewareCmd = &cobra.Command{
 			logrus.Info("Do not forget to append your service middleware to your service middlewares")
 			logrus.Info("Add it to cmd/service/service.go#getServiceMiddleware()")
 		}
+		return nil
 	},
 }
 
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/hms58/genkit/generator"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var refactorCmd = &cobra.Command{
+	Use:     "refactor",
+	Short:   "A set of refactorings for existing services",
+	Long:    "A set of refactorings for existing services, they are only supported with the services generated with --dgd.",
+	Aliases: []string{"r"},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var renameMethodCmd = &cobra.Command{
+	Use:     "rename-method",
+	Short:   "Rename a service method and all of its generated code",
+	Long:    "Rename a service method and all of its generated code, only the services generated with --dgd are supported.",
+	Example: "kit refactor rename-method hello Foo Bar",
+	Aliases: []string{"rename"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 3 {
+			return errors.New("You must provide the service name, the method name and the new method name")
+		}
+		return generator.NewRenameMethodDgd(args[0], args[1], args[2]).Generate()
+	},
+}
+
+var removeMethodCmd = &cobra.Command{
+	Use:     "remove-method",
+	Short:   "Remove a service method and all of its generated code",
+	Long:    "Remove a service method and all of its generated code, only the services generated with --dgd are supported.",
+	Example: "kit refactor remove-method hello Foo",
+	Aliases: []string{"remove", "rm"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("You must provide the service name and the method name")
+		}
+		mode := generator.PruneOrphan
+		if viper.GetBool("r_rm_delete") {
+			mode = generator.PruneDelete
+		}
+		return generator.NewRemoveMethodDgd(args[0], args[1], mode).Generate()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(refactorCmd)
+	refactorCmd.AddCommand(renameMethodCmd)
+	refactorCmd.AddCommand(removeMethodCmd)
+	removeMethodCmd.Flags().Bool("delete", false, "Delete the method handler file instead of moving it to the `_orphaned` folder")
+	viper.BindPFlag("r_rm_delete", removeMethodCmd.Flags().Lookup("delete"))
+}
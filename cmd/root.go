@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"os"
-	"os/exec"
 	"runtime"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/generator"
+	"github.com/hms58/genkit/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -14,38 +19,94 @@ import (
 var RootCmd = &cobra.Command{
 	Use:   "kit",
 	Short: "Go-Kit CLI",
+	// the errors of the commands are logged by Execute.
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
 }
 
-// Execute runs the root command
+// errNoProtoc is returned by the commands that need protoc when it is missing,
+// checkProtoc logs how to install it.
+var errNoProtoc = errors.New("protoc is not installed")
+
+// Execute runs the root command, it exits with a non-zero status if the
+// command failed (e.x the generated code did not compile and no file was written).
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	err := RootCmd.Execute()
+	if !viper.GetBool("gk_dry_run") {
+		// keep track of what was generated even if the command failed midway.
+		if err := fs.Get().SaveManifest(); err != nil {
+			logrus.Error(err)
+		}
+	}
+	// the conflicts are reported even if the command failed, they were already
+	// written.
+	fs.Get().ConflictReport(os.Stdout)
+	if err != nil {
 		logrus.Error(err)
 		os.Exit(1)
 	}
+	if viper.GetBool("gk_dry_run") && fs.Get().DryRunReport(os.Stdout) {
+		// changes are pending, the generated code is not up to date.
+		os.Exit(1)
+	}
 }
 
 func init() {
+	cobra.OnInitialize(initConfig)
 	RootCmd.PersistentFlags().BoolP("debug", "d", false, "If you want to se the debug logs.")
 	RootCmd.PersistentFlags().BoolP("force", "f", false, "Force overide existing files without asking.")
 	RootCmd.PersistentFlags().StringP("folder", "b", "", "If you want to specify the base folder of the project.")
-	viper.BindPFlag("gk_folder", RootCmd.PersistentFlags().Lookup("folder"))
-	viper.BindPFlag("gk_force", RootCmd.PersistentFlags().Lookup("force"))
-	viper.BindPFlag("gk_debug", RootCmd.PersistentFlags().Lookup("debug"))
+	RootCmd.PersistentFlags().String(
+		"on-conflict",
+		fs.ConflictPrompt,
+		"What to do when a file already exists ("+strings.Join(fs.ConflictPolicies, "|")+").",
+	)
+	RootCmd.PersistentFlags().Bool("dry-run", false, "Do not write any file, print a diff of the changes instead.")
+	RootCmd.PersistentFlags().Bool("allow-breaking", false, "Write the proto changes that break the deployed clients.")
+	RootCmd.PersistentFlags().Bool("no-verify", false, "Do not type check the generated code before writing it.")
+	// the root flags only tune the run, each one sets the `gk_<flag>` setting
+	// but is kept out of the project config.
+	RootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		key := "gk_" + strings.Replace(f.Name, "-", "_", -1)
+		viper.BindPFlag(key, f)
+		utils.SetRuntimeSetting(key)
+	})
+}
+
+// initConfig loads the project config file, it runs after the flags are parsed
+// so the `--folder` flag is taken into account.
+func initConfig() {
+	if err := utils.LoadConfig(); err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+	if !fs.ValidConflictPolicy(fs.ConflictPolicy()) {
+		logrus.Errorf(
+			"Unknown conflict policy `%s`, use one of: %s",
+			fs.ConflictPolicy(),
+			strings.Join(fs.ConflictPolicies, ", "),
+		)
+		os.Exit(1)
+	}
+	if viper.ConfigFileUsed() != "" {
+		logrus.Debug("Using config file: ", viper.ConfigFileUsed())
+	}
 }
 
+// checkProtoc checks that protoc and the plugins of the gk_protoc settings are
+// installed.
 func checkProtoc() bool {
-	p := exec.Command("protoc")
-	if p.Run() != nil {
+	if err := generator.CheckProtoc(); err != nil {
+		logrus.Error(err)
 		logrus.Error("Please install protoc first and than rerun the command")
 		if runtime.GOOS == "windows" {
 			logrus.Info(
 				`Install proto3.
 https://github.com/google/protobuf/releases
-Update protoc Go bindings via
-> go get -u github.com/golang/protobuf/proto
+Install the protoc plugins of gk_protoc_plugins, e.x
 > go get -u github.com/golang/protobuf/protoc-gen-go
 
 See also
@@ -58,8 +119,8 @@ https://github.com/grpc/grpc-go/tree/master/examples`,
 > git clone https://github.com/google/protobuf
 > ./autogen.sh ; ./configure ; make ; make install
 
-Update protoc Go bindings via
-> go get -u github.com/golang/protobuf/{proto,protoc-gen-go}
+Install the protoc plugins of gk_protoc_plugins, e.x
+> go get -u github.com/golang/protobuf/protoc-gen-go
 
 See also
 https://github.com/grpc/grpc-go/tree/master/examples`,
@@ -74,7 +135,10 @@ cd protobuf/
 make
 make check
 sudo make install
-sudo ldconfig # refresh shared library cache.`)
+sudo ldconfig # refresh shared library cache.
+
+Install the protoc plugins of gk_protoc_plugins, e.x
+> go get -u github.com/golang/protobuf/protoc-gen-go`)
 		}
 		return false
 	}
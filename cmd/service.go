@@ -1,27 +1,52 @@
 package cmd
 
 import (
-	"github.com/Sirupsen/logrus"
+	"errors"
+
 	"github.com/hms58/genkit/generator"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var serviceCmd = &cobra.Command{
 	Use:     "service",
 	Short:   "Generate new service",
 	Aliases: []string{"s"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			logrus.Error("You must provide a name for the service")
-			return
+			return errors.New("You must provide a name for the service")
+		}
+		protoPath := viper.GetString("n_s_from_proto")
+		if protoPath != "" && viper.GetBool("gk_grpc_gateway") && !viper.GetBool("n_s_dgd") {
+			return errors.New("the gRPC gateway (gk_grpc_gateway) is only supported with --dgd")
+		}
+		if protoPath != "" && !checkProtoc() {
+			return errNoProtoc
 		}
 		g := generator.NewNewService(args[0])
 		if err := g.Generate(); err != nil {
-			logrus.Error(err)
+			return err
+		}
+		if protoPath == "" {
+			return nil
+		}
+		// the service of a proto contract is served with gRPC.
+		viper.Set("g_s_from_proto", protoPath)
+		if viper.GetBool("n_s_dgd") {
+			g = generator.NewGenerateServiceDdg(args[0], "grpc", false, false, false, []string{})
+		} else {
+			g = generator.NewGenerateService(args[0], "grpc", false, false, false, []string{})
 		}
+		return g.Generate()
 	},
 }
 
 func init() {
 	newCmd.AddCommand(serviceCmd)
+	serviceCmd.Flags().String("module", "", "The module path used if a go.mod file needs to be created")
+	serviceCmd.Flags().String("from-proto", "", "Generate the service from the service declared in this proto file")
+	serviceCmd.Flags().Bool("dgd", false, "Use the dgd template rpcs for the service generated from the proto file")
+	viper.BindPFlag("n_s_module", serviceCmd.Flags().Lookup("module"))
+	viper.BindPFlag("n_s_from_proto", serviceCmd.Flags().Lookup("from-proto"))
+	viper.BindPFlag("n_s_dgd", serviceCmd.Flags().Lookup("dgd"))
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:     "status",
+	Short:   "List the generated, user-owned and modified files of the services",
+	Example: "kit status hello",
+	Aliases: []string{"st"},
+	Run: func(cmd *cobra.Command, args []string) {
+		files := fs.Get().Status()
+		if len(files) == 0 {
+			fmt.Printf("No generated files found in `%s`.\n", fs.ManifestPath)
+			return
+		}
+		services := []string{}
+		byService := map[string][]fs.FileStatus{}
+		for _, f := range files {
+			s := "(project)"
+			if i := strings.Index(f.Path, "/"); i > 0 {
+				s = f.Path[:i]
+			}
+			if _, ok := byService[s]; !ok {
+				services = append(services, s)
+			}
+			byService[s] = append(byService[s], f)
+		}
+		if len(args) > 0 {
+			services = args
+		}
+		for _, s := range services {
+			fmt.Println(s)
+			if len(byService[s]) == 0 {
+				fmt.Println("  no generated files")
+			}
+			for _, f := range byService[s] {
+				kind := "user-owned"
+				if f.Generated {
+					kind = "generated"
+				}
+				fmt.Printf("  %-10s  %-9s  %s\n", kind, f.State, f.Path)
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statusCmd)
+}
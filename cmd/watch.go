@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/generator"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var watchCmd = &cobra.Command{
+	Use:     "watch",
+	Short:   "Regenerate the services when their interface or proto file changes",
+	Example: "kit watch hello -t grpc",
+	Aliases: []string{"w"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Error("You must provide at least one service name")
+			return
+		}
+		w, err := newServiceWatcher(args)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		defer w.Close()
+		// nobody answers the prompt while watching, the conflicts are
+		// skipped and reported instead.
+		if fs.ConflictPolicy() == fs.ConflictPrompt {
+			viper.Set("gk_on_conflict", fs.ConflictSkip)
+		}
+		w.Watch(viper.GetDuration("w_debounce"))
+	},
+}
+
+// serviceWatcher watches the interface and proto files of the services.
+type serviceWatcher struct {
+	*fsnotify.Watcher
+	// services maps the watched files to their service and content maps them
+	// to their last known content so the files written by kit are ignored.
+	services map[string]string
+	content  map[string]string
+	// dirs are the watched folders.
+	dirs map[string]bool
+}
+
+func newServiceWatcher(names []string) (*serviceWatcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &serviceWatcher{
+		Watcher:  fw,
+		services: map[string]string{},
+		content:  map[string]string{},
+		dirs:     map[string]bool{},
+	}
+	for _, name := range names {
+		snakeName := utils.ToLowerSnakeCase2(name)
+		files := []string{
+			path.Join(
+				fmt.Sprintf(viper.GetString("gk_service_path_format"), snakeName),
+				viper.GetString("gk_service_file_name"),
+			),
+			path.Join(
+				fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), snakeName),
+				fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), snakeName),
+			),
+		}
+		for i, f := range files {
+			f = filepath.Join(viper.GetString("gk_folder"), f)
+			if _, err := os.Stat(f); err != nil && i == 0 {
+				w.Close()
+				return nil, fmt.Errorf("service `%s` was not found: %s", name, err)
+			}
+			w.services[f] = name
+			w.content[f] = readFile(f)
+			// editors often replace the file, so the folder is watched.
+			if err := w.watchDir(filepath.Dir(f)); err != nil {
+				w.Close()
+				return nil, err
+			}
+			logrus.Infof("Watching `%s`", f)
+		}
+	}
+	return w, nil
+}
+
+// watchDir watches the folder `dir` or, if it does not exist yet, the closest
+// folder above it so its creation is seen.
+func (w *serviceWatcher) watchDir(dir string) error {
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+	if w.dirs[dir] {
+		return nil
+	}
+	if err := w.Add(dir); err != nil {
+		return err
+	}
+	w.dirs[dir] = true
+	return nil
+}
+
+// created watches the folders of the watched files that are inside the created
+// folder `dir` and returns the services whose files were already written there.
+func (w *serviceWatcher) created(dir string) (names []string) {
+	for f, name := range w.services {
+		if !strings.HasPrefix(f, dir+string(filepath.Separator)) {
+			continue
+		}
+		if err := w.watchDir(filepath.Dir(f)); err != nil {
+			logrus.Error(err)
+		}
+		if readFile(f) != w.content[f] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Watch regenerates the changed services once no change happened for the
+// debounce duration, it runs until the watcher is closed.
+func (w *serviceWatcher) Watch(debounce time.Duration) {
+	changed := map[string]bool{}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if fi, err := os.Stat(e.Name); err == nil && fi.IsDir() && e.Op&fsnotify.Create != 0 {
+				for _, name := range w.created(filepath.Clean(e.Name)) {
+					changed[name] = true
+					timer.Reset(debounce)
+				}
+				continue
+			}
+			name, ok := w.services[filepath.Clean(e.Name)]
+			if !ok || e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if readFile(e.Name) == w.content[filepath.Clean(e.Name)] {
+				continue
+			}
+			changed[name] = true
+			timer.Reset(debounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logrus.Error(err)
+		case <-timer.C:
+			names := []string{}
+			for n := range changed {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			changed = map[string]bool{}
+			for _, n := range names {
+				w.regenerate(n)
+			}
+			// the files written by kit must not trigger a new run.
+			for f := range w.content {
+				w.content[f] = readFile(f)
+			}
+		}
+	}
+}
+
+// regenerate runs `kit g s` and regenerates the existing clients of the service.
+func (w *serviceWatcher) regenerate(name string) {
+	logrus.Infof("`%s` changed, regenerating.", name)
+	defer func() {
+		// a failed run must not stop the watcher.
+		if r := recover(); r != nil {
+			logrus.Errorf("Could not regenerate `%s`: %v", name, r)
+		}
+	}()
+	kfs := fs.NewDefaultFs("")
+	if err := initserviceCmd.RunE(initserviceCmd, []string{name}); err != nil {
+		logrus.Error(err)
+	}
+	snakeName := utils.ToLowerSnakeCase2(name)
+	clients := map[string]string{
+		"http": path.Join(
+			fmt.Sprintf(viper.GetString("gk_http_client_path_format"), snakeName),
+			viper.GetString("gk_http_client_file_name"),
+		),
+		"grpc": path.Join(
+			fmt.Sprintf(viper.GetString("gk_grpc_client_path_format"), snakeName),
+			viper.GetString("gk_grpc_client_file_name"),
+		),
+	}
+	for _, tp := range generator.SupportedTransports {
+		if b, _ := kfs.Exists(clients[tp]); clients[tp] == "" || !b {
+			continue
+		}
+		if err := generator.NewGenerateClient(name, tp).Generate(); err != nil {
+			logrus.Error(err)
+		}
+	}
+	kfs.ConflictReport(os.Stdout)
+	if viper.GetBool("gk_dry_run") {
+		kfs.DryRunReport(os.Stdout)
+		return
+	}
+	if err := kfs.SaveManifest(); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// readFile returns the content of the file or an empty string if it can not
+// be read.
+func readFile(f string) string {
+	d, _ := ioutil.ReadFile(f)
+	return string(d)
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	// the generation flags are the ones of `kit g s` so both commands behave
+	// the same, g_service.go is initialized before this file.
+	watchCmd.Flags().AddFlagSet(initserviceCmd.Flags())
+	watchCmd.Flags().Duration("debounce", 500*time.Millisecond, "How long to wait after the last change before regenerating")
+	viper.BindPFlag("w_debounce", watchCmd.Flags().Lookup("debounce"))
+}
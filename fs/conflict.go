@@ -0,0 +1,104 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/spf13/viper"
+)
+
+// Conflict policies, they decide what happens when a generator wants to write
+// a file that already exists with a different content.
+const (
+	// ConflictPrompt asks the user if the file should be overwritten.
+	ConflictPrompt = "prompt"
+	// ConflictSkip keeps the existing file.
+	ConflictSkip = "skip"
+	// ConflictOverwrite overwrites the existing file.
+	ConflictOverwrite = "overwrite"
+	// ConflictBackup copies the existing file to `file.orig` and overwrites it.
+	ConflictBackup = "backup"
+	// ConflictWriteNew keeps the existing file and writes the new content to `file.new`.
+	ConflictWriteNew = "write-new"
+)
+
+// ConflictPolicies are all the supported conflict policies.
+var ConflictPolicies = []string{
+	ConflictPrompt,
+	ConflictSkip,
+	ConflictOverwrite,
+	ConflictBackup,
+	ConflictWriteNew,
+}
+
+// Conflict is a file that already existed and what was done with it.
+type Conflict struct {
+	Path   string
+	Action string
+}
+
+// ValidConflictPolicy returns true if `policy` is a supported conflict policy.
+func ValidConflictPolicy(policy string) bool {
+	for _, v := range ConflictPolicies {
+		if v == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// ConflictPolicy returns the conflict policy of the run, `--force` means overwrite.
+func ConflictPolicy() string {
+	if viper.GetBool("gk_force_override") || viper.GetBool("gk_force") {
+		return ConflictOverwrite
+	}
+	policy := viper.GetString("gk_on_conflict")
+	if policy == "" {
+		return ConflictPrompt
+	}
+	return policy
+}
+
+// Conflicts returns the files that hit a conflict during the run.
+func (f *KitFs) Conflicts() []Conflict {
+	return f.conflicts
+}
+
+// resolveConflict applies the conflict policy to the existing file at `path`
+// written by `generator`.
+func (f *KitFs) resolveConflict(generator, path string, old string, data string) error {
+	policy := ConflictPolicy()
+	if policy == ConflictPrompt && viper.GetBool("gk_dry_run") {
+		// there is no one to ask, show what overwriting would do.
+		policy = ConflictOverwrite
+	}
+	if policy == ConflictPrompt {
+		policy = ConflictSkip
+		if prompter.YN(fmt.Sprintf("`%s` already exists do you want to override it ?", path), false) {
+			policy = ConflictOverwrite
+		}
+	}
+	switch policy {
+	case ConflictSkip:
+		f.conflicts = append(f.conflicts, Conflict{path, "skipped"})
+		return nil
+	case ConflictOverwrite:
+		f.conflicts = append(f.conflicts, Conflict{path, "overwritten"})
+		return f.write(generator, path, data)
+	case ConflictBackup:
+		if err := f.write(generator, path+".orig", old); err != nil {
+			return err
+		}
+		f.conflicts = append(f.conflicts, Conflict{path, fmt.Sprintf("backed up to `%s.orig` and overwritten", path)})
+		return f.write(generator, path, data)
+	case ConflictWriteNew:
+		f.conflicts = append(f.conflicts, Conflict{path, fmt.Sprintf("kept, new content written to `%s.new`", path)})
+		return f.write(generator, path+".new", data)
+	}
+	return fmt.Errorf(
+		"unknown conflict policy `%s` (%s)",
+		policy,
+		strings.Join(ConflictPolicies, ", "),
+	)
+}
@@ -0,0 +1,56 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+func TestKitFs_WriteFile_conflict(t *testing.T) {
+	defer viper.Set("gk_on_conflict", "")
+	tests := []struct {
+		name   string
+		policy string
+		want   map[string]string
+	}{
+		{
+			name:   "Test skip",
+			policy: ConflictSkip,
+			want:   map[string]string{"a.go": "old"},
+		},
+		{
+			name:   "Test overwrite",
+			policy: ConflictOverwrite,
+			want:   map[string]string{"a.go": "new"},
+		},
+		{
+			name:   "Test backup",
+			policy: ConflictBackup,
+			want:   map[string]string{"a.go": "new", "a.go.orig": "old"},
+		},
+		{
+			name:   "Test write new",
+			policy: ConflictWriteNew,
+			want:   map[string]string{"a.go": "old", "a.go.new": "new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("gk_on_conflict", tt.policy)
+			f := &KitFs{Fs: afero.NewMemMapFs()}
+			afero.WriteFile(f.Fs, "a.go", []byte("old"), 0644)
+			if err := f.WriteFile("a.go", "new", false); err != nil {
+				t.Fatalf("KitFs.WriteFile() error = %v", err)
+			}
+			for p, want := range tt.want {
+				if got, _ := f.ReadFile(p); got != want {
+					t.Errorf("KitFs.WriteFile() `%s` = %v, want %v", p, got, want)
+				}
+			}
+			if len(f.Conflicts()) != 1 || f.Conflicts()[0].Path != "a.go" {
+				t.Errorf("KitFs.Conflicts() = %v, want one conflict for a.go", f.Conflicts())
+			}
+		})
+	}
+}
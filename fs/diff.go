@@ -0,0 +1,191 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around every change.
+const diffContext = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type diffOp struct {
+	kind opKind
+	line string
+}
+
+// splitLines splits s in lines keeping track of a missing trailing new line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script that turns a into b using
+// the Myers O(ND) algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+	var d int
+outer:
+	for d = 0; d <= max; d++ {
+		vc := make([]int, len(v))
+		copy(vc, v)
+		trace = append(trace, vc)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break outer
+			}
+		}
+	}
+	// Walk the trace backwards to build the edit script.
+	ops := []diffOp{}
+	x, y := n, m
+	for ; d > 0; d-- {
+		vp := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && vp[offset+k-1] < vp[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := vp[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{opEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{opInsert, b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{opDelete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{opEqual, a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// UnifiedDiff returns the unified diff between `oldData` and `newData`, an empty
+// string is returned if there is no difference.
+func UnifiedDiff(oldName, newName, oldData, newData string) string {
+	if oldData == newData {
+		return ""
+	}
+	ops := diffLines(splitLines(oldData), splitLines(newData))
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		// find the next change.
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk while the changes are close to each other.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			eq := end
+			for eq < len(ops) && ops[eq].kind == opEqual {
+				eq++
+			}
+			if eq == len(ops) || eq-end > 2*diffContext {
+				end += diffContext
+				if end > eq {
+					end = eq
+				}
+				break
+			}
+			end = eq
+		}
+		oldStart, newStart := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != opInsert {
+				oldStart++
+			}
+			if op.kind != opDelete {
+				newStart++
+			}
+		}
+		oldLen, newLen := 0, 0
+		hunk := new(bytes.Buffer)
+		for _, op := range ops[start:end] {
+			prefix := " "
+			switch op.kind {
+			case opDelete:
+				prefix = "-"
+				oldLen++
+			case opInsert:
+				prefix = "+"
+				newLen++
+			default:
+				oldLen++
+				newLen++
+			}
+			hunk.WriteString(prefix + op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		if oldLen == 0 {
+			oldStart--
+		}
+		if newLen == 0 {
+			newStart--
+		}
+		fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		buf.Write(hunk.Bytes())
+		i = end
+	}
+	return buf.String()
+}
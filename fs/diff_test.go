@@ -0,0 +1,50 @@
+package fs
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldData string
+		newData string
+		want    string
+	}{
+		{
+			name:    "Test identical content",
+			oldData: "a\nb\n",
+			newData: "a\nb\n",
+			want:    "",
+		},
+		{
+			name:    "Test new file",
+			oldData: "",
+			newData: "a\nb\n",
+			want:    "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "Test changed line",
+			oldData: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			newData: "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want:    "--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:    "Test separate hunks",
+			oldData: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			newData: "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want:    "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name:    "Test missing new line at end of file",
+			oldData: "a\n",
+			newData: "a\nb",
+			want:    "--- a/f\n+++ b/f\n@@ -1,1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("a/f", "b/f", tt.oldData, tt.newData); got != tt.want {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,10 @@
 package fs
 
 import (
-	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/Songmu/prompter"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
@@ -15,6 +14,30 @@ var defaultFs *KitFs
 // KitFs wraps an afero.Fs
 type KitFs struct {
 	Fs afero.Fs
+
+	// written keeps the paths in the order they were first written and
+	// original the content they had before that.
+	written   []string
+	original  map[string]fileState
+	conflicts []Conflict
+	// removed keeps the files removed during a dry run, they are only
+	// hidden because the project is read only.
+	removed map[string]bool
+	// mf is the manifest of the generated files, see manifest.
+	mf        *Manifest
+	mfChanged bool
+	// base is the filesystem the files are written to when a transaction
+	// is committed, dirs the folders created during the transaction and
+	// baseConflicts the number of conflicts before it began.
+	base          afero.Fs
+	dirs          []string
+	baseConflicts int
+}
+
+// fileState is the content of a file before kit touched it.
+type fileState struct {
+	exists bool
+	data   string
 }
 
 func (f *KitFs) init(dir string) {
@@ -28,6 +51,10 @@ func (f *KitFs) init(dir string) {
 			inFs = afero.NewOsFs()
 		}
 	}
+	if viper.GetBool("gk_dry_run") {
+		// all the changes go to memory, the project is only read.
+		inFs = afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(inFs), afero.NewMemMapFs())
+	}
 	if dir != "" {
 		f.Fs = afero.NewBasePathFs(inFs, dir)
 	} else {
@@ -38,36 +65,119 @@ func (f *KitFs) init(dir string) {
 // ReadFile reads the file from `path` and returns the content in string format
 // or returns an error if it occurs.
 func (f *KitFs) ReadFile(path string) (string, error) {
+	if f.removed[filepath.Clean(path)] {
+		return "", os.ErrNotExist
+	}
 	d, err := afero.ReadFile(f.Fs, path)
 	return string(d), err
 }
 
 // WriteFile writs a file to the `path` with `data` as content, if `force` is set
-// to true it will override the file if it already exists.
+// to true it will override the file if it already exists, otherwise the conflict
+// policy (`--on-conflict`) decides what happens. Generated files that were edited
+// by hand since kit wrote them are never overwritten unless forced.
 func (f *KitFs) WriteFile(path string, data string, force bool) error {
-	if b, _ := f.Exists(path); b && !(viper.GetBool("gk_force_override") || force) {
+	return f.WriteFileAs(DefaultGenerator, path, data, force)
+}
+
+// WriteFileAs is WriteFile for the generator named `generator`, the manifest
+// records the generator that last wrote the file.
+func (f *KitFs) WriteFileAs(generator, path string, data string, force bool) error {
+	if f.editedByHand(path) && ConflictPolicy() != ConflictOverwrite {
+		f.skipEdited(path)
+		return nil
+	}
+	if b, _ := f.Exists(path); b && !force {
 		s, _ := f.ReadFile(path)
 		if s == data {
 			logrus.Warnf("`%s` exists and is identical it will be ignored", path)
+			// the file is still what the generator wants.
+			f.track(path)
+			f.record(path, data, generator)
 			return nil
 		}
-		b := prompter.YN(fmt.Sprintf("`%s` already exists do you want to override it ?", path), false)
-		if !b {
-			return nil
-		}
+		return f.resolveConflict(generator, path, s, data)
 	}
+	return f.write(generator, path, data)
+}
+
+// write tracks and writes the file without any check.
+func (f *KitFs) write(generator, path string, data string) error {
+	f.track(path)
+	delete(f.removed, filepath.Clean(path))
+	f.record(path, data, generator)
 	// return afero.WriteFile(f.Fs, path, []byte(data), os.ModePerm)
 	var modePerm os.FileMode = 0644
 	return afero.WriteFile(f.Fs, path, []byte(data), modePerm)
 }
 
+// track saves the content `path` had before it is written for the first time.
+func (f *KitFs) track(path string) {
+	path = filepath.Clean(path)
+	if f.original == nil {
+		f.original = map[string]fileState{}
+	}
+	if _, ok := f.original[path]; ok {
+		return
+	}
+	st := fileState{}
+	if b, _ := f.Exists(path); b {
+		st.exists = true
+		st.data, _ = f.ReadFile(path)
+	}
+	f.original[path] = st
+	f.written = append(f.written, path)
+}
+
+// Remove removes the file at `path`, during a dry run or a transaction it
+// is only hidden.
+func (f *KitFs) Remove(path string) error {
+	f.track(path)
+	f.forget(path)
+	if viper.GetBool("gk_dry_run") || f.InTransaction() {
+		if f.removed == nil {
+			f.removed = map[string]bool{}
+		}
+		f.removed[filepath.Clean(path)] = true
+		return nil
+	}
+	return f.Fs.Remove(path)
+}
+
+// Move moves the file at `src` to `dst`, the generator that wrote `src` is
+// recorded for `dst`.
+func (f *KitFs) Move(src, dst string) error {
+	s, err := f.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	generator := DefaultGenerator
+	if e := f.manifest().Files[filepath.ToSlash(filepath.Clean(src))]; e != nil {
+		generator = e.Generator
+	}
+	if err = f.WriteFileAs(generator, dst, s, false); err != nil {
+		return err
+	}
+	return f.Remove(src)
+}
+
 // Mkdir creates a directory.
 func (f *KitFs) Mkdir(dir string) error {
+	if f.InTransaction() {
+		f.dirs = append(f.dirs, dir)
+	}
 	return f.Fs.Mkdir(dir, os.ModePerm)
 }
 
 // MkdirAll creates a directory and its parents if they don't exist.
 func (f *KitFs) MkdirAll(path string) error {
+	if f.InTransaction() {
+		// the copy on write layer fails on the folders of the disk.
+		if ok, err := afero.IsDir(f.Fs, path); err == nil && ok {
+			return nil
+		}
+		f.dirs = append(f.dirs, path)
+	}
 	return f.Fs.MkdirAll(path, os.ModePerm)
 }
 
@@ -75,6 +185,9 @@ func (f *KitFs) MkdirAll(path string) error {
 // the dir/file does not exist, it will return an error if something
 // went wrong.
 func (f *KitFs) Exists(path string) (bool, error) {
+	if f.removed[filepath.Clean(path)] {
+		return false, nil
+	}
 	return afero.Exists(f.Fs, path)
 }
 
@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+func TestKitFs_Remove(t *testing.T) {
+	defer viper.Set("gk_dry_run", false)
+	for _, dryRun := range []bool{false, true} {
+		viper.Set("gk_dry_run", dryRun)
+		f := &KitFs{Fs: afero.NewMemMapFs()}
+		afero.WriteFile(f.Fs, "a.go", []byte("a"), 0644)
+		if err := f.Move("a.go", "_orphaned/a.go"); err != nil {
+			t.Fatalf("KitFs.Move() error = %v", err)
+		}
+		if b, _ := f.Exists("a.go"); b {
+			t.Errorf("KitFs.Move() dry run %v, `a.go` still exists", dryRun)
+		}
+		if s, _ := f.ReadFile("_orphaned/a.go"); s != "a" {
+			t.Errorf("KitFs.Move() dry run %v, `_orphaned/a.go` = %v, want a", dryRun, s)
+		}
+		changes := f.Changes()
+		if len(changes) != 2 || changes[0].Status != StatusCreated || changes[1].Status != StatusDeleted {
+			t.Errorf("KitFs.Changes() dry run %v = %v, want created and deleted", dryRun, changes)
+		}
+	}
+}
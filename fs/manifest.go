@@ -0,0 +1,272 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
+)
+
+// ManifestPath is where kit keeps the manifest of the files it generated.
+const ManifestPath = ".genkit/manifest.json"
+
+// GeneratedMarker is the comment kit adds to the files that are only generated,
+// those files are not supposed to be edited by hand.
+const GeneratedMarker = "AUTO GENERATED BY GK-CLI DO NOT EDIT"
+
+// DefaultGenerator is the generator recorded for the files that are not
+// written by a generator, see KitFs.WriteFileAs.
+const DefaultGenerator = "kit"
+
+// File states reported by `kit status`.
+const (
+	StateUnchanged = "unchanged"
+	StateModified  = "modified"
+	StateMissing   = "missing"
+)
+
+// Manifest records what kit generated.
+type Manifest struct {
+	Files map[string]*ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is a file written by kit.
+type ManifestEntry struct {
+	// Generator is the last generator that wrote the file.
+	Generator string `json:"generator"`
+	// Declarations are the go declarations that kit contributed to the file.
+	Declarations []string `json:"declarations,omitempty"`
+	// Hash is the sha256 of the content kit wrote.
+	Hash string `json:"hash"`
+	// Generated is true if the whole file is generated (see GeneratedMarker).
+	Generated bool `json:"generated"`
+	// Docs are the doc comments kit wrote by declaration (and by rpc and
+	// message for proto files), a doc that is not the recorded one was edited
+	// by hand.
+	Docs map[string]string `json:"docs,omitempty"`
+}
+
+// FileStatus is the state of a file of the manifest.
+type FileStatus struct {
+	Path string
+	ManifestEntry
+	State string
+}
+
+func hashOf(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// manifest loads the manifest the first time it is needed.
+func (f *KitFs) manifest() *Manifest {
+	if f.mf != nil {
+		return f.mf
+	}
+	f.mf = &Manifest{Files: map[string]*ManifestEntry{}}
+	d, err := afero.ReadFile(f.Fs, ManifestPath)
+	if err != nil {
+		return f.mf
+	}
+	if err = json.Unmarshal(d, f.mf); err != nil {
+		logrus.Warnf("Could not read `%s`, it will be recreated: %s", ManifestPath, err)
+	}
+	if f.mf.Files == nil {
+		f.mf.Files = map[string]*ManifestEntry{}
+	}
+	return f.mf
+}
+
+// SaveManifest writes the manifest if it changed during the run.
+func (f *KitFs) SaveManifest() error {
+	if !f.mfChanged {
+		return nil
+	}
+	d, err := json.MarshalIndent(f.manifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = f.Fs.MkdirAll(path.Dir(ManifestPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err = afero.WriteFile(f.Fs, ManifestPath, append(d, '\n'), 0644); err != nil {
+		return err
+	}
+	f.mfChanged = false
+	return nil
+}
+
+// record saves the file written by kit in the manifest, `generator` is the
+// name of the generator that wrote it.
+func (f *KitFs) record(p string, data string, generator string) {
+	p = filepath.ToSlash(filepath.Clean(p))
+	m := f.manifest()
+	e := &ManifestEntry{
+		Generator: generator,
+		Hash:      hashOf(data),
+		Generated: strings.Contains(data, GeneratedMarker),
+	}
+	switch {
+	case strings.HasSuffix(p, ".go"):
+		e.Declarations = f.contributed(p, data, e.Generated)
+		if !e.Generated {
+			e.Docs = f.docs(p, data, m.Files[p], e.Declarations, parser.ParseDeclDocs)
+		}
+	case strings.HasSuffix(p, ".proto"):
+		e.Docs = f.docs(p, data, m.Files[p], nil, parser.ParseProtoDocs)
+	}
+	m.Files[p] = e
+	f.mfChanged = true
+}
+
+// forget removes the file from the manifest.
+func (f *KitFs) forget(p string) {
+	p = filepath.ToSlash(filepath.Clean(p))
+	m := f.manifest()
+	if _, ok := m.Files[p]; ok {
+		delete(m.Files, p)
+		f.mfChanged = true
+	}
+}
+
+// contributed returns the declarations of `data` that kit added, the ones it
+// added before that are still there and the ones that were not in the file
+// before kit wrote it during this run (all of them if the file is generated).
+func (f *KitFs) contributed(p string, data string, generated bool) []string {
+	current, err := parser.ParseDeclKeys(data)
+	if err != nil {
+		return nil
+	}
+	recorded := map[string]bool{}
+	if e := f.manifest().Files[p]; e != nil {
+		for _, k := range e.Declarations {
+			recorded[k] = true
+		}
+	}
+	original := map[string]bool{}
+	if st := f.original[filepath.Clean(p)]; st.exists {
+		keys, _ := parser.ParseDeclKeys(st.data)
+		for _, k := range keys {
+			original[k] = true
+		}
+	}
+	decls := []string{}
+	for _, k := range current {
+		if generated || recorded[k] || !original[k] {
+			decls = append(decls, k)
+		}
+	}
+	sort.Strings(decls)
+	return decls
+}
+
+// docs returns the doc comments of the declarations `keys` (all of them if
+// `keys` is nil) that kit wrote. A doc that was edited by hand before this
+// write keeps its recorded value so it is still seen as edited.
+func (f *KitFs) docs(p string, data string, old *ManifestEntry, keys []string,
+	parse func(string) (map[string]string, error)) map[string]string {
+	current, err := parse(data)
+	if err != nil {
+		return nil
+	}
+	before := map[string]string{}
+	if s, err := f.ReadFile(p); err == nil {
+		if d, err := parse(s); err == nil {
+			before = d
+		}
+	}
+	if keys == nil {
+		for k := range current {
+			keys = append(keys, k)
+		}
+	}
+	docs := map[string]string{}
+	for _, k := range keys {
+		cur, ok := current[k]
+		if !ok {
+			continue
+		}
+		prev, existed := before[k]
+		rec, recorded := "", false
+		if old != nil {
+			rec, recorded = old.Docs[k]
+		}
+		switch {
+		case !existed, recorded && prev == rec, !recorded && cur != prev:
+			docs[k] = cur
+		case recorded:
+			docs[k] = rec
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	return docs
+}
+
+// RecordedDoc returns the doc comment kit wrote for the declaration `key` of
+// `p`, see ManifestEntry.Docs.
+func (f *KitFs) RecordedDoc(p string, key string) (string, bool) {
+	e := f.manifest().Files[filepath.ToSlash(filepath.Clean(p))]
+	if e == nil {
+		return "", false
+	}
+	doc, ok := e.Docs[key]
+	return doc, ok
+}
+
+// editedByHand returns true if `p` is a generated file that changed since kit
+// last wrote it.
+func (f *KitFs) editedByHand(p string) bool {
+	e := f.manifest().Files[filepath.ToSlash(filepath.Clean(p))]
+	if e == nil || !e.Generated {
+		return false
+	}
+	s, err := f.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	return hashOf(s) != e.Hash
+}
+
+// skipEdited tells the user that the generated file `p` was edited by hand and
+// is not overwritten.
+func (f *KitFs) skipEdited(p string) {
+	action := "skipped, edited by hand (use --force to overwrite)"
+	for _, c := range f.conflicts {
+		if c.Path == p && c.Action == action {
+			return
+		}
+	}
+	logrus.Warnf("`%s` is generated but it was edited by hand, it will not be overwritten.", p)
+	f.conflicts = append(f.conflicts, Conflict{p, action})
+}
+
+// Status returns the state of every file of the manifest sorted by path.
+func (f *KitFs) Status() []FileStatus {
+	m := f.manifest()
+	paths := []string{}
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	files := []FileStatus{}
+	for _, p := range paths {
+		st := FileStatus{Path: p, ManifestEntry: *m.Files[p], State: StateUnchanged}
+		if s, err := f.ReadFile(p); err != nil {
+			st.State = StateMissing
+		} else if hashOf(s) != st.Hash {
+			st.State = StateModified
+		}
+		files = append(files, st)
+	}
+	return files
+}
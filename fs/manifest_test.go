@@ -0,0 +1,108 @@
+package fs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+const generatedSrc = "// THIS FILE IS AUTO GENERATED BY GK-CLI DO NOT EDIT!!\npackage a\n\nfunc A() {}\n"
+
+func TestKitFs_WriteFileEditedByHand(t *testing.T) {
+	defer viper.Set("gk_force", false)
+	f := &KitFs{Fs: afero.NewMemMapFs()}
+	if err := f.WriteFile("a/a_gen.go", generatedSrc, true); err != nil {
+		t.Fatalf("KitFs.WriteFile() error = %v", err)
+	}
+	afero.WriteFile(f.Fs, "a/a_gen.go", []byte(generatedSrc+"// edit\n"), 0644)
+	if got := f.Status(); len(got) != 1 || got[0].State != StateModified || !got[0].Generated {
+		t.Errorf("KitFs.Status() = %v, want one modified generated file", got)
+	}
+	f.WriteFile("a/a_gen.go", generatedSrc, true)
+	if s, _ := f.ReadFile("a/a_gen.go"); s == generatedSrc {
+		t.Error("KitFs.WriteFile() overwrote a generated file edited by hand")
+	}
+	if c := f.Conflicts(); len(c) != 1 || c[0].Path != "a/a_gen.go" {
+		t.Errorf("KitFs.Conflicts() = %v, want a/a_gen.go", c)
+	}
+	viper.Set("gk_force", true)
+	f.WriteFile("a/a_gen.go", generatedSrc, true)
+	if s, _ := f.ReadFile("a/a_gen.go"); s != generatedSrc {
+		t.Error("KitFs.WriteFile() did not overwrite the generated file with --force")
+	}
+	if got := f.Status(); got[0].State != StateUnchanged {
+		t.Errorf("KitFs.Status() = %v, want unchanged", got)
+	}
+}
+
+func TestKitFs_Manifest(t *testing.T) {
+	f := &KitFs{Fs: afero.NewMemMapFs()}
+	afero.WriteFile(f.Fs, "a/a.go", []byte("package a\n\nfunc User() {}\n"), 0644)
+	f.WriteFile("a/a.go", "package a\n\nfunc User() {}\n\nfunc Kit() {}\n", true)
+	f.WriteFile("a/b.go", "package a\n", false)
+	f.Remove("a/b.go")
+	if err := f.SaveManifest(); err != nil {
+		t.Fatalf("KitFs.SaveManifest() error = %v", err)
+	}
+	f = &KitFs{Fs: f.Fs}
+	got := f.Status()
+	if len(got) != 1 || got[0].Path != "a/a.go" || got[0].Generated {
+		t.Fatalf("KitFs.Status() = %v, want the user-owned a/a.go", got)
+	}
+	if want := []string{"Kit"}; !reflect.DeepEqual(got[0].Declarations, want) {
+		t.Errorf("KitFs.Status() declarations = %v, want %v", got[0].Declarations, want)
+	}
+	f.WriteFile("a/a.go", "package a\n\nfunc User() {}\n\nfunc Kit() {}\n\nfunc Kit2() {}\n", true)
+	if want := []string{"Kit", "Kit2"}; !reflect.DeepEqual(f.Status()[0].Declarations, want) {
+		t.Errorf("KitFs.Status() declarations = %v, want %v", f.Status()[0].Declarations, want)
+	}
+	f.Fs.Remove("a/a.go")
+	if got := f.Status(); got[0].State != StateMissing {
+		t.Errorf("KitFs.Status() = %v, want missing", got)
+	}
+}
+
+func TestKitFs_RecordedDoc(t *testing.T) {
+	f := &KitFs{Fs: afero.NewMemMapFs()}
+	afero.WriteFile(f.Fs, "a/a.go", []byte("package a\n\n// User doc.\nfunc User() {}\n"), 0644)
+	f.WriteFile("a/a.go", "package a\n\n// User doc.\nfunc User() {}\n\n// Kit doc.\nfunc Kit() {}\n", true)
+	if doc, ok := f.RecordedDoc("a/a.go", "Kit"); !ok || doc != "Kit doc.\n" {
+		t.Errorf("KitFs.RecordedDoc() = %q, %v, want the doc of Kit", doc, ok)
+	}
+	if _, ok := f.RecordedDoc("a/a.go", "User"); ok {
+		t.Error("KitFs.RecordedDoc() recorded the doc of a declaration kit did not write")
+	}
+	// the doc edited by hand is not recorded again.
+	afero.WriteFile(f.Fs, "a/a.go", []byte("package a\n\n// User doc.\nfunc User() {}\n\n// Mine.\nfunc Kit() {}\n"), 0644)
+	f.WriteFile("a/a.go", "package a\n\n// User doc.\nfunc User() {}\n\n// Mine.\nfunc Kit() {}\n\nfunc Kit2() {}\n", true)
+	if doc, _ := f.RecordedDoc("a/a.go", "Kit"); doc != "Kit doc.\n" {
+		t.Errorf("KitFs.RecordedDoc() = %q, want the doc kit wrote", doc)
+	}
+}
+
+func TestKitFs_WriteFileAs(t *testing.T) {
+	f := &KitFs{Fs: afero.NewMemMapFs()}
+	afero.WriteFile(f.Fs, "a/a.go", []byte("package a\n\nfunc User() {}\n"), 0644)
+	// the file is not written but it is what the generator wants.
+	if err := f.WriteFileAs("generateA", "a/a.go", "package a\n\nfunc User() {}\n", false); err != nil {
+		t.Fatalf("KitFs.WriteFileAs() error = %v", err)
+	}
+	f.WriteFileAs("generateB", "a/b.go", "package a\n", true)
+	f.WriteFile("a/c.go", "package a\n", true)
+	if err := f.Move("a/b.go", "a/d.go"); err != nil {
+		t.Fatalf("KitFs.Move() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, st := range f.Status() {
+		got[st.Path] = st.Generator
+		if st.Path == "a/a.go" && (st.State != StateUnchanged || len(st.Declarations) != 0) {
+			t.Errorf("KitFs.Status() a/a.go = %v, want unchanged without declarations", st)
+		}
+	}
+	want := map[string]string{"a/a.go": "generateA", "a/c.go": DefaultGenerator, "a/d.go": "generateB"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KitFs.Status() generators = %v, want %v", got, want)
+	}
+}
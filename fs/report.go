@@ -0,0 +1,90 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+)
+
+// Change statuses reported after a run.
+const (
+	StatusCreated   = "created"
+	StatusModified  = "modified"
+	StatusDeleted   = "deleted"
+	StatusUnchanged = "unchanged"
+)
+
+// Change describes what a run did to a file.
+type Change struct {
+	Path   string
+	Status string
+	Old    string
+	New    string
+}
+
+// Changes returns the changes of every file written by the generators in
+// the order they were first written.
+func (f *KitFs) Changes() []Change {
+	changes := []Change{}
+	for _, p := range f.written {
+		st := f.original[p]
+		c := Change{Path: p, Old: st.data}
+		c.New, _ = f.ReadFile(p)
+		exists, _ := f.Exists(p)
+		switch {
+		case !st.exists && !exists:
+			continue
+		case !st.exists:
+			c.Status = StatusCreated
+		case !exists:
+			c.Status = StatusDeleted
+		case st.data != c.New:
+			c.Status = StatusModified
+		default:
+			c.Status = StatusUnchanged
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// DryRunReport writes a unified diff of every created, modified or deleted file and a
+// summary of the changes to `w`, it returns true if there are pending changes.
+func (f *KitFs) DryRunReport(w io.Writer) bool {
+	changes := f.Changes()
+	count := map[string]int{}
+	for _, c := range changes {
+		count[c.Status]++
+		switch c.Status {
+		case StatusCreated:
+			fmt.Fprint(w, UnifiedDiff("/dev/null", "b/"+c.Path, "", c.New))
+		case StatusModified:
+			fmt.Fprint(w, UnifiedDiff("a/"+c.Path, "b/"+c.Path, c.Old, c.New))
+		case StatusDeleted:
+			fmt.Fprint(w, UnifiedDiff("a/"+c.Path, "/dev/null", c.Old, ""))
+		}
+	}
+	fmt.Fprintf(
+		w,
+		"\n%d created, %d modified, %d deleted, %d unchanged\n",
+		count[StatusCreated],
+		count[StatusModified],
+		count[StatusDeleted],
+		count[StatusUnchanged],
+	)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %-10s %s\n", c.Status, c.Path)
+	}
+	return count[StatusCreated]+count[StatusModified]+count[StatusDeleted] > 0
+}
+
+// ConflictReport writes every file that hit a conflict and what was done
+// with it to `w`, it writes nothing if there were no conflicts.
+func (f *KitFs) ConflictReport(w io.Writer) {
+	if len(f.conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%d conflict(s):\n", len(f.conflicts))
+	for _, c := range f.conflicts {
+		fmt.Fprintf(w, "  %s: %s\n", c.Path, c.Action)
+	}
+}
@@ -0,0 +1,73 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// Begin starts a transaction, the files are written to memory until Commit is
+// called. It does nothing during a dry run because nothing is written anyway.
+func (f *KitFs) Begin() {
+	if f.base != nil || viper.GetBool("gk_dry_run") {
+		return
+	}
+	f.base, f.baseConflicts = f.Fs, len(f.conflicts)
+	f.Fs = afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(f.base), afero.NewMemMapFs())
+}
+
+// InTransaction returns true if the files are kept in memory until Commit.
+func (f *KitFs) InTransaction() bool {
+	return f.base != nil
+}
+
+// Commit writes the files of the transaction to disk.
+func (f *KitFs) Commit() error {
+	if f.base == nil {
+		return nil
+	}
+	overlay := f.Fs
+	f.Fs, f.base = f.base, nil
+	for _, d := range f.dirs {
+		if err := f.Fs.MkdirAll(d, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	removed := f.removed
+	f.removed, f.dirs = nil, nil
+	for _, p := range f.written {
+		if removed[p] {
+			if err := f.Fs.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		d, err := afero.ReadFile(overlay, p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = f.Fs.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			return err
+		}
+		if err = afero.WriteFile(f.Fs, p, d, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback drops the files of the transaction and its conflicts, the manifest
+// is read from disk again.
+func (f *KitFs) Rollback() {
+	if f.base == nil {
+		return
+	}
+	f.Fs, f.base = f.base, nil
+	f.conflicts = f.conflicts[:f.baseConflicts]
+	f.written, f.original, f.removed, f.dirs = nil, nil, nil, nil
+	f.mf, f.mfChanged = nil, false
+}
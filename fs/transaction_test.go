@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+func TestKitFs_Transaction(t *testing.T) {
+	viper.Set("gk_on_conflict", ConflictOverwrite)
+	defer viper.Set("gk_on_conflict", nil)
+	for _, commit := range []bool{true, false} {
+		base := afero.NewMemMapFs()
+		afero.WriteFile(base, "a.go", []byte("a"), 0644)
+		afero.WriteFile(base, "b.go", []byte("b"), 0644)
+		afero.WriteFile(base, "d.go", []byte("d"), 0644)
+		base.MkdirAll("e", 0755)
+		f := &KitFs{Fs: base, conflicts: []Conflict{{"e.go", "skipped"}}}
+		f.Begin()
+		f.WriteFile("a.go", "a2", true)
+		f.WriteFile("d.go", "d2", false)
+		if err := f.MkdirAll("e"); err != nil {
+			t.Fatalf("KitFs.MkdirAll() error = %v on an existing folder", err)
+		}
+		f.MkdirAll("c")
+		f.WriteFile("c/c.go", "c", false)
+		f.Remove("b.go")
+		if d, _ := afero.ReadFile(base, "a.go"); string(d) != "a" {
+			t.Fatalf("KitFs.WriteFile() wrote %q to disk during the transaction", d)
+		}
+		if s, _ := f.ReadFile("a.go"); s != "a2" {
+			t.Errorf("KitFs.ReadFile() = %v, want a2 during the transaction", s)
+		}
+		want := map[string]string{"a.go": "a", "b.go": "b", "c/c.go": "", "d.go": "d"}
+		conflicts := []Conflict{{"e.go", "skipped"}}
+		if commit {
+			if err := f.Commit(); err != nil {
+				t.Fatalf("KitFs.Commit() error = %v", err)
+			}
+			want = map[string]string{"a.go": "a2", "b.go": "", "c/c.go": "c", "d.go": "d2"}
+			conflicts = append(conflicts, Conflict{"d.go", "overwritten"})
+		} else {
+			f.Rollback()
+			if len(f.Status()) != 0 {
+				t.Errorf("KitFs.Rollback() kept the manifest entries %v", f.Status())
+			}
+		}
+		if !reflect.DeepEqual(f.Conflicts(), conflicts) {
+			t.Errorf("commit %v, KitFs.Conflicts() = %v, want %v", commit, f.Conflicts(), conflicts)
+		}
+		for p, s := range want {
+			d, err := afero.ReadFile(base, p)
+			if s == "" && err == nil || s != "" && string(d) != s {
+				t.Errorf("commit %v, `%s` = %q (%v), want %q", commit, p, d, err, s)
+			}
+		}
+	}
+}
@@ -7,12 +7,6 @@ import (
 
 	"bytes"
 
-	"os/exec"
-
-	"os"
-
-	"runtime"
-
 	"errors"
 
 	"github.com/Sirupsen/logrus"
@@ -57,11 +51,18 @@ func NewGenerateTransport(name string, gorillaMux bool, transport string, method
 	i.InitPg()
 	//
 	i.fs = fs.Get()
+	i.generator = "GenerateTransport"
 	return i
 }
 
 // Generate generates the transport.
-func (g *GenerateTransport) Generate() (err error) {
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (g *GenerateTransport) Generate() error {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateTransport) generate() (err error) {
 	for n, v := range SupportedTransports {
 		if v == g.transport {
 			break
@@ -90,6 +91,13 @@ func (g *GenerateTransport) Generate() (err error) {
 	}
 	switch g.transport {
 	case "http":
+		for _, m := range g.serviceInterface.Methods {
+			if k := streamKind(m); k != "" {
+				logrus.Warnf("The method `%s` is a %s streaming rpc, only the gRPC transport serves it.", m.Name, k)
+			}
+		}
+		g.serviceInterface.Methods = unaryMethods(g.serviceInterface.Methods)
+		mth = unaryMethods(mth)
 		tG := newGenerateHTTPTransport(g.name, g.gorillaMux, g.serviceInterface, g.methods)
 		err = tG.Generate()
 		if err != nil {
@@ -117,10 +125,8 @@ func (g *GenerateTransport) Generate() (err error) {
 			return err
 		}
 		logrus.Warn("===============================================================")
-		logrus.Warn("The GRPC implementation is not finished you need to update your")
+		logrus.Warn("The GRPC implementation is not finished you need to check your")
 		logrus.Warn(" service proto buffer and run the compile script.")
-		logrus.Warn("---------------------------------------------------------------")
-		logrus.Warn("You also need to implement the Encoders and Decoders!")
 		logrus.Warn("===============================================================")
 	default:
 		return errors.New("this transport type is not yet implemented")
@@ -130,7 +136,7 @@ func (g *GenerateTransport) Generate() (err error) {
 func (g *GenerateTransport) serviceFound() bool {
 	for n, v := range g.file.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.destPath)
 			return true
 		} else if n == len(g.file.Interfaces)-1 {
 			return false
@@ -149,6 +155,10 @@ func (g *GenerateTransport) removeBadMethods() {
 			logrus.Warnf("The method '%s' does not have any return value and will be ignored", v.Name)
 			continue
 		}
+		if p, ok := unsupportedParameter(v); ok {
+			logrus.Warnf("The method '%s' uses '%s %s' that can not be encoded by the transport and will be ignored", v.Name, p.Name, p.Type)
+			continue
+		}
 		for n, p := range v.Parameters {
 			if p.Type == "context.Context" {
 				keepMethods = append(keepMethods, v)
@@ -207,6 +217,7 @@ func newGenerateHTTPTransport(name string, gorillaMux bool, serviceInterface par
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateHTTPTransport"
 	return t
 }
 func (g *generateHTTPTransport) Generate() (err error) {
@@ -223,7 +234,7 @@ func (g *generateHTTPTransport) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("http")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -273,9 +284,7 @@ func (g *generateHTTPTransport) Generate() (err error) {
 			}
 		}
 		if !handlerFound {
-			g.code.appendMultilineComment([]string{
-				fmt.Sprintf("make%sHandler creates the handler logic", m.Name),
-			})
+			g.code.appendMultilineComment(withMethodDoc(makeHandlerDoc(m), m))
 			g.code.NewLine()
 			var st *jen.Statement
 			if g.gorillaMux {
@@ -516,7 +525,7 @@ func (g *generateHTTPTransport) Generate() (err error) {
 		}
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	src += "\n" + g.code.Raw().GoString()
@@ -539,7 +548,23 @@ func (g *generateHTTPTransport) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	if err = g.writeFile(g.filePath, s, true); err != nil {
+		return err
+	}
+	return g.syncDocs(g.filePath, g.docs())
+}
+
+// docs returns the doc comments of the handlers of the service methods.
+func (g *generateHTTPTransport) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(docs, declDoc{
+			key:  "make" + m.Name + "Handler",
+			doc:  withMethodDoc(makeHandlerDoc(m), m),
+			dflt: makeHandlerDoc(m),
+		})
+	}
+	return docs
 }
 
 type generateHTTPTransportBase struct {
@@ -575,6 +600,7 @@ func newGenerateHTTPTransportBase(name string, gorillaMux bool, serviceInterface
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateHTTPTransportBase"
 	return t
 }
 func (g *generateHTTPTransportBase) Generate() (err error) {
@@ -660,7 +686,7 @@ func (g *generateHTTPTransportBase) Generate() (err error) {
 	)
 	g.code.NewLine()
 
-	if err := g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true); err != nil {
+	if err := g.writeFile(g.filePath, g.srcFile.GoString(), true); err != nil {
 		return err
 	}
 	return g.generateCmdPathVar()
@@ -677,7 +703,7 @@ func (g *generateHTTPTransportBase) generateCmdPathVar() (err error) {
 	} else if !b {
 		generateFirstTime = true
 		f := jen.NewFile("http")
-		g.fs.WriteFile(g.httpPathFilePath, f.GoString(), false)
+		g.writeFile(g.httpPathFilePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.httpPathFilePath)
 	if err != nil {
@@ -705,7 +731,7 @@ func (g *generateHTTPTransportBase) generateCmdPathVar() (err error) {
 
 	if generateFirstTime {
 
-		return g.fs.WriteFile(g.httpPathFilePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.httpPathFilePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	// src += "\n" + g.code.Raw().GoString()
@@ -729,7 +755,7 @@ func (g *generateHTTPTransportBase) generateCmdPathVar() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.httpPathFilePath, s, true)
+	return g.writeFile(g.httpPathFilePath, s, true)
 }
 
 type generateGRPCTransportProto struct {
@@ -759,9 +785,14 @@ func newGenerateGRPCTransportProto(name string, serviceInterface parser.Interfac
 	)
 	t.compileFilePath = path.Join(t.destPath, viper.GetString("gk_grpc_compile_file_name"))
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransportProto"
 	return t
 }
 func (g *generateGRPCTransportProto) Generate() (err error) {
+	pc, err := loadCheckedProtocConfig()
+	if err != nil {
+		return err
+	}
 	g.CreateFolderStructure(g.destPath)
 	if b, err := g.fs.Exists(g.pbFilePath); err != nil {
 		return err
@@ -809,91 +840,40 @@ func (g *generateGRPCTransportProto) Generate() (err error) {
 		}
 		g.getServiceRPC(s)
 	}
-	g.generateRequestResponse()
+	if err = g.generateRequestResponse(); err != nil {
+		return err
+	}
+	// the new rpcs get their comments here too.
+	g.syncProtoDocs(g.getService())
+	pbImport, _ := utils.GetPbImportPath(g.name)
+	pc.applyOptions(g.protoSrc, pbImport)
+	if err = g.checkProtoChanges(g.pbFilePath, g.protoSrc); err != nil {
+		return err
+	}
 	buf := new(bytes.Buffer)
 	formatter := protofmt.NewFormatter(buf, " ")
 	formatter.Format(g.protoSrc)
-	err = g.fs.WriteFile(g.pbFilePath, buf.String(), true)
+	err = g.writeFile(g.pbFilePath, buf.String(), true)
 	if err != nil {
 		return err
 	}
-	if viper.GetString("gk_folder") != "" {
-		g.pbFilePath = path.Join(viper.GetString("gk_folder"), g.pbFilePath)
-	}
-	if !viper.GetBool("gk_testing") {
-		cmd := exec.Command("protoc", g.pbFilePath, "--go_out=plugins=grpc:.")
-		cmd.Stdout = os.Stdout
-		err = cmd.Run()
-		if err != nil {
-			return err
-		}
-	}
-	if b, e := g.fs.Exists(g.compileFilePath); e != nil {
-		return e
-	} else if b {
-		return
-	}
-
-	if runtime.GOOS == "windows" {
-		return g.fs.WriteFile(
-			g.compileFilePath,
-			fmt.Sprintf(`:: Install proto3.
-:: https://github.com/google/protobuf/releases
-:: Update protoc Go bindings via
-::  go get -u github.com/golang/protobuf/proto
-::  go get -u github.com/golang/protobuf/protoc-gen-go
-::
-:: See also
-::  https://github.com/grpc/grpc-go/tree/master/examples
+	return pc.build(g.pbFilePath, g.compileFilePath)
+}
 
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-			false,
-		)
+// syncProtoDocs updates the comments of the rpcs so they follow the docs of
+// the service methods, see BaseGenerator.syncProtoDoc.
+func (g *generateGRPCTransportProto) syncProtoDocs(svc *proto.Service) {
+	if svc == nil {
+		return
 	}
-	if runtime.GOOS == "darwin" {
-		return g.fs.WriteFile(
-			g.compileFilePath,
-			fmt.Sprintf(`#!/usr/bin/env sh
-
-# Install proto3 from source macOS only.
-#  brew install autoconf automake libtool
-#  git clone https://github.com/google/protobuf
-#  ./autogen.sh ; ./configure ; make ; make install
-#
-# Update protoc Go bindings via
-#  go get -u github.com/golang/protobuf/{proto,protoc-gen-go}
-#
-# See also
-#  https://github.com/grpc/grpc-go/tree/master/examples
-
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-			false,
-		)
+	for _, m := range g.serviceInterface.Methods {
+		for _, e := range svc.Elements {
+			if r, ok := e.(*proto.RPC); ok && r.Name == m.Name {
+				// protofmt can not print the empty lines of rpc comments.
+				r.Comment = g.syncProtoDoc(g.pbFilePath, declDoc{key: "rpc " + m.Name, doc: nonEmptyLines(methodDoc(m))}, r.Comment)
+			}
+		}
 	}
-	return g.fs.WriteFile(
-		g.compileFilePath,
-		fmt.Sprintf(`#!/usr/bin/env sh
-
-# Install proto3
-# sudo apt-get install -y git autoconf automake libtool curl make g++ unzip
-# git clone https://github.com/google/protobuf.git
-# cd protobuf/
-# ./autogen.sh
-# ./configure
-# make
-# make check
-# sudo make install
-# sudo ldconfig # refresh shared library cache.
-#
-# Update protoc Go bindings via
-#  go get -u github.com/golang/protobuf/{proto,protoc-gen-go}
-#
-# See also
-#  https://github.com/grpc/grpc-go/tree/master/examples
-
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-		false,
-	)
 }
 func (g *generateGRPCTransportProto) getService() *proto.Service {
 	for i, e := range g.protoSrc.Elements {
@@ -905,52 +885,84 @@ func (g *generateGRPCTransportProto) getService() *proto.Service {
 	}
 	return nil
 }
-func (g *generateGRPCTransportProto) generateRequestResponse() {
+
+// generateRequestResponse adds the request and the reply messages of the
+// methods with the fields of their parameters and results, the messages kit
+// left empty are filled and the others are kept as they are.
+func (g *generateGRPCTransportProto) generateRequestResponse() error {
+	pm, err := newPbMessages(&g.BaseGenerator, g.name, g.serviceInterface.Methods)
+	if err != nil {
+		return err
+	}
+	pm.useNested(protoMessageDefs("", g.protoSrc.Elements, map[string]protoMessageDef{}))
+	msgs := []*proto.Message{}
 	for _, v := range g.serviceInterface.Methods {
-		foundRequest := false
-		foundReply := false
+		msgs = append(
+			msgs,
+			protoMessage(v.Name+"Request", pm.requests[v.Name]),
+			protoMessage(v.Name+"Reply", pm.replies[v.Name]),
+		)
+	}
+	for _, msg := range append(msgs, pm.structMessages()...) {
+		found := false
 		for _, e := range g.protoSrc.Elements {
-			if r, ok := e.(*proto.Message); ok {
-				if r.Name == v.Name+"Request" {
-					foundRequest = true
-				}
-				if r.Name == v.Name+"Reply" {
-					foundReply = true
+			if r, ok := e.(*proto.Message); ok && r.Name == msg.Name {
+				found = true
+				if pm.ok {
+					mergeMessage(r, msg)
+					reportStaleMessage(r, msg, g.pbFilePath)
 				}
 			}
 		}
-		if !foundRequest {
-			g.protoSrc.Elements = append(g.protoSrc.Elements, &proto.Message{
-				Name: v.Name + "Request",
-			})
+		if !found {
+			g.protoSrc.Elements = append(g.protoSrc.Elements, msg)
 		}
-		if !foundReply {
-			g.protoSrc.Elements = append(g.protoSrc.Elements, &proto.Message{
-				Name: v.Name + "Reply",
-			})
+	}
+	for _, imp := range pm.protoImports() {
+		g.addImport(imp)
+	}
+	return nil
+}
+
+// addImport imports the proto file `filename` after the package declaration.
+func (g *generateGRPCTransportProto) addImport(filename string) {
+	at := 0
+	for i, e := range g.protoSrc.Elements {
+		switch r := e.(type) {
+		case *proto.Import:
+			if r.Filename == filename {
+				return
+			}
+			at = i + 1
+		case *proto.Syntax, *proto.Package:
+			if at <= i {
+				at = i + 1
+			}
 		}
 	}
+	elements := append([]proto.Visitee{}, g.protoSrc.Elements[:at]...)
+	elements = append(elements, &proto.Import{Filename: filename})
+	g.protoSrc.Elements = append(elements, g.protoSrc.Elements[at:]...)
 }
 func (g *generateGRPCTransportProto) getServiceRPC(svc *proto.Service) {
 	for _, v := range g.serviceInterface.Methods {
-		found := false
+		var rpc *proto.RPC
 		for _, e := range svc.Elements {
 			if r, ok := e.(*proto.RPC); ok {
 				if r.Name == v.Name {
-					found = true
+					rpc = r
 				}
 			}
 		}
-		if found {
-			continue
-		}
-		svc.Elements = append(svc.Elements,
-			&proto.RPC{
+		if rpc == nil {
+			rpc = &proto.RPC{
 				Name:        v.Name,
 				ReturnsType: v.Name + "Reply",
 				RequestType: v.Name + "Request",
-			},
-		)
+			}
+			svc.Elements = append(svc.Elements, rpc)
+		}
+		setRPCStreams(rpc, streamKind(v))
 	}
 }
 
@@ -981,6 +993,7 @@ func newGenerateGRPCTransportBase(name string, serviceInterface parser.Interface
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransportBase"
 	return t
 }
 func (g *generateGRPCTransportBase) Generate() (err error) {
@@ -1020,6 +1033,10 @@ func (g *generateGRPCTransportBase) Generate() (err error) {
 		}
 		for _, m := range g.allMethods {
 			n := utils.ToLowerFirstCamelCase(m.Name)
+			if streamKind(m) != "" {
+				g.addStreamEndpoint(m, vl, &fields)
+				continue
+			}
 			for _, v := range g.file.Methods {
 				if v.Name == "make"+m.Name+"Handler" {
 					vl[jen.Id(n)] = jen.Id("make"+m.Name+"Handler").Call(
@@ -1033,6 +1050,10 @@ func (g *generateGRPCTransportBase) Generate() (err error) {
 	} else {
 		for _, m := range g.serviceInterface.Methods {
 			n := utils.ToLowerFirstCamelCase(m.Name)
+			if streamKind(m) != "" {
+				g.addStreamEndpoint(m, vl, &fields)
+				continue
+			}
 			vl[jen.Id(n)] = jen.Id("make"+m.Name+"Handler").Call(
 				jen.Id("endpoints"),
 				jen.Id("options").Index(jen.Lit(m.Name)),
@@ -1056,7 +1077,19 @@ func (g *generateGRPCTransportBase) Generate() (err error) {
 		jen.Return(jen.Id("&grpcServer").Values(vl)),
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+}
+
+// addStreamEndpoint adds the endpoint of the streaming rpc of `m` to the
+// fields of the server, the streaming rpcs call the endpoint without a go-kit
+// handler.
+func (g *generateGRPCTransportBase) addStreamEndpoint(m parser.Method, vl jen.Dict, fields *[]jen.Code) {
+	if !streamServed(m) {
+		return
+	}
+	n := utils.ToLowerFirstCamelCase(m.Name)
+	vl[jen.Id(n)] = jen.Id("endpoints").Dot(m.Name + "Endpoint")
+	*fields = append(*fields, jen.Id(n).Qual("github.com/go-kit/kit/endpoint", "Endpoint"))
 }
 
 type generateGRPCTransport struct {
@@ -1083,6 +1116,7 @@ func newGenerateGRPCTransport(name string, serviceInterface parser.Interface, me
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransport"
 	return t
 }
 func (g *generateGRPCTransport) Generate() (err error) {
@@ -1103,7 +1137,7 @@ func (g *generateGRPCTransport) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("grpc")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1113,10 +1147,45 @@ func (g *generateGRPCTransport) Generate() (err error) {
 	if err != nil {
 		return err
 	}
+	pm, err := newPbMessages(&g.BaseGenerator, g.name, g.serviceInterface.Methods)
+	if err != nil {
+		return err
+	}
+	if err = pm.useProto(&g.BaseGenerator, g.name); err != nil {
+		return err
+	}
+	if pm.ok {
+		// the decoders and encoders older versions left to implement are
+		// generated again now that the messages have fields.
+		if stubs := stubDecls(g.file); len(stubs) > 0 {
+			if src, err = removeDecls(src, stubs); err != nil {
+				return err
+			}
+			if g.file, err = parser.NewFileParser().Parse([]byte(src)); err != nil {
+				return err
+			}
+		}
+		if err = writeConverters(g.fs, g.generator, g.destPath, pm); err != nil {
+			return err
+		}
+		if g.stale = g.staleConverters(pm); len(g.stale) > 0 {
+			if src, err = g.removeStaleDecls(src); err != nil {
+				return err
+			}
+			if g.file, err = parser.NewFileParser().Parse([]byte(src)); err != nil {
+				return err
+			}
+		}
+	}
 	for _, m := range g.serviceInterface.Methods {
+		stream := streamKind(m) != ""
+		if stream && !streamServed(m) {
+			logrus.Warnf("The method `%s` is a %s streaming rpc without the matching channels, its gRPC handler has to be implemented manually.", m.Name, streamKind(m))
+			continue
+		}
 		decoderFound := false
 		encoderFound := false
-		handlerFound := false
+		handlerFound := stream
 		funcFound := false
 		for _, v := range g.file.Methods {
 			if v.Name == fmt.Sprintf("decode%sRequest", m.Name) {
@@ -1133,9 +1202,7 @@ func (g *generateGRPCTransport) Generate() (err error) {
 			}
 		}
 		if !handlerFound {
-			g.code.appendMultilineComment([]string{
-				fmt.Sprintf("make%sHandler creates the handler logic", m.Name),
-			})
+			g.code.appendMultilineComment(withMethodDoc(makeHandlerDoc(m), m))
 			g.code.NewLine()
 			g.code.appendFunction(
 				fmt.Sprintf("make%sHandler", m.Name),
@@ -1165,11 +1232,22 @@ func (g *generateGRPCTransport) Generate() (err error) {
 		}
 
 		if !decoderFound {
-			g.code.appendMultilineComment([]string{
-				fmt.Sprintf("decode%sResponse is a transport/grpc.DecodeRequestFunc that converts a", m.Name),
-				"gRPC request to a user-domain sum request.",
-				"TODO implement the decoder",
-			})
+			doc := []string{
+				fmt.Sprintf("decode%sRequest is a transport/grpc.DecodeRequestFunc that converts a", m.Name),
+				"gRPC request to a user-domain request.",
+			}
+			body := pm.decodeRequest(m.Name)
+			if !pm.ok {
+				doc = append(doc, "TODO implement the decoder")
+				body = []jen.Code{
+					jen.Return(
+						jen.Nil(), jen.Qual("errors", "New").Call(
+							jen.Lit(fmt.Sprintf("'%s' Decoder is not impelemented", utils.ToCamelCase(g.name))),
+						),
+					),
+				}
+			}
+			g.code.appendMultilineComment(doc)
 			g.code.NewLine()
 			g.code.appendFunction(
 				fmt.Sprintf("decode%sRequest", m.Name),
@@ -1183,20 +1261,27 @@ func (g *generateGRPCTransport) Generate() (err error) {
 					jen.Error(),
 				},
 				"",
-				jen.Return(
-					jen.Nil(), jen.Qual("errors", "New").Call(
-						jen.Lit(fmt.Sprintf("'%s' Decoder is not impelemented", utils.ToCamelCase(g.name))),
-					),
-				),
+				body...,
 			)
 			g.code.NewLine()
 		}
 		if !encoderFound {
-			g.code.appendMultilineComment([]string{
+			doc := []string{
 				fmt.Sprintf("encode%sResponse is a transport/grpc.EncodeResponseFunc that converts", m.Name),
 				"a user-domain response to a gRPC reply.",
-				"TODO implement the encoder",
-			})
+			}
+			body := pm.encodeResponse(m.Name)
+			if !pm.ok {
+				doc = append(doc, "TODO implement the encoder")
+				body = []jen.Code{
+					jen.Return(
+						jen.Nil(), jen.Qual("errors", "New").Call(
+							jen.Lit(fmt.Sprintf("'%s' Encoder is not impelemented", utils.ToCamelCase(g.name))),
+						),
+					),
+				}
+			}
+			g.code.appendMultilineComment(doc)
 			g.code.NewLine()
 			g.code.appendFunction(
 				fmt.Sprintf("encode%sResponse", m.Name),
@@ -1210,15 +1295,26 @@ func (g *generateGRPCTransport) Generate() (err error) {
 					jen.Error(),
 				},
 				"",
-				jen.Return(
-					jen.Nil(), jen.Qual("errors", "New").Call(
-						jen.Lit(fmt.Sprintf("'%s' Encoder is not impelemented", utils.ToCamelCase(g.name))),
-					),
-				),
+				body...,
 			)
 			g.code.NewLine()
 		}
-		if !funcFound {
+		if !funcFound && stream {
+			stp := g.GenerateNameBySample("grpcServer", append(m.Parameters, m.Results...))
+			body := pm.streamServer(m, stp)
+			if _, _, ok := pm.streams(m); !ok {
+				body = streamServerStub(utils.ToCamelCase(g.name))
+			}
+			g.code.appendFunction(
+				m.Name,
+				jen.Id(stp).Id("*grpcServer"),
+				pm.streamServerParams(utils.ToCamelCase(g.name), m),
+				[]jen.Code{jen.Error()},
+				"",
+				body...,
+			)
+			g.code.NewLine()
+		} else if !funcFound {
 			stp := g.GenerateNameBySample("grpcServer", append(m.Parameters, m.Results...))
 			n := utils.ToCamelCase(m.Name)
 			g.code.appendFunction(
@@ -1255,7 +1351,7 @@ func (g *generateGRPCTransport) Generate() (err error) {
 		}
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	src += "\n" + g.code.Raw().GoString()
@@ -1278,5 +1374,64 @@ func (g *generateGRPCTransport) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	if err = g.writeFile(g.filePath, s, true); err != nil {
+		return err
+	}
+	return g.syncDocs(g.filePath, g.docs())
+}
+
+// docs returns the doc comments of the handlers of the service methods.
+func (g *generateGRPCTransport) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(docs, declDoc{
+			key:  "make" + m.Name + "Handler",
+			doc:  withMethodDoc(makeHandlerDoc(m), m),
+			dflt: makeHandlerDoc(m),
+		})
+	}
+	return docs
+}
+
+// staleConverters returns the decoders and the encoders of the file that do
+// not convert the parameters and the results of their method anymore, the
+// ones that were edited are reported instead.
+func (g *generateGRPCTransport) staleConverters(pm *pbMessages) []string {
+	stale := []string{}
+	for _, m := range g.serviceInterface.Methods {
+		if streamKind(m) != "" {
+			continue
+		}
+		bodies := map[string][]jen.Code{
+			fmt.Sprintf("decode%sRequest", m.Name):  pm.decodeRequest(m.Name),
+			fmt.Sprintf("encode%sResponse", m.Name): pm.encodeResponse(m.Name),
+		}
+		for _, v := range g.file.Methods {
+			body, ok := bodies[v.Name]
+			if !ok || v.Struct.Type != "" || v.Body == funcBody(g.destPath, body) {
+				continue
+			}
+			if generatedConverter(v.Body) {
+				stale = append(stale, v.Name)
+			} else {
+				reportSignatureChange(m.Name, v.Name, g.filePath)
+			}
+		}
+	}
+	return stale
+}
+
+// stubDecls returns the decoders and the encoders of the file that were
+// generated without an implementation.
+func stubDecls(f *parser.File) []string {
+	stubs := []string{}
+	for _, v := range f.Methods {
+		if strings.Contains(v.Body, "Decoder is not impelemented") || strings.Contains(v.Body, "Encoder is not impelemented") {
+			stubs = append(stubs, v.Name)
+		}
+		if strings.Contains(v.Body, "Stream is not impelemented") && v.Struct.Type == "*grpcServer" {
+			stubs = append(stubs, "grpcServer."+v.Name)
+		}
+	}
+	return stubs
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/emicklei/proto"
 	"github.com/hms58/genkit/fs"
 	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
 )
 
 func TestNewGenerateTransport(t *testing.T) {
@@ -37,6 +38,7 @@ func TestNewGenerateTransport(t *testing.T) {
 					b.srcFile = jen.NewFilePath("")
 					b.InitPg()
 					b.fs = fs.Get()
+					b.generator = "GenerateTransport"
 					return b
 				}(),
 				name:          "test",
@@ -61,6 +63,7 @@ func TestNewGenerateTransport(t *testing.T) {
 					b.srcFile = jen.NewFilePath("")
 					b.InitPg()
 					b.fs = fs.Get()
+					b.generator = "GenerateTransport"
 					return b
 				}(),
 				name:          "t es_t",
@@ -488,6 +491,7 @@ func TestGenerateTransport_removeBadMethods(t *testing.T) {
 					Bar(a int)(r string, err error)
 					foobar(a int)(r string, err error)
 					BarFoo(ctx context.Context, a int)
+					Watch(ctx context.Context, a int)(c <-chan chan string, err error)
 					} `, true)
 					b.fs = f
 					return b
@@ -1142,3 +1146,101 @@ func Test_generateGRPCTransport_Generate(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateGRPCTransport_staleConverters(t *testing.T) {
+	pm := newTestPbMessages(t)
+	src := `package grpc
+
+func decodeListRequest(_ context.Context, r interface{}) (interface{}, error) {` + funcBody("pkg/grpc", pm.decodeRequest("List")) + `}
+
+// encodeListResponse was generated before the time was a result.
+func encodeListResponse(_ context.Context, r interface{}) (interface{}, error) {
+	v := r.(endpoint.ListResponse)
+	return &pb.ListReply{Users: toPbUsers(v.Users)}, nil
+}
+
+func decodeSaveRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.SaveRequest)
+	return endpoint.SaveRequest{U: fromPbUser(req.U)}, validate(req)
+}
+`
+	file, err := parser.NewFileParser().Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &generateGRPCTransport{
+		destPath:         "pkg/grpc",
+		file:             file,
+		serviceInterface: parser.Interface{Methods: []parser.Method{{Name: "Save"}, {Name: "List"}, {Name: "Ping"}}},
+	}
+	// the decoder edited by hand is reported, not generated again.
+	if got := g.staleConverters(pm); !reflect.DeepEqual(got, []string{"encodeListResponse"}) {
+		t.Errorf("generateGRPCTransport.staleConverters() = %v, want [encodeListResponse]", got)
+	}
+}
+
+func Test_generateHTTPTransport_docs(t *testing.T) {
+	src := `package http
+
+// makeFooHandler creates the handler logic
+func makeFooHandler(m *http.ServeMux, endpoints endpoint.Endpoints, options []http1.ServerOption) {}
+
+// makeBarHandler serves bar.
+func makeBarHandler(m *http.ServeMux, endpoints endpoint.Endpoints, options []http1.ServerOption) {}
+`
+	g := &generateHTTPTransport{
+		BaseGenerator: BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}},
+		serviceInterface: parser.Interface{Methods: []parser.Method{
+			{Name: "Foo", Comment: "Foo does foo.\n"},
+			{Name: "Bar", Comment: "Bar does bar.\n"},
+		}},
+	}
+	afero.WriteFile(g.fs.Fs, "pkg/http/handler.go", []byte(src), 0644)
+	if err := g.syncDocs("pkg/http/handler.go", g.docs()); err != nil {
+		t.Fatalf("generateHTTPTransport.syncDocs() error = %v", err)
+	}
+	want := `package http
+
+// makeFooHandler creates the handler logic
+//
+// Foo does foo.
+func makeFooHandler(m *http.ServeMux, endpoints endpoint.Endpoints, options []http1.ServerOption) {}
+
+// makeBarHandler serves bar.
+func makeBarHandler(m *http.ServeMux, endpoints endpoint.Endpoints, options []http1.ServerOption) {}
+`
+	if got, _ := g.fs.ReadFile("pkg/http/handler.go"); got != want {
+		t.Errorf("generateHTTPTransport.syncDocs() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_generateGRPCTransportProto_syncProtoDocs(t *testing.T) {
+	svc := &proto.Service{Name: "Users", Elements: []proto.Visitee{
+		&proto.RPC{Name: "Foo"},
+		&proto.RPC{Name: "Bar", Comment: &proto.Comment{Lines: []string{" Bar was documented by hand."}}},
+		&proto.RPC{Name: "Baz", Comment: &proto.Comment{Lines: []string{" Baz does baz."}}},
+	}}
+	g := &generateGRPCTransportProto{
+		BaseGenerator: BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}},
+		pbFilePath:    "pkg/grpc/pb/users.proto",
+		serviceInterface: parser.Interface{Methods: []parser.Method{
+			{Name: "Foo", Comment: "Foo does foo.\n\nkit:http GET /foo\n"},
+			{Name: "Bar", Comment: "Bar does bar.\n"},
+			{Name: "Baz"},
+		}},
+	}
+	g.syncProtoDocs(svc)
+	want := map[string]string{
+		"Foo": "Foo does foo.\n",
+		"Bar": "Bar was documented by hand.\n",
+		// the rpc has no doc since the method has none, the comment is not
+		// the default one so it is kept.
+		"Baz": "Baz does baz.\n",
+	}
+	for _, e := range svc.Elements {
+		r := e.(*proto.RPC)
+		if got := parser.ProtoCommentText(r.Comment); got != want[r.Name] {
+			t.Errorf("generateGRPCTransportProto.syncProtoDocs() %s = %q, want %q", r.Name, got, want[r.Name])
+		}
+	}
+}
@@ -7,11 +7,7 @@ import (
 
 	"bytes"
 
-	"os/exec"
-
-	"os"
-
-	"runtime"
+	"strconv"
 
 	"errors"
 
@@ -55,11 +51,18 @@ func NewGenerateTransportDgd(name string, gorillaMux bool, transport string, met
 	i.InitPg()
 	//
 	i.fs = fs.Get()
+	i.generator = "GenerateTransportDgd"
 	return i
 }
 
 // Generate generates the transport.
-func (g *GenerateTransportDgd) Generate() (err error) {
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (g *GenerateTransportDgd) Generate() error {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateTransportDgd) generate() (err error) {
 	for n, v := range SupportedTransports {
 		if v == g.transport {
 			break
@@ -94,12 +97,14 @@ func (g *GenerateTransportDgd) Generate() (err error) {
 
 	switch g.transport {
 	case "http":
-		tG := newGenerateHTTPTransportDgd(g.name, g.gorillaMux, g.serviceInterface, g.methods)
+		svc := g.serviceInterface
+		svc.Methods = skipMethods(svc.Methods, parser.SkipHTTP)
+		tG := newGenerateHTTPTransportDgd(g.name, g.gorillaMux, svc, g.methods)
 		err = tG.Generate()
 		if err != nil {
 			return err
 		}
-		tbG := newGenerateHTTPTransportBaseDgd(g.name, g.gorillaMux, g.serviceInterface, g.methods, mth)
+		tbG := newGenerateHTTPTransportBaseDgd(g.name, g.gorillaMux, svc, g.methods, skipMethods(mth, parser.SkipHTTP))
 		err = tbG.Generate()
 		if err != nil {
 			return err
@@ -110,16 +115,23 @@ func (g *GenerateTransportDgd) Generate() (err error) {
 		// if err != nil {
 		// 	return err
 		// }
-		gt := newGenerateGRPCTransportDgd(g.name, g.serviceInterface, g.methods)
+		svc := g.serviceInterface
+		svc.Methods = skipMethods(svc.Methods, parser.SkipGRPC)
+		gt := newGenerateGRPCTransportDgd(g.name, svc, g.methods)
 		err = gt.Generate()
 		if err != nil {
 			return err
 		}
-		gb := newGenerateGRPCTransportBaseDgd(g.name, g.serviceInterface, g.methods, mth)
+		gb := newGenerateGRPCTransportBaseDgd(g.name, svc, g.methods, skipMethods(mth, parser.SkipGRPC))
 		err = gb.Generate()
 		if err != nil {
 			return err
 		}
+		gw := newGenerateGRPCGatewayDgd(g.name, skipMethods(skipMethods(mth, parser.SkipGRPC), parser.SkipHTTP))
+		err = gw.Generate()
+		if err != nil {
+			return err
+		}
 		logrus.Warn("===============================================================")
 		logrus.Warn("The GRPC implementation is not finished you need to update your")
 		logrus.Warn(" service proto buffer and run the compile script.")
@@ -134,7 +146,7 @@ func (g *GenerateTransportDgd) Generate() (err error) {
 func (g *GenerateTransportDgd) serviceFound() bool {
 	for n, v := range g.file.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.destPath)
 			return true
 		} else if n == len(g.file.Interfaces)-1 {
 			return false
@@ -190,6 +202,7 @@ func newGenerateHTTPTransportDgd(name string, gorillaMux bool, serviceInterface
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateHTTPTransportDgd"
 	return t
 }
 func (g *generateHTTPTransportDgd) Generate() (err error) {
@@ -220,7 +233,7 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("http")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -238,6 +251,7 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 		decoderFound := false
 		encoderFound := false
 		handlerFound := false
+		handlerBody := ""
 		for _, v := range g.file.Methods {
 			if v.Name == "ErrorEncoder" {
 				errorEncoderFound = true
@@ -250,6 +264,7 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 			}
 			if v.Name == fmt.Sprintf("make%sHandler", m.Name) {
 				handlerFound = true
+				handlerBody = v.Body
 			}
 		}
 
@@ -284,24 +299,29 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 			errorEncoderFound = true
 		}
 
+		if handlerFound && m.Directives.HTTPMethod != "" && !strings.Contains(handlerBody, `"`+m.Directives.HTTPMethod) {
+			logrus.Infof("The handler of `%s` exists, its route is not updated to the method `%s` of the `kit:http` directive.", m.Name, m.Directives.HTTPMethod)
+		}
 		if !handlerFound {
-			g.code.appendMultilineComment([]string{
-				fmt.Sprintf("make%sHandler creates the handler logic", m.Name),
-			})
+			g.code.appendMultilineComment(withMethodDoc(makeHandlerDoc(m), m))
 			g.code.NewLine()
 
 			routerPath := fmt.Sprintf(dgd_router_map_pattern_format, utils.ToUpperFirstCamelCase(m.Name))
 
+			verb := "POST"
+			if m.Directives.HTTPMethod != "" {
+				verb = m.Directives.HTTPMethod
+			}
 			var st *jen.Statement
 			if g.gorillaMux {
 				st = jen.Id("m").Dot("Methods").Call(
-					jen.Lit("POST"),
+					jen.Lit(verb),
 				).Dot("Path").Call(
 					jen.Qual(confPath, routerPath),
 				).Dot("Handler").Call(
 					jen.Qual("github.com/gorilla/handlers", "CORS").Call(
 						jen.Qual("github.com/gorilla/handlers", "AllowedMethods").Call(
-							jen.Index().String().Values(jen.Lit("POST")),
+							jen.Index().String().Values(jen.Lit(verb)),
 						),
 						jen.Qual("github.com/gorilla/handlers", "AllowedOrigins").Call(
 							jen.Index().String().Values(jen.Lit("*")),
@@ -316,8 +336,13 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 					),
 				)
 			} else {
+				pattern := jen.Qual(confPath, routerPath)
+				if m.Directives.HTTPMethod != "" {
+					// e.x `GET /users/{id}`, the method patterns need go 1.22.
+					pattern = jen.Lit(verb + " ").Op("+").Qual(confPath, routerPath)
+				}
 				st = jen.Id("m").Dot("Handle").Call(
-					jen.Qual(confPath, routerPath),
+					pattern,
 					jen.Qual("github.com/go-kit/kit/transport/http", "NewServer").Call(
 						jen.Id(fmt.Sprintf("endpoints.%sEndpoint", m.Name)),
 						jen.Id(fmt.Sprintf("decode%sRequest", m.Name)),
@@ -405,7 +430,7 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 	}
 
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	src += "\n" + g.code.Raw().GoString()
@@ -428,7 +453,27 @@ func (g *generateHTTPTransportDgd) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	if err = g.writeFile(g.filePath, s, true); err != nil {
+		return err
+	}
+	return g.syncDocs(g.filePath, g.docs())
+}
+
+func makeHandlerDoc(m parser.Method) []string {
+	return []string{fmt.Sprintf("make%sHandler creates the handler logic", m.Name)}
+}
+
+// docs returns the doc comments of the handlers of the service methods.
+func (g *generateHTTPTransportDgd) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(docs, declDoc{
+			key:  "make" + m.Name + "Handler",
+			doc:  withMethodDoc(makeHandlerDoc(m), m),
+			dflt: makeHandlerDoc(m),
+		})
+	}
+	return docs
 }
 
 type generateHTTPTransportBaseDgd struct {
@@ -466,6 +511,7 @@ func newGenerateHTTPTransportBaseDgd(name string, gorillaMux bool, serviceInterf
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateHTTPTransportBaseDgd"
 	return t
 }
 func (g *generateHTTPTransportBaseDgd) Generate() (err error) {
@@ -555,7 +601,7 @@ func (g *generateHTTPTransportBaseDgd) Generate() (err error) {
 	)
 	g.code.NewLine()
 
-	if err := g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true); err != nil {
+	if err := g.writeFile(g.filePath, g.srcFile.GoString(), true); err != nil {
 		return err
 	}
 	return g.generateCmdPathVar()
@@ -574,7 +620,7 @@ func (g *generateHTTPTransportBaseDgd) generateCmdPathVar() (err error) {
 		service := path.Base(g.routerConfPath)
 		f := jen.NewFile(service)
 		// f := jen.NewFile("conf")
-		g.fs.WriteFile(g.httpPathFilePath, f.GoString(), false)
+		g.writeFile(g.httpPathFilePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.httpPathFilePath)
 	if err != nil {
@@ -585,24 +631,41 @@ func (g *generateHTTPTransportBaseDgd) generateCmdPathVar() (err error) {
 	if err != nil {
 		return err
 	}
+	routes := map[string]string{}
+	for _, m := range g.allMethods {
+		routes[m.Name] = m.Directives.HTTPPath
+	}
 	for _, path := range g.cmdPaths {
 		exitingReqPathVar := false
 		reqPathVar := fmt.Sprintf(dgd_router_map_pattern_format, utils.ToUpperFirstCamelCase(path))
 		for _, m := range g.file.Vars {
 			if m.Name == reqPathVar {
 				exitingReqPathVar = true
+				if route := routes[path]; route != "" && m.Value != strconv.Quote(route) {
+					// the path of the `kit:http` directive changed.
+					src = strings.Replace(
+						src,
+						fmt.Sprintf("%s = %s", reqPathVar, m.Value),
+						fmt.Sprintf("%s = %s", reqPathVar, strconv.Quote(route)),
+						1,
+					)
+				}
 				break
 			}
 		}
 		if !exitingReqPathVar {
-			g.code.Raw().Var().Id(reqPathVar).Op("=").Lit("/" + strings.Replace(utils.ToLowerSnakeCase(path), "_", "-", -1))
+			route := routes[path]
+			if route == "" {
+				route = "/" + strings.Replace(utils.ToLowerSnakeCase(path), "_", "-", -1)
+			}
+			g.code.Raw().Var().Id(reqPathVar).Op("=").Lit(route)
 			g.code.NewLine()
 		}
 	}
 
 	if generateFirstTime {
 
-		return g.fs.WriteFile(g.httpPathFilePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.httpPathFilePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	// src += "\n" + g.code.Raw().GoString()
@@ -626,7 +689,7 @@ func (g *generateHTTPTransportBaseDgd) generateCmdPathVar() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.httpPathFilePath, s, true)
+	return g.writeFile(g.httpPathFilePath, s, true)
 }
 
 type generateGRPCTransportProtoDgd struct {
@@ -663,11 +726,19 @@ func NewGenerateGRPCTransportProtoDgd(name string, serviceInterface parser.Inter
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransportProtoDgd"
 	return t
 }
 
 func (g *generateGRPCTransportProtoDgd) Generate() (err error) {
 	// g.generateRequestResponse_Go()
+	if err = unaryRPCs(skipMethods(g.serviceInterface.Methods, parser.SkipGRPC)); err != nil {
+		return err
+	}
+	pc, err := loadCheckedProtocConfig()
+	if err != nil {
+		return err
+	}
 	g.CreateFolderStructure(g.destPath)
 	if b, err := g.fs.Exists(g.pbFilePath); err != nil {
 		return err
@@ -716,91 +787,48 @@ func (g *generateGRPCTransportProtoDgd) Generate() (err error) {
 		g.getServiceRPC(s)
 	}
 	g.generateRequestResponse()
+	// the new rpcs and messages get their comments here too.
+	g.syncProtoDocs(g.getService())
+	pbImport, _ := utils.GetPbImportPath(g.name)
+	pc.applyOptions(g.protoSrc, pbImport)
+	if err = g.checkProtoChanges(g.pbFilePath, g.protoSrc); err != nil {
+		return err
+	}
 	buf := new(bytes.Buffer)
 	formatter := protofmt.NewFormatter(buf, "    ")
 	formatter.Format(g.protoSrc)
-	err = g.fs.WriteFile(g.pbFilePath, buf.String(), true)
+	err = g.writeFile(g.pbFilePath, buf.String(), true)
 	if err != nil {
 		return err
 	}
-	if viper.GetString("gk_folder") != "" {
-		g.pbFilePath = path.Join(viper.GetString("gk_folder"), g.pbFilePath)
-	}
-	if !viper.GetBool("gk_testing") {
-		cmd := exec.Command("protoc", g.pbFilePath, "--go_out=plugins=grpc:.")
-		cmd.Stdout = os.Stdout
-		err = cmd.Run()
-		if err != nil {
-			return err
-		}
-	}
-	if b, e := g.fs.Exists(g.compileFilePath); e != nil {
-		return e
-	} else if b {
-		return
-	}
+	return pc.build(g.pbFilePath, g.compileFilePath)
+}
 
-	if runtime.GOOS == "windows" {
-		return g.fs.WriteFile(
-			g.compileFilePath,
-			fmt.Sprintf(`:: Install proto3.
-:: https://github.com/google/protobuf/releases
-:: Update protoc Go bindings via
-::  go get -u github.com/golang/protobuf/proto
-::  go get -u github.com/golang/protobuf/protoc-gen-go
-::
-:: See also
-::  https://github.com/grpc/grpc-go/tree/master/examples
-
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-			false,
-		)
-	}
-	if runtime.GOOS == "darwin" {
-		return g.fs.WriteFile(
-			g.compileFilePath,
-			fmt.Sprintf(`#!/usr/bin/env sh
-
-# Install proto3 from source macOS only.
-#  brew install autoconf automake libtool
-#  git clone https://github.com/google/protobuf
-#  ./autogen.sh ; ./configure ; make ; make install
-#
-# Update protoc Go bindings via
-#  go get -u github.com/golang/protobuf/{proto,protoc-gen-go}
-#
-# See also
-#  https://github.com/grpc/grpc-go/tree/master/examples
-
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-			false,
-		)
+// syncProtoDocs updates the comments of the rpcs and of the request messages so
+// they follow the docs of the service methods, see BaseGenerator.syncProtoDoc.
+func (g *generateGRPCTransportProtoDgd) syncProtoDocs(svc *proto.Service) {
+	for _, m := range g.serviceInterface.Methods {
+		if svc != nil {
+			for _, e := range svc.Elements {
+				if r, ok := e.(*proto.RPC); ok && r.Name == m.Name {
+					// protofmt can not print the empty lines of rpc comments.
+					r.Comment = g.syncProtoDoc(g.pbFilePath, declDoc{key: "rpc " + m.Name, doc: nonEmptyLines(methodDoc(m))}, r.Comment)
+				}
+			}
+		}
+		reqDataName := fmt.Sprintf(dgd_req_data_proto_format, m.Name)
+		for _, e := range g.protoSrc.Elements {
+			if r, ok := e.(*proto.Message); ok && r.Name == reqDataName {
+				r.Comment = g.syncProtoDoc(g.pbFilePath, declDoc{
+					key:  "message " + reqDataName,
+					doc:  withMethodDoc([]string{m.Name}, m),
+					dflt: []string{m.Name},
+				}, r.Comment)
+			}
+		}
 	}
-	return g.fs.WriteFile(
-		g.compileFilePath,
-		fmt.Sprintf(`#!/usr/bin/env sh
-
-# Install proto3
-# sudo apt-get install -y git autoconf automake libtool curl make g++ unzip
-# git clone https://github.com/google/protobuf.git
-# cd protobuf/
-# ./autogen.sh
-# ./configure
-# make
-# make check
-# sudo make install
-# sudo ldconfig # refresh shared library cache.
-#
-# Update protoc Go bindings via
-#  go get -u github.com/golang/protobuf/{proto,protoc-gen-go}
-#
-# See also
-#  https://github.com/grpc/grpc-go/tree/master/examples
-
-protoc %s.proto --go_out=plugins=grpc:.`, g.name),
-		false,
-	)
 }
+
 func (g *generateGRPCTransportProtoDgd) getService() *proto.Service {
 	for i, e := range g.protoSrc.Elements {
 		if r, ok := e.(*proto.Service); ok {
@@ -917,7 +945,7 @@ func (g *generateGRPCTransportProtoDgd) generateRequestResponse_Go() (err error)
 	} else if !b {
 		g.generateGoFirstTime = true
 		f := jen.NewFile("pb")
-		g.fs.WriteFile(g.goFilePath, f.GoString(), false)
+		g.writeFile(g.goFilePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.goFilePath)
 	if err != nil {
@@ -980,7 +1008,7 @@ func (g *generateGRPCTransportProtoDgd) generateRequestResponse_Go() (err error)
 	}
 
 	if g.generateGoFirstTime {
-		return g.fs.WriteFile(g.goFilePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.goFilePath, g.srcFile.GoString(), true)
 	}
 	src += "\n" + g.code.Raw().GoString()
 	tmpSrc := g.srcFile.GoString()
@@ -1003,32 +1031,74 @@ func (g *generateGRPCTransportProtoDgd) generateRequestResponse_Go() (err error)
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.goFilePath, s, true)
+	return g.writeFile(g.goFilePath, s, true)
 }
 
 func (g *generateGRPCTransportProtoDgd) getServiceRPC(svc *proto.Service) {
 	for _, v := range g.serviceInterface.Methods {
-		found := false
-		for _, e := range svc.Elements {
+		var rpc *proto.RPC
+		for i, e := range svc.Elements {
 			if r, ok := e.(*proto.RPC); ok {
 				if r.Name == v.Name {
-					found = true
+					rpc = r
+					if v.Directives.Skips(parser.SkipGRPC) {
+						logrus.Infof("The method `%s` skips the gRPC transport, its rpc is removed.", v.Name)
+						svc.Elements = append(svc.Elements[:i], svc.Elements[i+1:]...)
+					}
+					break
 				}
 			}
 		}
-		if found {
+		if v.Directives.Skips(parser.SkipGRPC) {
 			continue
 		}
-		reqDataName := fmt.Sprintf(dgd_req_data_proto_format, v.Name)
-		rspDataName := fmt.Sprintf(dgd_rsp_data_proto_format, v.Name)
-		svc.Elements = append(svc.Elements,
-			&proto.RPC{
+		if rpc == nil {
+			reqDataName := fmt.Sprintf(dgd_req_data_proto_format, v.Name)
+			rspDataName := fmt.Sprintf(dgd_rsp_data_proto_format, v.Name)
+			rpc = &proto.RPC{
 				Name:        v.Name,
 				ReturnsType: rspDataName,
 				RequestType: reqDataName,
-			},
-		)
+			}
+			svc.Elements = append(svc.Elements, rpc)
+		}
+		g.applyRPCDirectives(rpc, v.Directives)
+	}
+}
+
+// unaryRPCs returns an error if the `kit:grpc-stream` directive makes the rpc
+// of one of the methods a streaming rpc, the dgd gRPC transport only serves
+// unary rpcs.
+func unaryRPCs(methods []parser.Method) error {
+	for _, m := range methods {
+		if m.Directives.GRPCStream != "" {
+			return fmt.Errorf(
+				"the method `%s` is a %s streaming rpc but the dgd gRPC transport only serves unary rpcs, remove its `kit:%s` directive",
+				m.Name,
+				m.Directives.GRPCStream,
+				parser.DirectiveGRPCStream,
+			)
+		}
 	}
+	return nil
+}
+
+// applyRPCDirectives makes the rpc unary (see unaryRPCs) and deprecates the
+// rpc of deprecated methods.
+func (g *generateGRPCTransportProtoDgd) applyRPCDirectives(rpc *proto.RPC, d parser.Directives) {
+	setRPCStreams(rpc, "")
+	if !d.Deprecated {
+		return
+	}
+	for _, e := range rpc.Elements {
+		if o, ok := e.(*proto.Option); ok && o.Name == "deprecated" {
+			return
+		}
+	}
+	rpc.Elements = append(rpc.Elements, &proto.Option{
+		Name:     "deprecated",
+		Constant: proto.Literal{Source: "true"},
+	})
 }
 
 type generateGRPCTransportBaseDgd struct {
@@ -1058,6 +1128,7 @@ func newGenerateGRPCTransportBaseDgd(name string, serviceInterface parser.Interf
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransportBaseDgd"
 	return t
 }
 func (g *generateGRPCTransportBaseDgd) Generate() (err error) {
@@ -1133,7 +1204,7 @@ func (g *generateGRPCTransportBaseDgd) Generate() (err error) {
 		jen.Return(jen.Id("&grpcServer").Values(vl)),
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 }
 
 type generateGRPCTransportDgd struct {
@@ -1160,6 +1231,7 @@ func newGenerateGRPCTransportDgd(name string, serviceInterface parser.Interface,
 	t.srcFile = jen.NewFilePath(t.destPath)
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateGRPCTransportDgd"
 	return t
 }
 func (g *generateGRPCTransportDgd) Generate() (err error) {
@@ -1180,7 +1252,7 @@ func (g *generateGRPCTransportDgd) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("grpc")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1332,7 +1404,7 @@ func (g *generateGRPCTransportDgd) Generate() (err error) {
 		}
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	src += "\n" + g.code.Raw().GoString()
@@ -1355,5 +1427,5 @@ func (g *generateGRPCTransportDgd) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
@@ -1,10 +1,8 @@
 package generator
 
 import (
-	"bytes"
 	"fmt"
 	"path"
-	"strconv"
 	"strings"
 	// "log"
 
@@ -31,6 +29,7 @@ type GenerateServiceDdg struct {
 	methods                              []string
 	filePath                             string
 	file                                 *parser.File
+	pkg                                  *parser.Package
 	serviceInterface                     parser.Interface
 	sMiddleware, gorillaMux, eMiddleware bool
 }
@@ -55,11 +54,17 @@ func NewGenerateServiceDdg(name, transport string, sMiddleware, gorillaMux, eMid
 	i.InitPg()
 	//
 	i.fs = fs.Get()
+	i.generator = "GenerateServiceDdg"
 	return i
 }
 
-// Generate generates the service.
+// Generate generates the service, the files are written to disk only if all
+// the generators succeed and the generated code compiles (unless `--no-verify`).
 func (g *GenerateServiceDdg) Generate() (err error) {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateServiceDdg) generate() (err error) {
 	for n, v := range SupportedTransportsDdg {
 		if v == g.transport {
 			break
@@ -68,6 +73,11 @@ func (g *GenerateServiceDdg) Generate() (err error) {
 			return
 		}
 	}
+	if protoPath := viper.GetString("g_s_from_proto"); protoPath != "" {
+		if err = NewServiceFromProto(g.name, protoPath, true).Generate(); err != nil {
+			return err
+		}
+	}
 	if b, err := g.fs.Exists(g.filePath); err != nil {
 		return err
 	} else if !b {
@@ -80,9 +90,23 @@ func (g *GenerateServiceDdg) Generate() (err error) {
 	}
 
 	g.file, err = parser.NewFileParser().Parse([]byte(svcSrc))
+	if err != nil {
+		return err
+	}
+	g.pkg, err = g.ParsePackage(g.destPath)
+	if err != nil {
+		return err
+	}
 	if !g.serviceFound() {
 		return
 	}
+	// the methods with a wrong format are still part of the service so
+	// their code is not pruned.
+	prG := newGeneratePruneDgd(g.name, g.serviceStructName, g.serviceInterface, viper.GetString("g_s_prune"))
+	err = prG.Generate()
+	if err != nil {
+		return err
+	}
 	g.removeBadMethods(&svcSrc)
 	// 先生成 *.proto 文件，生成依赖需要
 	gp := NewGenerateGRPCTransportProtoDgd(g.name, g.serviceInterface, g.methods)
@@ -104,7 +128,7 @@ func (g *GenerateServiceDdg) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	err = g.fs.WriteFile(g.filePath, s, true)
+	err = g.writeFile(g.filePath, s, true)
 	if err != nil {
 		return err
 	}
@@ -167,20 +191,16 @@ func (g *GenerateServiceDdg) generateServiceMethods() {
 	}
 }
 func (g *GenerateServiceDdg) generateServiceStruct() {
-	for _, v := range g.file.Structures {
-		if v.Name == g.serviceStructName {
-			logrus.Debugf("Service `%s` structure already exists so it will not be recreated.", g.serviceStructName)
-			return
-		}
+	if _, ok := g.pkg.Types[g.serviceStructName]; ok {
+		logrus.Debugf("Service `%s` structure already exists so it will not be recreated.", g.serviceStructName)
+		return
 	}
 	g.pg.appendStruct(g.serviceStructName)
 }
 func (g *GenerateServiceDdg) generateNewMethod() {
-	for _, v := range g.file.Methods {
-		if v.Name == "New" {
-			logrus.Debugf("Service method `%s` already exists so it will not be recreated.", v.Name)
-			return
-		}
+	if g.pkg.HasMethod("", "New") {
+		logrus.Debug("Service method `New` already exists so it will not be recreated.")
+		return
 	}
 	g.pg.Raw().Commentf(
 		"New returns a %s with all of the expected middleware wired in.",
@@ -209,11 +229,9 @@ func (g *GenerateServiceDdg) generateNewMethod() {
 }
 func (g *GenerateServiceDdg) generateNewBasicStructMethod() {
 	fn := fmt.Sprintf("New%s", utils.ToCamelCase(g.serviceStructName))
-	for _, v := range g.file.Methods {
-		if v.Name == fn {
-			logrus.Debugf("Service method `%s` already exists so it will not be recreated.", v.Name)
-			return
-		}
+	if g.pkg.HasMethod("", fn) {
+		logrus.Debugf("Service method `%s` already exists so it will not be recreated.", fn)
+		return
 	}
 	g.pg.Raw().Commentf(
 		"New%s returns a naive, stateless implementation of %s.",
@@ -226,16 +244,21 @@ func (g *GenerateServiceDdg) generateNewBasicStructMethod() {
 	g.pg.appendFunction(fn, nil, []jen.Code{}, []jen.Code{}, g.interfaceName, body...)
 	g.pg.NewLine()
 }
+
+// serviceFound looks for the service interface in the service file and then
+// in the other files of the package.
 func (g *GenerateServiceDdg) serviceFound() bool {
-	for n, v := range g.file.Interfaces {
+	for _, v := range g.file.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.destPath)
 			return true
-		} else if n == len(g.file.Interfaces)-1 {
-			logrus.Errorf("Could not find the service interface in `%s`", g.name)
-			return false
 		}
 	}
+	if v, ok := g.pkg.Interface(g.interfaceName); ok {
+		g.serviceInterface = g.ExpandInterface(v, g.destPath)
+		return true
+	}
+	logrus.Errorf("Could not find the service interface in `%s`", g.name)
 	return false
 }
 func (g *GenerateServiceDdg) removeBadMethods(srcPtr *string) {
@@ -276,6 +299,7 @@ func newGenerateServiceDdgMiddleware(name string, serviceFile *parser.File,
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceMiddlewareDdg"
 	return gsm
 }
 func (g *generateServiceMiddlewareDdg) Generate() error {
@@ -285,7 +309,7 @@ func (g *generateServiceMiddlewareDdg) Generate() error {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("service")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -365,7 +389,11 @@ func (g *generateServiceMiddlewareDdg) Generate() error {
 		g.generateMethodMiddleware("loggingMiddleware", true)
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+	}
+	src, err = g.removeStaleDecls(src)
+	if err != nil {
+		return err
 	}
 	src += "\n" + g.code.Raw().GoString()
 	tmpSrc := g.srcFile.GoString()
@@ -389,7 +417,7 @@ func (g *generateServiceMiddlewareDdg) Generate() error {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 
 func (g *generateServiceMiddlewareDdg) generateMethodMiddleware(mdw string, df bool) {
@@ -405,6 +433,14 @@ func (g *generateServiceMiddlewareDdg) generateMethodMiddleware(mdw string, df b
 		for _, v := range g.file.Methods {
 			if v.Name == m.Name && v.Struct.Type == mdw {
 				mthdFound = true
+				if !sameSignature(m, v) {
+					if df {
+						g.stale = append(g.stale, mdw+"."+m.Name)
+						mthdFound = false
+					} else {
+						reportSignatureChange(m.Name, mdw+"."+m.Name, g.filePath)
+					}
+				}
 				break
 			}
 		}
@@ -416,8 +452,7 @@ func (g *generateServiceMiddlewareDdg) generateMethodMiddleware(mdw string, df b
 			for _, p := range m.Parameters {
 				pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceFile.Imports)
 				if pth != "" {
-					s := strings.Split(p.Type, ".")
-					middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Qual(pth, s[1]))
+					middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceFile.Imports)))
 				} else {
 					if p.Type == "context.Context" {
 						middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Qual("context", "Context"))
@@ -433,8 +468,7 @@ func (g *generateServiceMiddlewareDdg) generateMethodMiddleware(mdw string, df b
 			for _, p := range m.Results {
 				pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceFile.Imports)
 				if pth != "" {
-					s := strings.Split(p.Type, ".")
-					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Qual(pth, s[1]))
+					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceFile.Imports)))
 				} else {
 					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Id(p.Type))
 				}
@@ -442,7 +476,9 @@ func (g *generateServiceMiddlewareDdg) generateMethodMiddleware(mdw string, df b
 			}
 			loggerLog = append([]jen.Code{jen.Lit("method"), jen.Lit(m.Name)}, loggerLog...)
 			var deferBlock *jen.Statement
-			if df {
+			if m.Directives.Skips(parser.SkipMiddleware) {
+				deferBlock = jen.Comment(m.Name + " skips the middleware (kit:skip middleware).").Line()
+			} else if df {
 				deferBlock = jen.Defer().Func().Call().Block(jen.Id(stp).Dot("logger").Dot("Log").Call(
 					loggerLog...,
 				)).Call()
@@ -490,6 +526,7 @@ func newGenerateServiceDdgEndpoints(name string, imports []parser.NamedTypeValue
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceEndpointsDgd"
 	return gsm
 }
 func (g *generateServiceEndpointsDgd) Generate() error {
@@ -499,7 +536,7 @@ func (g *generateServiceEndpointsDgd) Generate() error {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("endpoint")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	epSrc, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -519,7 +556,11 @@ func (g *generateServiceEndpointsDgd) Generate() error {
 		}
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+	}
+	epSrc, err = g.removeStaleDecls(epSrc)
+	if err != nil {
+		return err
 	}
 	epSrc += "\n" + g.code.Raw().GoString()
 	tmpSrc := g.srcFile.GoString()
@@ -544,30 +585,31 @@ func (g *generateServiceEndpointsDgd) Generate() error {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	if err = g.writeFile(g.filePath, s, true); err != nil {
+		return err
+	}
+	return g.syncDocs(g.filePath, g.docs())
 }
 
-// EnsureThatWeUseQualifierIfNeeded is used to see if we need to import a path of a given type.
-func (b *generateServiceEndpointsDgd) EnsureThatWeUseQualifierIfNeeded(tp string, imp []parser.NamedTypeValue) string {
-	if bytes.HasPrefix([]byte(tp), []byte("...")) {
-		return ""
-	}
-	if t := strings.Split(tp, "."); len(t) > 0 {
-		s := t[0]
-		// 兼容指针
-		if len(s) > 0 && s[0] == '*' {
-			s = s[1:]
-		}
-		for _, v := range imp {
-			i, _ := strconv.Unquote(v.Type)
+func makeEndpointDoc(m parser.Method) []string {
+	return []string{fmt.Sprintf("Make%sEndpoint returns an endpoint that invokes %s on the service.", m.Name, m.Name)}
+}
 
-			if strings.HasSuffix(i, s) || v.Name == s {
-				return i
-			}
-		}
-		return ""
+func endpointsMethodDoc(m parser.Method) []string {
+	return []string{fmt.Sprintf("%s implements Service. Primarily useful in a client.", m.Name)}
+}
+
+// docs returns the doc comments of the endpoints of the service methods.
+func (g *generateServiceEndpointsDgd) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(
+			docs,
+			declDoc{key: "Make" + m.Name + "Endpoint", doc: withMethodDoc(makeEndpointDoc(m), m), dflt: makeEndpointDoc(m)},
+			declDoc{key: "Endpoints." + m.Name, doc: withMethodDoc(endpointsMethodDoc(m), m), dflt: endpointsMethodDoc(m)},
+		)
 	}
-	return ""
+	return docs
 }
 
 func (g *generateServiceEndpointsDgd) generateEndpointsClientMethods() {
@@ -587,7 +629,10 @@ func (g *generateServiceEndpointsDgd) generateEndpointsClientMethods() {
 		found := false
 		for _, v := range g.file.Methods {
 			if v.Name == m.Name && v.Struct.Type == "Endpoints" {
-				found = true
+				found = sameSignature(m, v)
+				if !found {
+					g.stale = append(g.stale, "Endpoints."+m.Name)
+				}
 				break
 			}
 		}
@@ -615,15 +660,8 @@ func (g *generateServiceEndpointsDgd) generateEndpointsClientMethods() {
 			}
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-
-				if len(s[0]) > 0 && s[0][0] == '*' {
-					sp = append(sp, jen.Id(p.Name).Id("*").Qual(pth, s[1]))
-					rsp_jc = jen.Id("*").Qual(pth, s[1])
-				} else {
-					sp = append(sp, jen.Id(p.Name).Qual(pth, s[1]))
-					rsp_jc = jen.Qual(pth, s[1])
-				}
+				rsp_jc = g.TypeCode(p.Type, g.serviceImports)
+				sp = append(sp, jen.Id(p.Name).Add(rsp_jc))
 			} else {
 				sp = append(sp, jen.Id(p.Name).Id(tp))
 				rsp_jc = jen.Id(tp)
@@ -647,8 +685,7 @@ func (g *generateServiceEndpointsDgd) generateEndpointsClientMethods() {
 			}
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-				rs = append(rs, jen.Id(p.Name).Qual(pth, s[1]))
+				rs = append(rs, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceImports)))
 			} else {
 				rs = append(rs, jen.Id(p.Name).Id(tp))
 			}
@@ -673,7 +710,8 @@ func (g *generateServiceEndpointsDgd) generateEndpointsClientMethods() {
 			jen.Id("*").Id(rpName).Op("=").Id("*").Call(jen.Id("response").Dot("").Call(rsp_jc)),
 			jen.Return(jen.List(resList...)),
 		}
-		g.code.Raw().Commentf("%s implements Service. Primarily useful in a client.", m.Name).Line()
+		g.code.appendMultilineComment(withMethodDoc(endpointsMethodDoc(m), m))
+		g.code.NewLine()
 		g.code.appendFunction(
 			m.Name,
 			jen.Id(stp).Id("Endpoints"),
@@ -822,7 +860,7 @@ func (g *generateServiceEndpointsDgd) generateMethodEndpoint() (err error) {
 				"",
 				bd...,
 			)
-			g.code.Raw().Commentf("Make%sEndpoint returns an endpoint that invokes %s on the service.", m.Name, m.Name)
+			g.code.appendMultilineComment(withMethodDoc(makeEndpointDoc(m), m))
 			g.code.NewLine()
 			g.code.appendFunction(
 				"Make"+m.Name+"Endpoint",
@@ -897,6 +935,7 @@ func newGenerateServiceDdgEndpointsBase(name string, serviceInterface parser.Int
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceEndpointsDgdBase"
 	return gsm
 }
 func (g *generateServiceEndpointsDgdBase) Generate() (err error) {
@@ -921,6 +960,7 @@ func (g *generateServiceEndpointsDgdBase) Generate() (err error) {
 	)
 	eps := jen.Dict{}
 	loops := []jen.Code{}
+	timeout := false
 	for _, v := range g.serviceInterface.Methods {
 		eps[jen.Id(v.Name+"Endpoint")] = jen.Id("Make" + v.Name + "Endpoint").Call(jen.Id("s"))
 		l := jen.For(jen.List(jen.Id("_"), jen.Id("m")).Op(":=").Range().Id("mdw").Index(jen.Lit(v.Name)))
@@ -928,6 +968,12 @@ func (g *generateServiceEndpointsDgdBase) Generate() (err error) {
 			jen.Id("eps").Dot(v.Name + "Endpoint").Op("=").Id("m").Call(jen.Id("eps").Dot(v.Name + "Endpoint")),
 		)
 		loops = append(loops, l)
+		if v.Directives.Timeout > 0 {
+			loops = append(loops, jen.Id("eps").Dot(v.Name+"Endpoint").Op("=").Id("timeout").Call(
+				durationCode(v.Directives.Timeout),
+			).Call(jen.Id("eps").Dot(v.Name+"Endpoint")))
+			timeout = true
+		}
 	}
 	svcImport, err := utils.GetServiceImportPath(g.name)
 	if err != nil {
@@ -958,7 +1004,15 @@ func (g *generateServiceEndpointsDgdBase) Generate() (err error) {
 		body...,
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	if timeout {
+		g.code.appendMultilineComment([]string{
+			"timeout returns an endpoint middleware that cancels the context of the",
+			"requests after d, see the kit:timeout directive of the service methods.",
+		})
+		g.code.NewLine()
+		g.code.Raw().Add(timeoutMiddleware()).Line()
+	}
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 }
 
 type generateEndpointMiddlewareDgd struct {
@@ -981,6 +1035,7 @@ func newGenerateEndpointMiddlewareDgd(name string) Gen {
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateEndpointMiddlewareDgd"
 	return gsm
 }
 func (g *generateEndpointMiddlewareDgd) Generate() (err error) {
@@ -993,7 +1048,7 @@ func (g *generateEndpointMiddlewareDgd) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("endpoint")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1130,7 +1185,7 @@ func (g *generateEndpointMiddlewareDgd) Generate() (err error) {
 		g.code.NewLine()
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 
 	src += "\n" + g.code.Raw().GoString()
@@ -1154,7 +1209,7 @@ func (g *generateEndpointMiddlewareDgd) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 
 type generateCmdBaseDgd struct {
@@ -1167,8 +1222,10 @@ type generateCmdBaseDgd struct {
 	grpcDestPath                       string
 	httpFilePath                       string
 	grpcFilePath                       string
+	gatewayFilePath                    string
 	httpFile                           *parser.File
 	grpcFile                           *parser.File
+	gatewayFile                        *parser.File
 	generateSvcDefaultsMiddleware      bool
 	generateEndpointDefaultsMiddleware bool
 	serviceInterface                   parser.Interface
@@ -1189,9 +1246,11 @@ func newGenerateCmdBaseDgd(name string, serviceInterface parser.Interface,
 	t.filePath = path.Join(t.destPath, viper.GetString("gk_cmd_base_file_name"))
 	t.httpFilePath = path.Join(t.httpDestPath, viper.GetString("gk_http_file_name"))
 	t.grpcFilePath = path.Join(t.grpcDestPath, viper.GetString("gk_grpc_file_name"))
+	t.gatewayFilePath = path.Join(t.httpDestPath, viper.GetString("gk_http_gateway_file_name"))
 	t.srcFile = jen.NewFile("service")
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateCmdBaseDgd"
 	return t
 }
 func (g *generateCmdBaseDgd) Generate() (err error) {
@@ -1251,6 +1310,21 @@ func (g *generateCmdBaseDgd) Generate() (err error) {
 		}
 		cd = append(cd, jen.Id("initGRPCHandler").Call(jen.Id("endpoints"), jen.Id("g")))
 	}
+	existingGateway := false
+	if b, err := g.fs.Exists(g.gatewayFilePath); err != nil {
+		return err
+	} else if b {
+		existingGateway = true
+		src, err := g.fs.ReadFile(g.gatewayFilePath)
+		if err != nil {
+			return err
+		}
+		g.gatewayFile, err = parser.NewFileParser().Parse([]byte(src))
+		if err != nil {
+			return err
+		}
+		cd = append(cd, jen.Id("initGatewayHandler").Call(jen.Id("endpoints"), jen.Id("g")))
+	}
 	cd = append(cd, jen.Return(jen.Id("g")))
 	g.code.appendFunction(
 		"createService",
@@ -1373,6 +1447,56 @@ func (g *generateCmdBaseDgd) Generate() (err error) {
 			pl.Raw(),
 		)
 		g.code.NewLine()
+		health, err := g.grpcHealth(g.name)
+		if err != nil {
+			return err
+		}
+		if health {
+			appendGRPCHealth(g.code, g.protoService(g.name))
+		}
+	}
+	if existingGateway {
+		opt := jen.Dict{}
+		for _, v := range g.serviceInterface.Methods {
+			for _, m := range g.gatewayFile.Methods {
+				if m.Name == "decode"+v.Name+"GatewayRequest" {
+					opt[jen.Lit(v.Name)] = jen.Values(
+						jen.List(
+							jen.Qual("github.com/go-kit/kit/transport/http", "ServerErrorLogger").Call(jen.Id("logger")),
+							jen.Qual("github.com/go-kit/kit/transport/http", "ServerBefore").Call(
+								jen.Qual("github.com/go-kit/kit/tracing/opentracing", "HTTPToContext").Call(
+									jen.Id("tracer"),
+									jen.Lit(v.Name),
+									jen.Id("logger"),
+								),
+							),
+						),
+					)
+				}
+			}
+		}
+		pl := NewPartialGenerator(nil)
+		pl.Raw().Id("options").Op(":=").Map(jen.String()).Index().Qual(
+			"github.com/go-kit/kit/transport/http",
+			"ServerOption",
+		).Values(
+			opt,
+		).Line()
+		pl.Raw().Return(jen.Id("options"))
+		g.code.appendFunction(
+			"defaultGatewayOptions",
+			nil,
+			[]jen.Code{
+				jen.Id("logger").Qual("github.com/go-kit/kit/log", "Logger"),
+				jen.Id("tracer").Qual("github.com/opentracing/opentracing-go", "Tracer"),
+			},
+			[]jen.Code{
+				jen.Map(jen.String()).Index().Qual("github.com/go-kit/kit/transport/http", "ServerOption"),
+			},
+			"",
+			pl.Raw(),
+		)
+		g.code.NewLine()
 	}
 	if g.generateEndpointDefaultsMiddleware {
 		body := []jen.Code{}
@@ -1458,7 +1582,7 @@ func (g *generateCmdBaseDgd) Generate() (err error) {
 		),
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 }
 
 type generateCmdDgd struct {
@@ -1474,6 +1598,7 @@ type generateCmdDgd struct {
 	grpcDestPath                       string
 	httpFilePath                       string
 	grpcFilePath                       string
+	gatewayFilePath                    string
 	generateSvcDefaultsMiddleware      bool
 	generateEndpointDefaultsMiddleware bool
 	serviceInterface                   parser.Interface
@@ -1495,9 +1620,11 @@ func newGenerateCmdDgd(name string, serviceInterface parser.Interface,
 	t.filePath = path.Join(t.destPath, viper.GetString("gk_cmd_svc_file_name"))
 	t.httpFilePath = path.Join(t.httpDestPath, viper.GetString("gk_http_file_name"))
 	t.grpcFilePath = path.Join(t.grpcDestPath, viper.GetString("gk_grpc_file_name"))
+	t.gatewayFilePath = path.Join(t.httpDestPath, viper.GetString("gk_http_gateway_file_name"))
 	t.srcFile = jen.NewFile("service")
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateCmdDgd"
 	return t
 }
 
@@ -1512,7 +1639,7 @@ func (g *generateCmdDgd) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("service")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1559,6 +1686,14 @@ func (g *generateCmdDgd) Generate() (err error) {
 			return err
 		}
 	}
+	if b, err := g.fs.Exists(g.gatewayFilePath); err != nil {
+		return err
+	} else if b {
+		err = g.generateInitGateway()
+		if err != nil {
+			return err
+		}
+	}
 	err = g.generateGetMiddleware()
 	if err != nil {
 		return err
@@ -1567,7 +1702,7 @@ func (g *generateCmdDgd) Generate() (err error) {
 	g.generateCancelInterrupt()
 	g.generateCmdMain()
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	f, err := parser.NewFileParser().Parse([]byte(tmpSrc))
@@ -1581,6 +1716,8 @@ func (g *generateCmdDgd) Generate() (err error) {
 	}
 	if len(imp) > 0 {
 		pSrc := g.code.Raw().GoString()
+		// the imports without alias have the name of their package.
+		fileImports := g.resolver().resolveImports(g.file.Imports)
 		foundSameImport := false
 		inx := 0
 		// Small(stupid) workaround
@@ -1588,7 +1725,7 @@ func (g *generateCmdDgd) Generate() (err error) {
 		mp := map[string]string{}
 		keep := imp
 		for a, i := range imp {
-			for _, v := range g.file.Imports {
+			for _, v := range fileImports {
 				if v.Type == i.Type && i.Name != v.Name {
 					mp[txt+i.Name] = v.Name
 					pSrc = strings.Replace(pSrc, i.Name+".", txt+i.Name+".", -1)
@@ -1598,7 +1735,7 @@ func (g *generateCmdDgd) Generate() (err error) {
 		}
 
 		for a, i := range keep {
-			for _, v := range g.file.Imports {
+			for _, v := range fileImports {
 				if v.Name == i.Name {
 					foundSameImport = true
 					inx = a
@@ -1610,7 +1747,7 @@ func (g *generateCmdDgd) Generate() (err error) {
 			a := 1
 			for {
 				canUse := true
-				for _, v := range g.file.Imports {
+				for _, v := range fileImports {
 					if fmt.Sprintf("%s%d", keep[inx].Name, a) == v.Name {
 						canUse = false
 						break
@@ -1637,12 +1774,15 @@ func (g *generateCmdDgd) Generate() (err error) {
 		if err != nil {
 			return err
 		}
+	} else {
+		// the new functions only use the imports of the file.
+		src += "\n" + g.code.Raw().GoString()
 	}
 	s, err := utils.GoImportsSource(g.destPath, src)
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 func (g *generateCmdDgd) generateRun() (*PartialGenerator, error) {
 	pg := NewPartialGenerator(nil)
@@ -1929,6 +2069,7 @@ func (g *generateCmdDgd) generateInitHTTP() (err error) {
 func (g *generateCmdDgd) generateInitGRPC() (err error) {
 	for _, v := range g.file.Methods {
 		if v.Name == "initGRPCHandler" {
+			warnGRPCHealth(v.Body, g.filePath)
 			return
 		}
 	}
@@ -1972,32 +2113,106 @@ func (g *generateCmdDgd) generateInitGRPC() (err error) {
 			),
 		),
 	).Line()
+	health, err := g.grpcHealth(g.name)
+	if err != nil {
+		return err
+	}
+	pt.Raw().Add(grpcServe(pbImport, g.name, health))
+	g.code.NewLine()
+	g.code.appendFunction(
+		"initGRPCHandler",
+		nil,
+		[]jen.Code{
+			jen.Id("endpoints").Qual(epImport, "Endpoints"),
+			jen.Id("g").Id("*").Qual("github.com/oklog/oklog/pkg/group", "Group"),
+		},
+		[]jen.Code{},
+		"",
+		pt.Raw(),
+	)
+	return
+}
+
+// generateInitGateway generates initGatewayHandler that serves the HTTP/JSON
+// gateway on its own listener, the `gateway-addr` flag is added if missing.
+func (g *generateCmdDgd) generateInitGateway() (err error) {
+	for _, v := range g.file.Methods {
+		if v.Name == "initGatewayHandler" {
+			return
+		}
+	}
+	httpImport, err := utils.GetHTTPTransportImportPath(g.name)
+	if err != nil {
+		return err
+	}
+	epImport, err := utils.GetEndpointImportPath(g.name)
+	if err != nil {
+		return err
+	}
+	gatewayAddr := false
+	for _, v := range g.file.Vars {
+		if v.Name == "gatewayAddr" {
+			gatewayAddr = true
+		}
+	}
+	if !gatewayAddr {
+		g.code.NewLine()
+		g.code.Raw().Var().Id("gatewayAddr").Op("=").Id("fs").Dot("String").Call(
+			jen.Lit("gateway-addr"),
+			jen.Lit(":8084"),
+			jen.Lit("HTTP/JSON gateway listen address"),
+		)
+		g.code.NewLine()
+	}
+
+	pt := NewPartialGenerator(nil)
+	pt.Raw().Id("options").Op(":=").Id("defaultGatewayOptions").Call(
+		jen.Id("logger"),
+		jen.Id("tracer"),
+	).Line().Comment("Add your gateway options here").Line().Line()
+	pt.Raw().Id("gatewayHandler").Op(":=").Qual(httpImport, "NewGatewayHandler").Call(
+		jen.Id("endpoints"),
+		jen.Id("options"),
+	).Line()
+
+	pt.Raw().List(jen.Id("gatewayListener"), jen.Err()).Op(":=").Qual("net", "Listen").Call(
+		jen.Lit("tcp"),
+		jen.Id("*gatewayAddr"),
+	).Line()
+	pt.Raw().If(
+		jen.Err().Op("!=").Nil().Block(
+			jen.Id("logger").Dot("Log").Call(
+				jen.Lit("transport"),
+				jen.Lit("gateway"),
+				jen.Lit("during"),
+				jen.Lit("Listen"),
+				jen.Lit("err"),
+				jen.Err(),
+			),
+		),
+	).Line()
 	pt.Raw().Id("g").Dot("Add").Call(
 		jen.Func().Params().Error().Block(
 			jen.Id("logger").Dot("Log").Call(
 				jen.Lit("transport"),
-				jen.Lit("gRPC"),
+				jen.Lit("gateway"),
 				jen.Lit("addr"),
-				jen.Id("*grpcAddr"),
-			),
-			jen.Id("baseServer").Op(":=").Qual("google.golang.org/grpc", "NewServer").Call(),
-			jen.Qual(pbImport, fmt.Sprintf("Register%sServer", utils.ToCamelCase(g.name))).Call(
-				jen.Id("baseServer"),
-				jen.Id("grpcServer"),
+				jen.Id("*gatewayAddr"),
 			),
 			jen.Return(
-				jen.Id("baseServer").Dot("Serve").Call(
-					jen.Id("grpcListener"),
+				jen.Qual("net/http", "Serve").Call(
+					jen.Id("gatewayListener"),
+					jen.Id("gatewayHandler"),
 				),
 			),
 		),
 		jen.Func().Params(jen.Error()).Block(
-			jen.Id("grpcListener").Dot("Close").Call(),
+			jen.Id("gatewayListener").Dot("Close").Call(),
 		),
 	).Line()
 	g.code.NewLine()
 	g.code.appendFunction(
-		"initGRPCHandler",
+		"initGatewayHandler",
 		nil,
 		[]jen.Code{
 			jen.Id("endpoints").Qual(epImport, "Endpoints"),
@@ -2208,7 +2423,7 @@ func (g *generateCmdDgd) generateCmdMain() error {
 	src.Func().Id("main").Params().Block(
 		jen.Qual(cmdSvcImport, "Run").Call(),
 	)
-	return g.fs.WriteFile(mainFilePath, src.GoString(), false)
+	return g.writeFile(mainFilePath, src.GoString(), false)
 }
 
 type generateHandlerFileDdg struct {
@@ -2244,16 +2459,25 @@ func newGenerateHandlerFileDdg(name, serviceStructName, stp string, method parse
 	ghs.srcFile = jen.NewFilePath(ghs.destPath)
 	ghs.InitPg()
 	ghs.fs = fs.Get()
+	ghs.generator = "generateHandlerFileDdg"
 	return ghs
 }
 
 func (g *generateHandlerFileDdg) Generate() (err error) {
+	// the method can be implemented in any file of the package.
+	pkg, err := g.ParsePackage(g.destPath)
+	if err != nil {
+		return err
+	}
+	if g.serviceMethodFound(pkg.Methods, g.destPath) {
+		return g.syncDocs(g.filePath, g.docs())
+	}
 	if b, err := g.fs.Exists(g.filePath); err != nil {
 		return err
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("service")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	svcSrc, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -2261,10 +2485,9 @@ func (g *generateHandlerFileDdg) Generate() (err error) {
 	}
 
 	g.file, err = parser.NewFileParser().Parse([]byte(svcSrc))
-	if g.serviceMethodFound() {
-		return nil
+	if err != nil {
+		return err
 	}
-
 	g.generateServiceMethods()
 
 	if g.generateFirstTime {
@@ -2273,7 +2496,7 @@ func (g *generateHandlerFileDdg) Generate() (err error) {
 		if err != nil {
 			return err
 		}
-		return g.fs.WriteFile(g.filePath, s, true)
+		return g.writeFile(g.filePath, s, true)
 	}
 
 	svcSrc += "\n" + g.code.Raw().GoString()
@@ -2298,13 +2521,16 @@ func (g *generateHandlerFileDdg) Generate() (err error) {
 		return err
 	}
 
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 
-func (g *generateHandlerFileDdg) serviceMethodFound() bool {
-	for _, v := range g.file.Methods {
+func (g *generateHandlerFileDdg) serviceMethodFound(methods []parser.Method, path string) bool {
+	for _, v := range methods {
 		if g.method.Name == v.Name && v.Struct.Type == "*"+g.serviceStructName {
 			logrus.Debugf("Service method `%s` already exists so it will not be recreated.", v.Name)
+			if !sameSignature(g.method, v) {
+				reportSignatureChange(g.method.Name, g.serviceStructName+"."+v.Name, path)
+			}
 			return true
 		}
 	}
@@ -2312,11 +2538,12 @@ func (g *generateHandlerFileDdg) serviceMethodFound() bool {
 	return false
 }
 
-func (g *generateHandlerFileDdg) generateServiceMethods() {
+// docs returns the doc comment of the implementation of the service method.
+func (g *generateHandlerFileDdg) docs() []declDoc {
+	return []declDoc{{key: g.serviceStructName + "." + g.method.Name, doc: methodDoc(g.method)}}
+}
 
-	if g.serviceMethodFound() {
-		return
-	}
+func (g *generateHandlerFileDdg) generateServiceMethods() {
 
 	sp := []jen.Code{}
 	for _, p := range g.method.Parameters {
@@ -2333,6 +2560,10 @@ func (g *generateHandlerFileDdg) generateServiceMethods() {
 		jen.Comment("TODO implement the business logic of " + g.method.Name),
 		jen.Return(rt...),
 	}
+	if doc := methodDoc(g.method); len(doc) > 0 {
+		g.code.appendMultilineComment(doc)
+		g.code.NewLine()
+	}
 	g.code.appendFunction(
 		g.method.Name,
 		jen.Id(g.stp).Id("*"+g.serviceStructName),
@@ -2385,6 +2616,7 @@ func newGenerateCommUtilsDdg(name string, isProjComm bool) Gen {
 	ghs.srcFile = jen.NewFilePath(ghs.destPath)
 
 	ghs.fs = fs.Get()
+	ghs.generator = "generateCommUtilsDdg"
 	return ghs
 }
 
@@ -2418,7 +2650,7 @@ func (g *generateCommUtilsDdg) generateErrcode() (err error) {
 		g.errcodeGenerateFirstTime = true
 		service := path.Base(g.destPath)
 		f := jen.NewFile(service)
-		g.fs.WriteFile(g.errcodeFilePath, f.GoString(), false)
+		g.writeFile(g.errcodeFilePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.errcodeFilePath)
 	if err != nil {
@@ -2426,6 +2658,9 @@ func (g *generateCommUtilsDdg) generateErrcode() (err error) {
 	}
 
 	g.file, err = parser.NewFileParser().Parse([]byte(src))
+	if err != nil {
+		return err
+	}
 	//=======================================================================
 	hasErrCodeOk := false
 	hasErrCodeMax := false
@@ -2464,7 +2699,7 @@ func (g *generateCommUtilsDdg) generateErrcode() (err error) {
 		if err != nil {
 			return err
 		}
-		return g.fs.WriteFile(g.errcodeFilePath, s, true)
+		return g.writeFile(g.errcodeFilePath, s, true)
 	}
 
 	src += "\n" + g.code.Raw().GoString()
@@ -2489,7 +2724,7 @@ func (g *generateCommUtilsDdg) generateErrcode() (err error) {
 		return err
 	}
 
-	return g.fs.WriteFile(g.errcodeFilePath, s, true)
+	return g.writeFile(g.errcodeFilePath, s, true)
 }
 
 func (g *generateCommUtilsDdg) generateErrmsg() (err error) {
@@ -2500,7 +2735,7 @@ func (g *generateCommUtilsDdg) generateErrmsg() (err error) {
 		g.errmsgenerateFirstTime = true
 		service := path.Base(g.destPath)
 		f := jen.NewFile(service)
-		g.fs.WriteFile(g.errmsgFilePath, f.GoString(), false)
+		g.writeFile(g.errmsgFilePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.errmsgFilePath)
 	if err != nil {
@@ -2521,7 +2756,7 @@ func (g *generateCommUtilsDdg) generateErrmsg() (err error) {
 		if err != nil {
 			return err
 		}
-		return g.fs.WriteFile(g.errmsgFilePath, s, true)
+		return g.writeFile(g.errmsgFilePath, s, true)
 	}
 
 	src += "\n" + g.code.Raw().GoString()
@@ -2546,7 +2781,7 @@ func (g *generateCommUtilsDdg) generateErrmsg() (err error) {
 		return err
 	}
 
-	return g.fs.WriteFile(g.errmsgFilePath, s, true)
+	return g.writeFile(g.errmsgFilePath, s, true)
 }
 
 func (g *generateCommUtilsDdg) generateErrmsgInit() (err error) {
@@ -0,0 +1,655 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	ps "go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// The modes of `kit g s --prune` for the files that are owned by the user.
+const (
+	// PruneOrphan moves the orphaned files to the `_orphaned` folder.
+	PruneOrphan = "orphan"
+	// PruneDelete deletes the orphaned files.
+	PruneDelete = "delete"
+)
+
+// PruneModes are the supported `--prune` modes.
+var PruneModes = []string{PruneOrphan, PruneDelete}
+
+// orphanedFolder is where the orphaned files are moved, go ignores folders
+// that start with `_` so the project keeps compiling.
+const orphanedFolder = "_orphaned"
+
+// prunedFile describes the generated declarations of a method in a file, the
+// markers tell that the method was generated and the formats are all the
+// declarations of the method (see parser.DeclKey), `%s` is the method name.
+// The files with refs (the `_gen` and the client files) also use the methods
+// in the bodies and the literals of other declarations, the uses are removed
+// too (see pruneRefs).
+type prunedFile struct {
+	path    string
+	markers []string
+	formats []string
+	refs    bool
+}
+
+// generatePruneDgd removes the generated code of the methods that are no
+// longer part of the service interface.
+type generatePruneDgd struct {
+	BaseGenerator
+	name              string
+	interfaceName     string
+	serviceStructName string
+	destPath          string
+	pbFilePath        string
+	mode              string
+	methods           map[string]bool
+	orphans           map[string]bool
+	files             []prunedFile
+	orphanFiles       []string
+	// messageFormats are the proto messages of a method.
+	messageFormats []string
+	// handlerFiles tells that the service methods are implemented in the
+	// `handler_<method>.go` files, they are in the service file otherwise.
+	handlerFiles bool
+	serviceFile  string
+	orphanDecls  []string
+}
+
+func newGeneratePruneDgd(name, serviceStructName string, serviceInterface parser.Interface, mode string) Gen {
+	g := newPrune(name, serviceStructName, serviceInterface, mode)
+	snakeName := utils.ToLowerSnakeCase2(name)
+	g.handlerFiles = true
+	g.messageFormats = []string{dgd_req_data_proto_format, dgd_rsp_data_proto_format}
+	g.files = append(
+		g.files,
+		prunedFile{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_endpoint_path_format"), snakeName),
+				viper.GetString("gk_endpoint_file_name"),
+			),
+			markers: []string{"Make%sEndpoint"},
+			formats: []string{"Make%sEndpoint", "Endpoints.%s"},
+		},
+		prunedFile{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_gdg_conf_path_format"), snakeName),
+				viper.GetString("gk_http_router_conf_file_name"),
+			),
+			markers: []string{dgd_router_map_pattern_format},
+			formats: []string{dgd_router_map_pattern_format},
+		},
+	)
+	return g
+}
+
+// newGeneratePrune returns the pruning of the services of NewGenerateService,
+// the methods are implemented in the service file and the endpoints have
+// their request and response structs.
+func newGeneratePrune(name, serviceStructName string, serviceInterface parser.Interface, mode string) Gen {
+	g := newPrune(name, serviceStructName, serviceInterface, mode)
+	g.messageFormats = []string{"%sRequest", "%sReply"}
+	g.files = append(g.files, prunedFile{
+		path: path.Join(
+			fmt.Sprintf(viper.GetString("gk_endpoint_path_format"), utils.ToLowerSnakeCase2(name)),
+			viper.GetString("gk_endpoint_file_name"),
+		),
+		markers: []string{"Make%sEndpoint"},
+		formats: []string{"Make%sEndpoint", "Endpoints.%s", "%sRequest", "%sResponse", "%sResponse.Failed"},
+	})
+	return g
+}
+
+// newPrune returns the pruning of the files both kinds of services have.
+func newPrune(name, serviceStructName string, serviceInterface parser.Interface, mode string) *generatePruneDgd {
+	snakeName := utils.ToLowerSnakeCase2(name)
+	g := &generatePruneDgd{
+		name:              name,
+		interfaceName:     utils.ToCamelCase(name + "Service"),
+		serviceStructName: serviceStructName,
+		destPath:          fmt.Sprintf(viper.GetString("gk_service_path_format"), snakeName),
+		mode:              mode,
+		methods:           map[string]bool{},
+		orphans:           map[string]bool{},
+	}
+	for _, m := range serviceInterface.Methods {
+		g.methods[m.Name] = true
+	}
+	g.serviceFile = path.Join(g.destPath, viper.GetString("gk_service_file_name"))
+	g.pbFilePath = path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), snakeName),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), snakeName),
+	)
+	httpPath := fmt.Sprintf(viper.GetString("gk_http_path_format"), snakeName)
+	grpcPath := fmt.Sprintf(viper.GetString("gk_grpc_path_format"), snakeName)
+	handlerFormats := []string{"make%sHandler", "decode%sRequest", "encode%sResponse"}
+	g.files = []prunedFile{
+		{
+			path:    path.Join(g.destPath, viper.GetString("gk_service_middleware_file_name")),
+			formats: []string{"loggingMiddleware.%s"},
+		},
+		{
+			path:    path.Join(httpPath, viper.GetString("gk_http_file_name")),
+			markers: []string{"make%sHandler"},
+			formats: handlerFormats,
+		},
+		{
+			path:    path.Join(grpcPath, viper.GetString("gk_grpc_file_name")),
+			markers: []string{"make%sHandler"},
+			formats: append([]string{"grpcServer.%s"}, handlerFormats...),
+		},
+		{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_endpoint_path_format"), snakeName),
+				viper.GetString("gk_endpoint_base_file_name"),
+			),
+			refs: true,
+		},
+		{
+			path: path.Join(httpPath, viper.GetString("gk_http_base_file_name")),
+			refs: true,
+		},
+		{
+			path:    path.Join(httpPath, viper.GetString("gk_http_gateway_file_name")),
+			formats: []string{"decode%sGatewayRequest", "encode%sGatewayResponse"},
+			refs:    true,
+		},
+		{
+			path: path.Join(grpcPath, viper.GetString("gk_grpc_base_file_name")),
+			refs: true,
+		},
+		{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_cmd_service_path_format"), snakeName),
+				viper.GetString("gk_cmd_base_file_name"),
+			),
+			refs: true,
+		},
+		{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_http_client_path_format"), snakeName),
+				viper.GetString("gk_http_client_file_name"),
+			),
+			markers: []string{"decode%sResponse"},
+			formats: []string{"decode%sResponse"},
+			refs:    true,
+		},
+		{
+			path: path.Join(
+				fmt.Sprintf(viper.GetString("gk_grpc_client_path_format"), snakeName),
+				viper.GetString("gk_grpc_client_file_name"),
+			),
+			markers: []string{"encode%sRequest"},
+			formats: []string{"encode%sRequest", "decode%sResponse"},
+			refs:    true,
+		},
+	}
+	g.srcFile = jen.NewFilePath(g.destPath)
+	g.InitPg()
+	g.fs = fs.Get()
+	g.generator = "generatePruneDgd"
+	return g
+}
+
+func (g *generatePruneDgd) Generate() (err error) {
+	for _, f := range g.files {
+		if err = g.findOrphans(f); err != nil {
+			return err
+		}
+	}
+	if err = g.findProtoOrphans(); err != nil {
+		return err
+	}
+	if g.handlerFiles {
+		if err = g.findOrphanFiles(); err != nil {
+			return err
+		}
+	}
+	if len(g.orphans) == 0 {
+		return nil
+	}
+	if !g.handlerFiles {
+		if err = g.findOrphanDecls(); err != nil {
+			return err
+		}
+	}
+	names := []string{}
+	for n := range g.orphans {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		logrus.Infof("`%s` is no longer part of `%s`, removing its generated code.", n, g.interfaceName)
+	}
+	for _, f := range g.files {
+		if err = g.pruneFile(f); err != nil {
+			return err
+		}
+	}
+	if err = g.pruneProto(); err != nil {
+		return err
+	}
+	if err = g.pruneOrphanDecls(); err != nil {
+		return err
+	}
+	return g.pruneOrphanFiles()
+}
+
+// parseDecls parses the go file and returns its declaration keys (see parser.DeclKey),
+// it returns nil if the file does not exist.
+func (g *generatePruneDgd) parseDecls(filePath string) (src string, keys []string, err error) {
+	if b, err := g.fs.Exists(filePath); err != nil || !b {
+		return "", nil, err
+	}
+	src, err = g.fs.ReadFile(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := ps.ParseFile(token.NewFileSet(), filePath, src, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	return src, parser.DeclKeys(f), nil
+}
+
+// methodFromKey returns the method name of the declaration key if it
+// matches the format.
+func methodFromKey(format, key string) (string, bool) {
+	i := strings.Index(format, "%s")
+	prefix, suffix := format[:i], format[i+2:]
+	if len(key) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) {
+		return "", false
+	}
+	name := key[len(prefix) : len(key)-len(suffix)]
+	if strings.Contains(name, ".") {
+		return "", false
+	}
+	return name, true
+}
+
+func (g *generatePruneDgd) findOrphans(f prunedFile) error {
+	_, keys, err := g.parseDecls(f.path)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		for _, mk := range f.markers {
+			if n, ok := methodFromKey(mk, k); ok && !g.methods[n] {
+				g.orphans[n] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generatePruneDgd) findProtoOrphans() error {
+	def, err := g.parseProto()
+	if err != nil || def == nil {
+		return err
+	}
+	for _, e := range def.Elements {
+		if s, ok := e.(*proto.Service); ok && s.Name == utils.ToCamelCase(g.name) {
+			for _, se := range s.Elements {
+				if r, ok := se.(*proto.RPC); ok && !g.methods[r.Name] {
+					g.orphans[r.Name] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// findOrphanFiles finds the handler files that only implement methods that
+// were removed from the service.
+func (g *generatePruneDgd) findOrphanFiles() error {
+	if b, err := g.fs.Exists(g.destPath); err != nil || !b {
+		return err
+	}
+	infos, err := afero.ReadDir(g.fs.Fs, g.destPath)
+	if err != nil {
+		return err
+	}
+	handlerFileName := path.Base(viper.GetString("gk_gdg_handler_file_name"))
+	for _, info := range infos {
+		if _, ok := methodFromKey(handlerFileName, info.Name()); info.IsDir() || !ok {
+			continue
+		}
+		filePath := path.Join(g.destPath, info.Name())
+		_, keys, err := g.parseDecls(filePath)
+		if err != nil {
+			return err
+		}
+		orphans := []string{}
+		orphan := len(keys) > 0
+		for _, k := range keys {
+			n, ok := methodFromKey(g.serviceStructName+".%s", k)
+			if !ok || g.methods[n] {
+				orphan = false
+				break
+			}
+			orphans = append(orphans, n)
+		}
+		if !orphan {
+			continue
+		}
+		for _, n := range orphans {
+			g.orphans[n] = true
+		}
+		g.orphanFiles = append(g.orphanFiles, filePath)
+	}
+	return nil
+}
+
+// findOrphanDecls finds the implementations of the orphaned methods in the
+// service file, the other methods of the service struct are kept.
+func (g *generatePruneDgd) findOrphanDecls() error {
+	_, keys, err := g.parseDecls(g.serviceFile)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if n, ok := methodFromKey(g.serviceStructName+".%s", k); ok && g.orphans[n] {
+			g.orphanDecls = append(g.orphanDecls, k)
+		}
+	}
+	return nil
+}
+
+// pruneOrphanDecls moves the implementations of the orphaned methods to the
+// `_orphaned` folder or deletes them depending on the mode, without a mode the
+// user is only warned because they are owned by the user.
+func (g *generatePruneDgd) pruneOrphanDecls() error {
+	if len(g.orphanDecls) == 0 {
+		return nil
+	}
+	if g.mode != PruneOrphan && g.mode != PruneDelete {
+		logrus.Warnf(
+			"`%s` in `%s` implement methods that were removed from `%s`, use `--prune` to move them to `%s` or `--prune=delete` to delete them.",
+			strings.Join(g.orphanDecls, "`, `"),
+			g.serviceFile,
+			g.interfaceName,
+			orphanedFolder,
+		)
+		return nil
+	}
+	src, err := g.fs.ReadFile(g.serviceFile)
+	if err != nil {
+		return err
+	}
+	if g.mode == PruneOrphan {
+		fset := token.NewFileSet()
+		f, err := ps.ParseFile(fset, g.serviceFile, src, ps.ParseComments)
+		if err != nil {
+			return err
+		}
+		orphans := map[string]bool{}
+		for _, k := range g.orphanDecls {
+			orphans[k] = true
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			keys := parser.DeclKey(d)
+			if !ok || len(keys) == 0 || !orphans[keys[0]] {
+				continue
+			}
+			start := fd.Pos()
+			if fd.Doc != nil {
+				start = fd.Doc.Pos()
+			}
+			n, _ := methodFromKey(g.serviceStructName+".%s", keys[0])
+			dst := path.Join(g.destPath, orphanedFolder, utils.ToLowerSnakeCase(n)+".go")
+			if err = g.CreateFolderStructure(path.Dir(dst)); err != nil {
+				return err
+			}
+			logrus.Infof("Moving `%s` to `%s`.", keys[0], dst)
+			code := fmt.Sprintf("package %s\n\n%s\n", f.Name.Name, src[fset.Position(start).Offset:fset.Position(fd.End()).Offset])
+			if err = g.writeFile(dst, code, false); err != nil {
+				return err
+			}
+		}
+	} else {
+		logrus.Infof("Deleting `%s` from `%s`.", strings.Join(g.orphanDecls, "`, `"), g.serviceFile)
+	}
+	if src, err = removeDecls(src, g.orphanDecls); err != nil {
+		return err
+	}
+	s, err := utils.GoImportsSource(g.destPath, src)
+	if err != nil {
+		return err
+	}
+	return g.writeFile(g.serviceFile, s, true)
+}
+
+func (g *generatePruneDgd) pruneFile(f prunedFile) error {
+	src, keys, err := g.parseDecls(f.path)
+	if err != nil || src == "" {
+		return err
+	}
+	decls := []string{}
+	for _, k := range keys {
+		for _, fm := range f.formats {
+			if n, ok := methodFromKey(fm, k); ok && g.orphans[n] {
+				decls = append(decls, k)
+			}
+		}
+	}
+	pruned := len(decls) > 0
+	src, err = removeDecls(src, decls)
+	if err != nil {
+		return err
+	}
+	if f.refs {
+		var found bool
+		if src, found, err = pruneRefs(src, g.orphans); err != nil {
+			return err
+		}
+		pruned = pruned || found
+	}
+	if !pruned {
+		return nil
+	}
+	// unused imports are removed.
+	s, err := utils.GoImportsSource(path.Dir(f.path), src)
+	if err != nil {
+		return err
+	}
+	return g.writeFile(f.path, s, true)
+}
+
+// parseProto returns the parsed proto file or nil if it does not exist.
+func (g *generatePruneDgd) parseProto() (*proto.Proto, error) {
+	if b, err := g.fs.Exists(g.pbFilePath); err != nil || !b {
+		return nil, err
+	}
+	src, err := g.fs.ReadFile(g.pbFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return proto.NewParser(bytes.NewReader([]byte(src))).Parse()
+}
+
+// pruneProto removes the rpc and the request/response messages of the orphaned
+// methods.
+func (g *generatePruneDgd) pruneProto() error {
+	def, err := g.parseProto()
+	if err != nil || def == nil {
+		return err
+	}
+	messages := map[string]bool{}
+	for n := range g.orphans {
+		for _, f := range g.messageFormats {
+			messages[fmt.Sprintf(f, n)] = true
+		}
+	}
+	elements := []proto.Visitee{}
+	for _, e := range def.Elements {
+		switch v := e.(type) {
+		case *proto.Message:
+			if messages[v.Name] {
+				continue
+			}
+		case *proto.Service:
+			if v.Name == utils.ToCamelCase(g.name) {
+				svcElements := []proto.Visitee{}
+				for _, se := range v.Elements {
+					if r, ok := se.(*proto.RPC); ok && g.orphans[r.Name] {
+						continue
+					}
+					svcElements = append(svcElements, se)
+				}
+				v.Elements = svcElements
+			}
+		}
+		elements = append(elements, e)
+	}
+	def.Elements = elements
+	if err = g.checkProtoChanges(g.pbFilePath, def); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	protofmt.NewFormatter(buf, "    ").Format(def)
+	return g.writeFile(g.pbFilePath, buf.String(), true)
+}
+
+// pruneOrphanFiles moves or deletes the orphaned files depending on the mode,
+// without a mode the user is only warned because the files are owned by the user.
+func (g *generatePruneDgd) pruneOrphanFiles() error {
+	for _, f := range g.orphanFiles {
+		switch g.mode {
+		case PruneOrphan:
+			dst := path.Join(path.Dir(f), orphanedFolder, path.Base(f))
+			if err := g.CreateFolderStructure(path.Dir(dst)); err != nil {
+				return err
+			}
+			logrus.Infof("Moving `%s` to `%s`.", f, dst)
+			if err := g.fs.Move(f, dst); err != nil {
+				return err
+			}
+		case PruneDelete:
+			logrus.Infof("Deleting `%s`.", f)
+			if err := g.fs.Remove(f); err != nil {
+				return err
+			}
+		default:
+			logrus.Warnf(
+				"`%s` only implements methods that were removed from `%s`, use `--prune` to move it to `%s` or `--prune=delete` to delete it.",
+				f,
+				g.interfaceName,
+				orphanedFolder,
+			)
+		}
+	}
+	return nil
+}
+
+// pruneRefs removes the uses of the orphaned methods from the declarations of
+// the go source: the struct fields, the elements of the composite literals and
+// the statements that refer to them e.x `FooEndpoint`, `makeFooHandler` or
+// `"Foo"`. It returns false if the source does not use them.
+func pruneRefs(src string, orphans map[string]bool) (string, bool, error) {
+	// fields are only matched as the names of fields e.x the handlers of
+	// grpcServer, `new` is also a builtin.
+	names, fields, lits := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for n := range orphans {
+		for _, f := range []string{"%sEndpoint", "Make%sEndpoint", "make%sHandler", "decode%sRequest", "encode%sResponse", "encode%sRequest", "decode%sResponse"} {
+			names[fmt.Sprintf(f, n)] = true
+		}
+		names[utils.ToLowerFirstCamelCase(n)+"Endpoint"] = true
+		fields[utils.ToLowerFirstCamelCase(n)] = true
+		lits[fmt.Sprintf("%q", n)] = true
+	}
+	isField := func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		return ok && (names[id.Name] || fields[id.Name])
+	}
+	refers := func(n ast.Node) bool {
+		found := false
+		ast.Inspect(n, func(c ast.Node) bool {
+			switch x := c.(type) {
+			case *ast.Ident:
+				found = found || names[x.Name]
+			case *ast.BasicLit:
+				found = found || (x.Kind == token.STRING && lits[x.Value])
+			}
+			return !found
+		})
+		return found
+	}
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return "", false, err
+	}
+	pruned := false
+	blocks := []*ast.BlockStmt{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			elts := []ast.Expr{}
+			for _, e := range x.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok && isField(kv.Key) {
+					continue
+				}
+				if !refers(e) {
+					elts = append(elts, e)
+				}
+			}
+			pruned = pruned || len(elts) != len(x.Elts)
+			x.Elts = elts
+		case *ast.StructType:
+			fields := []*ast.Field{}
+			for _, fd := range x.Fields.List {
+				if len(fd.Names) == 0 || !isField(fd.Names[0]) {
+					fields = append(fields, fd)
+				}
+			}
+			pruned = pruned || len(fields) != len(x.Fields.List)
+			x.Fields.List = fields
+		case *ast.BlockStmt:
+			blocks = append(blocks, x)
+		}
+		return true
+	})
+	// the inner blocks are pruned first so only the statements that still
+	// refer to a method once their blocks are pruned are removed.
+	for i := len(blocks) - 1; i >= 0; i-- {
+		list := []ast.Stmt{}
+		for _, st := range blocks[i].List {
+			if !refers(st) {
+				list = append(list, st)
+			}
+		}
+		pruned = pruned || len(list) != len(blocks[i].List)
+		blocks[i].List = list
+	}
+	if !pruned {
+		return src, false, nil
+	}
+	buf := new(bytes.Buffer)
+	if err = format.Node(buf, fset, f); err != nil {
+		return "", false, err
+	}
+	// the printer keeps the lines of the first elements that were removed.
+	return blockStartLines.ReplaceAllString(buf.String(), "{\n"), true, nil
+}
+
+var blockStartLines = regexp.MustCompile(`\{\n(?:[ \t]*\n)+`)
@@ -0,0 +1,244 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
+)
+
+func Test_methodFromKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "Test prefix and suffix",
+			format: "make%sHandler",
+			key:    "makeFooHandler",
+			want:   "Foo",
+			wantOk: true,
+		},
+		{
+			name:   "Test method",
+			format: "Endpoints.%s",
+			key:    "Endpoints.Foo",
+			want:   "Foo",
+			wantOk: true,
+		},
+		{
+			name:   "Test other receiver",
+			format: "Endpoints.%s",
+			key:    "loggingMiddleware.Foo",
+			wantOk: false,
+		},
+		{
+			name:   "Test empty name",
+			format: "%sReqPattern",
+			key:    "ReqPattern",
+			wantOk: false,
+		},
+		{
+			name:   "Test method of another type",
+			format: "Make%s",
+			key:    "MakeFoo.Bar",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := methodFromKey(tt.format, tt.key)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("methodFromKey() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_pruneRefs(t *testing.T) {
+	src := `package grpc
+
+type grpcServer struct {
+	foo grpc.Handler
+	bar grpc.Handler
+}
+
+func NewGRPCServer(endpoints endpoint.Endpoints, options map[string][]grpc.ServerOption) pb.HelloServer {
+	return &grpcServer{
+		bar: makeBarHandler(endpoints, options["Bar"]),
+		foo: makeFooHandler(endpoints, options["Foo"]),
+	}
+}
+
+func New(s service.HelloService, mdw map[string][]endpoint.Middleware) Endpoints {
+	eps := Endpoints{
+		BarEndpoint: MakeBarEndpoint(s),
+		FooEndpoint: MakeFooEndpoint(s),
+	}
+	for _, m := range mdw["Foo"] {
+		eps.FooEndpoint = m(eps.FooEndpoint)
+	}
+	for _, m := range mdw["Bar"] {
+		eps.BarEndpoint = m(eps.BarEndpoint)
+	}
+	methods := []string{"Foo", "Bar"}
+	if len(methods) > 0 {
+		makeFooHandler(m, endpoints, options["Foo"])
+	}
+	b := new(bytes.Buffer)
+	return eps
+}
+`
+	want := `package grpc
+
+type grpcServer struct {
+	foo grpc.Handler
+}
+
+func NewGRPCServer(endpoints endpoint.Endpoints, options map[string][]grpc.ServerOption) pb.HelloServer {
+	return &grpcServer{
+		foo: makeFooHandler(endpoints, options["Foo"]),
+	}
+}
+
+func New(s service.HelloService, mdw map[string][]endpoint.Middleware) Endpoints {
+	eps := Endpoints{
+		FooEndpoint: MakeFooEndpoint(s),
+	}
+	for _, m := range mdw["Foo"] {
+		eps.FooEndpoint = m(eps.FooEndpoint)
+	}
+
+	methods := []string{"Foo"}
+	if len(methods) > 0 {
+		makeFooHandler(m, endpoints, options["Foo"])
+	}
+	b := new(bytes.Buffer)
+	return eps
+}
+`
+	got, pruned, err := pruneRefs(src, map[string]bool{"Bar": true, "New": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pruned || got != want {
+		t.Errorf("pruneRefs() = %v, %s, want true, %s", pruned, got, want)
+	}
+	if _, pruned, _ := pruneRefs(src, map[string]bool{"Baz": true}); pruned {
+		t.Error("pruneRefs() pruned the uses of a method the source does not use")
+	}
+}
+
+func TestGeneratePrune_Generate(t *testing.T) {
+	setDefaults()
+	files := map[string]string{
+		"hello/client/http/http.go": `package http
+
+import (
+	"context"
+	http1 "net/http"
+
+	endpoint "github.com/go-kit/kit/endpoint"
+)
+
+func New(instance string, options map[string][]http.ClientOption) (service.HelloService, error) {
+	var fooEndpoint endpoint.Endpoint
+	{
+		fooEndpoint = http.NewClient("POST", u, encodeHTTPGenericRequest, decodeFooResponse, options["Foo"]...).Endpoint()
+	}
+	var barEndpoint endpoint.Endpoint
+	{
+		barEndpoint = http.NewClient("POST", u, encodeHTTPGenericRequest, decodeBarResponse, options["Bar"]...).Endpoint()
+	}
+	return endpoint1.Endpoints{
+		BarEndpoint: barEndpoint,
+		FooEndpoint: fooEndpoint,
+	}, nil
+}
+
+// decodeFooResponse decodes the Foo response.
+func decodeFooResponse(_ context.Context, r *http1.Response) (interface{}, error) {
+	return nil, nil
+}
+
+// decodeBarResponse decodes the Bar response.
+func decodeBarResponse(_ context.Context, r *http1.Response) (interface{}, error) {
+	return nil, nil
+}
+`,
+		"hello/client/grpc/grpc.go": `package grpc
+
+import "context"
+
+// encodeBarRequest encodes the Bar request.
+func encodeBarRequest(_ context.Context, request interface{}) (interface{}, error) {
+	return nil, nil
+}
+`,
+		"hello/pkg/service/service.go": `package service
+
+import "context"
+
+type HelloService interface {
+	Foo(ctx context.Context) error
+}
+
+type basicHelloService struct{}
+
+func (b *basicHelloService) Foo(ctx context.Context) error {
+	return b.check()
+}
+
+// Bar says bar.
+func (b *basicHelloService) Bar(ctx context.Context) error {
+	return nil
+}
+
+func (b *basicHelloService) check() error {
+	return nil
+}
+`,
+	}
+	g := newGeneratePrune(
+		"hello",
+		"basicHelloService",
+		parser.Interface{Methods: []parser.Method{{Name: "Foo"}}},
+		PruneOrphan,
+	).(*generatePruneDgd)
+	g.fs = &fs.KitFs{Fs: afero.NewMemMapFs()}
+	for p, src := range files {
+		afero.WriteFile(g.fs.Fs, p, []byte(src), 0644)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for p := range files {
+		src, err := g.fs.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(src, "Bar") || strings.Contains(src, "bar") {
+			t.Errorf("generatePrune.Generate() left the removed method in %s:\n%s", p, src)
+		}
+	}
+	for _, want := range []string{"decodeFooResponse", "FooEndpoint: fooEndpoint", "func (b *basicHelloService) check() error"} {
+		found := false
+		for p := range files {
+			src, _ := g.fs.ReadFile(p)
+			found = found || strings.Contains(src, want)
+		}
+		if !found {
+			t.Errorf("generatePrune.Generate() removed `%s`", want)
+		}
+	}
+	// the implementation of the removed method is moved to the orphaned folder.
+	src, err := g.fs.ReadFile("hello/pkg/service/_orphaned/bar.go")
+	if err != nil || !strings.Contains(src, "// Bar says bar.\nfunc (b *basicHelloService) Bar(") {
+		t.Errorf("generatePrune.Generate() did not move Bar to the orphaned folder: %v\n%s", err, src)
+	}
+}
@@ -0,0 +1,795 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	ps "go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// methodNameFormats are the names of the symbols the generators derive from a
+// method name.
+var methodNameFormats = []string{
+	"%sEndpoint",
+	"Make%sEndpoint",
+	"make%sHandler",
+	"decode%sRequest",
+	"encode%sResponse",
+	"decode%sResponse",
+	"encode%sRequest",
+	dgd_router_map_pattern_format,
+	dgd_req_data_proto_format,
+	dgd_rsp_data_proto_format,
+}
+
+// methodIdents returns the identifiers the generators derive from a method
+// name, the method name itself is not one of them.
+func methodIdents(method string) (idents []string) {
+	for _, f := range methodNameFormats {
+		idents = append(idents, fmt.Sprintf(f, method))
+	}
+	// the client endpoint variables.
+	return append(idents, utils.ToLowerFirstCamelCase(method)+"Endpoint")
+}
+
+// routerPath returns the http path of a method.
+func routerPath(method string) string {
+	return "/" + strings.Replace(utils.ToLowerSnakeCase(method), "_", "-", -1)
+}
+
+// methodRename has the names changed by the rename of a service method. The
+// method name (and the grpc handler field named after it) is only renamed
+// where it resolves to the service: the interface, the methods of the types
+// that implement it and the selectors on values of these types.
+type methodRename struct {
+	method, newName string
+	field, newField string
+	iface           string
+	idents          map[string]string
+	// the types that implement the service e.x the service struct, the
+	// middlewares, `Endpoints` and `grpcServer`.
+	types map[string]bool
+	// the types of the struct fields and of the function results of the
+	// sources, by type name and by qualified function name.
+	fields  map[string]map[string]string
+	results map[string]string
+}
+
+// newMethodRename returns the rename of the method `method` of the service
+// interface `iface`, `srcs` are the sources of the service packages.
+func newMethodRename(iface, method, newName string, srcs []string) *methodRename {
+	rn := &methodRename{
+		method:   method,
+		newName:  newName,
+		field:    utils.ToLowerFirstCamelCase(method),
+		newField: utils.ToLowerFirstCamelCase(newName),
+		iface:    iface,
+		idents:   map[string]string{},
+		types:    map[string]bool{iface: true, "Endpoints": true, "grpcServer": true},
+		fields:   map[string]map[string]string{},
+		results:  map[string]string{},
+	}
+	oldIdents, newIdents := methodIdents(method), methodIdents(newName)
+	for i := range oldIdents {
+		rn.idents[oldIdents[i]] = newIdents[i]
+	}
+	ifaceMethods := []string{}
+	methods := map[string]map[string]bool{}
+	for _, src := range srcs {
+		f, err := ps.ParseFile(token.NewFileSet(), "", src, 0)
+		if err != nil {
+			continue
+		}
+		for _, d := range f.Decls {
+			switch v := d.(type) {
+			case *ast.FuncDecl:
+				if v.Recv != nil && len(v.Recv.List) > 0 {
+					t := typeName(v.Recv.List[0].Type)
+					if methods[t] == nil {
+						methods[t] = map[string]bool{}
+					}
+					methods[t][v.Name.Name] = true
+				} else if v.Type.Results != nil && len(v.Type.Results.List) > 0 {
+					rn.results[f.Name.Name+"."+v.Name.Name] = typeName(v.Type.Results.List[0].Type)
+				}
+			case *ast.GenDecl:
+				for _, sp := range v.Specs {
+					ts, ok := sp.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					switch t := ts.Type.(type) {
+					case *ast.StructType:
+						rn.fields[ts.Name.Name] = map[string]string{}
+						for _, fd := range t.Fields.List {
+							for _, n := range fd.Names {
+								rn.fields[ts.Name.Name][n.Name] = typeName(fd.Type)
+							}
+							if len(fd.Names) == 0 {
+								rn.fields[ts.Name.Name][typeName(fd.Type)] = typeName(fd.Type)
+							}
+						}
+					case *ast.InterfaceType:
+						if ts.Name.Name != iface {
+							continue
+						}
+						for _, fd := range t.Methods.List {
+							for _, n := range fd.Names {
+								ifaceMethods = append(ifaceMethods, n.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for t, ms := range methods {
+		implements := len(ifaceMethods) > 0
+		for _, m := range ifaceMethods {
+			implements = implements && ms[m]
+		}
+		if implements {
+			rn.types[t] = true
+		}
+	}
+	return rn
+}
+
+// typeName returns the name of the type without its package and pointer e.x
+// `HelloService` for `*service.HelloService`, empty for the other types.
+func typeName(e ast.Expr) string {
+	switch v := e.(type) {
+	case *ast.Ident:
+		return v.Name
+	case *ast.StarExpr:
+		return typeName(v.X)
+	case *ast.SelectorExpr:
+		return v.Sel.Name
+	case *ast.ParenExpr:
+		return typeName(v.X)
+	}
+	return ""
+}
+
+// valueType returns the name of the type of the value of the expression `e` of
+// the package `pkg`, the variables have the types of `vars`.
+func (rn *methodRename) valueType(pkg string, e ast.Expr, vars map[string]string) string {
+	switch v := e.(type) {
+	case *ast.Ident:
+		return vars[v.Name]
+	case *ast.ParenExpr:
+		return rn.valueType(pkg, v.X, vars)
+	case *ast.StarExpr:
+		return rn.valueType(pkg, v.X, vars)
+	case *ast.UnaryExpr:
+		return rn.valueType(pkg, v.X, vars)
+	case *ast.CompositeLit:
+		return typeName(v.Type)
+	case *ast.SelectorExpr:
+		return rn.fields[rn.valueType(pkg, v.X, vars)][v.Sel.Name]
+	case *ast.CallExpr:
+		switch fn := v.Fun.(type) {
+		case *ast.Ident:
+			return rn.results[pkg+"."+fn.Name]
+		case *ast.SelectorExpr:
+			if x, ok := fn.X.(*ast.Ident); ok {
+				return rn.results[x.Name+"."+fn.Sel.Name]
+			}
+		}
+	}
+	return ""
+}
+
+// funcVars returns the types of the receiver, the parameters, the results and
+// the variables declared in the function.
+func (rn *methodRename) funcVars(pkg string, fd *ast.FuncDecl) map[string]string {
+	vars := map[string]string{}
+	addFields := func(l *ast.FieldList) {
+		if l == nil {
+			return
+		}
+		for _, f := range l.List {
+			for _, n := range f.Names {
+				vars[n.Name] = typeName(f.Type)
+			}
+		}
+	}
+	addFields(fd.Recv)
+	addFields(fd.Type.Params)
+	addFields(fd.Type.Results)
+	if fd.Body == nil {
+		return vars
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.FuncLit:
+			addFields(v.Type.Params)
+			addFields(v.Type.Results)
+		case *ast.ValueSpec:
+			for i, id := range v.Names {
+				if v.Type != nil {
+					vars[id.Name] = typeName(v.Type)
+				} else if i < len(v.Values) {
+					vars[id.Name] = rn.valueType(pkg, v.Values[i], vars)
+				}
+			}
+		case *ast.AssignStmt:
+			if v.Tok != token.DEFINE || len(v.Lhs) != len(v.Rhs) {
+				return true
+			}
+			for i, l := range v.Lhs {
+				if id, ok := l.(*ast.Ident); ok {
+					vars[id.Name] = rn.valueType(pkg, v.Rhs[i], vars)
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// refactorMethodDgd has what the method refactorings have in common.
+type refactorMethodDgd struct {
+	BaseGenerator
+	name              string
+	method            string
+	interfaceName     string
+	serviceStructName string
+	destPath          string
+	filePath          string
+	pbFilePath        string
+	src               string
+	file              *parser.File
+	serviceInterface  parser.Interface
+}
+
+func (r *refactorMethodDgd) init(name, method string) {
+	snakeName := utils.ToLowerSnakeCase2(name)
+	r.name = name
+	r.method = method
+	r.interfaceName = utils.ToCamelCase(name + "Service")
+	r.serviceStructName = utils.ToLowerFirstCamelCase(viper.GetString("gk_service_struct_prefix") + "-" + r.interfaceName)
+	r.destPath = fmt.Sprintf(viper.GetString("gk_service_path_format"), snakeName)
+	r.filePath = path.Join(r.destPath, viper.GetString("gk_service_file_name"))
+	r.pbFilePath = path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), snakeName),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), snakeName),
+	)
+	r.srcFile = jen.NewFilePath(r.destPath)
+	r.InitPg()
+	r.fs = fs.Get()
+}
+
+// readService reads the service and makes sure the method is part of it.
+func (r *refactorMethodDgd) readService() (err error) {
+	if b, err := r.fs.Exists(r.filePath); err != nil {
+		return err
+	} else if !b {
+		return fmt.Errorf("service %s was not found", r.name)
+	}
+	r.src, err = r.fs.ReadFile(r.filePath)
+	if err != nil {
+		return err
+	}
+	r.file, err = parser.NewFileParser().Parse([]byte(r.src))
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, v := range r.file.Interfaces {
+		if v.Name == r.interfaceName {
+			r.serviceInterface = v
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("could not find the service interface in `%s`", r.name)
+	}
+	if !r.hasMethod(r.method) {
+		for _, m := range r.ExpandInterface(r.serviceInterface, r.destPath).Methods {
+			if m.Name == r.method {
+				return fmt.Errorf("the method `%s` is declared in an interface embedded in `%s`, refactor that interface instead", r.method, r.interfaceName)
+			}
+		}
+		return fmt.Errorf("the service `%s` has no method `%s`", r.interfaceName, r.method)
+	}
+	for _, m := range r.serviceInterface.Methods {
+		if m.Name == r.method && !dgdMethod(m) {
+			return fmt.Errorf(
+				"the method `%s` of `%s` is not a dgd method, the refactorings are only supported with --dgd services",
+				r.method, r.interfaceName,
+			)
+		}
+	}
+	return nil
+}
+
+// dgdMethod returns true if the method has the signature of the dgd template
+// `Foo(ctx context.Context, req_pb pb.FooReq, rsp_pb *pb.FooRsp) (errcode int32)`
+// or the one that is completed to it `Foo(ctx context.Context) (errcode int32)`.
+func dgdMethod(m parser.Method) bool {
+	if len(m.Results) != 1 || m.Results[0].Type != "int32" ||
+		len(m.Parameters) == 0 || m.Parameters[0].Type != "context.Context" {
+		return false
+	}
+	switch len(m.Parameters) {
+	case 1:
+		return true
+	case 3:
+		return m.Parameters[1].Type == fmt.Sprintf("pb."+dgd_req_struct_format, m.Name) &&
+			m.Parameters[2].Type == fmt.Sprintf("*pb."+dgd_rsp_struct_format, m.Name)
+	}
+	return false
+}
+
+func (r *refactorMethodDgd) hasMethod(name string) bool {
+	for _, m := range r.serviceInterface.Methods {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// goFiles returns the go files of the service folders, the protoc output is
+// left out because it is compiled again.
+func (r *refactorMethodDgd) goFiles() (files []string, err error) {
+	snakeName := utils.ToLowerSnakeCase2(r.name)
+	for _, k := range []string{
+		"gk_service_path_format",
+		"gk_endpoint_path_format",
+		"gk_http_path_format",
+		"gk_grpc_path_format",
+		"gk_gdg_conf_path_format",
+		"gk_cmd_service_path_format",
+		"gk_http_client_path_format",
+		"gk_grpc_client_path_format",
+	} {
+		dir := fmt.Sprintf(viper.GetString(k), snakeName)
+		if b, err := r.fs.Exists(dir); err != nil {
+			return nil, err
+		} else if !b {
+			continue
+		}
+		infos, err := afero.ReadDir(r.fs.Fs, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+				files = append(files, path.Join(dir, info.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// writeSource formats the source, removes the imports that are not used anymore
+// and writes it if it changed.
+func (r *refactorMethodDgd) writeSource(filePath, old, src string) error {
+	if src == old {
+		return nil
+	}
+	s, err := utils.GoImportsSource(path.Dir(filePath), src)
+	if err != nil {
+		return err
+	}
+	return r.writeFile(filePath, s, true)
+}
+
+// readProto returns the parsed proto file or nil if it does not exist.
+func (r *refactorMethodDgd) readProto() (*proto.Proto, error) {
+	if b, err := r.fs.Exists(r.pbFilePath); err != nil || !b {
+		return nil, err
+	}
+	src, err := r.fs.ReadFile(r.pbFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return proto.NewParser(bytes.NewReader([]byte(src))).Parse()
+}
+
+func (r *refactorMethodDgd) writeProto(def *proto.Proto) error {
+	if err := r.checkProtoChanges(r.pbFilePath, def); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	protofmt.NewFormatter(buf, "    ").Format(def)
+	if err := r.writeFile(r.pbFilePath, buf.String(), true); err != nil {
+		return err
+	}
+	pc, err := loadProtocConfig()
+	if err != nil {
+		return err
+	}
+	return pc.compile(r.pbFilePath)
+}
+
+// RenameMethodDgd implements Gen and is used to rename a service method and
+// all the code generated for it.
+type RenameMethodDgd struct {
+	refactorMethodDgd
+	newName string
+}
+
+// NewRenameMethodDgd returns a initialized and ready generator.
+func NewRenameMethodDgd(name, method, newName string) Gen {
+	r := &RenameMethodDgd{
+		newName: newName,
+	}
+	r.init(name, method)
+	r.generator = "RenameMethodDgd"
+	return r
+}
+
+// Generate renames the method.
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (r *RenameMethodDgd) Generate() error {
+	return r.generateVerified(r.generate)
+}
+
+func (r *RenameMethodDgd) generate() (err error) {
+	if !token.IsExported(r.newName) {
+		return fmt.Errorf("the method name `%s` is not valid, it has to be exported", r.newName)
+	}
+	if err = r.readService(); err != nil {
+		return err
+	}
+	if r.hasMethod(r.newName) {
+		return fmt.Errorf("the service `%s` already has a method `%s`", r.interfaceName, r.newName)
+	}
+	logrus.Infof("Renaming `%s` to `%s`.", r.method, r.newName)
+	files, err := r.goFiles()
+	if err != nil {
+		return err
+	}
+	srcs := make([]string, len(files))
+	for i, f := range files {
+		if srcs[i], err = r.fs.ReadFile(f); err != nil {
+			return err
+		}
+	}
+	rn := newMethodRename(r.interfaceName, r.method, r.newName, srcs)
+	for i, f := range files {
+		src, err := rn.rename(srcs[i], r.generatedFile(f))
+		if err != nil {
+			return fmt.Errorf("could not rename `%s` in `%s`: %s", r.method, f, err)
+		}
+		if err = r.writeSource(f, srcs[i], src); err != nil {
+			return err
+		}
+	}
+	snakeName := utils.ToLowerSnakeCase2(r.name)
+	handlerFile := fmt.Sprintf(viper.GetString("gk_gdg_handler_file_name"), snakeName, utils.ToLowerSnakeCase(r.method))
+	if b, err := r.fs.Exists(handlerFile); err != nil {
+		return err
+	} else if b {
+		newHandlerFile := fmt.Sprintf(viper.GetString("gk_gdg_handler_file_name"), snakeName, utils.ToLowerSnakeCase(r.newName))
+		logrus.Infof("Moving `%s` to `%s`.", handlerFile, newHandlerFile)
+		if err = r.fs.Move(handlerFile, newHandlerFile); err != nil {
+			return err
+		}
+	}
+	return r.renameProto()
+}
+
+// renameProto renames the rpc and the request/response messages.
+func (r *RenameMethodDgd) renameProto() error {
+	def, err := r.readProto()
+	if err != nil || def == nil {
+		return err
+	}
+	names := map[string]string{
+		fmt.Sprintf(dgd_req_data_proto_format, r.method): fmt.Sprintf(dgd_req_data_proto_format, r.newName),
+		fmt.Sprintf(dgd_rsp_data_proto_format, r.method): fmt.Sprintf(dgd_rsp_data_proto_format, r.newName),
+	}
+	for _, e := range def.Elements {
+		switch v := e.(type) {
+		case *proto.Message:
+			if n, ok := names[v.Name]; ok {
+				v.Name = n
+				renameComment(v.Comment, r.method, r.newName)
+			}
+		case *proto.Service:
+			if v.Name != utils.ToCamelCase(r.name) {
+				continue
+			}
+			for _, se := range v.Elements {
+				if rpc, ok := se.(*proto.RPC); ok && rpc.Name == r.method {
+					rpc.Name = r.newName
+					if n, ok := names[rpc.RequestType]; ok {
+						rpc.RequestType = n
+					}
+					if n, ok := names[rpc.ReturnsType]; ok {
+						rpc.ReturnsType = n
+					}
+					renameComment(rpc.Comment, r.method, r.newName)
+				}
+			}
+		}
+	}
+	return r.writeProto(def)
+}
+
+// renameComment renames the whole word `old` in the comment lines.
+func renameComment(c *proto.Comment, old, new string) {
+	if c == nil {
+		return
+	}
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(old) + `\b`)
+	for i, l := range c.Lines {
+		c.Lines[i] = re.ReplaceAllString(l, new)
+	}
+}
+
+// RemoveMethodDgd implements Gen and is used to remove a service method and
+// all the code generated for it.
+type RemoveMethodDgd struct {
+	refactorMethodDgd
+	mode string
+}
+
+// NewRemoveMethodDgd returns a initialized and ready generator, `mode` is the
+// prune mode used for the method handler file.
+func NewRemoveMethodDgd(name, method, mode string) Gen {
+	r := &RemoveMethodDgd{
+		mode: mode,
+	}
+	r.init(name, method)
+	r.generator = "RemoveMethodDgd"
+	return r
+}
+
+// Generate removes the method.
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (r *RemoveMethodDgd) Generate() error {
+	return r.generateVerified(r.generate)
+}
+
+func (r *RemoveMethodDgd) generate() (err error) {
+	if err = r.readService(); err != nil {
+		return err
+	}
+	logrus.Infof("Removing `%s` from `%s`.", r.method, r.interfaceName)
+	src, err := removeInterfaceMethod(r.src, r.interfaceName, r.method)
+	if err != nil {
+		return err
+	}
+	if err = r.writeSource(r.filePath, r.src, src); err != nil {
+		return err
+	}
+	methods := []parser.Method{}
+	for _, m := range r.serviceInterface.Methods {
+		if m.Name != r.method {
+			methods = append(methods, m)
+		}
+	}
+	r.serviceInterface.Methods = methods
+	// the prune removes the handler file and the uses of the method from
+	// the files that are generated in one piece.
+	// the methods of the embedded interfaces are still part of the service.
+	iface := r.ExpandInterface(r.serviceInterface, r.destPath)
+	return newGeneratePruneDgd(r.name, r.serviceStructName, iface, r.mode).Generate()
+}
+
+// generatedFile returns true for the files that kit generates in one piece,
+// the `_gen` files and the client.
+func (r *refactorMethodDgd) generatedFile(f string) bool {
+	snakeName := utils.ToLowerSnakeCase2(r.name)
+	dir := path.Dir(f)
+	return strings.HasSuffix(f, "_gen.go") ||
+		dir == fmt.Sprintf(viper.GetString("gk_http_client_path_format"), snakeName) ||
+		dir == fmt.Sprintf(viper.GetString("gk_grpc_client_path_format"), snakeName)
+}
+
+// rename renames the method in the source, the method name string literals
+// are only renamed in the `generated` files. The doc comments of the renamed
+// declarations are updated as well.
+func (rn *methodRename) rename(src string, generated bool) (string, error) {
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	renamed := map[*ast.Ident]string{}
+	renameSelectors := func(n ast.Node, vars map[string]string) {
+		ast.Inspect(n, func(c ast.Node) bool {
+			if se, ok := c.(*ast.SelectorExpr); ok && rn.types[rn.valueType(f.Name.Name, se.X, vars)] {
+				switch se.Sel.Name {
+				case rn.method:
+					renamed[se.Sel] = rn.newName
+				case rn.field:
+					renamed[se.Sel] = rn.newField
+				}
+			}
+			return true
+		})
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.Ident:
+			if s, ok := rn.idents[v.Name]; ok {
+				renamed[v] = s
+			}
+		case *ast.FuncDecl:
+			if v.Recv != nil && len(v.Recv.List) > 0 && rn.types[typeName(v.Recv.List[0].Type)] && v.Name.Name == rn.method {
+				renamed[v.Name] = rn.newName
+			}
+			renameSelectors(v, rn.funcVars(f.Name.Name, v))
+		case *ast.TypeSpec:
+			switch t := v.Type.(type) {
+			case *ast.InterfaceType:
+				if v.Name.Name != rn.iface {
+					return true
+				}
+				for _, fd := range t.Methods.List {
+					for _, id := range fd.Names {
+						if id.Name == rn.method {
+							renamed[id] = rn.newName
+						}
+					}
+				}
+			case *ast.StructType:
+				if !rn.types[v.Name.Name] {
+					return true
+				}
+				for _, fd := range t.Fields.List {
+					for _, id := range fd.Names {
+						if id.Name == rn.field {
+							renamed[id] = rn.newField
+						}
+					}
+				}
+			}
+		case *ast.CompositeLit:
+			if !rn.types[typeName(v.Type)] {
+				return true
+			}
+			for _, e := range v.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok {
+					if id, ok := kv.Key.(*ast.Ident); ok && id.Name == rn.field {
+						renamed[id] = rn.newField
+					}
+				}
+			}
+		}
+		return true
+	})
+	changed := len(renamed) > 0
+	pattern := fmt.Sprintf(dgd_router_map_pattern_format, rn.method)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.ValueSpec:
+			// the router path of the method.
+			for i, id := range v.Names {
+				if id.Name != pattern || i >= len(v.Values) {
+					continue
+				}
+				if l, ok := v.Values[i].(*ast.BasicLit); ok && l.Value == strconv.Quote(routerPath(rn.method)) {
+					l.Value = strconv.Quote(routerPath(rn.newName))
+					changed = true
+				}
+			}
+		case *ast.BasicLit:
+			if generated && v.Kind == token.STRING && v.Value == strconv.Quote(rn.method) {
+				v.Value = strconv.Quote(rn.newName)
+				changed = true
+			}
+		}
+		return true
+	})
+	if !changed {
+		return src, nil
+	}
+	docs := []*ast.CommentGroup{}
+	isRenamed := func(doc *ast.CommentGroup, names ...*ast.Ident) {
+		for _, id := range names {
+			if _, ok := renamed[id]; ok && doc != nil {
+				docs = append(docs, doc)
+				return
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.FuncDecl:
+			isRenamed(v.Doc, v.Name)
+		case *ast.GenDecl:
+			for _, sp := range v.Specs {
+				switch s := sp.(type) {
+				case *ast.TypeSpec:
+					isRenamed(v.Doc, s.Name)
+				case *ast.ValueSpec:
+					isRenamed(v.Doc, s.Names...)
+				}
+			}
+		case *ast.Field:
+			isRenamed(v.Doc, v.Names...)
+		}
+		return true
+	})
+	for id, s := range renamed {
+		id.Name = s
+	}
+	names := map[string]string{rn.method: rn.newName}
+	for o, s := range rn.idents {
+		names[o] = s
+	}
+	for _, d := range docs {
+		for _, c := range d.List {
+			for o, s := range names {
+				re := regexp.MustCompile(`\b` + regexp.QuoteMeta(o) + `\b`)
+				c.Text = re.ReplaceAllString(c.Text, s)
+			}
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err = format.Node(buf, fset, f); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// removeInterfaceMethod removes the method (and its comments) from the interface.
+func removeInterfaceMethod(src, iface, method string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	var removed *ast.Field
+	ast.Inspect(f, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != iface {
+			return true
+		}
+		it, ok := ts.Type.(*ast.InterfaceType)
+		if !ok {
+			return false
+		}
+		fields := []*ast.Field{}
+		for _, fd := range it.Methods.List {
+			if len(fd.Names) == 1 && fd.Names[0].Name == method {
+				removed = fd
+				continue
+			}
+			fields = append(fields, fd)
+		}
+		it.Methods.List = fields
+		return false
+	})
+	if removed == nil {
+		return "", errors.New("method not found in the service interface")
+	}
+	comments := []*ast.CommentGroup{}
+	for _, c := range f.Comments {
+		if c != removed.Doc && c != removed.Comment {
+			comments = append(comments, c)
+		}
+	}
+	f.Comments = comments
+	buf := new(bytes.Buffer)
+	if err = format.Node(buf, fset, f); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,249 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/hms58/genkit/parser"
+)
+
+func Test_methodRename_rename(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		generated bool
+		want      string
+	}{
+		{
+			name: "Test service interface and middleware",
+			src: `package service
+
+// HelloService describes the service.
+type HelloService interface {
+	// Get gets.
+	Get(ctx context.Context) (errcode int32)
+	Put(ctx context.Context) (errcode int32)
+}
+
+type loggingMiddleware struct {
+	logger log.Logger
+	next   HelloService
+}
+
+// Get logs the method call.
+func (l loggingMiddleware) Get(ctx context.Context) (errcode int32) {
+	return l.next.Get(ctx)
+}
+
+func (l loggingMiddleware) Put(ctx context.Context) (errcode int32) {
+	return l.next.Put(ctx)
+}
+
+type cache struct{}
+
+// Get is not a service method.
+func (c cache) Get(key string) string {
+	return new(cache).Get(key)
+}
+`,
+			want: `package service
+
+// HelloService describes the service.
+type HelloService interface {
+	// Fetch gets.
+	Fetch(ctx context.Context) (errcode int32)
+	Put(ctx context.Context) (errcode int32)
+}
+
+type loggingMiddleware struct {
+	logger log.Logger
+	next   HelloService
+}
+
+// Fetch logs the method call.
+func (l loggingMiddleware) Fetch(ctx context.Context) (errcode int32) {
+	return l.next.Fetch(ctx)
+}
+
+func (l loggingMiddleware) Put(ctx context.Context) (errcode int32) {
+	return l.next.Put(ctx)
+}
+
+type cache struct{}
+
+// Get is not a service method.
+func (c cache) Get(key string) string {
+	return new(cache).Get(key)
+}
+`,
+		},
+		{
+			name: "Test endpoint with unrelated calls",
+			src: `package endpoint
+
+// MakeGetEndpoint returns an endpoint that invokes Get on the service.
+func MakeGetEndpoint(s service.HelloService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		// keep this Get comment
+		r := request.(*http.Request)
+		id := r.URL.Query().Get("id")
+		errcode := s.Get(ctx, request.(pb.GetReq), &pb.GetRsp{})
+		return errcode, nil
+	}
+}
+
+var m = map[string]string{"Get": "/get", "Gets": "/gets"}
+`,
+			want: `package endpoint
+
+// MakeFetchEndpoint returns an endpoint that invokes Fetch on the service.
+func MakeFetchEndpoint(s service.HelloService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		// keep this Get comment
+		r := request.(*http.Request)
+		id := r.URL.Query().Get("id")
+		errcode := s.Fetch(ctx, request.(pb.FetchReq), &pb.FetchRsp{})
+		return errcode, nil
+	}
+}
+
+var m = map[string]string{"Get": "/get", "Gets": "/gets"}
+`,
+		},
+		{
+			name: "Test generated grpc handler",
+			src: `package grpc
+
+type grpcServer struct {
+	get grpc.Handler
+}
+
+func NewGRPCServer(endpoints endpoint.Endpoints, options map[string][]grpc.ServerOption) pb.HelloServer {
+	return &grpcServer{get: makeGetHandler(endpoints, options["Get"])}
+}
+
+func (g *grpcServer) Get(ctx context.Context, req *pb.GetReq) (*pb.GetRsp, error) {
+	_, rep, err := g.get.ServeGRPC(ctx, req)
+	return rep.(*pb.GetRsp), err
+}
+`,
+			generated: true,
+			want: `package grpc
+
+type grpcServer struct {
+	fetch grpc.Handler
+}
+
+func NewGRPCServer(endpoints endpoint.Endpoints, options map[string][]grpc.ServerOption) pb.HelloServer {
+	return &grpcServer{fetch: makeFetchHandler(endpoints, options["Fetch"])}
+}
+
+func (g *grpcServer) Fetch(ctx context.Context, req *pb.FetchReq) (*pb.FetchRsp, error) {
+	_, rep, err := g.fetch.ServeGRPC(ctx, req)
+	return rep.(*pb.FetchRsp), err
+}
+`,
+		},
+		{
+			name: "Test router path",
+			src: `package conf
+
+var GetReqPattern = "/get"
+
+var Other = "/get"
+`,
+			want: `package conf
+
+var FetchReqPattern = "/fetch"
+
+var Other = "/get"
+`,
+		},
+	}
+	srcs := []string{}
+	for _, tt := range tests {
+		srcs = append(srcs, tt.src)
+	}
+	rn := newMethodRename("HelloService", "Get", "Fetch", srcs)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rn.rename(tt.src, tt.generated)
+			if err != nil {
+				t.Fatalf("rename() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("rename() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_removeInterfaceMethod(t *testing.T) {
+	src := `package service
+
+// HelloService describes the service.
+type HelloService interface {
+	// Foo does foo.
+	Foo(ctx context.Context) (errcode int32)
+	Bar(ctx context.Context) (errcode int32)
+}
+`
+	want := `package service
+
+// HelloService describes the service.
+type HelloService interface {
+	Bar(ctx context.Context) (errcode int32)
+}
+`
+	got, err := removeInterfaceMethod(src, "HelloService", "Foo")
+	if err != nil {
+		t.Fatalf("removeInterfaceMethod() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("removeInterfaceMethod() = %q, want %q", got, want)
+	}
+	if _, err = removeInterfaceMethod(src, "HelloService", "Baz"); err == nil {
+		t.Error("removeInterfaceMethod() expected an error for a missing method")
+	}
+}
+
+func Test_dgdMethod(t *testing.T) {
+	ctx := parser.NamedTypeValue{Name: "ctx", Type: "context.Context"}
+	errcode := []parser.NamedTypeValue{{Name: "errcode", Type: "int32"}}
+	tests := []struct {
+		name string
+		m    parser.Method
+		want bool
+	}{
+		{
+			name: "dgd method",
+			m: parser.Method{Name: "Foo", Parameters: []parser.NamedTypeValue{
+				ctx, {Name: "req_pb", Type: "pb.FooReq"}, {Name: "rsp_pb", Type: "*pb.FooRsp"},
+			}, Results: errcode},
+			want: true,
+		},
+		{
+			name: "dgd method to complete",
+			m:    parser.Method{Name: "Foo", Parameters: []parser.NamedTypeValue{ctx}, Results: errcode},
+			want: true,
+		},
+		{
+			name: "messages of another method",
+			m: parser.Method{Name: "Foo", Parameters: []parser.NamedTypeValue{
+				ctx, {Name: "req_pb", Type: "pb.BarReq"}, {Name: "rsp_pb", Type: "*pb.BarRsp"},
+			}, Results: errcode},
+		},
+		{
+			name: "standard method",
+			m: parser.Method{Name: "Foo", Parameters: []parser.NamedTypeValue{
+				ctx, {Name: "s", Type: "string"},
+			}, Results: []parser.NamedTypeValue{{Name: "rs", Type: "string"}, {Name: "err", Type: "error"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dgdMethod(tt.m); got != tt.want {
+				t.Errorf("dgdMethod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -35,8 +35,11 @@ func RemoveBadMethods(serviceInterface parser.Interface, srcPtr *string) []parse
 		req_pb := fmt.Sprintf("pb.%sReq", v.Name)
 		rsp_pb := fmt.Sprintf("*pb.%sRsp", v.Name)
 
+		// the methods of embedded interfaces are not in the source, they are
+		// not completed.
 		if len(v.Parameters) == 1 && v.Parameters[0].Type == "context.Context" &&
-			len(v.Results) == 1 && v.Results[0].Type == "int32" && srcPtr != nil {
+			len(v.Results) == 1 && v.Results[0].Type == "int32" && srcPtr != nil &&
+			strings.Contains(*srcPtr, fmt.Sprintf("%s(%s %s)", v.Name, v.Parameters[0].Name, v.Parameters[0].Type)) {
 			v.Parameters = append(v.Parameters, parser.NamedTypeValue{
 				Name: "req_pb",
 				Type: req_pb,
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"go/ast"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+)
+
+// skipMethods returns the methods that do not skip `target` with a `kit:skip`
+// directive (see parser.SkipHTTP).
+func skipMethods(methods []parser.Method, target string) []parser.Method {
+	keep := []parser.Method{}
+	for _, m := range methods {
+		if m.Directives.Skips(target) {
+			logrus.Debugf("The method `%s` skips `%s`.", m.Name, target)
+			continue
+		}
+		keep = append(keep, m)
+	}
+	return keep
+}
+
+// unaryMethods returns the methods whose gRPC rpc is not streamed (see
+// streamKind), the unary transports can not serve the others.
+func unaryMethods(methods []parser.Method) []parser.Method {
+	keep := []parser.Method{}
+	for _, m := range methods {
+		if streamKind(m) == "" {
+			keep = append(keep, m)
+		}
+	}
+	return keep
+}
+
+// streamChans returns the first channel parameter and the first channel result
+// of `m` the method can receive from, they carry the messages of the request
+// and of the reply streams of its gRPC rpc.
+func streamChans(m parser.Method) (in, out *parser.Type) {
+	first := func(l []parser.NamedTypeValue) *parser.Type {
+		for _, p := range l {
+			if t, err := p.TypeExpr(); err == nil && t.Kind == parser.KindChan && t.Dir&ast.RECV != 0 {
+				return t
+			}
+		}
+		return nil
+	}
+	return first(m.Parameters), first(m.Results)
+}
+
+// streamKind returns the kind of stream of the gRPC rpc of `m`, the one of its
+// `kit:grpc-stream` directive or else the one of its channels: a channel
+// parameter streams the requests, a channel result the replies and both
+// make a bidirectional stream. It is empty for the unary rpcs.
+func streamKind(m parser.Method) string {
+	if m.Directives.GRPCStream != "" {
+		return m.Directives.GRPCStream
+	}
+	in, out := streamChans(m)
+	switch {
+	case in != nil && out != nil:
+		return parser.StreamBidi
+	case in != nil:
+		return parser.StreamClient
+	case out != nil:
+		return parser.StreamServer
+	}
+	return ""
+}
+
+// streamServed returns true if the gRPC transport can serve the streaming rpc
+// of `m`, the channels of the method have to match its stream kind.
+func streamServed(m parser.Method) bool {
+	in, out := streamChans(m)
+	switch streamKind(m) {
+	case parser.StreamClient:
+		return in != nil && out == nil
+	case parser.StreamServer:
+		return in == nil && out != nil
+	case parser.StreamBidi:
+		return in != nil && out != nil
+	}
+	return false
+}
+
+// timeoutMiddleware returns the function of the endpoint middleware that
+// cancels the context of the requests after `d`, see the `kit:timeout`
+// directive.
+func timeoutMiddleware() jen.Code {
+	return jen.Func().Id("timeout").Params(jen.Id("d").Qual("time", "Duration")).Qual("github.com/go-kit/kit/endpoint", "Middleware").Block(
+		jen.Return(jen.Func().Params(jen.Id("next").Qual("github.com/go-kit/kit/endpoint", "Endpoint")).Qual("github.com/go-kit/kit/endpoint", "Endpoint").Block(
+			jen.Return(jen.Func().Params(
+				jen.Id("ctx").Qual("context", "Context"),
+				jen.Id("request").Interface(),
+			).Params(jen.Interface(), jen.Error()).Block(
+				jen.List(jen.Id("ctx"), jen.Id("cancel")).Op(":=").Qual("context", "WithTimeout").Call(jen.Id("ctx"), jen.Id("d")),
+				jen.Defer().Id("cancel").Call(),
+				jen.Return(jen.Id("next").Call(jen.Id("ctx"), jen.Id("request"))),
+			)),
+		)),
+	)
+}
+
+// durationCode returns the go code of the duration e.x `2 * time.Second`.
+func durationCode(d time.Duration) jen.Code {
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "Hour"},
+		{time.Minute, "Minute"},
+		{time.Second, "Second"},
+		{time.Millisecond, "Millisecond"},
+	} {
+		if d%u.d == 0 {
+			return jen.Lit(int(d/u.d)).Op("*").Qual("time", u.name)
+		}
+	}
+	return jen.Qual("time", "Duration").Call(jen.Lit(int(d)))
+}
+
+// skippedEndpoint returns the endpoint of the clients for the method `name` that
+// is not served by their transport, it always fails.
+func skippedEndpoint(name string) jen.Code {
+	return jen.Func().Params(
+		jen.Qual("context", "Context"),
+		jen.Interface(),
+	).Params(jen.Interface(), jen.Error()).Block(
+		jen.Return(jen.Nil(), jen.Qual("errors", "New").Call(jen.Lit(name+" is not served by this client"))),
+	)
+}
+
+// httpRoute returns the HTTP method and the path of the method `m`, see the
+// `kit:http` directive.
+func httpRoute(m parser.Method) (string, string) {
+	if m.Directives.HTTPMethod == "" {
+		return "POST", "/" + strings.Replace(utils.ToLowerSnakeCase(m.Name), "_", "-", -1)
+	}
+	return m.Directives.HTTPMethod, m.Directives.HTTPPath
+}
@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/hms58/genkit/parser"
+)
+
+func Test_methodDoc(t *testing.T) {
+	tests := []struct {
+		name string
+		m    parser.Method
+		want []string
+	}{
+		{
+			name: "Test directives are removed",
+			m:    parser.Method{Name: "Foo", Comment: "Foo greets.\nkit:http GET /foo\n"},
+			want: []string{"Foo greets."},
+		},
+		{
+			name: "Test deprecated",
+			m: parser.Method{
+				Name:       "Foo",
+				Comment:    "Foo greets.\nkit:deprecated use Bar instead\n",
+				Directives: parser.Directives{Deprecated: true, Deprecation: "use Bar instead"},
+			},
+			want: []string{"Foo greets.", "", "Deprecated: use Bar instead"},
+		},
+		{
+			name: "Test deprecated without reason",
+			m:    parser.Method{Name: "Foo", Comment: "kit:deprecated\n", Directives: parser.Directives{Deprecated: true}},
+			want: []string{"Deprecated: Foo should not be used anymore."},
+		},
+		{
+			name: "Test deprecated paragraph is kept",
+			m: parser.Method{
+				Name:       "Foo",
+				Comment:    "Foo greets.\n\nDeprecated: use Baz.\nkit:deprecated\n",
+				Directives: parser.Directives{Deprecated: true},
+			},
+			want: []string{"Foo greets.", "", "Deprecated: use Baz."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := methodDoc(tt.m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("methodDoc() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_durationCode(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2 * time.Second, "2 * time.Second"},
+		{90 * time.Second, "90 * time.Second"},
+		{time.Hour, "1 * time.Hour"},
+		{1500 * time.Millisecond, "1500 * time.Millisecond"},
+		{1500 * time.Microsecond, "time.Duration(1500000)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			if got := fmt.Sprintf("%#v", jen.Add(durationCode(tt.d))); got != tt.want {
+				t.Errorf("durationCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_httpRoute(t *testing.T) {
+	verb, route := httpRoute(parser.Method{Name: "GetUser"})
+	if verb != "POST" || route != "/get-user" {
+		t.Errorf("httpRoute() = %v %v, want POST /get-user", verb, route)
+	}
+	verb, route = httpRoute(parser.Method{Name: "GetUser", Directives: parser.Directives{HTTPMethod: "GET", HTTPPath: "/users/{id}"}})
+	if verb != "GET" || route != "/users/{id}" {
+		t.Errorf("httpRoute() = %v %v, want GET /users/{id}", verb, route)
+	}
+}
+
+func Test_streamKind(t *testing.T) {
+	ctx := parser.NewNameType("ctx", "context.Context")
+	tests := []struct {
+		name            string
+		m               parser.Method
+		want            string
+		wantServed      bool
+		wantUnsupported bool
+	}{
+		{
+			name: "Test unary",
+			m:    parser.Method{Parameters: []parser.NamedTypeValue{ctx}, Results: []parser.NamedTypeValue{parser.NewNameType("err", "error")}},
+		},
+		{
+			name:       "Test channel result",
+			m:          parser.Method{Parameters: []parser.NamedTypeValue{ctx}, Results: []parser.NamedTypeValue{parser.NewNameType("events", "<-chan Event"), parser.NewNameType("err", "error")}},
+			want:       parser.StreamServer,
+			wantServed: true,
+		},
+		{
+			name:       "Test channel parameter",
+			m:          parser.Method{Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("chunks", "chan []byte")}, Results: []parser.NamedTypeValue{parser.NewNameType("err", "error")}},
+			want:       parser.StreamClient,
+			wantServed: true,
+		},
+		{
+			name:       "Test both channels",
+			m:          parser.Method{Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("in", "<-chan string")}, Results: []parser.NamedTypeValue{parser.NewNameType("out", "<-chan string")}},
+			want:       parser.StreamBidi,
+			wantServed: true,
+		},
+		{
+			name: "Test directive without channels",
+			m: parser.Method{
+				Parameters: []parser.NamedTypeValue{ctx},
+				Results:    []parser.NamedTypeValue{parser.NewNameType("err", "error")},
+				Directives: parser.Directives{GRPCStream: parser.StreamServer},
+			},
+			want: parser.StreamServer,
+		},
+		{
+			name:            "Test send only channel",
+			m:               parser.Method{Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("out", "chan<- string")}, Results: []parser.NamedTypeValue{parser.NewNameType("err", "error")}},
+			wantUnsupported: true,
+		},
+		{
+			name:            "Test second channel",
+			m:               parser.Method{Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("a", "<-chan string"), parser.NewNameType("b", "<-chan string")}, Results: []parser.NamedTypeValue{parser.NewNameType("err", "error")}},
+			want:            parser.StreamClient,
+			wantServed:      true,
+			wantUnsupported: true,
+		},
+		{
+			name:            "Test channel of channels",
+			m:               parser.Method{Parameters: []parser.NamedTypeValue{ctx}, Results: []parser.NamedTypeValue{parser.NewNameType("out", "<-chan chan int")}},
+			want:            parser.StreamServer,
+			wantServed:      true,
+			wantUnsupported: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamKind(tt.m); got != tt.want {
+				t.Errorf("streamKind() = %q, want %q", got, tt.want)
+			}
+			if got := streamServed(tt.m); got != tt.wantServed {
+				t.Errorf("streamServed() = %v, want %v", got, tt.wantServed)
+			}
+			if _, got := unsupportedParameter(tt.m); got != tt.wantUnsupported {
+				t.Errorf("unsupportedParameter() = %v, want %v", got, tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func Test_unaryRPCs(t *testing.T) {
+	unary := parser.Method{Name: "Get"}
+	stream := parser.Method{Name: "Watch", Directives: parser.Directives{GRPCStream: parser.StreamServer}}
+	if err := unaryRPCs([]parser.Method{unary}); err != nil {
+		t.Errorf("unaryRPCs() error = %v", err)
+	}
+	if err := unaryRPCs([]parser.Method{unary, stream}); err == nil || !strings.Contains(err.Error(), "`Watch`") {
+		t.Errorf("unaryRPCs() error = %v, want the method `Watch` rejected", err)
+	}
+}
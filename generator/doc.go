@@ -0,0 +1,229 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	ps "go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/parser"
+)
+
+// serviceTemplateComment is the comment `kit new service` adds to the service
+// interface, it is not part of the doc of the methods added below it.
+var serviceTemplateComment = []string{
+	"Add your methods here",
+	"e.x: Foo(ctx context.Context,s string)(rs string, err error)",
+}
+
+// declDoc is the doc comment kit generates for a declaration of a service
+// method (or for an rpc or a message of the proto file).
+type declDoc struct {
+	// key is the declaration key, see parser.DeclKey and parser.ParseProtoDocs.
+	key string
+	doc []string
+	// dflt is the doc kit generates when the service method has no doc, a
+	// declaration without a recorded doc is only updated if it has this one.
+	dflt []string
+}
+
+// methodDoc returns the lines of the doc comment of the service method `m`
+// without its `kit:` directives, the methods deprecated with a directive get a
+// `Deprecated:` paragraph.
+func methodDoc(m parser.Method) []string {
+	lines := []string{}
+	deprecated := false
+	for _, l := range strings.Split(m.Comment, "\n") {
+		template := parser.IsDirective(l)
+		for _, t := range serviceTemplateComment {
+			template = template || strings.TrimSpace(l) == t
+		}
+		if !template {
+			lines = append(lines, l)
+		}
+		deprecated = deprecated || strings.HasPrefix(l, "Deprecated: ")
+	}
+	lines = trimEmptyLines(lines)
+	if m.Directives.Deprecated && !deprecated {
+		d := "Deprecated: " + m.Directives.Deprecation
+		if m.Directives.Deprecation == "" {
+			d = fmt.Sprintf("Deprecated: %s should not be used anymore.", m.Name)
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, d)
+	}
+	return lines
+}
+
+// withMethodDoc returns the doc comment `doc` followed by the doc of the
+// service method `m`.
+func withMethodDoc(doc []string, m parser.Method) []string {
+	md := methodDoc(m)
+	if len(md) == 0 {
+		return doc
+	}
+	if len(doc) == 0 {
+		return md
+	}
+	return append(append(append([]string{}, doc...), ""), md...)
+}
+
+func trimEmptyLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func nonEmptyLines(lines []string) []string {
+	s := []string{}
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			s = append(s, l)
+		}
+	}
+	return s
+}
+
+// docText returns the doc lines as ast.CommentGroup.Text returns the comment.
+func docText(lines []string) string {
+	lines = trimEmptyLines(lines)
+	if len(lines) == 0 {
+		return ""
+	}
+	s := []string{}
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		if l == "" && len(s) > 0 && s[len(s)-1] == "" {
+			continue
+		}
+		s = append(s, l)
+	}
+	return strings.Join(s, "\n") + "\n"
+}
+
+// docEdited returns true if the doc `cur` of the declaration `key` of `path` is
+// not the one kit wrote (or the default one if kit did not record it).
+func (b *BaseGenerator) docEdited(path string, d declDoc, cur string) bool {
+	if rec, ok := b.fs.RecordedDoc(path, d.key); ok {
+		return cur != rec
+	}
+	return cur != docText(d.dflt)
+}
+
+// syncDocs updates the doc comments of the functions of the go file `path` so
+// they follow the docs of the service methods, the docs edited by hand are kept.
+func (b *BaseGenerator) syncDocs(path string, docs []declDoc) error {
+	if ok, err := b.fs.Exists(path); err != nil || !ok {
+		return err
+	}
+	src, err := b.fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return err
+	}
+	want := map[string]declDoc{}
+	for _, d := range docs {
+		want[d.key] = d
+	}
+	type edit struct {
+		start, end int
+		text       string
+	}
+	edits := []edit{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		for _, k := range parser.DeclKey(fd) {
+			dd, ok := want[k]
+			cur := fd.Doc.Text()
+			if !ok || cur == docText(dd.doc) {
+				continue
+			}
+			if b.docEdited(path, dd, cur) {
+				logrus.Debugf("The doc of `%s` in `%s` was edited by hand, it is not updated.", k, path)
+				continue
+			}
+			text := commentSource(dd.doc)
+			switch {
+			case fd.Doc != nil && text == "":
+				// remove the doc and its line break.
+				edits = append(edits, edit{fset.Position(fd.Doc.Pos()).Offset, fset.Position(fd.Pos()).Offset, ""})
+			case fd.Doc != nil:
+				edits = append(edits, edit{fset.Position(fd.Doc.Pos()).Offset, fset.Position(fd.Doc.End()).Offset, text})
+			default:
+				start := fset.Position(fd.Pos()).Offset
+				edits = append(edits, edit{start, start, text + "\n"})
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = src[:e.start] + e.text + src[e.end:]
+	}
+	logrus.Debugf("Updating the doc comments of `%s`.", path)
+	return b.writeFile(path, src, true)
+}
+
+// commentSource returns the go line comment of the doc lines.
+func commentSource(lines []string) string {
+	lines = trimEmptyLines(lines)
+	s := []string{}
+	for _, l := range lines {
+		if l = strings.TrimRight(l, " \t"); l == "" {
+			s = append(s, "//")
+		} else {
+			s = append(s, "// "+l)
+		}
+	}
+	return strings.Join(s, "\n")
+}
+
+// protoComment returns the proto comment of the doc lines, nil if there are
+// no lines.
+func protoComment(lines []string) *proto.Comment {
+	lines = trimEmptyLines(lines)
+	if len(lines) == 0 {
+		return nil
+	}
+	c := &proto.Comment{}
+	for _, l := range lines {
+		if l = strings.TrimRight(l, " \t"); l != "" {
+			l = " " + l
+		}
+		c.Lines = append(c.Lines, l)
+	}
+	return c
+}
+
+// syncProtoDoc updates the comment `c` of the proto element `d.key` of `path`
+// unless it was edited by hand, it returns the comment to use.
+func (b *BaseGenerator) syncProtoDoc(path string, d declDoc, c *proto.Comment) *proto.Comment {
+	cur := parser.ProtoCommentText(c)
+	if cur == docText(d.doc) {
+		return c
+	}
+	if b.docEdited(path, d, cur) {
+		logrus.Debugf("The comment of `%s` in `%s` was edited by hand, it is not updated.", d.key, path)
+		return c
+	}
+	return protoComment(d.doc)
+}
@@ -0,0 +1,693 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	ps "go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/emicklei/proto"
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// protoGoTypes are the go types of the proto scalars.
+var protoGoTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"int64":    "int64",
+	"uint32":   "uint32",
+	"uint64":   "uint64",
+	"sint32":   "int32",
+	"sint64":   "int64",
+	"fixed32":  "uint32",
+	"fixed64":  "uint64",
+	"sfixed32": "int32",
+	"sfixed64": "int64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "[]byte",
+}
+
+// emptyProto is the well known message of the rpcs without parameters or
+// results.
+const emptyProto = "google.protobuf.Empty"
+
+// ServiceFromProto implements Gen and is used to add the rpcs of the service
+// declared in a proto file to the service interface.
+type ServiceFromProto struct {
+	BaseGenerator
+	name          string
+	interfaceName string
+	destPath      string
+	filePath      string
+	protoPath     string
+	pbFilePath    string
+	dgd           bool
+}
+
+// NewServiceFromProto returns a initialized and ready generator.
+//
+// The rpcs of the proto service named after the service become methods of the
+// service interface, with the `(ctx, pb.<M>Req, *pb.<M>Rsp) int32` shape of
+// the dgd services if dgd is set or with the fields of the request and of the
+// reply messages as parameters and results otherwise. The messages of the rpcs
+// are copied to the names kit uses if they are named otherwise.
+func NewServiceFromProto(name, protoPath string, dgd bool) Gen {
+	snakeName := utils.ToLowerSnakeCase2(name)
+	g := &ServiceFromProto{
+		name:          name,
+		interfaceName: utils.ToCamelCase(name + "Service"),
+		destPath:      fmt.Sprintf(viper.GetString("gk_service_path_format"), snakeName),
+		protoPath:     protoPath,
+		dgd:           dgd,
+	}
+	g.filePath = path.Join(g.destPath, viper.GetString("gk_service_file_name"))
+	g.pbFilePath = path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), snakeName),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), snakeName),
+	)
+	g.fs = fs.Get()
+	g.generator = "ServiceFromProto"
+	return g
+}
+
+// Generate adds the methods that are missing in the service interface and
+// copies the proto file to the pb folder of the service.
+func (g *ServiceFromProto) Generate() error {
+	src, err := g.fs.ReadFile(g.protoPath)
+	if err != nil {
+		return err
+	}
+	def, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		return fmt.Errorf("could not parse `%s`: %s", g.protoPath, err)
+	}
+	pf := newProtoFile(def)
+	svc, err := pf.service(utils.ToCamelCase(g.name))
+	if err != nil {
+		return fmt.Errorf("%s in `%s`", err, g.protoPath)
+	}
+	if b, err := g.fs.Exists(g.filePath); err != nil {
+		return err
+	} else if !b {
+		if err = NewNewService(g.name).Generate(); err != nil {
+			return err
+		}
+	}
+	filePath, svcSrc, existing, err := g.findInterface()
+	if err != nil {
+		return err
+	}
+	methods := []string{}
+	for _, e := range svc.Elements {
+		rpc, ok := e.(*proto.RPC)
+		if !ok {
+			continue
+		}
+		if err = pf.kitMessages(rpc, g.dgd); err != nil {
+			return fmt.Errorf("the rpc `%s` of `%s` can not be added to the service: %s", rpc.Name, g.protoPath, err)
+		}
+		if existing[rpc.Name] {
+			logrus.Debugf("The method `%s` is already part of `%s`.", rpc.Name, g.interfaceName)
+			continue
+		}
+		// the rpcs that are not methods would be pruned from the proto.
+		m, err := pf.method(rpc, g.dgd)
+		if err != nil {
+			return fmt.Errorf("the rpc `%s` of `%s` can not be added to the service: %s", rpc.Name, g.protoPath, err)
+		}
+		logrus.Infof("Adding the method `%s` to `%s`.", rpc.Name, g.interfaceName)
+		methods = append(methods, m)
+	}
+	if len(methods) > 0 {
+		if svcSrc, err = g.addMethods(svcSrc, methods, pf); err != nil {
+			return err
+		}
+		if err = g.writeFile(filePath, svcSrc, true); err != nil {
+			return err
+		}
+	}
+	if pf.copied {
+		buf := new(bytes.Buffer)
+		protofmt.NewFormatter(buf, " ").Format(def)
+		src = buf.String()
+	}
+	return g.copyProto(src, pf.copied)
+}
+
+// findInterface returns the file of the service package that declares the
+// service interface, its source and the names of the methods it declares.
+func (g *ServiceFromProto) findInterface() (string, string, map[string]bool, error) {
+	infos, err := afero.ReadDir(g.fs.Fs, g.destPath)
+	if err != nil {
+		return "", "", nil, err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filePath := path.Join(g.destPath, name)
+		src, err := g.fs.ReadFile(filePath)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if it, _, err := serviceInterfaceType(src, g.interfaceName); err != nil {
+			return "", "", nil, err
+		} else if it != nil {
+			existing := map[string]bool{}
+			for _, m := range it.Methods.List {
+				for _, n := range m.Names {
+					existing[n.Name] = true
+				}
+			}
+			return filePath, src, existing, nil
+		}
+	}
+	return "", "", nil, fmt.Errorf("could not find the service interface in `%s`", g.name)
+}
+
+// serviceInterfaceType returns the interface type `name` declared in the
+// source, nil if the source does not declare it.
+func serviceInterfaceType(src, name string) (*ast.InterfaceType, *token.FileSet, error) {
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gd.Specs {
+			if ts := s.(*ast.TypeSpec); ts.Name.Name == name {
+				if it, ok := ts.Type.(*ast.InterfaceType); ok {
+					return it, fset, nil
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// addMethods adds the methods at the end of the service interface, the types
+// of the proto file they use and their imports.
+func (g *ServiceFromProto) addMethods(src string, methods []string, pf *protoFile) (string, error) {
+	it, fset, err := serviceInterfaceType(src, g.interfaceName)
+	if err != nil {
+		return "", err
+	}
+	end := fset.Position(it.Methods.Closing).Offset
+	code := strings.Join(methods, "\n") + "\n"
+	last := fset.Position(it.Methods.Opening).Offset
+	if n := len(it.Methods.List); n > 0 {
+		last = fset.Position(it.Methods.List[n-1].End()).Offset
+	}
+	// the methods go above the comment `kit new service` adds.
+	if i := strings.Index(src[last:end], serviceTemplateComment[0]); i >= 0 {
+		end = last + i
+		code += "\n"
+	}
+	lineStart := strings.LastIndex(src[:end], "\n") + 1
+	if strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(src[lineStart:end]), "//")) == "" {
+		src = src[:lineStart] + code + src[lineStart:]
+	} else {
+		src = src[:end] + "\n" + code + src[end:]
+	}
+	declared, err := g.declaredTypes()
+	if err != nil {
+		return "", err
+	}
+	if decls := pf.typeDecls(declared); decls != "" {
+		src += "\n" + decls
+	}
+	imp, err := g.missingImports(src, pf)
+	if err != nil {
+		return "", err
+	}
+	if len(imp) > 0 {
+		return g.AddImportsToFile(imp, src)
+	}
+	s, err := format.Source([]byte(src))
+	return string(s), err
+}
+
+// declaredTypes returns the types declared in the service package.
+func (g *ServiceFromProto) declaredTypes() (map[string]bool, error) {
+	pkg, err := g.ParsePackage(g.destPath)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for n := range pkg.Types {
+		declared[n] = true
+	}
+	return declared, nil
+}
+
+// missingImports returns the imports of the methods that the source does not
+// import yet.
+func (g *ServiceFromProto) missingImports(src string, pf *protoFile) ([]parser.NamedTypeValue, error) {
+	f, err := ps.ParseFile(token.NewFileSet(), "", src, ps.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	imported := map[string]bool{}
+	for _, i := range f.Imports {
+		p, _ := strconv.Unquote(i.Path.Value)
+		imported[p] = true
+	}
+	imp := []parser.NamedTypeValue{}
+	paths := []string{"context"}
+	for p := range pf.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths[1:])
+	for _, p := range paths {
+		if !imported[p] {
+			imp = append(imp, parser.NewNameType("", strconv.Quote(p)))
+		}
+	}
+	if g.dgd {
+		pbImport, err := utils.GetPbImportPath(g.name)
+		if err != nil {
+			return nil, err
+		}
+		if !imported[pbImport] {
+			imp = append(imp, parser.NewNameType("pb", strconv.Quote(pbImport)))
+		}
+	}
+	return imp, nil
+}
+
+// copyProto writes the proto file to the pb folder of the service, the rpcs
+// and the messages that are generated later are added to this copy. The proto
+// file is only rewritten in place if kitMessages changed it.
+func (g *ServiceFromProto) copyProto(src string, changed bool) error {
+	if !changed && filepath.Clean(g.protoPath) == filepath.Clean(g.pbFilePath) {
+		return nil
+	}
+	if err := g.CreateFolderStructure(path.Dir(g.pbFilePath)); err != nil {
+		return err
+	}
+	return g.writeFile(g.pbFilePath, src, true)
+}
+
+// protoFile indexes the messages and the enums of a proto file by their name
+// relative to the package of the file (e.x `Outer.Inner`).
+type protoFile struct {
+	def      *proto.Proto
+	pkg      string
+	messages map[string]*proto.Message
+	enums    map[string]*proto.Enum
+	// used are the messages and the enums used by the methods, they get a go
+	// type in the service package.
+	used    []string
+	seen    map[string]bool
+	structs map[string][]parser.NamedTypeValue
+	imports map[string]bool
+	// copied is true if kitMessages added messages to the file.
+	copied bool
+}
+
+func newProtoFile(def *proto.Proto) *protoFile {
+	p := &protoFile{
+		def:      def,
+		messages: map[string]*proto.Message{},
+		enums:    map[string]*proto.Enum{},
+		seen:     map[string]bool{},
+		structs:  map[string][]parser.NamedTypeValue{},
+		imports:  map[string]bool{},
+	}
+	for _, e := range def.Elements {
+		if pk, ok := e.(*proto.Package); ok {
+			p.pkg = pk.Name
+		}
+	}
+	p.index("", def.Elements)
+	return p
+}
+
+func (p *protoFile) index(prefix string, elements []proto.Visitee) {
+	for _, e := range elements {
+		switch v := e.(type) {
+		case *proto.Message:
+			if v.IsExtend {
+				continue
+			}
+			p.messages[prefix+v.Name] = v
+			p.index(prefix+v.Name+".", v.Elements)
+		case *proto.Enum:
+			p.enums[prefix+v.Name] = v
+		}
+	}
+}
+
+// service returns the service `name` of the proto file.
+func (p *protoFile) service(name string) (*proto.Service, error) {
+	found := []string{}
+	for _, e := range p.def.Elements {
+		if s, ok := e.(*proto.Service); ok {
+			if s.Name == name {
+				return s, nil
+			}
+			found = append(found, s.Name)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("there is no service")
+	}
+	return nil, fmt.Errorf("there is no service `%s` (found %s), the proto service has to be named after the service", name, strings.Join(found, ", "))
+}
+
+// kitMessages makes the rpc take and return the messages named like kit
+// names them (`<M>Request` and `<M>Reply`, `<M>Req` and `<M>Rsp` if dgd is
+// set), the messages the rpc uses under other names are copied to these names.
+// The copies keep the field numbers so the rpc stays wire compatible.
+func (p *protoFile) kitMessages(rpc *proto.RPC, dgd bool) error {
+	req, rep := rpc.Name+"Request", rpc.Name+"Reply"
+	if dgd {
+		req, rep = fmt.Sprintf(dgd_req_data_proto_format, rpc.Name), fmt.Sprintf(dgd_rsp_data_proto_format, rpc.Name)
+	}
+	for _, t := range []struct {
+		typ  *string
+		name string
+	}{{&rpc.RequestType, req}, {&rpc.ReturnsType, rep}} {
+		name := ""
+		if *t.typ != emptyProto {
+			var ok bool
+			if name, ok = p.resolve(*t.typ, ""); !ok || p.messages[name] == nil {
+				return fmt.Errorf("the message `%s` is not declared in the proto file", *t.typ)
+			}
+			if name == t.name {
+				continue
+			}
+		}
+		if _, ok := p.messages[t.name]; ok {
+			return fmt.Errorf("it uses `%s` but the message `%s` is declared too, the rpc has to use it", *t.typ, t.name)
+		}
+		c := p.copyMessage(name, t.name)
+		c.Comment = &proto.Comment{Lines: []string{
+			fmt.Sprintf(" %s is the `%s` message of the `%s` rpc.", t.name, *t.typ, rpc.Name),
+		}}
+		p.def.Elements = append(p.def.Elements, c)
+		p.messages[t.name] = c
+		p.copied = true
+		*t.typ = t.name
+	}
+	return nil
+}
+
+// copyMessage returns a copy named `name` of the fields of the message
+// `scope`, an empty message if there is no such message. The types of the
+// fields are resolved in the scope of the message.
+func (p *protoFile) copyMessage(scope, name string) *proto.Message {
+	c := &proto.Message{Name: name}
+	msg, ok := p.messages[scope]
+	if !ok {
+		return c
+	}
+	typ := func(f *proto.Field) *proto.Field {
+		c := *f
+		if n, ok := p.resolve(f.Type, scope); ok {
+			c.Type = n
+		}
+		return &c
+	}
+	for _, e := range msg.Elements {
+		switch v := e.(type) {
+		case *proto.NormalField:
+			f := *v
+			f.Field = typ(v.Field)
+			c.Elements = append(c.Elements, &f)
+		case *proto.MapField:
+			f := *v
+			f.Field = typ(v.Field)
+			c.Elements = append(c.Elements, &f)
+		case *proto.Oneof:
+			o := *v
+			o.Elements = nil
+			for _, oe := range v.Elements {
+				if f, ok := oe.(*proto.OneOfField); ok {
+					o.Elements = append(o.Elements, &proto.OneOfField{Field: typ(f.Field)})
+					continue
+				}
+				o.Elements = append(o.Elements, oe)
+			}
+			c.Elements = append(c.Elements, &o)
+		case *proto.Reserved, *proto.Option:
+			c.Elements = append(c.Elements, e)
+		}
+	}
+	return c
+}
+
+// method returns the source of the method of the rpc with its doc, the rpc
+// uses the messages named by kitMessages.
+func (p *protoFile) method(rpc *proto.RPC, dgd bool) (string, error) {
+	var sig string
+	if dgd {
+		if rpc.StreamsRequest || rpc.StreamsReturns {
+			return "", fmt.Errorf("it is a streaming rpc but the dgd gRPC transport only serves unary rpcs")
+		}
+		sig = fmt.Sprintf("%s(ctx context.Context, req_pb pb.%s, rsp_pb *pb.%s) (errcode int32)", rpc.Name, rpc.RequestType, rpc.ReturnsType)
+	} else {
+		params, err := p.fields(rpc.RequestType)
+		if err != nil {
+			return "", err
+		}
+		results, err := p.fields(rpc.ReturnsType)
+		if err != nil {
+			return "", err
+		}
+		for i, r := range results {
+			// the errors are sent as strings.
+			if r.Name == "err" && r.Type == "string" {
+				results[i].Type = "error"
+			}
+		}
+		if len(results) == 0 {
+			results = append(results, parser.NewNameType("err", "error"))
+		}
+		sig = fmt.Sprintf("%s(%s) (%s)", rpc.Name, joinParams(append([]parser.NamedTypeValue{parser.NewNameType("ctx", "context.Context")}, params...)), joinParams(results))
+	}
+	doc := []string{}
+	if rpc.Comment != nil {
+		for _, l := range rpc.Comment.Lines {
+			doc = append(doc, strings.TrimPrefix(l, " "))
+		}
+	}
+	switch {
+	case rpc.StreamsRequest && rpc.StreamsReturns:
+		doc = append(doc, "kit:grpc-stream "+parser.StreamBidi)
+	case rpc.StreamsRequest:
+		doc = append(doc, "kit:grpc-stream "+parser.StreamClient)
+	case rpc.StreamsReturns:
+		doc = append(doc, "kit:grpc-stream "+parser.StreamServer)
+	}
+	for _, e := range rpc.Elements {
+		if o, ok := e.(*proto.Option); ok && o.Name == "deprecated" && o.Constant.Source == "true" {
+			doc = append(doc, "kit:deprecated")
+		}
+	}
+	code := ""
+	for _, l := range doc {
+		code += strings.TrimRight("// "+l, " ") + "\n"
+	}
+	return code + sig, nil
+}
+
+func joinParams(v []parser.NamedTypeValue) string {
+	s := []string{}
+	for _, p := range v {
+		s = append(s, p.Name+" "+p.Type)
+	}
+	return strings.Join(s, ", ")
+}
+
+// fields returns the names and the go types of the fields of the message.
+func (p *protoFile) fields(typ string) ([]parser.NamedTypeValue, error) {
+	if typ == emptyProto {
+		return nil, nil
+	}
+	name, ok := p.resolve(typ, "")
+	m, isMessage := p.messages[name]
+	if !ok || !isMessage {
+		return nil, fmt.Errorf("the message `%s` is not declared in the proto file", typ)
+	}
+	fields := []parser.NamedTypeValue{}
+	for _, e := range m.Elements {
+		var f parser.NamedTypeValue
+		var err error
+		switch v := e.(type) {
+		case *proto.NormalField:
+			f, err = p.field(v.Field, name)
+			if v.Repeated {
+				f.Type = "[]" + f.Type
+			}
+		case *proto.MapField:
+			var k string
+			if k, err = p.goType(v.KeyType, name); err == nil {
+				f, err = p.field(v.Field, name)
+				f.Type = fmt.Sprintf("map[%s]%s", k, f.Type)
+			}
+		case *proto.Oneof:
+			logrus.Warnf("The oneof `%s` of `%s` is not supported and is left out.", v.Name, name)
+			continue
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (p *protoFile) field(f *proto.Field, scope string) (parser.NamedTypeValue, error) {
+	t, err := p.goType(f.Type, scope)
+	if err != nil {
+		return parser.NamedTypeValue{}, err
+	}
+	name := utils.ToLowerFirstCamelCase(f.Name)
+	if token.Lookup(name).IsKeyword() {
+		name += "_"
+	}
+	return parser.NewNameType(name, t), nil
+}
+
+// goType returns the go type of the proto type used in the message `scope`,
+// the messages are pointers.
+func (p *protoFile) goType(typ, scope string) (string, error) {
+	if t, ok := protoGoTypes[typ]; ok {
+		return t, nil
+	}
+	if strings.TrimPrefix(typ, ".") == timestampProto {
+		p.imports["time"] = true
+		return "time.Time", nil
+	}
+	name, ok := p.resolve(typ, scope)
+	if !ok {
+		return "", fmt.Errorf("the type `%s` is not supported", typ)
+	}
+	if !p.seen[name] {
+		p.seen[name] = true
+		p.used = append(p.used, name)
+		if _, ok := p.messages[name]; ok {
+			// the types of the fields are used too.
+			fields, err := p.fields(name)
+			if err != nil {
+				return "", err
+			}
+			for i := range fields {
+				fields[i].Name = utils.ToUpperFirst(strings.TrimSuffix(fields[i].Name, "_"))
+			}
+			p.structs[name] = fields
+		}
+	}
+	if _, ok := p.messages[name]; ok {
+		return "*" + protoGoName(name), nil
+	}
+	return protoGoName(name), nil
+}
+
+// resolve returns the name of the message or of the enum `typ` used in the
+// message `scope`, the names are looked up from the innermost scope like
+// protoc does.
+func (p *protoFile) resolve(typ, scope string) (string, bool) {
+	if strings.HasPrefix(typ, ".") {
+		typ = strings.TrimPrefix(typ[1:], p.pkg+".")
+		scope = ""
+	} else if p.pkg != "" && strings.HasPrefix(typ, p.pkg+".") {
+		if _, ok := p.messages[typ]; !ok {
+			typ = strings.TrimPrefix(typ, p.pkg+".")
+		}
+	}
+	for {
+		name := typ
+		if scope != "" {
+			name = scope + "." + typ
+		}
+		if _, ok := p.messages[name]; ok {
+			return name, true
+		}
+		if _, ok := p.enums[name]; ok {
+			return name, true
+		}
+		if scope == "" {
+			return "", false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// protoGoName returns the go name of the message or of the enum `name` like
+// protoc-gen-go does (e.x `Outer_Inner` for `Outer.Inner`).
+func protoGoName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = pbGoName(p)
+	}
+	return strings.Join(parts, "_")
+}
+
+// typeDecls returns the source of the go types of the messages and of the
+// enums used by the methods that are not declared yet.
+func (p *protoFile) typeDecls(declared map[string]bool) string {
+	buf := new(bytes.Buffer)
+	for _, name := range p.used {
+		goName := protoGoName(name)
+		if declared[goName] {
+			continue
+		}
+		comment, kind := (*proto.Comment)(nil), "enum"
+		if m, ok := p.messages[name]; ok {
+			comment, kind = m.Comment, "message"
+		} else {
+			comment = p.enums[name].Comment
+		}
+		if comment != nil {
+			for _, l := range comment.Lines {
+				fmt.Fprintf(buf, "%s\n", strings.TrimRight("// "+strings.TrimPrefix(l, " "), " "))
+			}
+		} else {
+			fmt.Fprintf(buf, "// %s is the `%s` proto %s.\n", goName, name, kind)
+		}
+		if fields, ok := p.structs[name]; ok {
+			fmt.Fprintf(buf, "type %s struct {\n", goName)
+			for _, f := range fields {
+				fmt.Fprintf(buf, "%s %s\n", f.Name, f.Type)
+			}
+			fmt.Fprint(buf, "}\n\n")
+			continue
+		}
+		fmt.Fprintf(buf, "type %s int32\n\nconst (\n", goName)
+		for _, e := range p.enums[name].Elements {
+			if f, ok := e.(*proto.EnumField); ok {
+				fmt.Fprintf(buf, "%s_%s %s = %d\n", goName, f.Name, goName, f.Integer)
+			}
+		}
+		fmt.Fprint(buf, ")\n\n")
+	}
+	return buf.String()
+}
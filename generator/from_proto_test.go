@@ -0,0 +1,325 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/fs"
+	"github.com/spf13/afero"
+)
+
+const testShopProto = `syntax = "proto3";
+
+package shop.v1;
+
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/empty.proto";
+
+service Shop {
+    // Buy buys items.
+    rpc Buy (BuyRequest) returns (BuyReply);
+    rpc Ping (google.protobuf.Empty) returns (google.protobuf.Empty);
+    rpc Watch (WatchRequest) returns (stream WatchReply) {
+        option deprecated = true;
+    }
+    rpc Get (GetReq) returns (GetRsp);
+    rpc Find (Query) returns (shop.v1.Item);
+    rpc Bad (Item.Status) returns (BuyReply);
+    rpc Clash (Item) returns (ClashReply);
+}
+
+message BuyRequest {
+    string user_id = 1;
+    repeated Item items = 2;
+    map<string, int64> counts = 3;
+    google.protobuf.Timestamp at = 4;
+    bytes type = 5;
+}
+message BuyReply {
+    Item.Status status = 1;
+    string err = 2;
+}
+
+// Item is a thing to buy.
+message Item {
+    enum Status {
+        UNKNOWN = 0;
+        SOLD = 1;
+    }
+    string name = 1;
+    Status status = 2;
+    Item parent = 3;
+}
+message WatchRequest {}
+message WatchReply {
+    shop.v1.Item item = 1;
+}
+message GetReq {}
+message GetRsp {}
+message Query {
+    message Page {
+        int32 size = 1;
+    }
+    reserved 2;
+    string text = 1;
+    Page page = 3;
+    map<string, Item.Status> statuses = 4;
+}
+message ClashRequest {}
+message ClashReply {}
+`
+
+func testShopRPCs(t *testing.T) (*protoFile, map[string]*proto.RPC) {
+	def, err := proto.NewParser(strings.NewReader(testShopProto)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := newProtoFile(def)
+	svc, err := pf.service("Shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcs := map[string]*proto.RPC{}
+	for _, e := range svc.Elements {
+		if r, ok := e.(*proto.RPC); ok {
+			rpcs[r.Name] = r
+		}
+	}
+	return pf, rpcs
+}
+
+func TestProtoFile_method(t *testing.T) {
+	tests := []struct {
+		name    string
+		rpc     string
+		dgd     bool
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Test request and reply fields",
+			rpc:  "Buy",
+			want: "// Buy buys items.\nBuy(ctx context.Context, userId string, items []*Item, counts map[string]int64, at time.Time, type_ []byte) (status Item_Status, err error)",
+		},
+		{
+			name: "Test empty messages",
+			rpc:  "Ping",
+			want: "Ping(ctx context.Context) (err error)",
+		},
+		{
+			name: "Test directives",
+			rpc:  "Watch",
+			want: "// kit:grpc-stream server\n// kit:deprecated\nWatch(ctx context.Context) (item *Item)",
+		},
+		{
+			name: "Test other message names",
+			rpc:  "Find",
+			want: "Find(ctx context.Context, text string, page *Query_Page, statuses map[string]Item_Status) (name string, status Item_Status, parent *Item)",
+		},
+		{
+			name:    "Test request that is not a message",
+			rpc:     "Bad",
+			wantErr: true,
+		},
+		{
+			name:    "Test kit message declared for something else",
+			rpc:     "Clash",
+			wantErr: true,
+		},
+		{
+			name: "Test dgd",
+			rpc:  "Get",
+			dgd:  true,
+			want: "Get(ctx context.Context, req_pb pb.GetReq, rsp_pb *pb.GetRsp) (errcode int32)",
+		},
+		{
+			name: "Test dgd other message names",
+			rpc:  "Buy",
+			dgd:  true,
+			want: "// Buy buys items.\nBuy(ctx context.Context, req_pb pb.BuyReq, rsp_pb *pb.BuyRsp) (errcode int32)",
+		},
+		{
+			name:    "Test dgd streaming rpc",
+			rpc:     "Watch",
+			dgd:     true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pf, rpcs := testShopRPCs(t)
+			got := ""
+			err := pf.kitMessages(rpcs[tt.rpc], tt.dgd)
+			if err == nil {
+				got, err = pf.method(rpcs[tt.rpc], tt.dgd)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("protoFile.method() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("protoFile.method() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoFile_kitMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		rpc     string
+		dgd     bool
+		req     string
+		rep     string
+		reqWant []string
+		repWant []string
+	}{
+		{
+			name: "Test kit names are kept",
+			rpc:  "Buy",
+			req:  "BuyRequest",
+			rep:  "BuyReply",
+		},
+		{
+			name:    "Test fields copied with their resolved types",
+			rpc:     "Find",
+			req:     "FindRequest",
+			rep:     "FindReply",
+			reqWant: []string{"string text = 1", "Query.Page page = 3", "map<string, Item.Status> statuses = 4"},
+			repWant: []string{"string name = 1", "Item.Status status = 2", "Item parent = 3"},
+		},
+		{
+			name:    "Test empty messages copied",
+			rpc:     "Ping",
+			req:     "PingRequest",
+			rep:     "PingReply",
+			reqWant: []string{},
+			repWant: []string{},
+		},
+		{
+			name:    "Test dgd names",
+			rpc:     "Buy",
+			dgd:     true,
+			req:     "BuyReq",
+			rep:     "BuyRsp",
+			reqWant: []string{"string user_id = 1", "repeated Item items = 2", "map<string, int64> counts = 3", "google.protobuf.Timestamp at = 4", "bytes type = 5"},
+			repWant: []string{"Item.Status status = 1", "string err = 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pf, rpcs := testShopRPCs(t)
+			rpc := rpcs[tt.rpc]
+			if err := pf.kitMessages(rpc, tt.dgd); err != nil {
+				t.Fatalf("protoFile.kitMessages() error = %v", err)
+			}
+			if rpc.RequestType != tt.req || rpc.ReturnsType != tt.rep {
+				t.Errorf("the rpc uses (%s, %s), want (%s, %s)", rpc.RequestType, rpc.ReturnsType, tt.req, tt.rep)
+			}
+			if pf.copied != (tt.reqWant != nil) {
+				t.Errorf("protoFile.copied = %v, want %v", pf.copied, tt.reqWant != nil)
+			}
+			if tt.reqWant == nil {
+				return
+			}
+			for name, want := range map[string][]string{tt.req: tt.reqWant, tt.rep: tt.repWant} {
+				if got := fieldLines(pf.messages[name]); !reflect.DeepEqual(got, want) {
+					t.Errorf("the fields of `%s` = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoFile_typeDecls(t *testing.T) {
+	pf, rpcs := testShopRPCs(t)
+	if _, err := pf.method(rpcs["Buy"], false); err != nil {
+		t.Fatal(err)
+	}
+	got := pf.typeDecls(map[string]bool{})
+	want := "// Item is a thing to buy.\ntype Item struct {\nName string\nStatus Item_Status\nParent *Item\n}\n\n" +
+		"// Item_Status is the `Item.Status` proto enum.\ntype Item_Status int32\n\nconst (\nItem_Status_UNKNOWN Item_Status = 0\nItem_Status_SOLD Item_Status = 1\n)\n\n"
+	if got != want {
+		t.Errorf("protoFile.typeDecls() = %q, want %q", got, want)
+	}
+	if got := pf.typeDecls(map[string]bool{"Item": true, "Item_Status": true}); got != "" {
+		t.Errorf("protoFile.typeDecls() = %q, want the declared types left out", got)
+	}
+}
+
+func TestServiceFromProto_Generate(t *testing.T) {
+	setDefaults()
+	g := NewServiceFromProto("shop", "shop.proto", false).(*ServiceFromProto)
+	g.fs = &fs.KitFs{Fs: afero.NewMemMapFs()}
+	afero.WriteFile(g.fs.Fs, "shop.proto", []byte(testShopProto), 0644)
+	afero.WriteFile(g.fs.Fs, "shop/pkg/service/service.go", []byte(`package service
+
+import "context"
+
+// ShopService describes the service.
+type ShopService interface {
+	Ping(ctx context.Context) error
+	// Add your methods here
+	// e.x: Foo(ctx context.Context,s string)(rs string, err error)
+}
+`), 0644)
+	if err := g.Generate(); err == nil || !strings.Contains(err.Error(), "`Bad`") {
+		t.Fatalf("ServiceFromProto.Generate() error = %v, want the rpc `Bad` rejected", err)
+	}
+	afero.WriteFile(g.fs.Fs, "shop.proto", []byte(strings.Replace(testShopProto, "    rpc Bad (Item.Status) returns (BuyReply);\n    rpc Clash (Item) returns (ClashReply);\n", "", 1)), 0644)
+	if err := g.Generate(); err != nil {
+		t.Fatalf("ServiceFromProto.Generate() error = %v", err)
+	}
+	src, _ := g.fs.ReadFile("shop/pkg/service/service.go")
+	for _, want := range []string{
+		"\"time\"",
+		"\tPing(ctx context.Context) error\n\t// Buy buys items.\n\tBuy(",
+		"\tWatch(ctx context.Context) (item *Item)\n\tGet(ctx context.Context) (err error)\n",
+		"(name string, status Item_Status, parent *Item)\n\n\t// Add your methods here",
+		"type Query_Page struct {",
+		"type Item struct {",
+		"type Item_Status int32",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("the service does not contain `%s`:\n%s", want, src)
+		}
+	}
+	if strings.Count(src, "Ping(") != 1 {
+		t.Errorf("the existing method was added again:\n%s", src)
+	}
+	pbSrc, err := g.fs.ReadFile("shop/pkg/grpc/pb/shop.proto")
+	if err != nil {
+		t.Fatalf("the proto file was not copied to the pb folder: %v", err)
+	}
+	def, err := proto.NewParser(strings.NewReader(pbSrc)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := newProtoFile(def)
+	svc, err := pf.service("Shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcs := map[string]string{}
+	for _, e := range svc.Elements {
+		if r, ok := e.(*proto.RPC); ok {
+			rpcs[r.Name] = r.RequestType + " " + r.ReturnsType
+		}
+	}
+	want := map[string]string{
+		"Buy":   "BuyRequest BuyReply",
+		"Ping":  "PingRequest PingReply",
+		"Watch": "WatchRequest WatchReply",
+		"Get":   "GetRequest GetReply",
+		"Find":  "FindRequest FindReply",
+	}
+	if !reflect.DeepEqual(rpcs, want) {
+		t.Errorf("the rpcs of the copied proto file = %v, want %v", rpcs, want)
+	}
+	for _, name := range []string{"Query", "FindRequest", "FindReply", "GetRequest", "GetReply"} {
+		if pf.messages[name] == nil {
+			t.Errorf("the copied proto file does not declare `%s`:\n%s", name, pbSrc)
+		}
+	}
+}
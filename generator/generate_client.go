@@ -43,11 +43,18 @@ func NewGenerateClient(name string, transport string) Gen {
 	i.srcFile = jen.NewFilePath(i.destPath)
 	i.InitPg()
 	i.fs = fs.Get()
+	i.generator = "GenerateClient"
 	return i
 }
 
 // Generate generates the client lib.
-func (g *GenerateClient) Generate() (err error) {
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (g *GenerateClient) Generate() error {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateClient) generate() (err error) {
 	for n, v := range SupportedTransports {
 		if v == g.transport {
 			break
@@ -97,7 +104,7 @@ func (g *GenerateClient) Generate() (err error) {
 func (g *GenerateClient) serviceFound() bool {
 	for n, v := range g.serviceFile.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.serviceDestPath)
 			return true
 		} else if n == len(g.serviceFile.Interfaces)-1 {
 			logrus.Errorf("Could not find the service interface in `%s`", g.name)
@@ -117,6 +124,10 @@ func (g *GenerateClient) removeBadMethods() {
 			logrus.Warnf("The method '%s' does not have any return value and will be ignored", v.Name)
 			continue
 		}
+		if p, ok := unsupportedParameter(v); ok {
+			logrus.Warnf("The method '%s' uses '%s %s' that can not be encoded by the transport and will be ignored", v.Name, p.Name, p.Type)
+			continue
+		}
 		for n, p := range v.Parameters {
 			if p.Type == "context.Context" {
 				keepMethods = append(keepMethods, v)
@@ -152,6 +163,7 @@ func newGenerateHTTPClient(name string, serviceInterface parser.Interface, servi
 	i.srcFile = jen.NewFilePath(i.destPath)
 	i.InitPg()
 	i.fs = fs.Get()
+	i.generator = "generateHTTPClient"
 	return i
 }
 func (g *generateHTTPClient) Generate() (err error) {
@@ -175,6 +187,17 @@ func (g *generateHTTPClient) Generate() (err error) {
 	respS := jen.Dict{}
 	for _, m := range g.serviceInterface.Methods {
 		respS[jen.Id(m.Name+"Endpoint")] = jen.Id(utils.ToLowerFirstCamelCase(m.Name) + "Endpoint")
+		if m.Directives.Skips(parser.SkipClient) || m.Directives.Skips(parser.SkipHTTP) || streamKind(m) != "" {
+			handles = append(
+				handles,
+				jen.Id(utils.ToLowerFirstCamelCase(m.Name)+"Endpoint").Op(":=").Add(skippedEndpoint(m.Name)),
+			)
+			continue
+		}
+		verb, route := httpRoute(m)
+		if strings.Contains(route, "{") {
+			logrus.Warnf("The path `%s` of `%s` has variables, the client does not set them.", route, m.Name)
+		}
 		handles = append(
 			handles,
 			jen.Var().Id(utils.ToLowerFirstCamelCase(m.Name)+"Endpoint").Qual(
@@ -185,11 +208,9 @@ func (g *generateHTTPClient) Generate() (err error) {
 					"github.com/go-kit/kit/transport/http",
 					"NewClient",
 				).Call(
-					jen.Lit("POST"),
+					jen.Lit(verb),
 					jen.Id("copyURL").Call(
-						jen.Id("u"), jen.Lit(
-							"/"+strings.Replace(utils.ToLowerSnakeCase(m.Name), "_", "-", -1),
-						),
+						jen.Id("u"), jen.Lit(route),
 					),
 					jen.Id("encodeHTTPGenericRequest"),
 					jen.Id(fmt.Sprintf("decode%sResponse", m.Name)),
@@ -261,8 +282,34 @@ func (g *generateHTTPClient) Generate() (err error) {
 		jen.Return(),
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), false)
+	if err = g.syncDocs(g.filePath, g.docs()); err != nil {
+		return err
+	}
+	return g.writeFile(g.filePath, g.srcFile.GoString(), false)
+}
+
+func httpDecodeResponseDoc(m parser.Method) []string {
+	return []string{
+		fmt.Sprintf("decode%sResponse is a transport/http.DecodeResponseFunc that decodes", m.Name),
+		"a JSON-encoded concat response from the HTTP response body. If the response",
+		"as a non-200 status code, we will interpret that as an error and attempt to",
+		" decode the specific error message from the response body.",
+	}
+}
+
+// docs returns the doc comments of the client functions of the service methods.
+func (g *generateHTTPClient) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(docs, declDoc{
+			key:  "decode" + m.Name + "Response",
+			doc:  withMethodDoc(httpDecodeResponseDoc(m), m),
+			dflt: httpDecodeResponseDoc(m),
+		})
+	}
+	return docs
 }
+
 func (g *generateHTTPClient) generateDecodeEncodeMethods(endpointImport string) (err error) {
 	httpImport, err := utils.GetHTTPTransportImportPath(g.name)
 	if err != nil {
@@ -299,12 +346,7 @@ func (g *generateHTTPClient) generateDecodeEncodeMethods(endpointImport string)
 	)
 	g.code.NewLine()
 	for _, m := range g.serviceInterface.Methods {
-		g.code.appendMultilineComment([]string{
-			fmt.Sprintf("decode%sResponse is a transport/http.DecodeResponseFunc that decodes", m.Name),
-			"a JSON-encoded concat response from the HTTP response body. If the response",
-			"as a non-200 status code, we will interpret that as an error and attempt to",
-			" decode the specific error message from the response body.",
-		})
+		g.code.appendMultilineComment(withMethodDoc(httpDecodeResponseDoc(m), m))
 		g.code.NewLine()
 		g.code.appendFunction(
 			fmt.Sprintf("decode%sResponse", m.Name),
@@ -356,6 +398,7 @@ func newGenerateGRPCClient(name string, serviceInterface parser.Interface, servi
 	i.srcFile = jen.NewFilePath(i.destPath)
 	i.InitPg()
 	i.fs = fs.Get()
+	i.generator = "generateGRPCClient"
 	return i
 }
 func (g *generateGRPCClient) Generate() (err error) {
@@ -380,10 +423,32 @@ func (g *generateGRPCClient) Generate() (err error) {
 	})
 
 	g.code.NewLine()
+	pm, err := newPbMessages(&g.BaseGenerator, g.name, g.serviceInterface.Methods)
+	if err != nil {
+		return err
+	}
+	if err = pm.useProto(&g.BaseGenerator, g.name); err != nil {
+		return err
+	}
 	handles := []jen.Code{}
 	respS := jen.Dict{}
 	for _, m := range g.serviceInterface.Methods {
 		respS[jen.Id(m.Name+"Endpoint")] = jen.Id(utils.ToLowerFirstCamelCase(m.Name) + "Endpoint")
+		_, _, streamOk := pm.streams(m)
+		if m.Directives.Skips(parser.SkipClient) || m.Directives.Skips(parser.SkipGRPC) || (streamKind(m) != "" && !streamOk) {
+			handles = append(
+				handles,
+				jen.Id(utils.ToLowerFirstCamelCase(m.Name)+"Endpoint").Op(":=").Add(skippedEndpoint(m.Name)),
+			)
+			continue
+		}
+		if streamKind(m) != "" {
+			handles = append(
+				handles,
+				jen.Id(utils.ToLowerFirstCamelCase(m.Name)+"Endpoint").Op(":=").Add(pm.streamClient(utils.ToCamelCase(g.name), m)),
+			)
+			continue
+		}
 		handles = append(
 			handles,
 			jen.Var().Id(utils.ToLowerFirstCamelCase(m.Name)+"Endpoint").Qual(
@@ -395,7 +460,7 @@ func (g *generateGRPCClient) Generate() (err error) {
 					"NewClient",
 				).Call(
 					jen.Id("conn"),
-					jen.Lit("pb."+utils.ToCamelCase(g.name)),
+					jen.Lit(g.protoService(g.name)),
 					jen.Lit(m.Name),
 					jen.Id(fmt.Sprintf("encode%sRequest", m.Name)),
 					jen.Id(fmt.Sprintf("decode%sResponse", m.Name)),
@@ -431,19 +496,67 @@ func (g *generateGRPCClient) Generate() (err error) {
 		"",
 		body...,
 	)
-	err = g.generateDecodeEncodeMethods(endpointImport)
-	if err != nil {
+	if pm.ok {
+		if err = writeConverters(g.fs, g.generator, g.destPath, pm); err != nil {
+			return err
+		}
+	}
+	g.generateDecodeEncodeMethods(pm)
+	if err = g.syncDocs(g.filePath, g.docs()); err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), false)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), false)
+}
+
+func grpcEncodeRequestDoc(m parser.Method) []string {
+	return []string{
+		fmt.Sprintf("encode%sRequest is a transport/grpc.EncodeRequestFunc that converts a", m.Name),
+		" user-domain sum request to a gRPC request.",
+	}
 }
-func (g *generateGRPCClient) generateDecodeEncodeMethods(endpointImport string) (err error) {
+
+func grpcDecodeResponseDoc(m parser.Method) []string {
+	return []string{
+		fmt.Sprintf("decode%sResponse is a transport/grpc.DecodeResponseFunc that converts", m.Name),
+		"a gRPC concat reply to a user-domain concat response.",
+	}
+}
+
+// docs returns the doc comments of the client functions of the service methods.
+func (g *generateGRPCClient) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(
+			docs,
+			declDoc{key: "encode" + m.Name + "Request", doc: withMethodDoc(grpcEncodeRequestDoc(m), m), dflt: grpcEncodeRequestDoc(m)},
+			declDoc{key: "decode" + m.Name + "Response", doc: withMethodDoc(grpcDecodeResponseDoc(m), m), dflt: grpcDecodeResponseDoc(m)},
+		)
+	}
+	return docs
+}
+
+func (g *generateGRPCClient) generateDecodeEncodeMethods(pm *pbMessages) {
 	for _, m := range g.serviceInterface.Methods {
+		encode := pm.encodeRequest(m.Name)
+		decode := pm.decodeResponse(m.Name)
+		if !pm.ok {
+			encode = []jen.Code{
+				jen.Return(
+					jen.Nil(), jen.Qual("errors", "New").Call(
+						jen.Lit(fmt.Sprintf("'%s' Encoder is not impelemented", utils.ToCamelCase(g.name))),
+					),
+				),
+			}
+			decode = []jen.Code{
+				jen.Return(
+					jen.Nil(), jen.Qual("errors", "New").Call(
+						jen.Lit(fmt.Sprintf("'%s' Decoder is not impelemented", utils.ToCamelCase(g.name))),
+					),
+				),
+			}
+		}
 		g.code.NewLine()
-		g.code.appendMultilineComment([]string{
-			fmt.Sprintf("encode%sRequest is a transport/grpc.EncodeRequestFunc that converts a", m.Name),
-			" user-domain sum request to a gRPC request.",
-		})
+		g.code.appendMultilineComment(withMethodDoc(grpcEncodeRequestDoc(m), m))
 		g.code.NewLine()
 		g.code.appendFunction(
 			fmt.Sprintf("encode%sRequest", m.Name),
@@ -457,17 +570,10 @@ func (g *generateGRPCClient) generateDecodeEncodeMethods(endpointImport string)
 				jen.Error(),
 			},
 			"",
-			jen.Return(
-				jen.Nil(), jen.Qual("errors", "New").Call(
-					jen.Lit(fmt.Sprintf("'%s' Encoder is not impelemented", utils.ToCamelCase(g.name))),
-				),
-			),
+			encode...,
 		)
 		g.code.NewLine()
-		g.code.appendMultilineComment([]string{
-			fmt.Sprintf("decode%sResponse is a transport/grpc.DecodeResponseFunc that converts", m.Name),
-			"a gRPC concat reply to a user-domain concat response.",
-		})
+		g.code.appendMultilineComment(withMethodDoc(grpcDecodeResponseDoc(m), m))
 		g.code.NewLine()
 		g.code.appendFunction(
 			fmt.Sprintf("decode%sResponse", m.Name),
@@ -481,13 +587,8 @@ func (g *generateGRPCClient) generateDecodeEncodeMethods(endpointImport string)
 				jen.Error(),
 			},
 			"",
-			jen.Return(
-				jen.Nil(), jen.Qual("errors", "New").Call(
-					jen.Lit(fmt.Sprintf("'%s' Decoder is not impelemented", utils.ToCamelCase(g.name))),
-				),
-			),
+			decode...,
 		)
 		g.code.NewLine()
 	}
-	return
 }
@@ -52,6 +52,7 @@ func NewGenerateDocker(glide bool) Gen {
 	i.dockerCompose.Version = "2"
 	i.dockerCompose.Services = map[string]interface{}{}
 	i.fs = fs.Get()
+	i.generator = "GenerateDocker"
 	return i
 }
 
@@ -92,7 +93,7 @@ func (g *GenerateDocker) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile("docker-compose.yml", string(d), true)
+	return g.writeFile("docker-compose.yml", string(d), true)
 }
 func (g *GenerateDocker) generateDockerFile(name, svcFilePath, httpFilePath, grpcFilePath string) (err error) {
 	pth, err := utils.GetDockerFileProjectPath()
@@ -166,7 +167,7 @@ ENTRYPOINT  watcher -run %s/%s/cmd -watch %s/%s
 	} else {
 		dockerFile = fmt.Sprintf(dockerFile, fpath, fpath, pth, name, pth, name)
 	}
-	return g.fs.WriteFile(path.Join(name, "Dockerfile"), dockerFile, true)
+	return g.writeFile(path.Join(name, "Dockerfile"), dockerFile, true)
 }
 
 func (g *GenerateDocker) addToDockerCompose(name, pth, httpFilePath, grpcFilePath string) (err error) {
@@ -47,11 +47,18 @@ func NewGenerateMiddleware(name, serviceName string, ep bool) Gen {
 	}
 	i.filePath = path.Join(i.destPath, viper.GetString("gk_service_file_name"))
 	i.fs = fs.Get()
+	i.generator = "GenerateMiddleware"
 	return i
 }
 
 // Generate generates a new service middleware
-func (g *GenerateMiddleware) Generate() (err error) {
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (g *GenerateMiddleware) Generate() error {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateMiddleware) generate() (err error) {
 	if b, err := g.fs.Exists(g.filePath); err != nil {
 		return err
 	} else if !b {
@@ -138,7 +145,7 @@ func (g *GenerateMiddleware) generateServiceMiddleware() (err error) {
 	}
 	g.serviceGenerator.generateMethodMiddleware(mdwStrucName, false)
 	if g.serviceGenerator.generateFirstTime {
-		return g.fs.WriteFile(g.serviceGenerator.filePath, g.serviceGenerator.srcFile.GoString(), true)
+		return g.writeFile(g.serviceGenerator.filePath, g.serviceGenerator.srcFile.GoString(), true)
 	}
 	src, err := g.fs.ReadFile(g.serviceGenerator.filePath)
 	if err != nil {
@@ -165,7 +172,7 @@ func (g *GenerateMiddleware) generateServiceMiddleware() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.serviceGenerator.filePath, s, true)
+	return g.writeFile(g.serviceGenerator.filePath, s, true)
 }
 func (g *GenerateMiddleware) generateEndpointMiddleware() (err error) {
 	g.srcFile = jen.NewFilePath("endpoint")
@@ -176,7 +183,7 @@ func (g *GenerateMiddleware) generateEndpointMiddleware() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("endpoint")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	epSrc, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -231,7 +238,7 @@ func (g *GenerateMiddleware) generateEndpointMiddleware() (err error) {
 		g.code.NewLine()
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 
 	epSrc += "\n" + g.code.Raw().GoString()
@@ -255,12 +262,12 @@ func (g *GenerateMiddleware) generateEndpointMiddleware() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 func (g *GenerateMiddleware) serviceFound() bool {
 	for n, v := range g.file.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.destPath)
 			return true
 		} else if n == len(g.file.Interfaces)-1 {
 			logrus.Errorf("Could not find the service interface in `%s`", g.serviceName)
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"fmt"
+	"go/ast"
 	"path"
 	"strings"
 
@@ -52,11 +53,18 @@ func NewGenerateService(name, transport string, sMiddleware, gorillaMux, eMiddle
 	i.InitPg()
 	//
 	i.fs = fs.Get()
+	i.generator = "GenerateService"
 	return i
 }
 
 // Generate generates the service.
-func (g *GenerateService) Generate() (err error) {
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
+func (g *GenerateService) Generate() error {
+	return g.generateVerified(g.generate)
+}
+
+func (g *GenerateService) generate() (err error) {
 	for n, v := range SupportedTransports {
 		if v == g.transport {
 			break
@@ -65,6 +73,11 @@ func (g *GenerateService) Generate() (err error) {
 			return
 		}
 	}
+	if protoPath := viper.GetString("g_s_from_proto"); protoPath != "" {
+		if err = NewServiceFromProto(g.name, protoPath, false).Generate(); err != nil {
+			return err
+		}
+	}
 	if b, err := g.fs.Exists(g.filePath); err != nil {
 		return err
 	} else if !b {
@@ -79,6 +92,13 @@ func (g *GenerateService) Generate() (err error) {
 	if !g.serviceFound() {
 		return
 	}
+	// the methods with a wrong format are still part of the service so
+	// their code is not pruned.
+	prG := newGeneratePrune(g.name, g.serviceStructName, g.serviceInterface, viper.GetString("g_s_prune"))
+	err = prG.Generate()
+	if err != nil {
+		return err
+	}
 	g.removeBadMethods()
 	if len(g.serviceInterface.Methods) == 0 {
 		logrus.Error("The service has no suitable methods please implement the interface methods")
@@ -93,10 +113,13 @@ func (g *GenerateService) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	err = g.fs.WriteFile(g.filePath, s, true)
+	err = g.writeFile(g.filePath, s, true)
 	if err != nil {
 		return err
 	}
+	if err = g.syncDocs(g.filePath, g.docs()); err != nil {
+		return err
+	}
 	mdwG := newGenerateServiceMiddleware(g.name, g.file, g.serviceInterface, g.sMiddleware, "")
 	err = mdwG.Generate()
 	if err != nil {
@@ -138,6 +161,9 @@ func (g *GenerateService) generateServiceMethods() {
 		for _, v := range g.file.Methods {
 			if v.Name == m.Name && v.Struct.Type == "*"+g.serviceStructName {
 				logrus.Debugf("Service method `%s` already exists so it will not be recreated.", v.Name)
+				if !sameSignature(m, v) {
+					reportSignatureChange(m.Name, g.serviceStructName+"."+m.Name, g.filePath)
+				}
 				exists = true
 				break
 			}
@@ -160,6 +186,10 @@ func (g *GenerateService) generateServiceMethods() {
 			jen.Comment("TODO implement the business logic of " + m.Name),
 			jen.Return(rt...),
 		}
+		if doc := methodDoc(m); len(doc) > 0 {
+			g.pg.appendMultilineComment(doc)
+			g.pg.NewLine()
+		}
 		g.pg.appendFunction(
 			m.Name,
 			jen.Id(stp).Id("*"+g.serviceStructName),
@@ -171,6 +201,15 @@ func (g *GenerateService) generateServiceMethods() {
 		g.pg.NewLine()
 	}
 }
+
+// docs returns the doc comments of the implementations of the service methods.
+func (g *GenerateService) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(docs, declDoc{key: g.serviceStructName + "." + m.Name, doc: methodDoc(m)})
+	}
+	return docs
+}
 func (g *GenerateService) generateServiceStruct() {
 	for _, v := range g.file.Structures {
 		if v.Name == g.serviceStructName {
@@ -234,7 +273,7 @@ func (g *GenerateService) generateNewBasicStructMethod() {
 func (g *GenerateService) serviceFound() bool {
 	for n, v := range g.file.Interfaces {
 		if v.Name == g.interfaceName {
-			g.serviceInterface = v
+			g.serviceInterface = g.ExpandInterface(v, g.destPath)
 			return true
 		} else if n == len(g.file.Interfaces)-1 {
 			logrus.Errorf("Could not find the service interface in `%s`", g.name)
@@ -254,6 +293,10 @@ func (g *GenerateService) removeBadMethods() {
 			logrus.Warnf("The method '%s' does not have any return value and will be ignored", v.Name)
 			continue
 		}
+		if p, ok := unsupportedParameter(v); ok {
+			logrus.Warnf("The method '%s' uses '%s %s' that can not be encoded by the transport and will be ignored", v.Name, p.Name, p.Type)
+			continue
+		}
 		for n, p := range v.Parameters {
 			if p.Type == "context.Context" {
 				keepMethods = append(keepMethods, v)
@@ -302,6 +345,7 @@ func newGenerateServiceMiddleware(name string, serviceFile *parser.File,
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceMiddleware"
 	return gsm
 }
 func (g *generateServiceMiddleware) Generate() error {
@@ -311,7 +355,7 @@ func (g *generateServiceMiddleware) Generate() error {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("service")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -391,7 +435,11 @@ func (g *generateServiceMiddleware) Generate() error {
 		g.generateMethodMiddleware("loggingMiddleware", true)
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+	}
+	src, err = g.removeStaleDecls(src)
+	if err != nil {
+		return err
 	}
 	src += "\n" + g.code.Raw().GoString()
 	tmpSrc := g.srcFile.GoString()
@@ -415,7 +463,7 @@ func (g *generateServiceMiddleware) Generate() error {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 
 func (g *generateServiceMiddleware) generateMethodMiddleware(mdw string, df bool) {
@@ -431,6 +479,14 @@ func (g *generateServiceMiddleware) generateMethodMiddleware(mdw string, df bool
 		for _, v := range g.file.Methods {
 			if v.Name == m.Name && v.Struct.Type == mdw {
 				mthdFound = true
+				if !sameSignature(m, v) {
+					if df {
+						g.stale = append(g.stale, mdw+"."+m.Name)
+						mthdFound = false
+					} else {
+						reportSignatureChange(m.Name, mdw+"."+m.Name, g.filePath)
+					}
+				}
 				break
 			}
 		}
@@ -442,8 +498,7 @@ func (g *generateServiceMiddleware) generateMethodMiddleware(mdw string, df bool
 			for _, p := range m.Parameters {
 				pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceFile.Imports)
 				if pth != "" {
-					s := strings.Split(p.Type, ".")
-					middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Qual(pth, s[1]))
+					middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceFile.Imports)))
 				} else {
 					if p.Type == "context.Context" {
 						middlewareFuncParam = append(middlewareFuncParam, jen.Id(p.Name).Qual("context", "Context"))
@@ -459,8 +514,7 @@ func (g *generateServiceMiddleware) generateMethodMiddleware(mdw string, df bool
 			for _, p := range m.Results {
 				pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceFile.Imports)
 				if pth != "" {
-					s := strings.Split(p.Type, ".")
-					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Qual(pth, s[1]))
+					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceFile.Imports)))
 				} else {
 					middlewareFuncResult = append(middlewareFuncResult, jen.Id(p.Name).Id(p.Type))
 				}
@@ -468,7 +522,9 @@ func (g *generateServiceMiddleware) generateMethodMiddleware(mdw string, df bool
 			}
 			loggerLog = append([]jen.Code{jen.Lit("method"), jen.Lit(m.Name)}, loggerLog...)
 			var deferBlock *jen.Statement
-			if df {
+			if m.Directives.Skips(parser.SkipMiddleware) {
+				deferBlock = jen.Comment(m.Name + " skips the middleware (kit:skip middleware).").Line()
+			} else if df {
 				deferBlock = jen.Defer().Func().Call().Block(jen.Id(stp).Dot("logger").Dot("Log").Call(
 					loggerLog...,
 				)).Call()
@@ -516,6 +572,7 @@ func newGenerateServiceEndpoints(name string, imports []parser.NamedTypeValue,
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceEndpoints"
 	return gsm
 }
 func (g *generateServiceEndpoints) Generate() error {
@@ -525,7 +582,7 @@ func (g *generateServiceEndpoints) Generate() error {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("endpoint")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	epSrc, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -545,7 +602,11 @@ func (g *generateServiceEndpoints) Generate() error {
 		}
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+	}
+	epSrc, err = g.removeStaleDecls(epSrc)
+	if err != nil {
+		return err
 	}
 	epSrc += "\n" + g.code.Raw().GoString()
 	tmpSrc := g.srcFile.GoString()
@@ -570,7 +631,23 @@ func (g *generateServiceEndpoints) Generate() error {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	if err = g.writeFile(g.filePath, s, true); err != nil {
+		return err
+	}
+	return g.syncDocs(g.filePath, g.docs())
+}
+
+// docs returns the doc comments of the endpoints of the service methods.
+func (g *generateServiceEndpoints) docs() []declDoc {
+	docs := []declDoc{}
+	for _, m := range g.serviceInterface.Methods {
+		docs = append(
+			docs,
+			declDoc{key: "Make" + m.Name + "Endpoint", doc: withMethodDoc(makeEndpointDoc(m), m), dflt: makeEndpointDoc(m)},
+			declDoc{key: "Endpoints." + m.Name, doc: withMethodDoc(endpointsMethodDoc(m), m), dflt: endpointsMethodDoc(m)},
+		)
+	}
+	return docs
 }
 
 func (g *generateServiceEndpoints) generateEndpointsClientMethods() {
@@ -585,7 +662,10 @@ func (g *generateServiceEndpoints) generateEndpointsClientMethods() {
 		found := false
 		for _, v := range g.file.Methods {
 			if v.Name == m.Name && v.Struct.Type == "Endpoints" {
-				found = true
+				found = sameSignature(m, v)
+				if !found {
+					g.stale = append(g.stale, "Endpoints."+m.Name)
+				}
 				break
 			}
 		}
@@ -608,19 +688,10 @@ func (g *generateServiceEndpoints) generateEndpointsClientMethods() {
 				rqName = rqName + fmt.Sprintf("%d", i)
 				i++
 			}
-			tp := p.Type
-			ts := strings.Split(tp, ".")
-			if len(ts) == 1 {
-				if tp[:1] == strings.ToUpper(tp[:1]) && tp[0] != '[' && tp[0] != '*' {
-					// If the type of the parameter is not `something.MyType` and it starts with an uppercase
-					// than the type was defined inside the service package.
-					tp = "service." + tp
-				}
-			}
+			tp := serviceType(p.Type)
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-				sp = append(sp, jen.Id(p.Name).Qual(pth, s[1]))
+				sp = append(sp, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceImports)))
 			} else {
 				sp = append(sp, jen.Id(p.Name).Id(tp))
 			}
@@ -641,19 +712,10 @@ func (g *generateServiceEndpoints) generateEndpointsClientMethods() {
 				rqName = rqName + fmt.Sprintf("%d", i)
 				i++
 			}
-			tp := p.Type
-			ts := strings.Split(tp, ".")
-			if len(ts) == 1 {
-				if tp[:1] == strings.ToUpper(tp[:1]) && tp[0] != '[' && tp[0] != '*' {
-					// If the type of the parameter is not `something.MyType` and it starts with an uppercase
-					// than the type was defined inside the service package.
-					tp = "service." + tp
-				}
-			}
+			tp := serviceType(p.Type)
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-				rs = append(rs, jen.Id(p.Name).Qual(pth, s[1]))
+				rs = append(rs, jen.Id(p.Name).Add(g.TypeCode(p.Type, g.serviceImports)))
 			} else {
 				rs = append(rs, jen.Id(p.Name).Id(tp))
 			}
@@ -676,7 +738,8 @@ func (g *generateServiceEndpoints) generateEndpointsClientMethods() {
 			),
 			jen.Return(jen.List(resList...)),
 		}
-		g.code.Raw().Commentf("%s implements Service. Primarily useful in a client.", m.Name).Line()
+		g.code.appendMultilineComment(withMethodDoc(endpointsMethodDoc(m), m))
+		g.code.NewLine()
 		g.code.appendFunction(
 			m.Name,
 			jen.Id(stp).Id("Endpoints"),
@@ -711,19 +774,10 @@ func (g *generateServiceEndpoints) generateMethodEndpoint() (err error) {
 				mCallParam = append(mCallParam, jen.Id(p.Name))
 				continue
 			}
-			tp := p.Type
-			ts := strings.Split(tp, ".")
-			if len(ts) == 1 {
-				if tp[:1] == strings.ToUpper(tp[:1]) && tp[0] != '[' && tp[0] != '*' {
-					// If the type of the parameter is not `something.MyType` and it starts with an uppercase
-					// than the type was defined inside the service package.
-					tp = "service." + tp
-				}
-			}
+			tp := serviceType(p.Type)
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-				reqFields = append(reqFields, jen.Id(utils.ToCamelCase(p.Name)).Qual(pth, s[1]).Tag(map[string]string{
+				reqFields = append(reqFields, jen.Id(utils.ToCamelCase(p.Name)).Add(g.TypeCode(p.Type, g.serviceImports)).Tag(map[string]string{
 					"json": utils.ToLowerSnakeCase(utils.ToCamelCase(p.Name)),
 				}))
 			} else {
@@ -742,19 +796,10 @@ func (g *generateServiceEndpoints) generateMethodEndpoint() (err error) {
 				methodHasError = true
 				errName = utils.ToCamelCase(p.Name)
 			}
-			tp := p.Type
-			ts := strings.Split(tp, ".")
-			if len(ts) == 1 {
-				if tp[:1] == strings.ToUpper(tp[:1]) && tp[0] != '[' && tp[0] != '*' {
-					// If the type of the parameter is not `something.MyType` and it starts with an uppercase
-					// than the type was defined inside the service package.
-					tp = "service." + tp
-				}
-			}
+			tp := serviceType(p.Type)
 			pth := g.EnsureThatWeUseQualifierIfNeeded(p.Type, g.serviceImports)
 			if pth != "" {
-				s := strings.Split(p.Type, ".")
-				resFields = append(resFields, jen.Id(utils.ToCamelCase(p.Name)).Qual(pth, s[1]).Tag(map[string]string{
+				resFields = append(resFields, jen.Id(utils.ToCamelCase(p.Name)).Add(g.TypeCode(p.Type, g.serviceImports)).Tag(map[string]string{
 					"json": utils.ToLowerSnakeCase(p.Name),
 				}))
 			} else {
@@ -791,6 +836,25 @@ func (g *generateServiceEndpoints) generateMethodEndpoint() (err error) {
 				break
 			}
 		}
+		for _, v := range g.file.Structures {
+			if (v.Name == m.Name+"Request" && !sameFields(m.Parameters, v)) ||
+				(v.Name == m.Name+"Response" && !sameFields(m.Results, v)) {
+				// The signature changed, everything built from the request and
+				// response structs needs to be generated again.
+				g.stale = append(
+					g.stale,
+					m.Name+"Request",
+					m.Name+"Response",
+					"Make"+m.Name+"Endpoint",
+					m.Name+"Response.Failed",
+				)
+				requestStructExists = false
+				responseStructExists = false
+				makeMethdExists = false
+				failedFound = false
+				break
+			}
+		}
 		if !requestStructExists {
 			g.code.Raw().Commentf("%sRequest collects the request parameters for the %s method.", m.Name, m.Name)
 			g.code.NewLine()
@@ -835,7 +899,7 @@ func (g *generateServiceEndpoints) generateMethodEndpoint() (err error) {
 				"",
 				bd...,
 			)
-			g.code.Raw().Commentf("Make%sEndpoint returns an endpoint that invokes %s on the service.", m.Name, m.Name)
+			g.code.appendMultilineComment(withMethodDoc(makeEndpointDoc(m), m))
 			g.code.NewLine()
 			g.code.appendFunction(
 				"Make"+m.Name+"Endpoint",
@@ -910,6 +974,7 @@ func newGenerateServiceEndpointsBase(name string, serviceInterface parser.Interf
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateServiceEndpointsBase"
 	return gsm
 }
 func (g *generateServiceEndpointsBase) Generate() (err error) {
@@ -971,7 +1036,7 @@ func (g *generateServiceEndpointsBase) Generate() (err error) {
 		body...,
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 }
 
 type generateEndpointMiddleware struct {
@@ -994,6 +1059,7 @@ func newGenerateEndpointMiddleware(name string) Gen {
 	gsm.srcFile = jen.NewFilePath(gsm.destPath)
 	gsm.InitPg()
 	gsm.fs = fs.Get()
+	gsm.generator = "generateEndpointMiddleware"
 	return gsm
 }
 func (g *generateEndpointMiddleware) Generate() (err error) {
@@ -1006,7 +1072,7 @@ func (g *generateEndpointMiddleware) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("endpoint")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1143,7 +1209,7 @@ func (g *generateEndpointMiddleware) Generate() (err error) {
 		g.code.NewLine()
 	}
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 
 	src += "\n" + g.code.Raw().GoString()
@@ -1167,7 +1233,7 @@ func (g *generateEndpointMiddleware) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 
 type generateCmdBase struct {
@@ -1205,6 +1271,7 @@ func newGenerateCmdBase(name string, serviceInterface parser.Interface,
 	t.srcFile = jen.NewFile("service")
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateCmdBase"
 	return t
 }
 func (g *generateCmdBase) Generate() (err error) {
@@ -1386,6 +1453,13 @@ func (g *generateCmdBase) Generate() (err error) {
 			pl.Raw(),
 		)
 		g.code.NewLine()
+		health, err := g.grpcHealth(g.name)
+		if err != nil {
+			return err
+		}
+		if health {
+			appendGRPCHealth(g.code, g.protoService(g.name))
+		}
 	}
 	if g.generateEndpointDefaultsMiddleware {
 		body := []jen.Code{}
@@ -1471,7 +1545,7 @@ func (g *generateCmdBase) Generate() (err error) {
 		),
 	)
 	g.code.NewLine()
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 }
 
 type generateCmd struct {
@@ -1511,6 +1585,7 @@ func newGenerateCmd(name string, serviceInterface parser.Interface,
 	t.srcFile = jen.NewFile("service")
 	t.InitPg()
 	t.fs = fs.Get()
+	t.generator = "generateCmd"
 	return t
 }
 
@@ -1525,7 +1600,7 @@ func (g *generateCmd) Generate() (err error) {
 	} else if !b {
 		g.generateFirstTime = true
 		f := jen.NewFile("service")
-		g.fs.WriteFile(g.filePath, f.GoString(), false)
+		g.writeFile(g.filePath, f.GoString(), false)
 	}
 	src, err := g.fs.ReadFile(g.filePath)
 	if err != nil {
@@ -1580,7 +1655,7 @@ func (g *generateCmd) Generate() (err error) {
 	g.generateCancelInterrupt()
 	g.generateCmdMain()
 	if g.generateFirstTime {
-		return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), true)
+		return g.writeFile(g.filePath, g.srcFile.GoString(), true)
 	}
 	tmpSrc := g.srcFile.GoString()
 	f, err := parser.NewFileParser().Parse([]byte(tmpSrc))
@@ -1594,6 +1669,8 @@ func (g *generateCmd) Generate() (err error) {
 	}
 	if len(imp) > 0 {
 		pSrc := g.code.Raw().GoString()
+		// the imports without alias have the name of their package.
+		fileImports := g.resolver().resolveImports(g.file.Imports)
 		foundSameImport := false
 		inx := 0
 		// Small(stupid) workaround
@@ -1601,7 +1678,7 @@ func (g *generateCmd) Generate() (err error) {
 		mp := map[string]string{}
 		keep := imp
 		for a, i := range imp {
-			for _, v := range g.file.Imports {
+			for _, v := range fileImports {
 				if v.Type == i.Type && i.Name != v.Name {
 					mp[txt+i.Name] = v.Name
 					pSrc = strings.Replace(pSrc, i.Name+".", txt+i.Name+".", -1)
@@ -1611,7 +1688,7 @@ func (g *generateCmd) Generate() (err error) {
 		}
 
 		for a, i := range keep {
-			for _, v := range g.file.Imports {
+			for _, v := range fileImports {
 				if v.Name == i.Name {
 					foundSameImport = true
 					inx = a
@@ -1623,7 +1700,7 @@ func (g *generateCmd) Generate() (err error) {
 			a := 1
 			for {
 				canUse := true
-				for _, v := range g.file.Imports {
+				for _, v := range fileImports {
 					if fmt.Sprintf("%s%d", keep[inx].Name, a) == v.Name {
 						canUse = false
 						break
@@ -1655,7 +1732,7 @@ func (g *generateCmd) Generate() (err error) {
 	if err != nil {
 		return err
 	}
-	return g.fs.WriteFile(g.filePath, s, true)
+	return g.writeFile(g.filePath, s, true)
 }
 func (g *generateCmd) generateRun() (*PartialGenerator, error) {
 	pg := NewPartialGenerator(nil)
@@ -1942,6 +2019,7 @@ func (g *generateCmd) generateInitHTTP() (err error) {
 func (g *generateCmd) generateInitGRPC() (err error) {
 	for _, v := range g.file.Methods {
 		if v.Name == "initGRPCHandler" {
+			warnGRPCHealth(v.Body, g.filePath)
 			return
 		}
 	}
@@ -1985,29 +2063,11 @@ func (g *generateCmd) generateInitGRPC() (err error) {
 			),
 		),
 	).Line()
-	pt.Raw().Id("g").Dot("Add").Call(
-		jen.Func().Params().Error().Block(
-			jen.Id("logger").Dot("Log").Call(
-				jen.Lit("transport"),
-				jen.Lit("gRPC"),
-				jen.Lit("addr"),
-				jen.Id("*grpcAddr"),
-			),
-			jen.Id("baseServer").Op(":=").Qual("google.golang.org/grpc", "NewServer").Call(),
-			jen.Qual(pbImport, fmt.Sprintf("Register%sServer", utils.ToCamelCase(g.name))).Call(
-				jen.Id("baseServer"),
-				jen.Id("grpcServer"),
-			),
-			jen.Return(
-				jen.Id("baseServer").Dot("Serve").Call(
-					jen.Id("grpcListener"),
-				),
-			),
-		),
-		jen.Func().Params(jen.Error()).Block(
-			jen.Id("grpcListener").Dot("Close").Call(),
-		),
-	).Line()
+	health, err := g.grpcHealth(g.name)
+	if err != nil {
+		return err
+	}
+	pt.Raw().Add(grpcServe(pbImport, g.name, health))
 	g.code.NewLine()
 	g.code.appendFunction(
 		"initGRPCHandler",
@@ -2221,5 +2281,34 @@ func (g *generateCmd) generateCmdMain() error {
 	src.Func().Id("main").Params().Block(
 		jen.Qual(cmdSvcImport, "Run").Call(),
 	)
-	return g.fs.WriteFile(mainFilePath, src.GoString(), false)
+	return g.writeFile(mainFilePath, src.GoString(), false)
+}
+
+// serviceType qualifies the exported types of `tp` that have no package with
+// `service.`, they were defined inside the service package (e.x
+// `[]*service.User` for `[]*User`).
+func serviceType(tp string) string {
+	t, err := parser.ParseType(tp)
+	if err != nil {
+		return tp
+	}
+	qualifyServiceTypes(t)
+	return t.String()
+}
+
+func qualifyServiceTypes(t *parser.Type) {
+	if t == nil {
+		return
+	}
+	if t.Kind == parser.KindIdent && t.Package == "" && ast.IsExported(t.Name) {
+		t.Package = "service"
+	}
+	for _, c := range append([]*parser.Type{t.Elem, t.Key, t.Value}, t.TypeArgs...) {
+		qualifyServiceTypes(c)
+	}
+	for _, l := range [][]parser.Field{t.Params, t.Results, t.Fields, t.Methods} {
+		for _, f := range l {
+			qualifyServiceTypes(f.Type)
+		}
+	}
 }
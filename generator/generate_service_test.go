@@ -1 +1,115 @@
 package generator
+
+import (
+	"testing"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
+)
+
+func Test_serviceType(t *testing.T) {
+	tests := map[string]string{
+		"string":             "string",
+		"User":               "service.User",
+		"[]*User":            "[]*service.User",
+		"map[string]Status":  "map[string]service.Status",
+		"...User":            "...service.User",
+		"time.Time":          "time.Time",
+		"map[model.Key]User": "map[model.Key]service.User",
+		"func(User) error":   "func(service.User) error",
+	}
+	for tp, want := range tests {
+		if got := serviceType(tp); got != want {
+			t.Errorf("serviceType(%s) = %v, want %v", tp, got, want)
+		}
+	}
+}
+
+func TestGenerateService_docs(t *testing.T) {
+	src := `package service
+
+// Foo was documented by hand.
+func (b *basicUsersService) Foo(ctx context.Context) (err error) {
+	return
+}
+
+func (b *basicUsersService) Bar(ctx context.Context) (err error) {
+	return
+}
+`
+	g := &GenerateService{
+		BaseGenerator:     BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}},
+		serviceStructName: "basicUsersService",
+		serviceInterface: parser.Interface{Methods: []parser.Method{
+			{Name: "Foo", Comment: "Foo does foo.\n"},
+			{Name: "Bar", Comment: "Bar does bar.\nkit:http GET /bar\n"},
+		}},
+	}
+	afero.WriteFile(g.fs.Fs, "pkg/service/service.go", []byte(src), 0644)
+	if err := g.syncDocs("pkg/service/service.go", g.docs()); err != nil {
+		t.Fatalf("GenerateService.syncDocs() error = %v", err)
+	}
+	want := `package service
+
+// Foo was documented by hand.
+func (b *basicUsersService) Foo(ctx context.Context) (err error) {
+	return
+}
+
+// Bar does bar.
+func (b *basicUsersService) Bar(ctx context.Context) (err error) {
+	return
+}
+`
+	if got, _ := g.fs.ReadFile("pkg/service/service.go"); got != want {
+		t.Errorf("GenerateService.syncDocs() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_generateServiceEndpoints_docs(t *testing.T) {
+	src := `package endpoint
+
+// MakeFooEndpoint returns an endpoint that invokes Foo on the service.
+func MakeFooEndpoint(s service.UsersService) endpoint.Endpoint {
+	return nil
+}
+
+// Foo implements Service. Primarily useful in a client.
+//
+// Foo does foo.
+func (e Endpoints) Foo(ctx context.Context) (err error) {
+	return
+}
+`
+	g := &generateServiceEndpoints{
+		BaseGenerator: BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}},
+		serviceInterface: parser.Interface{Methods: []parser.Method{
+			{Name: "Foo", Comment: "Foo does foo and bar.\n"},
+		}},
+	}
+	afero.WriteFile(g.fs.Fs, "pkg/endpoint/endpoint.go", []byte(src), 0644)
+	if err := g.syncDocs("pkg/endpoint/endpoint.go", g.docs()); err != nil {
+		t.Fatalf("generateServiceEndpoints.syncDocs() error = %v", err)
+	}
+	// the doc of the client method was edited since the default one, it is kept.
+	want := `package endpoint
+
+// MakeFooEndpoint returns an endpoint that invokes Foo on the service.
+//
+// Foo does foo and bar.
+func MakeFooEndpoint(s service.UsersService) endpoint.Endpoint {
+	return nil
+}
+
+// Foo implements Service. Primarily useful in a client.
+//
+// Foo does foo.
+func (e Endpoints) Foo(ctx context.Context) (err error) {
+	return
+}
+`
+	if got, _ := g.fs.ReadFile("pkg/endpoint/endpoint.go"); got != want {
+		t.Errorf("generateServiceEndpoints.syncDocs() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"go/ast"
 	ps "go/parser"
 	"go/token"
+	"path"
 
 	"strings"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/hms58/genkit/fs"
 	"github.com/hms58/genkit/parser"
 	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
 )
 
 // Gen represents a generator.
@@ -30,6 +32,20 @@ type BaseGenerator struct {
 	srcFile *jen.File
 	code    *PartialGenerator
 	fs      *fs.KitFs
+	// generator is the name of the generator recorded in the manifest for
+	// the files it writes.
+	generator string
+	// stale holds the generated declarations that have to be regenerated.
+	stale []string
+	res   *typeResolver
+}
+
+// writeFile writes the file as the generator, see fs.KitFs.WriteFileAs.
+func (b *BaseGenerator) writeFile(path string, data string, force bool) error {
+	if b.generator == "" {
+		return b.fs.WriteFile(path, data, force)
+	}
+	return b.fs.WriteFileAs(b.generator, path, data, force)
 }
 
 // InitPg initiates the partial generator (used when we don't want to generate the full source only portions)
@@ -45,7 +61,7 @@ func (b *BaseGenerator) getMissingImports(imp []parser.NamedTypeValue, f *parser
 				if err != nil {
 					return n, err
 				}
-				if v.Type == vo.Type && strings.HasSuffix(tp, v.Name) {
+				if v.Type == vo.Type && b.resolver().packageName(tp) == v.Name {
 					break
 				}
 			}
@@ -75,6 +91,62 @@ func (b *BaseGenerator) CreateFolderStructure(path string) error {
 	return nil
 }
 
+// ParsePackage parses the go files of the package in `dir` (without the tests).
+func (b *BaseGenerator) ParsePackage(dir string) (*parser.Package, error) {
+	infos, err := afero.ReadDir(b.fs.Fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		p := path.Join(dir, name)
+		if e, _ := b.fs.Exists(p); !e {
+			continue
+		}
+		src, err := b.fs.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = []byte(src)
+	}
+	return parser.NewPackageParser().Parse(files)
+}
+
+// ExpandInterface adds the methods of the interfaces embedded in the interface
+// `iface` of the package in `dir`, they can be declared in that package or in
+// another package of the project.
+func (b *BaseGenerator) ExpandInterface(iface parser.Interface, dir string) parser.Interface {
+	if len(iface.Embedded) == 0 {
+		return iface
+	}
+	pkg, err := b.ParsePackage(dir)
+	if err != nil {
+		logrus.Warnf("Could not parse `%s`, the embedded interfaces of `%s` are ignored: %s", dir, iface.Name, err)
+		return iface
+	}
+	projectPath, _ := utils.GetProjectPath()
+	expanded, err := pkg.ExpandInterface(iface, func(importPath string) (*parser.Package, error) {
+		if projectPath == "" || (importPath != projectPath && !strings.HasPrefix(importPath, projectPath+"/")) {
+			// only the packages of the project are parsed.
+			return nil, nil
+		}
+		d := strings.TrimPrefix(strings.TrimPrefix(importPath, projectPath), "/")
+		if d == "" {
+			d = "."
+		}
+		return b.ParsePackage(d)
+	})
+	if err != nil {
+		logrus.Warnf("Could not expand the embedded interfaces of `%s`: %s", iface.Name, err)
+		return iface
+	}
+	return expanded
+}
+
 // GenerateNameBySample is used to generate a variable name using a sample.
 //
 // The exclude parameter represents the names that it can not use.
@@ -87,7 +159,7 @@ func (b *BaseGenerator) GenerateNameBySample(sample string, exclude []parser.Nam
 		if v.Name == name {
 			sn++
 			if sn > len(sample) {
-				sample = string(len(sample) - sn)
+				sample = string(rune(len(sample) - sn))
 			}
 			name = utils.ToLowerFirstCamelCase(sample)[:sn]
 		}
@@ -96,79 +168,224 @@ func (b *BaseGenerator) GenerateNameBySample(sample string, exclude []parser.Nam
 }
 
 // EnsureThatWeUseQualifierIfNeeded is used to see if we need to import a path of a given type.
+//
+// It returns the import path of the first qualified type found in `tp` (e.x
+// `pb` for `[]*pb.User`), use TypeCode to generate the type. The qualifiers are
+// matched with the names of the imported packages, not with their paths.
 func (b *BaseGenerator) EnsureThatWeUseQualifierIfNeeded(tp string, imp []parser.NamedTypeValue) string {
-	if bytes.HasPrefix([]byte(tp), []byte("...")) {
+	t, err := parser.ParseType(tp)
+	if err != nil || t.Kind == parser.KindVariadic {
 		return ""
 	}
-	if t := strings.Split(tp, "."); len(t) > 0 {
-		s := t[0]
-		for _, v := range imp {
-			i, _ := strconv.Unquote(v.Type)
-			if strings.HasSuffix(i, s) || v.Name == s {
-				return i
+	imp = b.resolver().resolveImports(imp)
+	for _, q := range qualifiedTypes(t) {
+		if i := importPath(q.Package, imp); i != "" {
+			return i
+		}
+	}
+	return ""
+}
+
+// TypeCode returns the code of the type `tp`, the qualified types use the
+// import paths in `imp` so the imports are added to the generated file. The
+// qualified types that are not in `imp` are kept as they are.
+func (b *BaseGenerator) TypeCode(tp string, imp []parser.NamedTypeValue) jen.Code {
+	t, err := parser.ParseType(tp)
+	if err != nil {
+		return jen.Id(tp)
+	}
+	return typeCode(t, b.resolver().resolveImports(imp))
+}
+
+func typeCode(t *parser.Type, imp []parser.NamedTypeValue) *jen.Statement {
+	switch t.Kind {
+	case parser.KindIdent:
+		if i := importPath(t.Package, imp); i != "" && len(t.TypeArgs) == 0 {
+			return jen.Qual(i, t.Name)
+		}
+		return jen.Id(t.String())
+	case parser.KindPointer:
+		return jen.Op("*").Add(typeCode(t.Elem, imp))
+	case parser.KindSlice:
+		return jen.Index().Add(typeCode(t.Elem, imp))
+	case parser.KindArray:
+		return jen.Index(jen.Id(t.Len)).Add(typeCode(t.Elem, imp))
+	case parser.KindVariadic:
+		return jen.Op("...").Add(typeCode(t.Elem, imp))
+	case parser.KindMap:
+		return jen.Map(typeCode(t.Key, imp)).Add(typeCode(t.Value, imp))
+	case parser.KindChan:
+		switch t.Dir {
+		case ast.SEND:
+			return jen.Chan().Op("<-").Add(typeCode(t.Elem, imp))
+		case ast.RECV:
+			return jen.Op("<-").Chan().Add(typeCode(t.Elem, imp))
+		}
+		if t.Elem.Kind == parser.KindChan && t.Elem.Dir == ast.RECV {
+			return jen.Chan().Parens(typeCode(t.Elem, imp))
+		}
+		return jen.Chan().Add(typeCode(t.Elem, imp))
+	case parser.KindFunc:
+		return jen.Func().Params(fieldsCode(t.Params, imp)...).Add(resultsCode(t.Results, imp))
+	case parser.KindStruct:
+		fields := []jen.Code{}
+		for _, f := range t.Fields {
+			c := typeCode(f.Type, imp)
+			if f.Name != "" {
+				c = jen.Id(f.Name).Add(c)
 			}
+			if f.Tag != "" {
+				c.Op(f.Tag)
+			}
+			fields = append(fields, c)
+		}
+		return jen.Struct(fields...)
+	case parser.KindInterface:
+		methods := []jen.Code{}
+		for _, m := range t.Methods {
+			if m.Name == "" {
+				methods = append(methods, typeCode(m.Type, imp))
+				continue
+			}
+			methods = append(methods, jen.Id(m.Name).Params(fieldsCode(m.Type.Params, imp)...).Add(
+				resultsCode(m.Type.Results, imp),
+			))
 		}
+		return jen.Interface(methods...)
+	}
+	return jen.Id(t.String())
+}
+
+func fieldsCode(fields []parser.Field, imp []parser.NamedTypeValue) []jen.Code {
+	c := []jen.Code{}
+	for _, f := range fields {
+		if f.Name == "" {
+			c = append(c, typeCode(f.Type, imp))
+		} else {
+			c = append(c, jen.Id(f.Name).Add(typeCode(f.Type, imp)))
+		}
+	}
+	return c
+}
+
+func resultsCode(results []parser.Field, imp []parser.NamedTypeValue) jen.Code {
+	switch {
+	case len(results) == 0:
+		return jen.Null()
+	case len(results) == 1 && results[0].Name == "":
+		return typeCode(results[0].Type, imp)
+	}
+	return jen.Params(fieldsCode(results, imp)...)
+}
+
+// qualifiedTypes returns the qualified types used in `t` (e.x the key and the
+// value of `map[a.Key]*b.Value`).
+func qualifiedTypes(t *parser.Type) []*parser.Type {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == parser.KindIdent {
+		if t.Package != "" {
+			return []*parser.Type{t}
+		}
+		return nil
+	}
+	q := []*parser.Type{}
+	for _, c := range []*parser.Type{t.Elem, t.Key, t.Value} {
+		q = append(q, qualifiedTypes(c)...)
+	}
+	for _, l := range [][]parser.Field{t.Params, t.Results, t.Fields, t.Methods} {
+		for _, f := range l {
+			q = append(q, qualifiedTypes(f.Type)...)
+		}
+	}
+	return q
+}
+
+// importPath returns the path of the import of the package `pkg`, the name of
+// the imports without alias is guessed from their path (see resolveImports).
+func importPath(pkg string, imp []parser.NamedTypeValue) string {
+	if pkg == "" {
 		return ""
 	}
+	for _, v := range imp {
+		i, _ := strconv.Unquote(v.Type)
+		name := v.Name
+		if name == "" {
+			name = guessPackageName(i)
+		}
+		if name == pkg {
+			return i
+		}
+	}
 	return ""
 }
 
-// AddImportsToFile adds missing imports toa file that we edit with the generator
+// unsupportedParameter returns the first parameter or result of `m` that can
+// not be sent by a transport (channels and functions can not be encoded), the
+// channels of streamChans stream their elements.
+func unsupportedParameter(m parser.Method) (parser.NamedTypeValue, bool) {
+	for _, l := range [][]parser.NamedTypeValue{m.Parameters, m.Results} {
+		streamed := false
+		for _, p := range l {
+			t, err := p.TypeExpr()
+			if err != nil {
+				return p, true
+			}
+			if !streamed && t.Kind == parser.KindChan && t.Dir&ast.RECV != 0 {
+				streamed = true
+				t = t.Elem
+			}
+			for _, k := range []string{parser.KindChan, parser.KindFunc} {
+				if _, ok := t.Find(k); ok {
+					return p, true
+				}
+			}
+		}
+	}
+	return parser.NamedTypeValue{}, false
+}
+
+// AddImportsToFile adds missing imports toa file that we edit with the generator,
+// the imports are added to the source so the comments of the file are kept.
 func (b *BaseGenerator) AddImportsToFile(imp []parser.NamedTypeValue, src string) (string, error) {
 	// Create the AST by parsing src
 	fset := token.NewFileSet()
-	f, err := ps.ParseFile(fset, "", src, 0)
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
 	if err != nil {
 		return "", err
 	}
-	found := false
-	// Add the imports
-	for i := 0; i < len(f.Decls); i++ {
-		d := f.Decls[i]
-		switch d.(type) {
-		case *ast.FuncDecl:
-			// No action
-		case *ast.GenDecl:
-			dd := d.(*ast.GenDecl)
-
-			// IMPORT Declarations
-			if dd.Tok == token.IMPORT {
-				if dd.Rparen == 0 || dd.Lparen == 0 {
-					dd.Rparen = f.Package
-					dd.Lparen = f.Package
-				}
-				found = true
-				// Add the new import
-				for _, v := range imp {
-					iSpec := &ast.ImportSpec{
-						Name: &ast.Ident{Name: v.Name},
-						Path: &ast.BasicLit{Value: v.Type},
-					}
-					dd.Specs = append(dd.Specs, iSpec)
-				}
-			}
+	specs := ""
+	for _, v := range imp {
+		specs += fmt.Sprintf("%s %s\n", v.Name, v.Type)
+	}
+	var dd *ast.GenDecl
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			dd = g
+			break
 		}
 	}
-	if !found {
-		dd := ast.GenDecl{
-			TokPos: f.Package + 1,
-			Tok:    token.IMPORT,
-			Specs:  []ast.Spec{},
-			Lparen: f.Package,
-			Rparen: f.Package,
-		}
-		lastPos := 0
-		for _, v := range imp {
-			lastPos += len(v.Name) + len(v.Type) + 1
-			iSpec := &ast.ImportSpec{
-				Name:   &ast.Ident{Name: v.Name},
-				Path:   &ast.BasicLit{Value: v.Type},
-				EndPos: token.Pos(lastPos),
-			}
-			dd.Specs = append(dd.Specs, iSpec)
-
+	switch {
+	case dd == nil:
+		// There is no import declaration, add one after the package clause.
+		end := fset.Position(f.Name.End()).Offset
+		src = src[:end] + "\n\nimport (\n" + specs + ")\n" + src[end:]
+	case dd.Lparen.IsValid():
+		end := fset.Position(dd.Rparen).Offset
+		if !strings.HasSuffix(strings.TrimRight(src[:end], " \t"), "\n") {
+			specs = "\n" + specs
 		}
-		f.Decls = append([]ast.Decl{&dd}, f.Decls...)
+		src = src[:end] + specs + src[end:]
+	default:
+		// e.x `import "context"`, group it with the new imports.
+		start, end := fset.Position(dd.Pos()).Offset, fset.Position(dd.End()).Offset
+		src = src[:start] + "import (\n" + src[start+len("import"):end] + "\n" + specs + ")" + src[end:]
+	}
+	fset = token.NewFileSet()
+	f, err = ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return "", err
 	}
 
 	// Sort the imports
@@ -198,6 +415,10 @@ func NewPartialGenerator(st *jen.Statement) *PartialGenerator {
 }
 func (p *PartialGenerator) appendMultilineComment(c []string) {
 	for i, v := range c {
+		if v == "" {
+			// an empty line of the comment without a trailing space.
+			v = "//"
+		}
 		if i != len(c)-1 {
 			p.raw.Comment(v).Line()
 			continue
@@ -1,12 +1,19 @@
 package generator
 
 import (
+	"fmt"
 	"path"
+	"reflect"
+	"strings"
+	"testing"
 
 	"runtime"
 
+	"github.com/dave/jennifer/jen"
+	"github.com/hms58/genkit/fs"
 	"github.com/hms58/genkit/parser"
 	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
 
@@ -29,6 +36,7 @@ func setDefaults() {
 	viper.SetDefault("gk_endpoint_middleware_file_name", "middleware.go")
 	viper.SetDefault("gk_http_file_name", "handler.go")
 	viper.SetDefault("gk_http_base_file_name", "handler_gen.go")
+	viper.SetDefault("gk_http_gateway_file_name", "gateway_gen.go")
 	viper.SetDefault("gk_cmd_base_file_name", "service_gen.go")
 	viper.SetDefault("gk_cmd_svc_file_name", "service.go")
 	viper.SetDefault("gk_http_client_file_name", "http.go")
@@ -36,12 +44,20 @@ func setDefaults() {
 	viper.SetDefault("gk_grpc_pb_file_name", "%s.proto")
 	viper.SetDefault("gk_grpc_base_file_name", "handler_gen.go")
 	viper.SetDefault("gk_grpc_file_name", "handler.go")
+	viper.SetDefault("gk_grpc_convert_file_name", "convert_gen.go")
 	if runtime.GOOS == "windows" {
 		viper.SetDefault("gk_grpc_compile_file_name", "compile.bat")
 	} else {
 		viper.SetDefault("gk_grpc_compile_file_name", "compile.sh")
 	}
 	viper.SetDefault("gk_service_struct_prefix", "basic")
+	viper.SetDefault("gk_protoc", "protoc")
+	viper.SetDefault("gk_protoc_include_paths", []string{"."})
+	viper.SetDefault("gk_protoc_plugins", []string{"go:plugins=grpc"})
+	viper.SetDefault("gk_protoc_paths", "source_relative")
+	viper.SetDefault("gk_proto_options", map[string]string{})
+	viper.SetDefault("gk_grpc_health", false)
+	viper.SetDefault("gk_grpc_gateway", false)
 	viper.Set("gk_testing", true)
 
 }
@@ -68,3 +84,134 @@ func getTestServiceInterface(name string) parser.Interface {
 		),
 	})
 }
+
+func TestBaseGenerator_TypeCode(t *testing.T) {
+	imp := []parser.NamedTypeValue{
+		parser.NewNameType("", "\"context\""),
+		parser.NewNameType("", "\"example.com/p/pb\""),
+	}
+	tests := []struct {
+		tp   string
+		want string
+	}{
+		{"int", "var v int"},
+		{"context.Context", "import context \"context\"\n\nvar v context.Context"},
+		{"[]*pb.User", "import pb \"example.com/p/pb\"\n\nvar v []*pb.User"},
+		{"map[string][4]pb.User", "import pb \"example.com/p/pb\"\n\nvar v map[string][4]pb.User"},
+		{"<-chan error", "var v <-chan error"},
+		{"func(context.Context) (n int, err error)", "import context \"context\"\n\nvar v func(context.Context) (n int, err error)"},
+		{"struct{ A unknown.A }", "var v struct {\n\tA unknown.A\n}"},
+	}
+	b := &BaseGenerator{}
+	for _, tt := range tests {
+		t.Run(tt.tp, func(t *testing.T) {
+			f := jen.NewFile("p")
+			f.Var().Id("v").Add(b.TypeCode(tt.tp, imp))
+			want := fmt.Sprintf("package p\n\n%s\n", tt.want)
+			if got := f.GoString(); got != want {
+				t.Errorf("BaseGenerator.TypeCode() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_withMethodDoc(t *testing.T) {
+	tests := []struct {
+		name string
+		m    parser.Method
+		want []string
+	}{
+		{
+			name: "Test no method doc",
+			m:    parser.Method{Name: "Foo"},
+			want: []string{"makeFooHandler creates the handler logic"},
+		},
+		{
+			name: "Test method doc",
+			m:    parser.Method{Name: "Foo", Comment: "Foo greets.\n\nIt says hello.\n"},
+			want: []string{"makeFooHandler creates the handler logic", "", "Foo greets.", "", "It says hello."},
+		},
+		{
+			name: "Test service template comment",
+			m:    parser.Method{Name: "Foo", Comment: strings.Join(serviceTemplateComment, "\n") + "\nFoo greets.\n"},
+			want: []string{"makeFooHandler creates the handler logic", "", "Foo greets."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withMethodDoc(makeHandlerDoc(tt.m), tt.m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withMethodDoc() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseGenerator_syncDocs(t *testing.T) {
+	src := "package a\n\n// Old doc.\nfunc A() {}\n\n// Mine.\nfunc B() {}\n\nfunc C() {}\n"
+	b := &BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}}
+	afero.WriteFile(b.fs.Fs, "a/a.go", []byte(src), 0644)
+	err := b.syncDocs("a/a.go", []declDoc{
+		{key: "A", doc: []string{"A does a.", "", "More."}, dflt: []string{"Old doc."}},
+		{key: "B", doc: []string{"B does b."}, dflt: []string{"B"}},
+		{key: "C", doc: []string{"C does c."}},
+	})
+	if err != nil {
+		t.Fatalf("BaseGenerator.syncDocs() error = %v", err)
+	}
+	want := "package a\n\n// A does a.\n//\n// More.\nfunc A() {}\n\n// Mine.\nfunc B() {}\n\n// C does c.\nfunc C() {}\n"
+	if got, _ := b.fs.ReadFile("a/a.go"); got != want {
+		t.Errorf("BaseGenerator.syncDocs() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBaseGenerator_ExpandInterface(t *testing.T) {
+	b := &BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}}
+	afero.WriteFile(b.fs.Fs, "hello/pkg/service/service.go", []byte("package service\n\ntype HelloService interface {\n\tFoo(ctx context.Context) error\n\tAdminService\n}\n"), 0644)
+	afero.WriteFile(b.fs.Fs, "hello/pkg/service/admin.go", []byte("package service\n\ntype AdminService interface {\n\tPing(ctx context.Context) error\n}\n"), 0644)
+	pkg, err := b.ParsePackage("hello/pkg/service")
+	if err != nil {
+		t.Fatalf("BaseGenerator.ParsePackage() error = %v", err)
+	}
+	iface, _ := pkg.Interface("HelloService")
+	got := b.ExpandInterface(iface, "hello/pkg/service")
+	if len(got.Methods) != 2 || got.Methods[0].Name != "Foo" || got.Methods[1].Name != "Ping" {
+		t.Errorf("BaseGenerator.ExpandInterface() = %v, want Foo and Ping", got.Methods)
+	}
+}
+
+func TestBaseGenerator_AddImportsToFile(t *testing.T) {
+	imp := []parser.NamedTypeValue{parser.NewNameType("log", `"github.com/go-kit/kit/log"`)}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Test no import",
+			src:  "package service\n\n// Middleware describes a service middleware.\ntype Middleware func(int) int\n",
+			want: "package service\n\nimport (\n\tlog \"github.com/go-kit/kit/log\"\n)\n\n// Middleware describes a service middleware.\ntype Middleware func(int) int\n",
+		},
+		{
+			name: "Test import group",
+			src:  "package service\n\nimport (\n\t// the context of the requests.\n\t\"context\"\n)\n\n// F does f.\nfunc F(context.Context) {}\n",
+			want: "package service\n\nimport (\n\t// the context of the requests.\n\t\"context\"\n\tlog \"github.com/go-kit/kit/log\"\n)\n\n// F does f.\nfunc F(context.Context) {}\n",
+		},
+		{
+			name: "Test single import",
+			src:  "package service\n\nimport \"context\"\n\n// F does f.\nfunc F(context.Context) {}\n",
+			want: "package service\n\nimport (\n\t\"context\"\n\tlog \"github.com/go-kit/kit/log\"\n)\n\n// F does f.\nfunc F(context.Context) {}\n",
+		},
+	}
+	b := &BaseGenerator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.AddImportsToFile(imp, tt.src)
+			if err != nil {
+				t.Fatalf("BaseGenerator.AddImportsToFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BaseGenerator.AddImportsToFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
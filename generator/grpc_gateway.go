@@ -0,0 +1,701 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/viper"
+)
+
+// gatewayOption is the rpc option that maps an rpc onto an HTTP route.
+const gatewayOption = "(google.api.http)"
+
+// gatewayVerbs are the HTTP methods of the google.api.http rules.
+var gatewayVerbs = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "patch": true}
+
+// gatewayRule is the HTTP route of an rpc served by the gateway, see the
+// google.api.http option.
+type gatewayRule struct {
+	rpc          *proto.RPC
+	verb         string
+	path         string
+	body         string
+	responseBody string
+}
+
+// gatewayVar is a variable of a path template, the ServeMux wildcard `name`
+// sets the field `field` of the request.
+type gatewayVar struct {
+	name  string
+	field string
+}
+
+// gatewayRules returns the routes of the rpcs of the methods, the rpc option
+// google.api.http wins over the `kit:http` directive of the method and over the
+// default route.
+func gatewayRules(def *proto.Proto, service string, methods []parser.Method) ([]gatewayRule, error) {
+	_, rpcs := protoRPCs(def)
+	rules := []gatewayRule{}
+	patterns := map[string]string{}
+	for _, m := range methods {
+		r, ok := rpcs[service+"."+m.Name]
+		if !ok || r.StreamsRequest || r.StreamsReturns {
+			continue
+		}
+		rule, found, err := gatewayRuleOption(r)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			rule.verb, rule.path = httpRoute(m)
+			if rule.verb != "GET" && rule.verb != "DELETE" {
+				rule.body = "*"
+			}
+		}
+		rule.rpc = r
+		if other, ok := patterns[rule.verb+" "+rule.path]; ok {
+			return nil, fmt.Errorf("the rpcs `%s` and `%s` have the same route `%s %s`", other, r.Name, rule.verb, rule.path)
+		}
+		patterns[rule.verb+" "+rule.path] = r.Name
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// gatewayRuleOption returns the route of the google.api.http option of `r`.
+func gatewayRuleOption(r *proto.RPC) (rule gatewayRule, found bool, err error) {
+	for _, e := range r.Elements {
+		o, ok := e.(*proto.Option)
+		if !ok || o.Name != gatewayOption {
+			continue
+		}
+		for _, c := range o.AggregatedConstants {
+			switch {
+			case c.Name == "body":
+				rule.body = c.Source
+			case c.Name == "response_body":
+				rule.responseBody = c.Source
+			case gatewayVerbs[c.Name]:
+				rule.verb, rule.path = strings.ToUpper(c.Name), c.Source
+			default:
+				return rule, false, fmt.Errorf("the `%s` of the option %s of the rpc `%s` is not supported", c.Name, gatewayOption, r.Name)
+			}
+		}
+		if rule.verb == "" {
+			return rule, false, fmt.Errorf("the option %s of the rpc `%s` has no HTTP method", gatewayOption, r.Name)
+		}
+		return rule, true, nil
+	}
+	return rule, false, nil
+}
+
+// hasGatewayRules tells if an rpc of the service has the google.api.http option.
+func hasGatewayRules(def *proto.Proto, service string) bool {
+	_, rpcs := protoRPCs(def)
+	for k, r := range rpcs {
+		if !strings.HasPrefix(k, service+".") {
+			continue
+		}
+		for _, e := range r.Elements {
+			if o, ok := e.(*proto.Option); ok && o.Name == gatewayOption {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gatewayPattern returns the ServeMux pattern of the path template `tpl` and
+// its variables, `{field}` and `{field=*}` match a segment and `{field=**}`
+// the rest of the path.
+func gatewayPattern(verb, tpl string) (string, []gatewayVar, error) {
+	if !strings.HasPrefix(tpl, "/") {
+		return "", nil, fmt.Errorf("the path `%s` does not start with /", tpl)
+	}
+	vars := []gatewayVar{}
+	pattern := ""
+	for rest := tpl; rest != ""; {
+		i := strings.Index(rest, "{")
+		if i < 0 {
+			pattern += rest
+			break
+		}
+		j := strings.Index(rest[i:], "}")
+		if j < 0 {
+			return "", nil, fmt.Errorf("the variable of the path `%s` is not closed", tpl)
+		}
+		j += i
+		after := rest[j+1:]
+		if !strings.HasSuffix(rest[:i], "/") || (after != "" && !strings.HasPrefix(after, "/")) {
+			return "", nil, fmt.Errorf("the variables of the path `%s` must be whole segments", tpl)
+		}
+		field, match := rest[i+1:j], "*"
+		if k := strings.Index(field, "="); k >= 0 {
+			field, match = field[:k], field[k+1:]
+		} else if strings.HasSuffix(field, "...") {
+			field, match = strings.TrimSuffix(field, "..."), "**"
+		}
+		name := strings.Replace(field, ".", "_", -1)
+		switch {
+		case match == "*":
+			pattern += rest[:i] + "{" + name + "}"
+		case match == "**" && after == "":
+			pattern += rest[:i] + "{" + name + "...}"
+		default:
+			return "", nil, fmt.Errorf("the variable `%s=%s` of the path `%s` is not supported", field, match, tpl)
+		}
+		vars = append(vars, gatewayVar{name: name, field: field})
+		rest = after
+	}
+	return verb + " " + pattern, vars, nil
+}
+
+// gatewayTypes resolves the messages and the enums of a proto file.
+type gatewayTypes struct {
+	pkg      string
+	messages map[string]protoMessageDef
+	enums    map[string]map[int]string
+}
+
+func newGatewayTypes(def *proto.Proto) gatewayTypes {
+	return gatewayTypes{
+		pkg:      protoPackage(def),
+		messages: protoMessageDefs("", def.Elements, map[string]protoMessageDef{}),
+		enums:    protoEnumDefs("", def.Elements, map[string]map[int]string{}),
+	}
+}
+
+// resolve returns the full name of the type `typ` used in the message `scope`
+// and its kind: scalar, enum or message, it is empty for the imported types.
+func (t gatewayTypes) resolve(scope, typ string) (string, string) {
+	if _, ok := protoGoTypes[typ]; ok {
+		return typ, "scalar"
+	}
+	if strings.HasPrefix(typ, ".") {
+		typ, scope = strings.TrimPrefix(typ[1:], t.pkg+"."), ""
+	} else if t.pkg != "" {
+		typ = strings.TrimPrefix(typ, t.pkg+".")
+	}
+	for s := scope; ; {
+		full := typ
+		if s != "" {
+			full = s + "." + typ
+		}
+		if _, ok := t.messages[full]; ok {
+			return full, "message"
+		}
+		if _, ok := t.enums[full]; ok {
+			return full, "enum"
+		}
+		if s == "" {
+			return typ, ""
+		}
+		if i := strings.LastIndex(s, "."); i >= 0 {
+			s = s[:i]
+		} else {
+			s = ""
+		}
+	}
+}
+
+// gatewayField is a field of a request set from the path or the query, the
+// messages of `parents` are created before it is set.
+type gatewayField struct {
+	name    string
+	field   protoField
+	goPath  []string
+	typ     string
+	kind    string
+	parents []gatewayField
+}
+
+// field returns the field `fieldPath` (e.x data.id) of the message `msg`.
+func (t gatewayTypes) field(msg, fieldPath string) (gatewayField, error) {
+	gf := gatewayField{name: fieldPath}
+	parts := strings.Split(fieldPath, ".")
+	for i, p := range parts {
+		def, ok := t.messages[msg]
+		if !ok {
+			return gf, fmt.Errorf("the message `%s` was not found", msg)
+		}
+		f, ok := def.fieldByName(p)
+		if !ok {
+			return gf, fmt.Errorf("the message `%s` has no field `%s`", msg, p)
+		}
+		if f.label == "oneof" || strings.HasPrefix(f.typ, "map<") {
+			return gf, fmt.Errorf("the field `%s.%s` can not be set from the path or the query", msg, p)
+		}
+		gf.goPath = append(gf.goPath, pbGoName(p))
+		full, kind := t.resolve(msg, f.typ)
+		if i < len(parts)-1 {
+			if kind != "message" || f.label == "repeated" {
+				return gf, fmt.Errorf("the field `%s.%s` is not a message", msg, p)
+			}
+			gf.parents = append(gf.parents, gatewayField{goPath: append([]string{}, gf.goPath...), typ: full})
+			msg = full
+			continue
+		}
+		if kind != "scalar" && kind != "enum" {
+			return gf, fmt.Errorf("the field `%s.%s` can not be set from the path or the query", msg, p)
+		}
+		gf.field, gf.typ, gf.kind = f, full, kind
+	}
+	return gf, nil
+}
+
+// gatewayTarget returns the expression of the field `goPath` of the request.
+func gatewayTarget(goPath []string) *jen.Statement {
+	s := jen.Id("req")
+	for _, p := range goPath {
+		s = s.Dot(p)
+	}
+	return s
+}
+
+// gatewayParses are the strconv functions that parse the scalars, with the Go
+// type of the field if it is not the type of the parsed value.
+var gatewayParses = map[string]struct {
+	fn   string
+	args []interface{}
+	conv string
+}{
+	"int32":    {"ParseInt", []interface{}{10, 32}, "int32"},
+	"sint32":   {"ParseInt", []interface{}{10, 32}, "int32"},
+	"sfixed32": {"ParseInt", []interface{}{10, 32}, "int32"},
+	"int64":    {"ParseInt", []interface{}{10, 64}, ""},
+	"sint64":   {"ParseInt", []interface{}{10, 64}, ""},
+	"sfixed64": {"ParseInt", []interface{}{10, 64}, ""},
+	"uint32":   {"ParseUint", []interface{}{10, 32}, "uint32"},
+	"fixed32":  {"ParseUint", []interface{}{10, 32}, "uint32"},
+	"uint64":   {"ParseUint", []interface{}{10, 64}, ""},
+	"fixed64":  {"ParseUint", []interface{}{10, 64}, ""},
+	"float":    {"ParseFloat", []interface{}{32}, "float32"},
+	"double":   {"ParseFloat", []interface{}{64}, ""},
+	"bool":     {"ParseBool", nil, ""},
+}
+
+// gatewaySet returns the statements that set the field `f` of the request from
+// the string `v`, a bad value is a gatewayError.
+func gatewaySet(pbImport string, f gatewayField) []jen.Code {
+	code := []jen.Code{}
+	for _, p := range f.parents {
+		code = append(code, jen.If(gatewayTarget(p.goPath).Op("==").Nil()).Block(
+			gatewayTarget(p.goPath).Op("=").Op("&").Qual(pbImport, protoGoName(p.typ)).Values(),
+		))
+	}
+	assign := func(value jen.Code) jen.Code {
+		if f.field.label == "repeated" {
+			return gatewayTarget(f.goPath).Op("=").Append(gatewayTarget(f.goPath), value)
+		}
+		return gatewayTarget(f.goPath).Op("=").Add(value)
+	}
+	fail := func(format string, value jen.Code) jen.Code {
+		return jen.Return(jen.Nil(), jen.Id("gatewayError").Values(
+			jen.Qual("fmt", "Errorf").Call(jen.Lit(fmt.Sprintf(format, f.name)), value),
+		))
+	}
+	switch {
+	case f.kind == "enum":
+		return append(
+			code,
+			jen.List(jen.Id("x"), jen.Id("ok")).Op(":=").Qual(pbImport, protoGoName(f.typ)+"_value").Index(jen.Id("v")),
+			jen.If(jen.Op("!").Id("ok")).Block(fail("invalid %s: %%q", jen.Id("v"))),
+			assign(jen.Qual(pbImport, protoGoName(f.typ)).Call(jen.Id("x"))),
+		)
+	case f.typ == "string":
+		return append(code, assign(jen.Id("v")))
+	}
+	parse := jen.Qual("encoding/base64", "StdEncoding").Dot("DecodeString").Call(jen.Id("v"))
+	value := jen.Id("x")
+	if p, ok := gatewayParses[f.typ]; ok {
+		args := []jen.Code{jen.Id("v")}
+		for _, a := range p.args {
+			args = append(args, jen.Lit(a))
+		}
+		parse = jen.Qual("strconv", p.fn).Call(args...)
+		if p.conv != "" {
+			value = jen.Id(p.conv).Call(jen.Id("x"))
+		}
+	}
+	return append(
+		code,
+		jen.List(jen.Id("x"), jen.Err()).Op(":=").Add(parse),
+		jen.If(jen.Err().Op("!=").Nil()).Block(fail("invalid %s: %%v", jen.Err())),
+		assign(value),
+	)
+}
+
+// gatewayDecoder returns the body of the decoder of the request of the rule,
+// the body is decoded first then the query and the path override it.
+func gatewayDecoder(t gatewayTypes, pbImport string, rule gatewayRule) ([]jen.Code, error) {
+	reqType, _ := t.resolve("", rule.rpc.RequestType)
+	def, ok := t.messages[reqType]
+	if !ok {
+		return nil, fmt.Errorf("the request `%s` of the rpc `%s` was not found", rule.rpc.RequestType, rule.rpc.Name)
+	}
+	_, vars, err := gatewayPattern(rule.verb, rule.path)
+	if err != nil {
+		return nil, fmt.Errorf("the rpc `%s`: %s", rule.rpc.Name, err)
+	}
+	bound := map[string]bool{}
+	code := []jen.Code{
+		jen.Id("req").Op(":=").Qual(pbImport, protoGoName(reqType)).Values(),
+	}
+	if rule.body != "" {
+		field := ""
+		if rule.body != "*" {
+			if _, ok := def.fieldByName(rule.body); !ok {
+				return nil, fmt.Errorf("the body `%s` of the rpc `%s` is not a field of `%s`", rule.body, rule.rpc.Name, reqType)
+			}
+			field = rule.body
+			bound[field] = true
+		}
+		code = append(code, jen.If(
+			jen.Err().Op(":=").Id("gatewayUnmarshal").Call(jen.Id("r").Dot("Body"), jen.Op("&").Id("req"), jen.Lit(field)),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Nil(), jen.Err())))
+	}
+	pathFields := []jen.Code{}
+	for _, v := range vars {
+		f, err := t.field(reqType, v.field)
+		if err != nil {
+			return nil, fmt.Errorf("the path of the rpc `%s`: %s", rule.rpc.Name, err)
+		}
+		bound[strings.Split(v.field, ".")[0]] = true
+		if f.field.label == "repeated" {
+			return nil, fmt.Errorf("the path of the rpc `%s`: the field `%s` is repeated", rule.rpc.Name, v.field)
+		}
+		pathFields = append(pathFields, jen.If(
+			jen.Id("v").Op(":=").Id("r").Dot("PathValue").Call(jen.Lit(v.name)),
+			jen.Id("v").Op("!=").Lit(""),
+		).Block(gatewaySet(pbImport, f)...))
+	}
+	if rule.body != "*" {
+		query := []jen.Code{}
+		for _, pf := range def.fields {
+			if bound[pf.name] || pf.label == "oneof" || strings.HasPrefix(pf.typ, "map<") {
+				continue
+			}
+			f, err := t.field(reqType, pf.name)
+			if err != nil {
+				// the messages are only set by the body.
+				continue
+			}
+			if pf.label == "repeated" {
+				query = append(query, jen.For(
+					jen.List(jen.Id("_"), jen.Id("v")).Op(":=").Range().Id("q").Index(jen.Lit(pf.name)),
+				).Block(gatewaySet(pbImport, f)...))
+				continue
+			}
+			query = append(query, jen.If(
+				jen.Id("v").Op(":=").Id("q").Dot("Get").Call(jen.Lit(pf.name)),
+				jen.Id("v").Op("!=").Lit(""),
+			).Block(gatewaySet(pbImport, f)...))
+		}
+		if len(query) > 0 {
+			code = append(code, jen.Id("q").Op(":=").Id("r").Dot("URL").Dot("Query").Call())
+			code = append(code, query...)
+		}
+	}
+	code = append(code, pathFields...)
+	return append(code, jen.Return(jen.Id("req"), jen.Nil())), nil
+}
+
+// gatewayEncoder returns the body of the encoder of the reply of the rule, the
+// endpoints reply the pb messages by value.
+func gatewayEncoder(t gatewayTypes, pbImport string, rule gatewayRule) ([]jen.Code, error) {
+	rspType, _ := t.resolve("", rule.rpc.ReturnsType)
+	def, ok := t.messages[rspType]
+	if !ok {
+		return nil, fmt.Errorf("the reply `%s` of the rpc `%s` was not found", rule.rpc.ReturnsType, rule.rpc.Name)
+	}
+	if rule.responseBody != "" {
+		if _, ok := def.fieldByName(rule.responseBody); !ok {
+			return nil, fmt.Errorf("the response body `%s` of the rpc `%s` is not a field of `%s`", rule.responseBody, rule.rpc.Name, rspType)
+		}
+	}
+	marshal := func(m jen.Code) jen.Code {
+		return jen.Return(jen.Id("gatewayMarshal").Call(jen.Id("w"), m, jen.Lit(rule.responseBody)))
+	}
+	return []jen.Code{
+		jen.Switch(jen.Id("rsp").Op(":=").Id("response").Assert(jen.Type())).Block(
+			jen.Case(jen.Qual(pbImport, protoGoName(rspType))).Block(marshal(jen.Op("&").Id("rsp"))),
+			jen.Case(jen.Op("*").Qual(pbImport, protoGoName(rspType))).Block(marshal(jen.Id("rsp"))),
+		),
+		jen.Return(jen.Qual("fmt", "Errorf").Call(jen.Lit("unexpected reply %T of "+rule.rpc.Name), jen.Id("response"))),
+	}, nil
+}
+
+// appendGatewayHelpers appends the error and the JSON coders of the gateway.
+func appendGatewayHelpers(code *PartialGenerator) {
+	code.appendMultilineComment([]string{
+		"gatewayError is the error of a request that does not match its rpc, it is",
+		"encoded as a 400 Bad Request.",
+	})
+	code.NewLine()
+	code.appendStruct("gatewayError", jen.Error())
+	code.NewLine()
+	code.appendMultilineComment([]string{"StatusCode implements the StatusCoder of the go-kit http transport."})
+	code.NewLine()
+	code.appendFunction(
+		"StatusCode",
+		jen.Id("gatewayError"),
+		[]jen.Code{},
+		[]jen.Code{},
+		"int",
+		jen.Return(jen.Qual("net/http", "StatusBadRequest")),
+	)
+	code.NewLine()
+	code.appendMultilineComment([]string{
+		"gatewayUnmarshal decodes the JSON body into the message `m`, or into its",
+		"field `field` if not empty.",
+	})
+	code.NewLine()
+	code.appendFunction(
+		"gatewayUnmarshal",
+		nil,
+		[]jen.Code{
+			jen.Id("body").Qual("io", "Reader"),
+			jen.Id("m").Qual("github.com/golang/protobuf/proto", "Message"),
+			jen.Id("field").String(),
+		},
+		[]jen.Code{},
+		"error",
+		jen.List(jen.Id("b"), jen.Err()).Op(":=").Qual("io/ioutil", "ReadAll").Call(jen.Id("body")),
+		jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+		jen.If(jen.Len(jen.Qual("bytes", "TrimSpace").Call(jen.Id("b"))).Op("==").Lit(0)).Block(jen.Return(jen.Nil())),
+		jen.If(jen.Id("field").Op("!=").Lit("")).Block(
+			jen.Id("b").Op("=").Append(
+				jen.Append(jen.Index().Byte().Call(jen.Lit(`{"`).Op("+").Id("field").Op("+").Lit(`":`)), jen.Id("b").Op("...")),
+				jen.LitRune('}'),
+			),
+		),
+		jen.Id("u").Op(":=").Qual("github.com/golang/protobuf/jsonpb", "Unmarshaler").Values(jen.Dict{
+			jen.Id("AllowUnknownFields"): jen.True(),
+		}),
+		jen.If(
+			jen.Err().Op(":=").Id("u").Dot("Unmarshal").Call(jen.Qual("bytes", "NewReader").Call(jen.Id("b")), jen.Id("m")),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Id("gatewayError").Values(jen.Err()))),
+		jen.Return(jen.Nil()),
+	)
+	code.NewLine()
+	code.appendMultilineComment([]string{
+		"gatewayMarshal encodes the message `m` in JSON, or its field `field` if not",
+		"empty.",
+	})
+	code.NewLine()
+	code.appendFunction(
+		"gatewayMarshal",
+		nil,
+		[]jen.Code{
+			jen.Id("w").Qual("net/http", "ResponseWriter"),
+			jen.Id("m").Qual("github.com/golang/protobuf/proto", "Message"),
+			jen.Id("field").String(),
+		},
+		[]jen.Code{},
+		"error",
+		jen.Id("buf").Op(":=").New(jen.Qual("bytes", "Buffer")),
+		jen.Id("marshaler").Op(":=").Qual("github.com/golang/protobuf/jsonpb", "Marshaler").Values(jen.Dict{
+			jen.Id("OrigName"):     jen.True(),
+			jen.Id("EmitDefaults"): jen.True(),
+		}),
+		jen.If(
+			jen.Err().Op(":=").Id("marshaler").Dot("Marshal").Call(jen.Id("buf"), jen.Id("m")),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Err())),
+		jen.Id("b").Op(":=").Id("buf").Dot("Bytes").Call(),
+		jen.If(jen.Id("field").Op("!=").Lit("")).Block(
+			jen.Id("fields").Op(":=").Map(jen.String()).Qual("encoding/json", "RawMessage").Values(),
+			jen.If(
+				jen.Err().Op(":=").Qual("encoding/json", "Unmarshal").Call(jen.Id("b"), jen.Op("&").Id("fields")),
+				jen.Err().Op("!=").Nil(),
+			).Block(jen.Return(jen.Err())),
+			jen.Id("b").Op("=").Id("fields").Index(jen.Id("field")),
+		),
+		jen.Id("w").Dot("Header").Call().Dot("Set").Call(jen.Lit("Content-Type"), jen.Lit("application/json; charset=utf-8")),
+		jen.List(jen.Id("_"), jen.Err()).Op(":=").Id("w").Dot("Write").Call(jen.Id("b")),
+		jen.Return(jen.Err()),
+	)
+	code.NewLine()
+}
+
+type generateGRPCGatewayDgd struct {
+	BaseGenerator
+	name       string
+	methods    []parser.Method
+	destPath   string
+	filePath   string
+	pbFilePath string
+}
+
+// newGenerateGRPCGatewayDgd returns the generator of the HTTP/JSON gateway of
+// the rpcs of the methods, it serves them on the HTTP routes of their
+// google.api.http option or of their `kit:http` directive.
+func newGenerateGRPCGatewayDgd(name string, methods []parser.Method) Gen {
+	t := &generateGRPCGatewayDgd{
+		name:     name,
+		methods:  methods,
+		destPath: fmt.Sprintf(viper.GetString("gk_http_path_format"), utils.ToLowerSnakeCase2(name)),
+	}
+	t.filePath = path.Join(t.destPath, viper.GetString("gk_http_gateway_file_name"))
+	t.pbFilePath = path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), utils.ToLowerSnakeCase2(name)),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), utils.ToLowerSnakeCase2(name)),
+	)
+	t.srcFile = jen.NewFilePath(t.destPath)
+	t.InitPg()
+	t.fs = fs.Get()
+	t.generator = "generateGRPCGatewayDgd"
+	return t
+}
+
+// Generate generates the gateway if it is enabled by the `gk_grpc_gateway`
+// setting, if it exists or if an rpc has the google.api.http option.
+func (g *generateGRPCGatewayDgd) Generate() (err error) {
+	src, err := g.fs.ReadFile(g.pbFilePath)
+	if err != nil {
+		return err
+	}
+	def, err := proto.NewParser(bytes.NewReader([]byte(src))).Parse()
+	if err != nil {
+		return err
+	}
+	service := utils.ToCamelCase(g.name)
+	exists, err := g.fs.Exists(g.filePath)
+	if err != nil {
+		return err
+	}
+	if !viper.GetBool("gk_grpc_gateway") && !exists && !hasGatewayRules(def, service) {
+		return nil
+	}
+	rules, err := gatewayRules(def, service, g.methods)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		logrus.Warnf("The gateway is enabled but no rpc of `%s` has an HTTP route, it is not generated.", g.name)
+		return nil
+	}
+	if v, err := utils.ModuleGoVersion(); err != nil {
+		return err
+	} else if utils.GoVersionBefore(v, 22) {
+		logrus.Warnf("The gateway needs go 1.22 (the ServeMux method patterns and Request.PathValue) but the go.mod file asks for go %s.", v)
+	}
+	endpointImport, err := utils.GetEndpointImportPath(g.name)
+	if err != nil {
+		return err
+	}
+	pbImport, err := utils.GetPbImportPath(g.name)
+	if err != nil {
+		return err
+	}
+	err = g.CreateFolderStructure(g.destPath)
+	if err != nil {
+		return err
+	}
+	g.srcFile.PackageComment("THIS FILE IS AUTO GENERATED BY GK-CLI DO NOT EDIT!!")
+	types := newGatewayTypes(def)
+	handlers := []jen.Code{
+		jen.Id("m").Op(":=").Qual("net/http", "NewServeMux").Call(),
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].rpc.Name < rules[j].rpc.Name })
+	for _, rule := range rules {
+		name := rule.rpc.Name
+		pattern, _, err := gatewayPattern(rule.verb, rule.path)
+		if err != nil {
+			return fmt.Errorf("the rpc `%s`: %s", name, err)
+		}
+		handlers = append(handlers, jen.Id("m").Dot("Handle").Call(
+			jen.Lit(pattern),
+			jen.Qual("github.com/go-kit/kit/transport/http", "NewServer").Call(
+				jen.Id("endpoints").Dot(name+"Endpoint"),
+				jen.Id(fmt.Sprintf("decode%sGatewayRequest", name)),
+				jen.Id(fmt.Sprintf("encode%sGatewayResponse", name)),
+				jen.Id("options").Index(jen.Lit(name)).Op("..."),
+			),
+		))
+	}
+	handlers = append(handlers, jen.Return(jen.Id("m")))
+	g.code.appendMultilineComment([]string{
+		"NewGatewayHandler returns the HTTP/JSON gateway of the rpcs, it maps the path,",
+		"the query and the body of the requests onto the pb requests and calls the",
+		"endpoints. The ServeMux method patterns and Request.PathValue need go 1.22.",
+	})
+	g.code.NewLine()
+	g.code.appendFunction(
+		"NewGatewayHandler",
+		nil,
+		[]jen.Code{
+			jen.Id("endpoints").Qual(endpointImport, "Endpoints"),
+			jen.Id("options").Map(jen.String()).Index().Qual("github.com/go-kit/kit/transport/http", "ServerOption"),
+		},
+		[]jen.Code{jen.Qual("net/http", "Handler")},
+		"",
+		handlers...,
+	)
+	g.code.NewLine()
+	for _, rule := range rules {
+		name := rule.rpc.Name
+		decode, err := gatewayDecoder(types, pbImport, rule)
+		if err != nil {
+			return err
+		}
+		encode, err := gatewayEncoder(types, pbImport, rule)
+		if err != nil {
+			return err
+		}
+		body := "without body"
+		switch rule.body {
+		case "*":
+			body = "with the request as body"
+		case "":
+		default:
+			body = fmt.Sprintf("with the field %s as body", rule.body)
+		}
+		g.code.appendMultilineComment([]string{
+			fmt.Sprintf("decode%sGatewayRequest decodes the request of `%s %s` %s.", name, rule.verb, rule.path, body),
+		})
+		g.code.NewLine()
+		g.code.appendFunction(
+			fmt.Sprintf("decode%sGatewayRequest", name),
+			nil,
+			[]jen.Code{
+				jen.Id("_").Qual("context", "Context"),
+				jen.Id("r").Op("*").Qual("net/http", "Request"),
+			},
+			[]jen.Code{jen.Interface(), jen.Error()},
+			"",
+			decode...,
+		)
+		g.code.NewLine()
+		g.code.appendMultilineComment([]string{
+			fmt.Sprintf("encode%sGatewayResponse encodes the reply of %s in JSON.", name, name),
+		})
+		g.code.NewLine()
+		g.code.appendFunction(
+			fmt.Sprintf("encode%sGatewayResponse", name),
+			nil,
+			[]jen.Code{
+				jen.Id("_").Qual("context", "Context"),
+				jen.Id("w").Qual("net/http", "ResponseWriter"),
+				jen.Id("response").Interface(),
+			},
+			[]jen.Code{},
+			"error",
+			encode...,
+		)
+		g.code.NewLine()
+	}
+	appendGatewayHelpers(g.code)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), true)
+}
@@ -0,0 +1,340 @@
+package generator
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+const testGatewayProto = `syntax = "proto3";
+
+package pb;
+
+import "google/api/annotations.proto";
+
+service Hello {
+    rpc GetUser (GetUserReq) returns (GetUserRsp) {
+      option (google.api.http) = {
+        get: "/v1/users/{user.id}"
+        response_body: "user"
+      };
+    }
+    rpc UpdateUser (UpdateUserReq) returns (GetUserRsp) {
+      option (google.api.http) = {
+        patch: "/v1/users/{id}"
+        body: "user"
+      };
+    }
+    rpc Ping (PingReq) returns (PingRsp);
+    rpc Tail (PingReq) returns (stream PingRsp);
+}
+
+enum Kind {
+    UNKNOWN = 0;
+    ADMIN = 1;
+}
+
+message User {
+    string id = 1;
+    string name = 2;
+}
+
+message GetUserReq {
+    message Page {
+        int32 size = 1;
+    }
+    User user = 1;
+    repeated int64 ids = 2;
+    Kind kind = 3;
+    bool verbose = 4;
+    map<string, string> tags = 5;
+    Page page = 6;
+}
+
+message GetUserRsp {
+    User user = 1;
+}
+
+message UpdateUserReq {
+    string id = 1;
+    User user = 2;
+    float weight = 3;
+}
+
+message PingReq {}
+
+message PingRsp {}
+`
+
+func Test_gatewayPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		tpl     string
+		want    string
+		vars    []gatewayVar
+		wantErr bool
+	}{
+		{
+			name: "variables",
+			tpl:  "/v1/users/{user.id}/items/{item}",
+			want: "GET /v1/users/{user_id}/items/{item}",
+			vars: []gatewayVar{{name: "user_id", field: "user.id"}, {name: "item", field: "item"}},
+		},
+		{
+			name: "segment and rest of the path",
+			tpl:  "/v1/{shelf=*}/files/{path=**}",
+			want: "GET /v1/{shelf}/files/{path...}",
+			vars: []gatewayVar{{name: "shelf", field: "shelf"}, {name: "path", field: "path"}},
+		},
+		{
+			name: "ServeMux wildcard of the kit:http directive",
+			tpl:  "/files/{path...}",
+			want: "GET /files/{path...}",
+			vars: []gatewayVar{{name: "path", field: "path"}},
+		},
+		{
+			name: "no variable",
+			tpl:  "/ping",
+			want: "GET /ping",
+			vars: []gatewayVar{},
+		},
+		{
+			name:    "multiple segments",
+			tpl:     "/v1/{name=shelves/*}",
+			wantErr: true,
+		},
+		{
+			name:    "partial segment",
+			tpl:     "/v1/user-{id}",
+			wantErr: true,
+		},
+		{
+			name:    "relative path",
+			tpl:     "v1/users",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, vars, err := gatewayPattern("GET", tt.tpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gatewayPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("gatewayPattern() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(vars, tt.vars) {
+				t.Errorf("gatewayPattern() vars = %v, want %v", vars, tt.vars)
+			}
+		})
+	}
+}
+
+func Test_gatewayRules(t *testing.T) {
+	methods := []parser.Method{
+		{Name: "GetUser"},
+		{Name: "UpdateUser"},
+		{Name: "Ping", Directives: parser.Directives{HTTPMethod: "GET", HTTPPath: "/ping"}},
+		{Name: "Tail"},
+	}
+	tests := []struct {
+		name    string
+		proto   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "options directives and streams",
+			proto: testGatewayProto,
+			want: []string{
+				"GetUser GET /v1/users/{user.id} body= response_body=user",
+				"UpdateUser PATCH /v1/users/{id} body=user response_body=",
+				"Ping GET /ping body= response_body=",
+			},
+		},
+		{
+			name:  "default route",
+			proto: strings.Replace(testGatewayProto, "patch: \"/v1/users/{id}\"\n        body: \"user\"", "put: \"/v1/users\"", 1),
+			want: []string{
+				"GetUser GET /v1/users/{user.id} body= response_body=user",
+				"UpdateUser PUT /v1/users body= response_body=",
+				"Ping GET /ping body= response_body=",
+			},
+		},
+		{
+			name:    "same route",
+			proto:   strings.Replace(testGatewayProto, "get: \"/v1/users/{user.id}\"", "get: \"/ping\"", 1),
+			wantErr: "the rpcs `GetUser` and `Ping` have the same route `GET /ping`",
+		},
+		{
+			name:    "unsupported key",
+			proto:   strings.Replace(testGatewayProto, "response_body:", "additional_bindings:", 1),
+			wantErr: "the `additional_bindings` of the option (google.api.http) of the rpc `GetUser` is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := gatewayRules(parseTestProto(t, tt.proto), "Hello", methods)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("gatewayRules() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, r := range rules {
+				got = append(got, r.rpc.Name+" "+r.verb+" "+r.path+" body="+r.body+" response_body="+r.responseBody)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gatewayRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// the methods without option nor directive are posted.
+	rules, err := gatewayRules(parseTestProto(t, testGatewayProto), "Hello", []parser.Method{{Name: "Ping"}})
+	if err != nil || len(rules) != 1 || rules[0].verb != "POST" || rules[0].path != "/ping" || rules[0].body != "*" {
+		t.Errorf("gatewayRules() = %v, %v, want POST /ping with the request as body", rules, err)
+	}
+}
+
+func TestGenerateGRPCGatewayDgd_Generate(t *testing.T) {
+	setDefaults()
+	methods := []parser.Method{{Name: "GetUser"}, {Name: "UpdateUser"}, {Name: "Ping"}}
+	newGateway := func(src string) *generateGRPCGatewayDgd {
+		g := newGenerateGRPCGatewayDgd("hello", methods).(*generateGRPCGatewayDgd)
+		g.fs = &fs.KitFs{Fs: afero.NewMemMapFs()}
+		afero.WriteFile(g.fs.Fs, g.pbFilePath, []byte(src), 0644)
+		return g
+	}
+	g := newGateway(testGatewayProto)
+	if err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	src, err := g.fs.ReadFile(g.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the path sets the field of a message, the query the scalars but the
+	// body field and the streams are not served.
+	for name, want := range map[string][]string{
+		"NewGatewayHandler": {
+			"m := http1.NewServeMux()",
+			`m.Handle("GET /v1/users/{user_id}", http.NewServer(endpoints.GetUserEndpoint, decodeGetUserGatewayRequest, encodeGetUserGatewayResponse, options["GetUser"]...))`,
+			`m.Handle("POST /ping", http.NewServer(endpoints.PingEndpoint, decodePingGatewayRequest, encodePingGatewayResponse, options["Ping"]...))`,
+			`m.Handle("PATCH /v1/users/{id}", http.NewServer(endpoints.UpdateUserEndpoint, decodeUpdateUserGatewayRequest, encodeUpdateUserGatewayResponse, options["UpdateUser"]...))`,
+			"return m",
+		},
+		"decodeGetUserGatewayRequest": {
+			"req := pb.GetUserReq{}",
+			"q := r.URL.Query()",
+			"x, err := strconv.ParseInt(v, 10, 64)",
+			`return nil, gatewayError{fmt.Errorf("invalid ids: %v", err)}`,
+			"req.Ids = append(req.Ids, x)",
+			`v := q.Get("kind")`,
+			"x, ok := pb.Kind_value[v]",
+			`return nil, gatewayError{fmt.Errorf("invalid kind: %q", v)}`,
+			"req.Kind = pb.Kind(x)",
+			`v := q.Get("verbose")`,
+			"x, err := strconv.ParseBool(v)",
+			`return nil, gatewayError{fmt.Errorf("invalid verbose: %v", err)}`,
+			"req.Verbose = x",
+			`v := r.PathValue("user_id")`,
+			"req.User = &pb.User{}",
+			"req.User.Id = v",
+			"return req, nil",
+		},
+		"encodeGetUserGatewayResponse": {
+			"rsp := response.(type)",
+			`return gatewayMarshal(w, &rsp, "user")`,
+			`return gatewayMarshal(w, rsp, "user")`,
+			`return fmt.Errorf("unexpected reply %T of GetUser", response)`,
+		},
+		"decodeUpdateUserGatewayRequest": {
+			"req := pb.UpdateUserReq{}",
+			`err := gatewayUnmarshal(r.Body, &req, "user")`,
+			"return nil, err",
+			"q := r.URL.Query()",
+			`v := q.Get("weight")`,
+			"x, err := strconv.ParseFloat(v, 32)",
+			`return nil, gatewayError{fmt.Errorf("invalid weight: %v", err)}`,
+			"req.Weight = float32(x)",
+			`v := r.PathValue("id")`,
+			"req.Id = v",
+			"return req, nil",
+		},
+		"decodePingGatewayRequest": {
+			"req := pb.PingReq{}",
+			`err := gatewayUnmarshal(r.Body, &req, "")`,
+			"return nil, err",
+			"return req, nil",
+		},
+		"StatusCode": {"return http1.StatusBadRequest"},
+	} {
+		if got := stmts(t, src, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("generateGRPCGatewayDgd.Generate() %s = %q, want %q", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"decodeGetUserGatewayRequest":  "_ context.Context, r *http1.Request",
+		"encodeGetUserGatewayResponse": "_ context.Context, w http1.ResponseWriter, response interface{}",
+	} {
+		params := []string{}
+		for _, p := range funcDecl(t, src, name).Type.Params.List {
+			for _, n := range p.Names {
+				params = append(params, n.Name+" "+types.ExprString(p.Type))
+			}
+		}
+		if got := strings.Join(params, ", "); got != want {
+			t.Errorf("generateGRPCGatewayDgd.Generate() %s params = %s, want %s", name, got, want)
+		}
+	}
+	cases := []string{}
+	ast.Inspect(funcDecl(t, src, "encodeGetUserGatewayResponse").Body, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CaseClause); ok {
+			for _, e := range c.List {
+				cases = append(cases, types.ExprString(e))
+			}
+		}
+		return true
+	})
+	if want := []string{"pb.GetUserRsp", "*pb.GetUserRsp"}; !reflect.DeepEqual(cases, want) {
+		t.Errorf("generateGRPCGatewayDgd.Generate() encodeGetUserGatewayResponse cases = %q, want %q", cases, want)
+	}
+
+	// the gateway is only generated if it is enabled.
+	g = newGateway(strings.Replace(testGatewayProto, "(google.api.http)", "deprecated_http", -1))
+	if err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := g.fs.Exists(g.filePath); ok {
+		t.Error("generateGRPCGatewayDgd.Generate() generated the gateway without the option nor the setting")
+	}
+	viper.Set("gk_grpc_gateway", true)
+	defer viper.Set("gk_grpc_gateway", nil)
+	g = newGateway(strings.Replace(testGatewayProto, "get: \"/v1/users/{user.id}\"", "get: \"/v1/users/{user.name.first}\"", 1))
+	if err := g.Generate(); err == nil || !strings.Contains(err.Error(), "the field `User.name` is not a message") {
+		t.Errorf("generateGRPCGatewayDgd.Generate() error = %v, want the field `User.name` is not a message", err)
+	}
+	// an enabled gateway without any route is skipped.
+	g = newGateway(testGatewayProto)
+	g.methods = []parser.Method{{Name: "Tail"}}
+	if err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := g.fs.Exists(g.filePath); ok {
+		t.Error("generateGRPCGatewayDgd.Generate() generated the gateway without any route")
+	}
+}
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/viper"
+)
+
+// grpcHealthFunc is the function of the generated cmd that serves the gRPC
+// health checking and server reflection services.
+const grpcHealthFunc = "initGRPCHealth"
+
+// grpcHealth tells if the cmd of the service `name` serves the gRPC health
+// checking and server reflection services, they are enabled by the
+// `gk_grpc_health` setting and kept while the cmd service file calls them.
+func (b *BaseGenerator) grpcHealth(name string) (bool, error) {
+	if viper.GetBool("gk_grpc_health") {
+		return true, nil
+	}
+	f := path.Join(
+		fmt.Sprintf(viper.GetString("gk_cmd_service_path_format"), utils.ToLowerSnakeCase2(name)),
+		viper.GetString("gk_cmd_svc_file_name"),
+	)
+	if ok, err := b.fs.Exists(f); err != nil || !ok {
+		return false, err
+	}
+	src, err := b.fs.ReadFile(f)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(src, grpcHealthFunc+"("), nil
+}
+
+// appendGRPCHealth appends the function that registers the health and the
+// reflection services on the gRPC server. The server and the proto service
+// `service` (e.x `pb.Hello`) are SERVING while the run group runs and
+// NOT_SERVING as soon as it is interrupted.
+func appendGRPCHealth(code *PartialGenerator, service string) {
+	healthpb := "google.golang.org/grpc/health/grpc_health_v1"
+	serving := func(status string) jen.Code {
+		return jen.Qual(healthpb, "HealthCheckResponse_"+status)
+	}
+	code.appendMultilineComment([]string{
+		fmt.Sprintf("%s registers the gRPC health checking and server reflection services,", grpcHealthFunc),
+		"the service is SERVING while the run group runs.",
+	})
+	code.NewLine()
+	code.appendFunction(
+		grpcHealthFunc,
+		nil,
+		[]jen.Code{
+			jen.Id("baseServer").Op("*").Qual("google.golang.org/grpc", "Server"),
+			jen.Id("g").Op("*").Qual("github.com/oklog/oklog/pkg/group", "Group"),
+		},
+		[]jen.Code{},
+		"",
+		jen.Id("healthServer").Op(":=").Qual("google.golang.org/grpc/health", "NewServer").Call(),
+		jen.Qual(healthpb, "RegisterHealthServer").Call(jen.Id("baseServer"), jen.Id("healthServer")),
+		jen.Qual("google.golang.org/grpc/reflection", "Register").Call(jen.Id("baseServer")),
+		jen.Id("stop").Op(":=").Make(jen.Chan().Struct()),
+		jen.Id("g").Dot("Add").Call(
+			jen.Func().Params().Error().Block(
+				jen.Id("healthServer").Dot("SetServingStatus").Call(jen.Lit(""), serving("SERVING")),
+				jen.Id("healthServer").Dot("SetServingStatus").Call(jen.Lit(service), serving("SERVING")),
+				jen.Op("<-").Id("stop"),
+				jen.Return(jen.Nil()),
+			),
+			jen.Func().Params(jen.Error()).Block(
+				jen.Comment("every service is NOT_SERVING from now on."),
+				jen.Id("healthServer").Dot("Shutdown").Call(),
+				jen.Close(jen.Id("stop")),
+			),
+		),
+	)
+	code.NewLine()
+}
+
+// grpcServe returns the code of initGRPCHandler that serves the gRPC server in
+// the run group, with the health checking and reflection services if `health`.
+func grpcServe(pbImport, name string, health bool) *jen.Statement {
+	register := jen.Qual(pbImport, fmt.Sprintf("Register%sServer", utils.ToCamelCase(name))).Call(
+		jen.Id("baseServer"),
+		jen.Id("grpcServer"),
+	)
+	logAddr := jen.Id("logger").Dot("Log").Call(
+		jen.Lit("transport"),
+		jen.Lit("gRPC"),
+		jen.Lit("addr"),
+		jen.Id("*grpcAddr"),
+	)
+	interrupt := jen.Func().Params(jen.Error()).Block(
+		jen.Id("grpcListener").Dot("Close").Call(),
+	)
+	if !health {
+		return jen.Id("g").Dot("Add").Call(
+			jen.Func().Params().Error().Block(
+				logAddr,
+				jen.Id("baseServer").Op(":=").Qual("google.golang.org/grpc", "NewServer").Call(),
+				register,
+				jen.Return(
+					jen.Id("baseServer").Dot("Serve").Call(
+						jen.Id("grpcListener"),
+					),
+				),
+			),
+			interrupt,
+		).Line()
+	}
+	// the server is registered before the run group runs so the health
+	// service can join the group.
+	return jen.Id("baseServer").Op(":=").Qual("google.golang.org/grpc", "NewServer").Call().Line().
+		Add(register).Line().
+		Id(grpcHealthFunc).Call(jen.Id("baseServer"), jen.Id("g")).Line().
+		Id("g").Dot("Add").Call(
+		jen.Func().Params().Error().Block(
+			logAddr,
+			jen.Return(
+				jen.Id("baseServer").Dot("Serve").Call(
+					jen.Id("grpcListener"),
+				),
+			),
+		),
+		interrupt,
+	).Line()
+}
+
+// warnGRPCHealth warns if the existing initGRPCHandler `handler` of the cmd
+// file `cmdFilePath` does not serve the health checking and reflection
+// services although they are enabled, the handler belongs to the user.
+func warnGRPCHealth(handler, cmdFilePath string) {
+	if !viper.GetBool("gk_grpc_health") || strings.Contains(handler, grpcHealthFunc+"(") {
+		return
+	}
+	logrus.Warnf(
+		"`initGRPCHandler` of `%s` already exists, create the gRPC server before `g.Add` and call `%s(baseServer, g)` after registering the service to serve the health checking and reflection services.",
+		cmdFilePath,
+		grpcHealthFunc,
+	)
+}
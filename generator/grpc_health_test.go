@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/jennifer/jen"
+)
+
+func Test_grpcServe(t *testing.T) {
+	tests := []struct {
+		name   string
+		health bool
+		want   []string
+	}{
+		{
+			name: "without health",
+			want: []string{
+				"g.Add((func() error literal), (func(error) literal))",
+				`logger.Log("transport", "gRPC", "addr", *grpcAddr)`,
+				"baseServer := grpc.NewServer()",
+				"pb.RegisterHelloServer(baseServer, grpcServer)",
+				"return baseServer.Serve(grpcListener)",
+				"grpcListener.Close()",
+			},
+		},
+		{
+			name:   "with health",
+			health: true,
+			want: []string{
+				"baseServer := grpc.NewServer()",
+				"pb.RegisterHelloServer(baseServer, grpcServer)",
+				"initGRPCHealth(baseServer, g)",
+				"g.Add((func() error literal), (func(error) literal))",
+				`logger.Log("transport", "gRPC", "addr", *grpcAddr)`,
+				"return baseServer.Serve(grpcListener)",
+				"grpcListener.Close()",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := jen.NewFilePath("example.com/p/hello/cmd/service")
+			f.Func().Id("initGRPCHandler").Params().Block(grpcServe("example.com/p/hello/pkg/pb", "hello", tt.health))
+			src := f.GoString()
+			if got := stmts(t, src, "initGRPCHandler"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grpcServe() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func Test_appendGRPCHealth(t *testing.T) {
+	code := NewPartialGenerator(nil)
+	appendGRPCHealth(code, "pb.HelloWorld")
+	f := jen.NewFilePath("example.com/p/hello/cmd/service")
+	f.Add(code.Raw())
+	src := f.GoString()
+	params := []string{}
+	for _, p := range funcDecl(t, src, "initGRPCHealth").Type.Params.List {
+		params = append(params, exprString(p.Type))
+	}
+	if want := []string{"*grpc.Server", "*group.Group"}; !reflect.DeepEqual(params, want) {
+		t.Errorf("the parameters of initGRPCHealth = %v, want %v", params, want)
+	}
+	// the server and the service are SERVING until the interrupt function of
+	// the run group runs.
+	want := []string{
+		"healthServer := health.NewServer()",
+		"grpchealthv1.RegisterHealthServer(baseServer, healthServer)",
+		"reflection.Register(baseServer)",
+		"stop := make(chan struct{})",
+		"g.Add((func() error literal), (func(error) literal))",
+		`healthServer.SetServingStatus("", grpchealthv1.HealthCheckResponse_SERVING)`,
+		`healthServer.SetServingStatus("pb.HelloWorld", grpchealthv1.HealthCheckResponse_SERVING)`,
+		"<-stop",
+		"return nil",
+		"healthServer.Shutdown()",
+		"close(stop)",
+	}
+	if got := stmts(t, src, "initGRPCHealth"); !reflect.DeepEqual(got, want) {
+		t.Errorf("appendGRPCHealth() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
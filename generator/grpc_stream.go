@@ -0,0 +1,259 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+)
+
+// setRPCStreams makes the requests and the replies of the rpc streams as the
+// stream kind `kind` says (see streamKind), an empty kind makes it unary.
+func setRPCStreams(rpc *proto.RPC, kind string) {
+	rpc.StreamsRequest = kind == parser.StreamClient || kind == parser.StreamBidi
+	rpc.StreamsReturns = kind == parser.StreamServer || kind == parser.StreamBidi
+}
+
+// streamField returns the field of the fields that carries the elements of a
+// stream.
+func streamField(fields []pbField) (pbField, bool) {
+	for _, f := range fields {
+		if f.stream {
+			return f, true
+		}
+	}
+	return pbField{}, false
+}
+
+// streams returns the fields of the request and of the reply streams of the
+// rpc of `m`, ok is false if the fields do not match the stream kind of the
+// method (e.x the type of the elements of a channel is not supported).
+func (p *pbMessages) streams(m parser.Method) (in, out pbField, ok bool) {
+	if !p.ok || !streamServed(m) {
+		return in, out, false
+	}
+	in, inOk := streamField(p.requests[m.Name])
+	out, outOk := streamField(p.replies[m.Name])
+	switch streamKind(m) {
+	case parser.StreamClient:
+		return in, out, inOk
+	case parser.StreamServer:
+		return in, out, outOk
+	}
+	return in, out, inOk && outOk
+}
+
+// streamServerParams returns the parameters of the gRPC server method of the
+// streaming rpc of `m` of the proto service `service`, as protoc-gen-go
+// declares them.
+func (p *pbMessages) streamServerParams(service string, m parser.Method) []jen.Code {
+	stream := jen.Id("stream").Qual(p.pbImport, service+"_"+m.Name+"Server")
+	if streamKind(m) == parser.StreamServer {
+		return []jen.Code{jen.Id("req").Op("*").Qual(p.pbImport, m.Name+"Request"), stream}
+	}
+	return []jen.Code{stream}
+}
+
+// streamServer returns the body of the gRPC server method of the streaming rpc
+// of `m`, the method calls the endpoint `endpoint` of the server `stp`. The
+// first message of a stream carries the fields of the request or of the reply,
+// the following ones an element of the channel each.
+func (p *pbMessages) streamServer(m parser.Method, stp string) []jen.Code {
+	in, out, _ := p.streams(m)
+	kind := streamKind(m)
+	fail := jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err()))
+	code := []jen.Code{}
+	if kind == parser.StreamServer {
+		code = append(
+			code,
+			jen.Id("ctx").Op(":=").Id("stream").Dot("Context").Call(),
+			jen.List(jen.Id("request"), jen.Err()).Op(":=").Id("decode"+m.Name+"Request").Call(jen.Id("ctx"), jen.Id("req")),
+			fail,
+		)
+	} else {
+		code = append(
+			code,
+			jen.List(jen.Id("ctx"), jen.Id("cancel")).Op(":=").Qual("context", "WithCancel").Call(jen.Id("stream").Dot("Context").Call()),
+			jen.Defer().Id("cancel").Call(),
+			jen.List(jen.Id("req"), jen.Err()).Op(":=").Id("stream").Dot("Recv").Call(),
+			fail,
+			jen.List(jen.Id("request"), jen.Err()).Op(":=").Id("decode"+m.Name+"Request").Call(jen.Id("ctx"), jen.Id("req")),
+			fail,
+			jen.Id("in").Op(":=").Make(jen.Chan().Add(goType(in.typ))),
+			jen.Id("errc").Op(":=").Make(jen.Chan().Error(), jen.Lit(1)),
+			jen.Go().Func().Params().Block(
+				jen.Defer().Close(jen.Id("in")),
+				jen.For().Block(
+					jen.List(jen.Id("req"), jen.Err()).Op(":=").Id("stream").Dot("Recv").Call(),
+					jen.If(jen.Err().Op("==").Qual("io", "EOF")).Block(jen.Return()),
+					jen.If(jen.Err().Op("!=").Nil()).Block(
+						jen.Id("errc").Op("<-").Err(),
+						jen.Return(),
+					),
+					jen.Select().Block(
+						jen.Case(jen.Id("in").Op("<-").Add(p.fromPb(jen.Id("req").Dot(in.pbName), in.typ))),
+						jen.Case(jen.Op("<-").Id("ctx").Dot("Done").Call()).Block(jen.Return()),
+					),
+				),
+			).Call(),
+			jen.Id("r").Op(":=").Id("request").Assert(jen.Qual(p.endpointImport, m.Name+"Request")),
+			jen.Id("r").Dot(in.goName).Op("=").Id("in"),
+			jen.Id("request").Op("=").Id("r"),
+		)
+	}
+	code = append(
+		code,
+		jen.List(jen.Id("response"), jen.Err()).Op(":=").Id(stp).Dot(utils.ToLowerFirstCamelCase(m.Name)).Call(jen.Id("ctx"), jen.Id("request")),
+		fail,
+	)
+	recvErr := jen.Select().Block(
+		jen.Case(jen.Err().Op(":=").Op("<-").Id("errc")).Block(jen.Return(jen.Err())),
+		jen.Default(),
+	)
+	if kind == parser.StreamClient {
+		return append(
+			code,
+			recvErr,
+			jen.List(jen.Id("rep"), jen.Err()).Op(":=").Id("encode"+m.Name+"Response").Call(jen.Id("ctx"), jen.Id("response")),
+			fail,
+			jen.Return(jen.Id("stream").Dot("SendAndClose").Call(jen.Id("rep").Assert(jen.Op("*").Qual(p.pbImport, m.Name+"Reply")))),
+		)
+	}
+	code = append(
+		code,
+		jen.List(jen.Id("rep"), jen.Err()).Op(":=").Id("encode"+m.Name+"Response").Call(jen.Id("ctx"), jen.Id("response")),
+		fail,
+		jen.If(
+			jen.Err().Op(":=").Id("stream").Dot("Send").Call(jen.Id("rep").Assert(jen.Op("*").Qual(p.pbImport, m.Name+"Reply"))),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Err())),
+		jen.If(
+			jen.Id("out").Op(":=").Id("response").Assert(jen.Qual(p.endpointImport, m.Name+"Response")).Dot(out.goName),
+			jen.Id("out").Op("!=").Nil(),
+		).Block(
+			jen.For(jen.Id("e").Op(":=").Range().Id("out")).Block(
+				jen.If(
+					jen.Err().Op(":=").Id("stream").Dot("Send").Call(
+						jen.Op("&").Qual(p.pbImport, m.Name+"Reply").Values(jen.Dict{
+							jen.Id(out.pbName): p.toPb(jen.Id("e"), out.typ),
+						}),
+					),
+					jen.Err().Op("!=").Nil(),
+				).Block(jen.Return(jen.Err())),
+			),
+		),
+	)
+	if kind == parser.StreamBidi {
+		code = append(code, recvErr)
+	}
+	return append(code, jen.Return(jen.Nil()))
+}
+
+// streamServerStub returns the body of the gRPC server method of a streaming
+// rpc whose messages are not known.
+func streamServerStub(service string) []jen.Code {
+	return []jen.Code{
+		jen.Return(jen.Qual("errors", "New").Call(jen.Lit(fmt.Sprintf("'%s' Stream is not impelemented", service)))),
+	}
+}
+
+// streamClient returns the client endpoint of the streaming rpc of `m` of the
+// proto service `service`, it calls the rpc on the connection `conn` and
+// converts the streams from and to the channels of the endpoint request and
+// response (see streamServer).
+func (p *pbMessages) streamClient(service string, m parser.Method) jen.Code {
+	in, out, _ := p.streams(m)
+	kind := streamKind(m)
+	fail := jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err()))
+	client := jen.Qual(p.pbImport, "New"+service+"Client").Call(jen.Id("conn")).Dot(m.Name)
+	code := []jen.Code{
+		jen.List(jen.Id("req"), jen.Err()).Op(":=").Id("encode"+m.Name+"Request").Call(jen.Id("ctx"), jen.Id("request")),
+		fail,
+	}
+	if kind == parser.StreamServer {
+		code = append(
+			code,
+			jen.List(jen.Id("stream"), jen.Err()).Op(":=").Add(client).Call(jen.Id("ctx"), jen.Id("req").Assert(jen.Op("*").Qual(p.pbImport, m.Name+"Request"))),
+			fail,
+		)
+	} else {
+		code = append(
+			code,
+			jen.List(jen.Id("stream"), jen.Err()).Op(":=").Add(client).Call(jen.Id("ctx")),
+			fail,
+			jen.If(
+				jen.Err().Op(":=").Id("stream").Dot("Send").Call(jen.Id("req").Assert(jen.Op("*").Qual(p.pbImport, m.Name+"Request"))),
+				jen.Err().Op("!=").Nil(),
+			).Block(jen.Return(jen.Nil(), jen.Err())),
+		)
+	}
+	send := func(stop jen.Code) jen.Code {
+		return jen.If(
+			jen.Id("in").Op(":=").Id("request").Assert(jen.Qual(p.endpointImport, m.Name+"Request")).Dot(in.goName),
+			jen.Id("in").Op("!=").Nil(),
+		).Block(
+			jen.For(jen.Id("e").Op(":=").Range().Id("in")).Block(
+				jen.If(
+					jen.Err().Op(":=").Id("stream").Dot("Send").Call(
+						jen.Op("&").Qual(p.pbImport, m.Name+"Request").Values(jen.Dict{
+							jen.Id(in.pbName): p.toPb(jen.Id("e"), in.typ),
+						}),
+					),
+					jen.Err().Op("!=").Nil(),
+				).Block(stop),
+			),
+		)
+	}
+	switch kind {
+	case parser.StreamClient:
+		code = append(
+			code,
+			send(jen.Return(jen.Nil(), jen.Err())),
+			jen.List(jen.Id("rep"), jen.Err()).Op(":=").Id("stream").Dot("CloseAndRecv").Call(),
+			fail,
+			jen.Return(jen.Id("decode"+m.Name+"Response").Call(jen.Id("ctx"), jen.Id("rep"))),
+		)
+		return streamEndpoint(code)
+	case parser.StreamBidi:
+		code = append(
+			code,
+			jen.Go().Func().Params().Block(
+				jen.Defer().Id("stream").Dot("CloseSend").Call(),
+				send(jen.Return()),
+			).Call(),
+		)
+	}
+	code = append(
+		code,
+		jen.List(jen.Id("rep"), jen.Err()).Op(":=").Id("stream").Dot("Recv").Call(),
+		fail,
+		jen.List(jen.Id("response"), jen.Err()).Op(":=").Id("decode"+m.Name+"Response").Call(jen.Id("ctx"), jen.Id("rep")),
+		fail,
+		jen.Id("out").Op(":=").Make(jen.Chan().Add(goType(out.typ))),
+		jen.Go().Func().Params().Block(
+			jen.Defer().Close(jen.Id("out")),
+			jen.For().Block(
+				jen.List(jen.Id("rep"), jen.Err()).Op(":=").Id("stream").Dot("Recv").Call(),
+				jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return()),
+				jen.Select().Block(
+					jen.Case(jen.Id("out").Op("<-").Add(p.fromPb(jen.Id("rep").Dot(out.pbName), out.typ))),
+					jen.Case(jen.Op("<-").Id("ctx").Dot("Done").Call()).Block(jen.Return()),
+				),
+			),
+		).Call(),
+		jen.Id("r").Op(":=").Id("response").Assert(jen.Qual(p.endpointImport, m.Name+"Response")),
+		jen.Id("r").Dot(out.goName).Op("=").Id("out"),
+		jen.Return(jen.Id("r"), jen.Nil()),
+	)
+	return streamEndpoint(code)
+}
+
+// streamEndpoint returns the endpoint function with the body.
+func streamEndpoint(body []jen.Code) jen.Code {
+	return jen.Func().Params(
+		jen.Id("ctx").Qual("context", "Context"),
+		jen.Id("request").Interface(),
+	).Params(jen.Interface(), jen.Error()).Block(body...)
+}
@@ -0,0 +1,188 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+)
+
+func newTestStreamMessages(t *testing.T) (*pbMessages, map[string]parser.Method) {
+	r := newTestResolver(map[string]string{
+		"pkg/service/service.go": `package service
+
+import "context"
+
+type Event struct {
+	Name string
+}
+
+type HelloService interface {
+	Tail(ctx context.Context, id string) (events <-chan Event, err error)
+	Upload(ctx context.Context, name string, chunks <-chan []byte) (size int64, err error)
+	Chat(ctx context.Context, in <-chan string) (out <-chan *Event)
+}
+`,
+	})
+	sigs, ok := r.interfaceMethods("example.com/p/pkg/service", "HelloService")
+	if !ok {
+		t.Fatal("typeResolver.interfaceMethods() did not find HelloService")
+	}
+	ctx := parser.NewNameType("ctx", "context.Context")
+	methods := map[string]parser.Method{
+		"Tail": {
+			Name:       "Tail",
+			Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("id", "string")},
+			Results:    []parser.NamedTypeValue{parser.NewNameType("events", "<-chan Event"), parser.NewNameType("err", "error")},
+		},
+		"Upload": {
+			Name:       "Upload",
+			Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("name", "string"), parser.NewNameType("chunks", "<-chan []byte")},
+			Results:    []parser.NamedTypeValue{parser.NewNameType("size", "int64"), parser.NewNameType("err", "error")},
+		},
+		"Chat": {
+			Name:       "Chat",
+			Parameters: []parser.NamedTypeValue{ctx, parser.NewNameType("in", "<-chan string")},
+			Results:    []parser.NamedTypeValue{parser.NewNameType("out", "<-chan *Event")},
+		},
+	}
+	p := newPbMessagesOf("example.com/p/pkg/pb", "example.com/p/pkg/endpoint")
+	p.addMethods(sigs, []parser.Method{methods["Tail"], methods["Upload"], methods["Chat"]})
+	return p, methods
+}
+
+func TestPbMessages_streams(t *testing.T) {
+	p, methods := newTestStreamMessages(t)
+	for _, tt := range []struct {
+		name   string
+		fields []pbField
+		want   []string
+	}{
+		{name: "TailReply", fields: p.replies["Tail"], want: []string{"Event events = 1", "string err = 2"}},
+		{name: "UploadRequest", fields: p.requests["Upload"], want: []string{"string name = 1", "bytes chunks = 2"}},
+		{name: "ChatReply", fields: p.replies["Chat"], want: []string{"Event out = 1"}},
+	} {
+		if got := strings.Join(fieldLines(protoMessage(tt.name, tt.fields)), ", "); got != strings.Join(tt.want, ", ") {
+			t.Errorf("protoMessage(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	for name, m := range methods {
+		if _, _, ok := p.streams(m); !ok {
+			t.Errorf("pbMessages.streams(%s) did not find the stream fields", name)
+		}
+	}
+	f := jen.NewFilePath("example.com/p/pkg/grpc")
+	for _, name := range []string{"Tail", "Upload", "Chat"} {
+		m := methods[name]
+		f.Func().Params(jen.Id("g").Op("*").Id("grpcServer")).Id(name).Params(p.streamServerParams("Hello", m)...).Error().Block(p.streamServer(m, "g")...)
+		f.Func().Id(utils.ToLowerFirstCamelCase(name)+"Client").Params(
+			jen.Id("conn").Op("*").Qual("google.golang.org/grpc", "ClientConn"),
+		).Qual("github.com/go-kit/kit/endpoint", "Endpoint").Block(jen.Return(p.streamClient("Hello", m)))
+		f.Func().Id("decode"+name+"Request").Params(jen.Id("r"), jen.Id("request").Interface()).Params(jen.Interface(), jen.Error()).Block(p.decodeRequest(name)...)
+	}
+	src := f.GoString()
+	// the servers implement the methods of pb.HelloServer.
+	for name, want := range map[string][]string{
+		"Tail":   {"*pb.TailRequest", "pb.Hello_TailServer"},
+		"Upload": {"pb.Hello_UploadServer"},
+		"Chat":   {"pb.Hello_ChatServer"},
+	} {
+		got := []string{}
+		for _, p := range funcDecl(t, src, name).Type.Params.List {
+			got = append(got, exprString(p.Type))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("the parameters of grpcServer.%s = %v, want %v", name, got, want)
+		}
+	}
+	// the statements are generated in this order, other statements can come
+	// in between.
+	for name, want := range map[string][]string{
+		"Tail": {
+			"ctx := stream.Context()",
+			"request, err := decodeTailRequest(ctx, req)",
+			"response, err := g.tail(ctx, request)",
+			"err := stream.Send(rep.(*pb.TailReply))",
+			"out := response.(endpoint.TailResponse).Events",
+			"err := stream.Send(&pb.TailReply{Events: toPbEvent(&e)})",
+		},
+		"Upload": {
+			"req, err := stream.Recv()",
+			"request, err := decodeUploadRequest(ctx, req)",
+			"in := make(chan []byte)",
+			"in <- req.Chunks",
+			"r.Chunks = in",
+			"response, err := g.upload(ctx, request)",
+			"return stream.SendAndClose(rep.(*pb.UploadReply))",
+		},
+		"Chat": {
+			"in <- req.In",
+			"response, err := g.chat(ctx, request)",
+			"err := stream.Send(&pb.ChatReply{Out: toPbEvent(e)})",
+		},
+		"tailClient": {
+			"stream, err := pb.NewHelloClient(conn).Tail(ctx, req.(*pb.TailRequest))",
+			"rep, err := stream.Recv()",
+			"out := make(chan service.Event)",
+			"out <- fromPbEvent(rep.Events)",
+			"r.Events = out",
+		},
+		"uploadClient": {
+			"stream, err := pb.NewHelloClient(conn).Upload(ctx)",
+			"err := stream.Send(req.(*pb.UploadRequest))",
+			"err := stream.Send(&pb.UploadRequest{Chunks: e})",
+			"rep, err := stream.CloseAndRecv()",
+			"return decodeUploadResponse(ctx, rep)",
+		},
+		"chatClient": {
+			"stream, err := pb.NewHelloClient(conn).Chat(ctx)",
+			"defer stream.CloseSend()",
+			"err := stream.Send(&pb.ChatRequest{In: e})",
+			"out := make(chan *service.Event)",
+			"out <- fromPbEventPtr(rep.Out)",
+		},
+	} {
+		got := stmts(t, src, name)
+		i := 0
+		for _, st := range got {
+			if i < len(want) && st == want[i] {
+				i++
+			}
+		}
+		if i < len(want) {
+			t.Errorf("%s does not have `%s` in order, its statements are:\n%s", name, want[i], strings.Join(got, "\n"))
+		}
+	}
+	// the channels are not decoded, the stream carries them.
+	for name, want := range map[string]map[string]string{
+		"decodeUploadRequest": {"Name": "v.Name"},
+		"decodeChatRequest":   {},
+	} {
+		if got := returnedFields(t, src, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s returns %v, want %v", name, got, want)
+		}
+	}
+}
+
+func Test_setRPCStreams(t *testing.T) {
+	tests := []struct {
+		kind                 string
+		wantRequest, wantRep bool
+	}{
+		{kind: ""},
+		{kind: parser.StreamClient, wantRequest: true},
+		{kind: parser.StreamServer, wantRep: true},
+		{kind: parser.StreamBidi, wantRequest: true, wantRep: true},
+	}
+	for _, tt := range tests {
+		rpc := &proto.RPC{StreamsRequest: true, StreamsReturns: true}
+		setRPCStreams(rpc, tt.kind)
+		if rpc.StreamsRequest != tt.wantRequest || rpc.StreamsReturns != tt.wantRep {
+			t.Errorf("setRPCStreams(%q) = %v, %v, want %v, %v", tt.kind, rpc.StreamsRequest, rpc.StreamsReturns, tt.wantRequest, tt.wantRep)
+		}
+	}
+}
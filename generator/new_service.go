@@ -3,8 +3,10 @@ package generator
 import (
 	"fmt"
 	"path"
+	"path/filepath"
 	"strings"
 
+	"github.com/Songmu/prompter"
 	"github.com/dave/jennifer/jen"
 	"github.com/hms58/genkit/fs"
 	"github.com/hms58/genkit/utils"
@@ -34,22 +36,54 @@ func NewNewService(name string) Gen {
 	gs.srcFile = jen.NewFilePath(strings.Replace(gs.destPath, "\\", "/", -1))
 	gs.InitPg()
 	gs.fs = fs.Get()
+	gs.generator = "NewService"
 	return gs
 }
 
 // Generate will run the generator.
+// The files are written to disk only if it succeeds and the generated code
+// compiles (unless `--no-verify`).
 func (g *NewService) Generate() error {
-	g.CreateFolderStructure(g.destPath)
-	comments := []string{
-		"Add your methods here",
-		"e.x: Foo(ctx context.Context,s string)(rs string, err error)",
+	return g.generateVerified(g.generate)
+}
+
+func (g *NewService) generate() error {
+	if err := g.generateGoMod(); err != nil {
+		return err
 	}
+	g.CreateFolderStructure(g.destPath)
 	partial := NewPartialGenerator(nil)
-	partial.appendMultilineComment(comments)
+	partial.appendMultilineComment(serviceTemplateComment)
 	g.code.Raw().Commentf("%s describes the service.", g.interfaceName).Line()
 	g.code.appendInterface(
 		g.interfaceName,
 		[]jen.Code{partial.Raw()},
 	)
-	return g.fs.WriteFile(g.filePath, g.srcFile.GoString(), false)
+	return g.writeFile(g.filePath, g.srcFile.GoString(), false)
+}
+
+// generateGoMod offers to create a go.mod file if the project is neither
+// inside a go module nor inside the $GOPATH/src folder.
+func (g *NewService) generateGoMod() error {
+	if viper.GetBool("gk_testing") {
+		return nil
+	}
+	if _, err := utils.GetProjectPath(); err != utils.ErrNoProject {
+		return err
+	}
+	modulePath := viper.GetString("n_s_module")
+	if modulePath == "" {
+		if !prompter.YN("The project is not inside a go module, do you want to create a `go.mod` file ?", true) {
+			return utils.ErrNoProject
+		}
+		pwd, err := utils.GetWorkingDir()
+		if err != nil {
+			return err
+		}
+		modulePath = prompter.Prompt("Module path", filepath.Base(pwd))
+	}
+	if modulePath == "" {
+		return utils.ErrNoProject
+	}
+	return g.writeFile(utils.GoModFileName, utils.GoModSource(modulePath), false)
 }
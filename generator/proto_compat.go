@@ -0,0 +1,389 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/emicklei/proto"
+	"github.com/spf13/viper"
+)
+
+// protoChange is a change of a proto file, a breaking change breaks the clients
+// that are built from the previous definition (e.x a field is renumbered).
+type protoChange struct {
+	breaking bool
+	msg      string
+}
+
+// protoField is a field of a message as it is seen on the wire.
+type protoField struct {
+	name, typ, label string
+	number           int
+}
+
+// protoMessageDef is a message with its fields and its reserved numbers and
+// names.
+type protoMessageDef struct {
+	msg           *proto.Message
+	fields        []protoField
+	reservedNums  []proto.Range
+	reservedNames map[string]bool
+}
+
+func (m protoMessageDef) fieldByNumber(n int) (protoField, bool) {
+	for _, f := range m.fields {
+		if f.number == n {
+			return f, true
+		}
+	}
+	return protoField{}, false
+}
+
+func (m protoMessageDef) fieldByName(name string) (protoField, bool) {
+	for _, f := range m.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return protoField{}, false
+}
+
+func (m protoMessageDef) reservesNumber(n int) bool {
+	for _, r := range m.reservedNums {
+		if n >= r.From && (r.Max || n <= r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// protoMessageDefs returns the messages of the elements by their full name
+// (e.x `Outer.Inner`), the nested messages included.
+func protoMessageDefs(prefix string, elements []proto.Visitee, defs map[string]protoMessageDef) map[string]protoMessageDef {
+	for _, e := range elements {
+		m, ok := e.(*proto.Message)
+		if !ok || m.IsExtend {
+			continue
+		}
+		def := protoMessageDef{msg: m, reservedNames: map[string]bool{}}
+		var visit func(elements []proto.Visitee, oneof bool)
+		visit = func(elements []proto.Visitee, oneof bool) {
+			for _, e := range elements {
+				switch f := e.(type) {
+				case *proto.NormalField:
+					label := ""
+					if f.Repeated {
+						label = "repeated"
+					} else if oneof {
+						label = "oneof"
+					}
+					def.fields = append(def.fields, protoField{name: f.Name, typ: f.Type, label: label, number: f.Sequence})
+				case *proto.MapField:
+					def.fields = append(def.fields, protoField{name: f.Name, typ: fmt.Sprintf("map<%s, %s>", f.KeyType, f.Type), number: f.Sequence})
+				case *proto.OneOfField:
+					def.fields = append(def.fields, protoField{name: f.Name, typ: f.Type, label: "oneof", number: f.Sequence})
+				case *proto.Oneof:
+					visit(f.Elements, true)
+				case *proto.Reserved:
+					def.reservedNums = append(def.reservedNums, f.Ranges...)
+					for _, n := range f.FieldNames {
+						def.reservedNames[n] = true
+					}
+				}
+			}
+		}
+		visit(m.Elements, false)
+		defs[prefix+m.Name] = def
+		protoMessageDefs(prefix+m.Name+".", m.Elements, defs)
+	}
+	return defs
+}
+
+// protoEnumDefs returns the values of the enums by the full name of the enum.
+func protoEnumDefs(prefix string, elements []proto.Visitee, defs map[string]map[int]string) map[string]map[int]string {
+	for _, e := range elements {
+		switch v := e.(type) {
+		case *proto.Enum:
+			values := map[int]string{}
+			for _, ev := range v.Elements {
+				if f, ok := ev.(*proto.EnumField); ok {
+					values[f.Integer] = f.Name
+				}
+			}
+			defs[prefix+v.Name] = values
+		case *proto.Message:
+			protoEnumDefs(prefix+v.Name+".", v.Elements, defs)
+		}
+	}
+	return defs
+}
+
+// protoRPCs returns the rpcs of the services by `<Service>.<Rpc>`.
+func protoRPCs(def *proto.Proto) (services map[string]bool, rpcs map[string]*proto.RPC) {
+	services, rpcs = map[string]bool{}, map[string]*proto.RPC{}
+	for _, e := range def.Elements {
+		if s, ok := e.(*proto.Service); ok {
+			services[s.Name] = true
+			for _, se := range s.Elements {
+				if r, ok := se.(*proto.RPC); ok {
+					rpcs[s.Name+"."+r.Name] = r
+				}
+			}
+		}
+	}
+	return services, rpcs
+}
+
+func protoPackage(def *proto.Proto) string {
+	for _, e := range def.Elements {
+		if p, ok := e.(*proto.Package); ok {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func rpcStreams(r *proto.RPC) string {
+	switch {
+	case r.StreamsRequest && r.StreamsReturns:
+		return "a bidi streaming"
+	case r.StreamsRequest:
+		return "a client streaming"
+	case r.StreamsReturns:
+		return "a server streaming"
+	}
+	return "an unary"
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch v := m.(type) {
+	case map[string]bool:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*proto.RPC:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]protoMessageDef:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]map[int]string:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareProto returns the changes from the proto definition `old` to `def`.
+func compareProto(old, def *proto.Proto) []protoChange {
+	changes := []protoChange{}
+	add := func(breaking bool, format string, a ...interface{}) {
+		changes = append(changes, protoChange{breaking: breaking, msg: fmt.Sprintf(format, a...)})
+	}
+	if o, n := protoPackage(old), protoPackage(def); o != n {
+		add(true, "the package `%s` is renamed to `%s`", o, n)
+	}
+	oldServices, oldRPCs := protoRPCs(old)
+	newServices, newRPCs := protoRPCs(def)
+	for _, s := range sortedKeys(oldServices) {
+		if !newServices[s] {
+			add(true, "the service `%s` is removed", s)
+		}
+	}
+	for _, name := range sortedKeys(oldRPCs) {
+		o, n := oldRPCs[name], newRPCs[name]
+		switch {
+		case n == nil:
+			add(true, "the rpc `%s` is removed", name)
+		case o.RequestType != n.RequestType:
+			add(true, "the request of the rpc `%s` changed from `%s` to `%s`", name, o.RequestType, n.RequestType)
+		case o.ReturnsType != n.ReturnsType:
+			add(true, "the reply of the rpc `%s` changed from `%s` to `%s`", name, o.ReturnsType, n.ReturnsType)
+		case rpcStreams(o) != rpcStreams(n):
+			add(true, "the rpc `%s` changed from %s to %s rpc", name, rpcStreams(o), rpcStreams(n))
+		}
+	}
+	for _, name := range sortedKeys(newRPCs) {
+		if oldRPCs[name] == nil {
+			add(false, "the rpc `%s` is added", name)
+		}
+	}
+	oldMsgs := protoMessageDefs("", old.Elements, map[string]protoMessageDef{})
+	newMsgs := protoMessageDefs("", def.Elements, map[string]protoMessageDef{})
+	removed := []string{}
+	for _, name := range sortedKeys(oldMsgs) {
+		o := oldMsgs[name]
+		n, ok := newMsgs[name]
+		if !ok {
+			// the nested messages go with the message.
+			nested := false
+			for _, r := range removed {
+				nested = nested || strings.HasPrefix(name, r+".")
+			}
+			if !nested {
+				add(true, "the message `%s` is removed", name)
+				removed = append(removed, name)
+			}
+			continue
+		}
+		for _, of := range o.fields {
+			nf, ok := n.fieldByNumber(of.number)
+			if !ok {
+				if moved, ok := n.fieldByName(of.name); ok {
+					add(true, "the field `%s.%s` is renumbered from %d to %d", name, of.name, of.number, moved.number)
+				} else if n.reservesNumber(of.number) && n.reservedNames[of.name] {
+					// the clients ignore a field that is never used again.
+					add(false, "the field `%s.%s` (%d) is removed and reserved", name, of.name, of.number)
+				} else {
+					add(true, "the field `%s.%s` (%d) is removed", name, of.name, of.number)
+				}
+				continue
+			}
+			if nf.name != of.name {
+				add(true, "the field %d of `%s` is renamed from `%s` to `%s`", of.number, name, of.name, nf.name)
+			}
+			if nf.typ != of.typ {
+				add(true, "the type of the field `%s.%s` changed from `%s` to `%s`", name, nf.name, of.typ, nf.typ)
+			} else if nf.label != of.label {
+				add(
+					true,
+					"the field `%s.%s` changed from `%s` to `%s`",
+					name,
+					nf.name,
+					strings.TrimSpace(of.label+" "+of.typ),
+					strings.TrimSpace(nf.label+" "+nf.typ),
+				)
+			}
+		}
+		for _, nf := range n.fields {
+			if _, ok := o.fieldByNumber(nf.number); ok {
+				continue
+			}
+			if _, ok := o.fieldByName(nf.name); ok {
+				// reported as renumbered.
+				continue
+			}
+			if o.reservesNumber(nf.number) || o.reservedNames[nf.name] {
+				add(true, "the field `%s.%s` (%d) reuses a reserved field", name, nf.name, nf.number)
+				continue
+			}
+			add(false, "the field `%s.%s` (%d) is added", name, nf.name, nf.number)
+		}
+	}
+	for _, name := range sortedKeys(newMsgs) {
+		if _, ok := oldMsgs[name]; !ok {
+			add(false, "the message `%s` is added", name)
+		}
+	}
+	oldEnums := protoEnumDefs("", old.Elements, map[string]map[int]string{})
+	newEnums := protoEnumDefs("", def.Elements, map[string]map[int]string{})
+	for _, name := range sortedKeys(oldEnums) {
+		n, ok := newEnums[name]
+		if !ok {
+			add(true, "the enum `%s` is removed", name)
+			continue
+		}
+		numbers := []int{}
+		for i := range oldEnums[name] {
+			numbers = append(numbers, i)
+		}
+		sort.Ints(numbers)
+		for _, i := range numbers {
+			if nv, ok := n[i]; !ok {
+				add(true, "the value `%s.%s` (%d) is removed", name, oldEnums[name][i], i)
+			} else if nv != oldEnums[name][i] {
+				add(true, "the value %d of `%s` is renamed from `%s` to `%s`", i, name, oldEnums[name][i], nv)
+			}
+		}
+	}
+	return changes
+}
+
+// reserveRemovedFields reserves the numbers and the names of the fields of
+// `old` that are not in the same message of `def` anymore, so they are never
+// used again with another meaning.
+func reserveRemovedFields(old, def *proto.Proto) {
+	oldMsgs := protoMessageDefs("", old.Elements, map[string]protoMessageDef{})
+	newMsgs := protoMessageDefs("", def.Elements, map[string]protoMessageDef{})
+	for _, name := range sortedKeys(oldMsgs) {
+		n, ok := newMsgs[name]
+		if !ok {
+			continue
+		}
+		// the numbers and the names can not be reserved by the same statement.
+		numbers, names := &proto.Reserved{}, &proto.Reserved{}
+		for _, of := range oldMsgs[name].fields {
+			if _, ok := n.fieldByNumber(of.number); !ok && !n.reservesNumber(of.number) {
+				numbers.Ranges = append(numbers.Ranges, proto.Range{From: of.number, To: of.number})
+			}
+			if _, ok := n.fieldByName(of.name); !ok && !n.reservedNames[of.name] {
+				names.FieldNames = append(names.FieldNames, of.name)
+			}
+		}
+		reserved := []proto.Visitee{}
+		if len(numbers.Ranges) > 0 {
+			reserved = append(reserved, numbers)
+		}
+		if len(names.FieldNames) > 0 {
+			reserved = append(reserved, names)
+		}
+		if len(reserved) == 0 {
+			continue
+		}
+		// the reserved fields go before the fields of the message.
+		at := 0
+		for i, e := range n.msg.Elements {
+			switch e.(type) {
+			case *proto.Reserved, *proto.Option:
+				at = i + 1
+			}
+		}
+		elements := append([]proto.Visitee{}, n.msg.Elements[:at]...)
+		elements = append(elements, reserved...)
+		n.msg.Elements = append(elements, n.msg.Elements[at:]...)
+	}
+}
+
+// checkProtoChanges compares the proto definition `def` with the proto file
+// `pbFilePath` that it replaces. The removed fields are reserved first so their
+// removal is compatible, the breaking changes that are left are refused unless
+// they are allowed with `--allow-breaking`.
+func (b *BaseGenerator) checkProtoChanges(pbFilePath string, def *proto.Proto) error {
+	if ok, err := b.fs.Exists(pbFilePath); err != nil || !ok {
+		return err
+	}
+	src, err := b.fs.ReadFile(pbFilePath)
+	if err != nil {
+		return err
+	}
+	old, err := proto.NewParser(bytes.NewReader([]byte(src))).Parse()
+	if err != nil {
+		return err
+	}
+	reserveRemovedFields(old, def)
+	breaking := 0
+	for _, c := range compareProto(old, def) {
+		if c.breaking {
+			breaking++
+			logrus.Warnf("Breaking change in `%s`: %s.", pbFilePath, c.msg)
+		} else {
+			logrus.Infof("Compatible change in `%s`: %s.", pbFilePath, c.msg)
+		}
+	}
+	if breaking > 0 && !viper.GetBool("gk_allow_breaking") {
+		return fmt.Errorf(
+			"`%s` has %d breaking change(s) that break the deployed clients, use --allow-breaking to write them anyway",
+			pbFilePath,
+			breaking,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+	"github.com/hms58/genkit/fs"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+const testCompatProto = `syntax = "proto3";
+
+package pb;
+
+service Hello {
+ rpc Foo (FooRequest) returns (FooReply);
+ rpc Tail (TailRequest) returns (stream TailReply);
+}
+
+message FooRequest {
+ reserved 9;
+ string name = 1;
+ repeated int64 ids = 2;
+ map<string, int32> counts = 3;
+ oneof by {
+  string email = 4;
+ }
+ message Inner {
+  bool ok = 1;
+ }
+}
+
+enum Kind {
+ UNKNOWN = 0;
+ USER = 1;
+}
+`
+
+func parseTestProto(t *testing.T, src string) *proto.Proto {
+	def, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return def
+}
+
+func Test_compareProto(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		want    []string
+		breaker bool
+	}{
+		{
+			name: "same definition",
+			new:  testCompatProto,
+			want: []string{},
+		},
+		{
+			name: "added rpc field and message",
+			new: strings.NewReplacer(
+				" rpc Tail", " rpc Bar (BarRequest) returns (BarReply);\n rpc Tail",
+				" map<string", " string city = 5;\n map<string",
+				"enum Kind", "message BarRequest {\n}\n\nenum Kind",
+				" USER = 1;", " USER = 1;\n ADMIN = 2;",
+			).Replace(testCompatProto),
+			want: []string{
+				"the rpc `Hello.Bar` is added",
+				"the field `FooRequest.city` (5) is added",
+				"the message `BarRequest` is added",
+			},
+		},
+		{
+			name: "removed rpc and field",
+			new: strings.NewReplacer(
+				" rpc Foo (FooRequest) returns (FooReply);\n", "",
+				" repeated int64 ids = 2;\n", "",
+			).Replace(testCompatProto),
+			want: []string{
+				"the rpc `Hello.Foo` is removed",
+				"the field `FooRequest.ids` (2) is removed",
+			},
+			breaker: true,
+		},
+		{
+			name: "removed and reserved field",
+			new: strings.NewReplacer(
+				" reserved 9;\n", " reserved 2, 9;\n reserved \"ids\";\n",
+				" repeated int64 ids = 2;\n", "",
+			).Replace(testCompatProto),
+			want: []string{
+				"the field `FooRequest.ids` (2) is removed and reserved",
+			},
+		},
+		{
+			name: "removed field with a reserved number",
+			new: strings.NewReplacer(
+				" reserved 9;\n", " reserved 2, 9;\n",
+				" repeated int64 ids = 2;\n", "",
+			).Replace(testCompatProto),
+			want: []string{
+				"the field `FooRequest.ids` (2) is removed",
+			},
+			breaker: true,
+		},
+		{
+			name: "renumbered retyped and renamed fields",
+			new: strings.NewReplacer(
+				"string name = 1;", "string name = 6;",
+				"repeated int64 ids = 2;", "repeated string ids = 2;",
+				"bool ok = 1;", "bool done = 1;",
+				"string email = 4;", "string mail = 4;",
+			).Replace(testCompatProto),
+			want: []string{
+				"the field `FooRequest.name` is renumbered from 1 to 6",
+				"the type of the field `FooRequest.ids` changed from `int64` to `string`",
+				"the field 4 of `FooRequest` is renamed from `email` to `mail`",
+				"the field 1 of `FooRequest.Inner` is renamed from `ok` to `done`",
+			},
+			breaker: true,
+		},
+		{
+			name: "repeated to singular",
+			new:  strings.Replace(testCompatProto, "repeated int64 ids = 2;", "int64 ids = 2;", 1),
+			want: []string{
+				"the field `FooRequest.ids` changed from `repeated int64` to `int64`",
+			},
+			breaker: true,
+		},
+		{
+			name: "reused reserved number",
+			new:  strings.Replace(testCompatProto, " string name = 1;", " string name = 1;\n string city = 9;", 1),
+			want: []string{
+				"the field `FooRequest.city` (9) reuses a reserved field",
+			},
+			breaker: true,
+		},
+		{
+			name: "removed message",
+			new:  strings.Replace(testCompatProto, testCompatProto[strings.Index(testCompatProto, "message FooRequest"):strings.Index(testCompatProto, "enum Kind")], "", 1),
+			want: []string{
+				"the message `FooRequest` is removed",
+			},
+			breaker: true,
+		},
+		{
+			name: "streams and enums",
+			new: strings.NewReplacer(
+				"returns (stream TailReply)", "returns (TailReply)",
+				" USER = 1;\n", "",
+			).Replace(testCompatProto),
+			want: []string{
+				"the rpc `Hello.Tail` changed from a server streaming to an unary rpc",
+				"the value `Kind.USER` (1) is removed",
+			},
+			breaker: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := tt.old
+			if old == "" {
+				old = testCompatProto
+			}
+			changes := compareProto(parseTestProto(t, old), parseTestProto(t, tt.new))
+			got := []string{}
+			breaking := false
+			for _, c := range changes {
+				got = append(got, c.msg)
+				breaking = breaking || c.breaking
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareProto() = %q, want %q", got, tt.want)
+			}
+			if breaking != tt.breaker {
+				t.Errorf("compareProto() breaking = %v, want %v", breaking, tt.breaker)
+			}
+		})
+	}
+}
+
+// messageLines returns the elements of the message as they are declared, the
+// oneofs and the nested messages by their name.
+func messageLines(m *proto.Message) []string {
+	lines := []string{}
+	for _, e := range m.Elements {
+		switch v := e.(type) {
+		case *proto.Reserved:
+			r := []string{}
+			for _, rg := range v.Ranges {
+				r = append(r, rg.SourceRepresentation())
+			}
+			for _, n := range v.FieldNames {
+				r = append(r, strconv.Quote(n))
+			}
+			lines = append(lines, "reserved "+strings.Join(r, ", "))
+		case *proto.Option:
+			lines = append(lines, fmt.Sprintf("option %s = %s", v.Name, v.Constant.SourceRepresentation()))
+		case *proto.Oneof:
+			lines = append(lines, "oneof "+v.Name)
+		case *proto.Message:
+			lines = append(lines, "message "+v.Name)
+		default:
+			lines = append(lines, fieldLines(&proto.Message{Elements: []proto.Visitee{e}})...)
+		}
+	}
+	return lines
+}
+
+func Test_reserveRemovedFields(t *testing.T) {
+	old := parseTestProto(t, testCompatProto)
+	def := parseTestProto(t, strings.NewReplacer(
+		" repeated int64 ids = 2;\n", "",
+		"string name = 1;", "string name = 6;",
+		" reserved 9;\n", " reserved 9;\n option deprecated = true;\n",
+	).Replace(testCompatProto))
+	reserveRemovedFields(old, def)
+	buf := new(bytes.Buffer)
+	protofmt.NewFormatter(buf, " ").Format(def)
+	// the reservations go after the ones of the message, the formatted file
+	// declares them.
+	var got []string
+	for _, e := range parseTestProto(t, buf.String()).Elements {
+		if m, ok := e.(*proto.Message); ok && m.Name == "FooRequest" {
+			got = messageLines(m)
+		}
+	}
+	want := []string{
+		"reserved 9",
+		"option deprecated = true",
+		"reserved 1, 2",
+		`reserved "ids"`,
+		"string name = 6",
+		"map<string, int32> counts = 3",
+		"oneof by",
+		"message Inner",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reserveRemovedFields() declares %q, want %q:\n%s", got, want, buf.String())
+	}
+	// the reserved fields are not reserved twice.
+	again := parseTestProto(t, buf.String())
+	reserveRemovedFields(old, again)
+	if changes := compareProto(parseTestProto(t, buf.String()), again); len(changes) != 0 {
+		t.Errorf("reserveRemovedFields() reserved the fields again: %v", changes)
+	}
+}
+
+func TestBaseGenerator_checkProtoChanges(t *testing.T) {
+	defer viper.Set("gk_allow_breaking", nil)
+	tests := []struct {
+		name          string
+		new           string
+		allowBreaking bool
+		wantErr       bool
+		want          []string
+	}{
+		{
+			name: "removed field",
+			new:  strings.Replace(testCompatProto, " repeated int64 ids = 2;\n", "", 1),
+			want: []string{"reserved 9", "reserved 2", `reserved "ids"`, "string name = 1"},
+		},
+		{
+			name:    "retyped field",
+			new:     strings.Replace(testCompatProto, "repeated int64 ids = 2;", "repeated string ids = 2;", 1),
+			wantErr: true,
+		},
+		{
+			name:          "allowed renumbered field",
+			new:           strings.Replace(testCompatProto, "string name = 1;", "string name = 6;", 1),
+			allowBreaking: true,
+			want:          []string{"reserved 9", "reserved 1", "string name = 6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("gk_allow_breaking", tt.allowBreaking)
+			b := &BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}}
+			afero.WriteFile(b.fs.Fs, "hello.proto", []byte(testCompatProto), 0644)
+			def := parseTestProto(t, tt.new)
+			err := b.checkProtoChanges("hello.proto", def)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BaseGenerator.checkProtoChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, e := range def.Elements {
+				if m, ok := e.(*proto.Message); ok && m.Name == "FooRequest" {
+					if got := messageLines(m)[:len(tt.want)]; !reflect.DeepEqual(got, tt.want) {
+						t.Errorf("BaseGenerator.checkProtoChanges() FooRequest = %q, want %q", got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,908 @@
+package generator
+
+import (
+	"fmt"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/viper"
+)
+
+const (
+	timestampProto  = "google.protobuf.Timestamp"
+	timestampImport = "google/protobuf/timestamp.proto"
+)
+
+// basicProtoTypes are the proto scalar types of the go basic types.
+var basicProtoTypes = map[types.BasicKind]string{
+	types.Bool:    "bool",
+	types.String:  "string",
+	types.Int:     "int64",
+	types.Int8:    "int32",
+	types.Int16:   "int32",
+	types.Int32:   "int32",
+	types.Int64:   "int64",
+	types.Uint:    "uint64",
+	types.Uint8:   "uint32",
+	types.Uint16:  "uint32",
+	types.Uint32:  "uint32",
+	types.Uint64:  "uint64",
+	types.Float32: "float",
+	types.Float64: "double",
+}
+
+// pbGoTypes are the go types protoc generates for the proto scalar types.
+var pbGoTypes = map[string]string{
+	"bool":   "bool",
+	"string": "string",
+	"int32":  "int32",
+	"int64":  "int64",
+	"uint32": "uint32",
+	"uint64": "uint64",
+	"float":  "float32",
+	"double": "float64",
+}
+
+// pbField is a field of a proto message built from a go parameter or a struct
+// field.
+type pbField struct {
+	// name is the name of the proto field, goName the name of the go field
+	// and pbName the name of the field protoc generates.
+	name, goName, pbName string
+	typ                  types.Type
+	// protoType is the proto type of the field or of its elements, key the
+	// type of the keys of a map.
+	protoType, key string
+	repeated       bool
+	// stream is true for the field of the channel of a streaming method, typ
+	// is then the type of its elements. Every message of the stream but the
+	// first one carries an element, the converters leave it out.
+	stream bool
+	// embedded are the embedded structs that promote the struct field,
+	// outermost first.
+	embedded []*types.Var
+}
+
+// pbMessages builds the proto messages of the requests and the replies of the
+// service methods from the go types of their parameters and results, user
+// structs get a message of their own. It also generates the converters
+// between the endpoint structs and the pb types.
+type pbMessages struct {
+	pbImport       string
+	endpointImport string
+	// ok is false if the service package could not be type checked, the
+	// messages are then left empty.
+	ok       bool
+	requests map[string][]pbField
+	replies  map[string][]pbField
+	structs  []*types.TypeName
+	fields   map[*types.TypeName][]pbField
+	names    map[*types.TypeName]string
+	// nested are the proto names of the structs declared as nested messages
+	// in the proto file.
+	nested    map[*types.TypeName]string
+	pointers  map[*types.TypeName]bool
+	timestamp bool
+	errors    bool
+}
+
+// newPbMessages maps the methods of the service `name` to proto messages.
+func newPbMessages(b *BaseGenerator, name string, methods []parser.Method) (*pbMessages, error) {
+	serviceImport, err := utils.GetServiceImportPath(name)
+	if err != nil {
+		return nil, err
+	}
+	pbImport, err := utils.GetPbImportPath(name)
+	if err != nil {
+		return nil, err
+	}
+	endpointImport, err := utils.GetEndpointImportPath(name)
+	if err != nil {
+		return nil, err
+	}
+	p := newPbMessagesOf(pbImport, endpointImport)
+	sigs, ok := b.resolver().interfaceMethods(serviceImport, utils.ToCamelCase(name+"Service"))
+	if !ok {
+		logrus.Warnf("Could not type check the service `%s`, the gRPC messages and their converters are not generated.", name)
+		return p, nil
+	}
+	p.addMethods(sigs, methods)
+	return p, nil
+}
+
+func newPbMessagesOf(pbImport, endpointImport string) *pbMessages {
+	return &pbMessages{
+		pbImport:       pbImport,
+		endpointImport: endpointImport,
+		requests:       map[string][]pbField{},
+		replies:        map[string][]pbField{},
+		fields:         map[*types.TypeName][]pbField{},
+		names:          map[*types.TypeName]string{},
+		nested:         map[*types.TypeName]string{},
+		pointers:       map[*types.TypeName]bool{},
+	}
+}
+
+// addMethods maps the parameters and the results of the methods with their
+// signatures `sigs`.
+func (p *pbMessages) addMethods(sigs map[string]*types.Signature, methods []parser.Method) {
+	p.ok = true
+	for _, m := range methods {
+		sig, ok := sigs[m.Name]
+		if !ok {
+			continue
+		}
+		p.requests[m.Name] = p.tupleFields(m.Name, sig.Params())
+		p.replies[m.Name] = p.tupleFields(m.Name, sig.Results())
+	}
+}
+
+// tupleFields returns the fields of the parameters or the results of the
+// method `method`, the context is not sent and the first channel streams its
+// elements (see streamChans).
+func (p *pbMessages) tupleFields(method string, t *types.Tuple) []pbField {
+	fields := []pbField{}
+	streamed := false
+	for i := 0; i < t.Len(); i++ {
+		v := t.At(i)
+		if isNamed(v.Type(), "context", "Context") {
+			continue
+		}
+		if v.Name() == "" {
+			logrus.Warnf("A `%s` of `%s` has no name, it is left out of the gRPC messages.", v.Type(), method)
+			continue
+		}
+		typ := v.Type()
+		ch, isChan := typ.Underlying().(*types.Chan)
+		if isChan && !streamed && ch.Dir() != types.SendOnly {
+			streamed = true
+			typ = ch.Elem()
+		} else {
+			isChan = false
+		}
+		f, err := p.field(utils.ToCamelCase(v.Name()), typ)
+		if err != nil {
+			logrus.Warnf("`%s` of `%s` is left out of the gRPC messages: %s", v.Name(), method, err)
+			continue
+		}
+		f.stream = isChan
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// field returns the proto field of the go field `goName` of type `t`.
+func (p *pbMessages) field(goName string, t types.Type) (pbField, error) {
+	name := utils.ToLowerSnakeCase(goName)
+	f := pbField{name: name, goName: goName, pbName: pbGoName(name), typ: t}
+	var err error
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		if isBytes(u) {
+			f.protoType = "bytes"
+			return f, nil
+		}
+		f.repeated = true
+		f.protoType, err = p.elemType(u.Elem())
+	case *types.Map:
+		b, ok := u.Key().Underlying().(*types.Basic)
+		if !ok || basicProtoTypes[b.Kind()] == "" || b.Info()&types.IsFloat != 0 {
+			return f, fmt.Errorf("the map keys of `%s` are not integers or strings", t)
+		}
+		f.key = basicProtoTypes[b.Kind()]
+		f.protoType, err = p.elemType(u.Elem())
+	default:
+		f.protoType, err = p.elemType(t)
+	}
+	return f, err
+}
+
+// elemType returns the proto type of a field or of the elements of a repeated
+// field or a map, the user structs are added to the messages.
+func (p *pbMessages) elemType(t types.Type) (string, error) {
+	if isError(t) {
+		p.errors = true
+		return "string", nil
+	}
+	if isNamed(t, "time", "Time") {
+		p.timestamp = true
+		return timestampProto, nil
+	}
+	if pt, ok := t.(*types.Pointer); ok {
+		if tn, ok := structType(pt.Elem()); ok {
+			p.pointers[tn] = true
+			return p.message(tn)
+		}
+		return "", fmt.Errorf("the pointer type `%s` is not supported", t)
+	}
+	if tn, ok := structType(t); ok {
+		return p.message(tn)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if pt, ok := basicProtoTypes[u.Kind()]; ok {
+			return pt, nil
+		}
+	case *types.Slice:
+		if isBytes(u) {
+			return "bytes", nil
+		}
+	}
+	return "", fmt.Errorf("the type `%s` is not supported", t)
+}
+
+// message returns the name of the message of the user struct `tn`.
+func (p *pbMessages) message(tn *types.TypeName) (string, error) {
+	if n, ok := p.names[tn]; ok {
+		return n, nil
+	}
+	n := tn.Name()
+	for o, on := range p.names {
+		if on == n && o != tn {
+			n = utils.ToCamelCase(tn.Pkg().Name()) + n
+		}
+	}
+	p.names[tn] = n
+	p.structs = append(p.structs, tn)
+	p.fields[tn] = promotedFields(p.structFields(n, tn.Type().Underlying().(*types.Struct), nil))
+	return n, nil
+}
+
+// structFields returns the fields of the struct `st` of the message `n` that
+// encoding/json encodes, the fields of the embedded structs without a json
+// name are promoted as encoding/json does.
+func (p *pbMessages) structFields(n string, st *types.Struct, embedded []*types.Var) []pbField {
+	fields := []pbField{}
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		pf := parser.Field{Tag: strconv.Quote(st.Tag(i))}
+		if !v.Embedded() {
+			pf.Name = v.Name()
+		}
+		jsonName, ok := pf.JSONName()
+		if !ok || !v.Exported() {
+			continue
+		}
+		if v.Embedded() && jsonName == "" {
+			if es, ok := v.Type().Underlying().(*types.Struct); ok {
+				fields = append(fields, p.structFields(n, es, append(embedded[:len(embedded):len(embedded)], v))...)
+				continue
+			}
+			if _, ok := v.Type().(*types.Pointer); ok {
+				logrus.Warnf("`%s` is embedded by pointer, its fields are left out of the gRPC message `%s`.", v.Name(), n)
+				continue
+			}
+		}
+		f, err := p.field(v.Name(), v.Type())
+		if err != nil {
+			logrus.Warnf("`%s` is left out of the gRPC message `%s`: %s", v.Name(), n, err)
+			continue
+		}
+		f.embedded = embedded
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// promotedFields returns the fields without the promoted fields that go hides:
+// the field the least embedded wins, the ones embedded as deep are ambiguous.
+func promotedFields(fields []pbField) []pbField {
+	depth := map[string]int{}
+	count := map[string]int{}
+	for _, f := range fields {
+		d, ok := depth[f.name]
+		switch {
+		case !ok || len(f.embedded) < d:
+			depth[f.name] = len(f.embedded)
+			count[f.name] = 1
+		case len(f.embedded) == d:
+			count[f.name]++
+		}
+	}
+	s := []pbField{}
+	for _, f := range fields {
+		if len(f.embedded) == depth[f.name] && count[f.name] == 1 {
+			s = append(s, f)
+		}
+	}
+	return s
+}
+
+// protoMessage returns the proto message `name` with the fields.
+func protoMessage(name string, fields []pbField) *proto.Message {
+	m := &proto.Message{Name: name}
+	for i, f := range fields {
+		pf := &proto.Field{Name: f.name, Type: f.protoType, Sequence: i + 1}
+		if f.key != "" {
+			m.Elements = append(m.Elements, &proto.MapField{Field: pf, KeyType: f.key})
+			continue
+		}
+		m.Elements = append(m.Elements, &proto.NormalField{Field: pf, Repeated: f.repeated})
+	}
+	return m
+}
+
+// protoField returns the field as the proto file declares it, without its
+// number.
+func (f pbField) protoField() protoField {
+	if f.key != "" {
+		return protoField{name: f.name, typ: fmt.Sprintf("map<%s, %s>", f.key, f.protoType)}
+	}
+	if f.repeated {
+		return protoField{name: f.name, typ: f.protoType, label: "repeated"}
+	}
+	return protoField{name: f.name, typ: f.protoType}
+}
+
+// mergeMessage adds the fields of the message `msg` that the existing message
+// `old` does not have, they are numbered after the highest number of the
+// message (its reserved numbers included) and the existing fields keep their
+// numbers. The reserved names are not added again.
+func mergeMessage(old, msg *proto.Message) {
+	def := protoMessageDefs("", []proto.Visitee{old}, map[string]protoMessageDef{})[old.Name]
+	last := 0
+	for _, f := range def.fields {
+		if f.number > last {
+			last = f.number
+		}
+	}
+	for _, r := range def.reservedNums {
+		if !r.Max && r.To > last {
+			last = r.To
+		}
+		if r.From > last {
+			last = r.From
+		}
+	}
+	for _, e := range msg.Elements {
+		var f *proto.Field
+		switch v := e.(type) {
+		case *proto.NormalField:
+			f = v.Field
+		case *proto.MapField:
+			f = v.Field
+		default:
+			continue
+		}
+		if _, ok := def.fieldByName(f.Name); ok || def.reservedNames[f.Name] {
+			continue
+		}
+		last++
+		f.Sequence = last
+		old.Elements = append(old.Elements, e)
+	}
+}
+
+// useProto leaves out of the converters the fields that the proto file of the
+// service `name` does not declare (or declares with another type): the pb
+// types are generated from the proto file, not from the go types.
+func (p *pbMessages) useProto(b *BaseGenerator, name string) error {
+	pbFilePath := path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), utils.ToLowerSnakeCase2(name)),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), utils.ToLowerSnakeCase2(name)),
+	)
+	if ok, err := b.fs.Exists(pbFilePath); err != nil || !ok {
+		return err
+	}
+	src, err := b.fs.ReadFile(pbFilePath)
+	if err != nil {
+		return err
+	}
+	def, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		return fmt.Errorf("could not parse `%s`: %s", pbFilePath, err)
+	}
+	defs := protoMessageDefs("", def.Elements, map[string]protoMessageDef{})
+	p.useNested(defs)
+	p.restrict(defs)
+	return nil
+}
+
+// useNested gives the structs named after a nested message of the proto file
+// (e.x `Outer_Inner` for `Outer.Inner`, as the services generated from a proto
+// file name them) the name of this message.
+func (p *pbMessages) useNested(defs map[string]protoMessageDef) {
+	names := map[string]string{}
+	for _, tn := range p.structs {
+		n := p.names[tn]
+		if _, ok := defs[n]; ok {
+			continue
+		}
+		for k := range defs {
+			if strings.Contains(k, ".") && protoGoName(k) == n {
+				p.nested[tn] = k
+				names[n] = k
+			}
+		}
+	}
+	rename := func(fields []pbField) {
+		for i, f := range fields {
+			if k, ok := names[f.protoType]; ok {
+				fields[i].protoType = k
+			}
+		}
+	}
+	for _, fields := range p.requests {
+		rename(fields)
+	}
+	for _, fields := range p.replies {
+		rename(fields)
+	}
+	for _, fields := range p.fields {
+		rename(fields)
+	}
+}
+
+// protoService returns the full name of the proto service of the service
+// `name`. The package of the proto file is `pb` unless the file declares
+// another one, as the files copied by `--from-proto` can.
+func (b *BaseGenerator) protoService(name string) string {
+	pkg := "pb"
+	pbFilePath := path.Join(
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), utils.ToLowerSnakeCase2(name)),
+		fmt.Sprintf(viper.GetString("gk_grpc_pb_file_name"), utils.ToLowerSnakeCase2(name)),
+	)
+	if src, err := b.fs.ReadFile(pbFilePath); err == nil {
+		if def, err := proto.NewParser(strings.NewReader(src)).Parse(); err == nil {
+			for _, e := range def.Elements {
+				if pk, ok := e.(*proto.Package); ok {
+					pkg = pk.Name
+				}
+			}
+		}
+	}
+	return pkg + "." + utils.ToCamelCase(name)
+}
+
+// restrict keeps the fields that the messages `defs` declare with their type.
+func (p *pbMessages) restrict(defs map[string]protoMessageDef) {
+	declared := func(msg string, fields []pbField) []pbField {
+		def := defs[msg]
+		s := []pbField{}
+		for _, f := range fields {
+			pf, ok := def.fieldByName(f.name)
+			if ok && pf.typ == f.protoField().typ && pf.label == f.protoField().label {
+				s = append(s, f)
+				continue
+			}
+			logrus.Warnf("The field `%s` of the message `%s` does not match the proto file, it is not converted.", f.name, msg)
+		}
+		return s
+	}
+	for m, fields := range p.requests {
+		p.requests[m] = declared(m+"Request", fields)
+	}
+	for m, fields := range p.replies {
+		p.replies[m] = declared(m+"Reply", fields)
+	}
+	for _, tn := range p.structs {
+		n := p.names[tn]
+		if k, ok := p.nested[tn]; ok {
+			n = k
+		}
+		p.fields[tn] = declared(n, p.fields[tn])
+	}
+}
+
+// structMessages returns the messages of the user structs, the nested
+// messages of the proto file are left out.
+func (p *pbMessages) structMessages() []*proto.Message {
+	msgs := []*proto.Message{}
+	for _, tn := range p.structs {
+		if _, ok := p.nested[tn]; ok {
+			continue
+		}
+		msgs = append(msgs, protoMessage(p.names[tn], p.fields[tn]))
+	}
+	return msgs
+}
+
+// protoImports returns the proto files the messages import.
+func (p *pbMessages) protoImports() []string {
+	if p.timestamp {
+		return []string{timestampImport}
+	}
+	return nil
+}
+
+// decodeRequest returns the body of the gRPC decoder of the request of `m`.
+func (p *pbMessages) decodeRequest(m string) []jen.Code {
+	return p.convert("r", jen.Op("*").Qual(p.pbImport, m+"Request"), jen.Qual(p.endpointImport, m+"Request"), p.fromPbDict, p.requests[m])
+}
+
+// encodeResponse returns the body of the gRPC encoder of the response of `m`.
+func (p *pbMessages) encodeResponse(m string) []jen.Code {
+	return p.convert("r", jen.Qual(p.endpointImport, m+"Response"), jen.Op("&").Qual(p.pbImport, m+"Reply"), p.toPbDict, p.replies[m])
+}
+
+// encodeRequest returns the body of the gRPC client encoder of the request of
+// `m`.
+func (p *pbMessages) encodeRequest(m string) []jen.Code {
+	return p.convert("request", jen.Qual(p.endpointImport, m+"Request"), jen.Op("&").Qual(p.pbImport, m+"Request"), p.toPbDict, p.requests[m])
+}
+
+// decodeResponse returns the body of the gRPC client decoder of the reply of
+// `m`.
+func (p *pbMessages) decodeResponse(m string) []jen.Code {
+	return p.convert("reply", jen.Op("*").Qual(p.pbImport, m+"Reply"), jen.Qual(p.endpointImport, m+"Response"), p.fromPbDict, p.replies[m])
+}
+
+// convert returns the statements that convert the parameter `param` of type
+// `from` to the composite literal `to` with the fields.
+func (p *pbMessages) convert(param string, from, to *jen.Statement, dict func(string, []pbField) jen.Dict, fields []pbField) []jen.Code {
+	d := dict("v", fields)
+	if len(d) == 0 {
+		return []jen.Code{jen.Return(to.Values(), jen.Nil())}
+	}
+	return []jen.Code{
+		jen.Id("v").Op(":=").Id(param).Assert(from),
+		jen.Return(to.Values(d), jen.Nil()),
+	}
+}
+
+func (p *pbMessages) toPbDict(v string, fields []pbField) jen.Dict {
+	d := jen.Dict{}
+	for _, f := range fields {
+		if f.stream {
+			continue
+		}
+		x := jen.Id(v)
+		for _, e := range f.embedded {
+			x = x.Dot(e.Name())
+		}
+		d[jen.Id(f.pbName)] = p.toPb(x.Dot(f.goName), f.typ)
+	}
+	return d
+}
+
+func (p *pbMessages) fromPbDict(v string, fields []pbField) jen.Dict {
+	d := jen.Dict{}
+	// the promoted fields are set in the literal of their embedded struct.
+	embedded := map[*types.Var][]pbField{}
+	order := []*types.Var{}
+	for _, f := range fields {
+		if f.stream {
+			continue
+		}
+		if len(f.embedded) > 0 {
+			e := f.embedded[0]
+			if _, ok := embedded[e]; !ok {
+				order = append(order, e)
+			}
+			f.embedded = f.embedded[1:]
+			embedded[e] = append(embedded[e], f)
+			continue
+		}
+		d[jen.Id(f.goName)] = p.fromPb(jen.Id(v).Dot(f.pbName), f.typ)
+	}
+	for _, e := range order {
+		d[jen.Id(e.Name())] = goType(e.Type()).Values(p.fromPbDict(v, embedded[e]))
+	}
+	return d
+}
+
+// converters returns the converters of the user structs, the times and the
+// errors used by the encoders and the decoders of the package.
+func (p *pbMessages) converters() []jen.Code {
+	code := []jen.Code{}
+	for _, tn := range p.structs {
+		n := p.names[tn]
+		pbType := jen.Op("*").Qual(p.pbImport, n)
+		code = append(
+			code,
+			jen.Commentf("toPb%s converts %s to its gRPC message.", n, tn.Name()),
+			jen.Func().Id("toPb"+n).Params(jen.Id("in").Op("*").Add(goType(tn.Type()))).Add(pbType).Block(
+				jen.If(jen.Id("in").Op("==").Nil()).Block(jen.Return(jen.Nil())),
+				jen.Return(jen.Op("&").Qual(p.pbImport, n).Values(p.toPbDict("in", p.fields[tn]))),
+			),
+			jen.Line(),
+			jen.Commentf("fromPb%s converts the gRPC message to %s.", n, tn.Name()),
+			jen.Func().Id("fromPb"+n).Params(jen.Id("in").Add(pbType)).Add(goType(tn.Type())).Block(
+				jen.If(jen.Id("in").Op("==").Nil()).Block(jen.Return(goType(tn.Type()).Values())),
+				jen.Return(goType(tn.Type()).Values(p.fromPbDict("in", p.fields[tn]))),
+			),
+			jen.Line(),
+		)
+		if p.pointers[tn] {
+			code = append(
+				code,
+				jen.Commentf("fromPb%sPtr converts the gRPC message to a pointer to %s.", n, tn.Name()),
+				jen.Func().Id("fromPb"+n+"Ptr").Params(jen.Id("in").Add(pbType)).Op("*").Add(goType(tn.Type())).Block(
+					jen.If(jen.Id("in").Op("==").Nil()).Block(jen.Return(jen.Nil())),
+					jen.Id("out").Op(":=").Id("fromPb"+n).Call(jen.Id("in")),
+					jen.Return(jen.Op("&").Id("out")),
+				),
+				jen.Line(),
+			)
+		}
+	}
+	if p.timestamp {
+		ts := jen.Op("*").Qual("github.com/golang/protobuf/ptypes/timestamp", "Timestamp")
+		code = append(
+			code,
+			jen.Comment("toPbTime converts a time to a timestamp, the zero time and the times"),
+			jen.Comment("a timestamp can not hold are sent as a null timestamp."),
+			jen.Func().Id("toPbTime").Params(jen.Id("t").Qual("time", "Time")).Add(ts).Block(
+				jen.If(jen.Id("t").Dot("IsZero").Call()).Block(jen.Return(jen.Nil())),
+				jen.List(jen.Id("ts"), jen.Id("_")).Op(":=").Qual("github.com/golang/protobuf/ptypes", "TimestampProto").Call(jen.Id("t")),
+				jen.Return(jen.Id("ts")),
+			),
+			jen.Line(),
+			jen.Comment("fromPbTime converts a timestamp to a time, a null timestamp is the zero time."),
+			jen.Func().Id("fromPbTime").Params(jen.Id("ts").Add(ts)).Qual("time", "Time").Block(
+				jen.If(jen.Id("ts").Op("==").Nil()).Block(jen.Return(jen.Qual("time", "Time").Values())),
+				jen.List(jen.Id("t"), jen.Id("_")).Op(":=").Qual("github.com/golang/protobuf/ptypes", "Timestamp").Call(jen.Id("ts")),
+				jen.Return(jen.Id("t")),
+			),
+			jen.Line(),
+		)
+	}
+	if p.errors {
+		code = append(
+			code,
+			jen.Comment("errToString converts an error to its message, a nil error is an empty message."),
+			jen.Func().Id("errToString").Params(jen.Err().Error()).String().Block(
+				jen.If(jen.Err().Op("==").Nil()).Block(jen.Return(jen.Lit(""))),
+				jen.Return(jen.Err().Dot("Error").Call()),
+			),
+			jen.Line(),
+			jen.Comment("errFromString converts an error message to an error, an empty message is a nil error."),
+			jen.Func().Id("errFromString").Params(jen.Id("s").String()).Error().Block(
+				jen.If(jen.Id("s").Op("==").Lit("")).Block(jen.Return(jen.Nil())),
+				jen.Return(jen.Qual("errors", "New").Call(jen.Id("s"))),
+			),
+			jen.Line(),
+		)
+	}
+	return code
+}
+
+// writeConverters writes the converters of the package in `destPath` as the
+// generator `generator`, the file is generated again on every run.
+func writeConverters(kfs *fs.KitFs, generator, destPath string, pm *pbMessages) error {
+	filePath := path.Join(destPath, viper.GetString("gk_grpc_convert_file_name"))
+	code := pm.converters()
+	if len(code) == 0 {
+		if b, err := kfs.Exists(filePath); err != nil || !b {
+			return err
+		}
+	}
+	f := jen.NewFilePath(destPath)
+	f.PackageComment("THIS FILE IS AUTO GENERATED BY GK-CLI DO NOT EDIT!!")
+	for _, c := range code {
+		f.Add(c)
+	}
+	return kfs.WriteFileAs(generator, filePath, f.GoString(), true)
+}
+
+// toPb returns the conversion of the go value `x` of type `t` to its pb type.
+func (p *pbMessages) toPb(x *jen.Statement, t types.Type) jen.Code {
+	if pbIdentical(t) {
+		return x
+	}
+	switch {
+	case isError(t):
+		return jen.Id("errToString").Call(x)
+	case isNamed(t, "time", "Time"):
+		return jen.Id("toPbTime").Call(x)
+	}
+	if pt, ok := t.(*types.Pointer); ok {
+		tn, _ := structType(pt.Elem())
+		return jen.Id("toPb" + p.names[tn]).Call(x)
+	}
+	if tn, ok := structType(t); ok {
+		return jen.Id("toPb" + p.names[tn]).Call(jen.Op("&").Add(x))
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return jen.Id(pbGoTypes[basicProtoTypes[u.Kind()]]).Call(x)
+	case *types.Slice:
+		if isBytes(u) {
+			return jen.Index().Byte().Call(x)
+		}
+		if pbIdentical(u) {
+			return jen.Add(p.pbType(u)).Call(x)
+		}
+		return convertSlice(x, p.pbType(u.Elem()), p.toPb(jen.Id("e"), u.Elem()))
+	case *types.Map:
+		if pbIdentical(u) {
+			return jen.Add(p.pbType(u)).Call(x)
+		}
+		return convertMap(x, p.pbType(u.Key()), p.pbType(u.Elem()), p.toPb(jen.Id("k"), u.Key()), p.toPb(jen.Id("e"), u.Elem()))
+	}
+	return x
+}
+
+// fromPb returns the conversion of the pb value `x` to the go type `t`.
+func (p *pbMessages) fromPb(x *jen.Statement, t types.Type) jen.Code {
+	if pbIdentical(t) {
+		return x
+	}
+	switch {
+	case isError(t):
+		return jen.Id("errFromString").Call(x)
+	case isNamed(t, "time", "Time"):
+		return jen.Id("fromPbTime").Call(x)
+	}
+	if pt, ok := t.(*types.Pointer); ok {
+		tn, _ := structType(pt.Elem())
+		return jen.Id("fromPb" + p.names[tn] + "Ptr").Call(x)
+	}
+	if tn, ok := structType(t); ok {
+		return jen.Id("fromPb" + p.names[tn]).Call(x)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		if !isBytes(u) && !pbIdentical(u) {
+			return convertSlice(x, goType(u.Elem()), p.fromPb(jen.Id("e"), u.Elem()), goType(t))
+		}
+	case *types.Map:
+		if !pbIdentical(u) {
+			return convertMap(x, goType(u.Key()), goType(u.Elem()), p.fromPb(jen.Id("k"), u.Key()), p.fromPb(jen.Id("e"), u.Elem()), goType(t))
+		}
+	}
+	return goType(t).Call(x)
+}
+
+// pbType returns the go type protoc generates for the go type `t`.
+func (p *pbMessages) pbType(t types.Type) jen.Code {
+	switch {
+	case isError(t):
+		return jen.String()
+	case isNamed(t, "time", "Time"):
+		return jen.Op("*").Qual("github.com/golang/protobuf/ptypes/timestamp", "Timestamp")
+	}
+	if pt, ok := t.(*types.Pointer); ok {
+		t = pt.Elem()
+	}
+	if tn, ok := structType(t); ok {
+		return jen.Op("*").Qual(p.pbImport, p.names[tn])
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return jen.Id(pbGoTypes[basicProtoTypes[u.Kind()]])
+	case *types.Slice:
+		if isBytes(u) {
+			return jen.Index().Byte()
+		}
+		return jen.Index().Add(p.pbType(u.Elem()))
+	case *types.Map:
+		return jen.Map(p.pbType(u.Key())).Add(p.pbType(u.Elem()))
+	}
+	return jen.Interface()
+}
+
+// convertSlice returns a function literal that converts the elements `e` of
+// the slice `x` with `conv`, `typ` is the type of the slice if it is not a
+// slice of `elem`.
+func convertSlice(x *jen.Statement, elem, conv jen.Code, typ ...jen.Code) jen.Code {
+	sl := jen.Index().Add(elem)
+	if len(typ) > 0 {
+		sl = jen.Add(typ[0])
+	}
+	return jen.Func().Params().Add(sl).Block(
+		jen.If(x.Clone().Op("==").Nil()).Block(jen.Return(jen.Nil())),
+		jen.Id("out").Op(":=").Make(sl, jen.Len(x.Clone())),
+		jen.For(jen.List(jen.Id("i"), jen.Id("e")).Op(":=").Range().Add(x.Clone())).Block(
+			jen.Id("out").Index(jen.Id("i")).Op("=").Add(conv),
+		),
+		jen.Return(jen.Id("out")),
+	).Call()
+}
+
+// convertMap returns a function literal that converts the keys `k` and the
+// values `e` of the map `x`.
+func convertMap(x *jen.Statement, key, elem, convKey, conv jen.Code, typ ...jen.Code) jen.Code {
+	mp := jen.Map(key).Add(elem)
+	if len(typ) > 0 {
+		mp = jen.Add(typ[0])
+	}
+	return jen.Func().Params().Add(mp).Block(
+		jen.If(x.Clone().Op("==").Nil()).Block(jen.Return(jen.Nil())),
+		jen.Id("out").Op(":=").Make(mp, jen.Len(x.Clone())),
+		jen.For(jen.List(jen.Id("k"), jen.Id("e")).Op(":=").Range().Add(x.Clone())).Block(
+			jen.Id("out").Index(convKey).Op("=").Add(conv),
+		),
+		jen.Return(jen.Id("out")),
+	).Call()
+}
+
+// pbIdentical returns true if the go type `t` is the type protoc generates for
+// it, its values are used as they are.
+func pbIdentical(t types.Type) bool {
+	switch u := t.(type) {
+	case *types.Basic:
+		return pbGoTypes[basicProtoTypes[u.Kind()]] == u.Name()
+	case *types.Slice:
+		return isBytes(u) && isBasic(u.Elem()) || !isBytes(u) && pbIdentical(u.Elem())
+	case *types.Map:
+		return pbIdentical(u.Key()) && pbIdentical(u.Elem())
+	}
+	return false
+}
+
+// goType returns the go code of the type `t`.
+func goType(t types.Type) *jen.Statement {
+	switch u := t.(type) {
+	case *types.Named:
+		if u.Obj().Pkg() == nil {
+			return jen.Id(u.Obj().Name())
+		}
+		return jen.Qual(u.Obj().Pkg().Path(), u.Obj().Name())
+	case *types.Pointer:
+		return jen.Op("*").Add(goType(u.Elem()))
+	case *types.Slice:
+		return jen.Index().Add(goType(u.Elem()))
+	case *types.Map:
+		return jen.Map(goType(u.Key())).Add(goType(u.Elem()))
+	}
+	return jen.Id(t.String())
+}
+
+// pbGoName returns the name protoc gives to the go field of the proto field
+// `name` e.x `UserId` for `user_id` (see CamelCase in protoc-gen-go).
+func pbGoName(name string) string {
+	isLower := func(c byte) bool { return 'a' <= c && c <= 'z' }
+	s := []byte{}
+	i := 0
+	if name != "" && name[0] == '_' {
+		s = append(s, 'X')
+		i++
+	}
+	for ; i < len(name); i++ {
+		c := name[i]
+		if c == '_' && i+1 < len(name) && isLower(name[i+1]) {
+			continue
+		}
+		if '0' <= c && c <= '9' {
+			s = append(s, c)
+			continue
+		}
+		if isLower(c) {
+			c -= 'a' - 'A'
+		}
+		s = append(s, c)
+		for i+1 < len(name) && isLower(name[i+1]) {
+			i++
+			s = append(s, name[i])
+		}
+	}
+	return string(s)
+}
+
+func isNamed(t types.Type, pkg, name string) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == pkg && n.Obj().Name() == name
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+func isBasic(t types.Type) bool {
+	_, ok := t.(*types.Basic)
+	return ok
+}
+
+func isBytes(s *types.Slice) bool {
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// structType returns the user struct type of `t`.
+func structType(t types.Type) (*types.TypeName, bool) {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	_, ok = n.Underlying().(*types.Struct)
+	return n.Obj(), ok
+}
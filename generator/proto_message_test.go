@@ -0,0 +1,554 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	ps "go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/parser"
+)
+
+func Test_pbGoName(t *testing.T) {
+	tests := map[string]string{
+		"id":        "Id",
+		"user_id":   "UserId",
+		"_hidden":   "XHidden",
+		"a_1":       "A_1",
+		"x2y":       "X2Y",
+		"http_code": "HttpCode",
+	}
+	for name, want := range tests {
+		if got := pbGoName(name); got != want {
+			t.Errorf("pbGoName(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func newTestPbMessages(t *testing.T) *pbMessages {
+	r := newTestResolver(map[string]string{
+		"pkg/service/service.go": `package service
+
+import (
+	"context"
+	"time"
+)
+
+type Status int
+
+type Address struct {
+	Street string
+	Zip    int
+}
+
+type User struct {
+	ID       string
+	Home     *Address
+	Work     Address
+	Previous []Address
+	Created  time.Time
+	Avatar   []byte
+	Status   Status
+	Ignored  string ` + "`json:\"-\"`" + `
+	private  string
+	Notify   chan bool
+}
+
+type HelloService interface {
+	Save(ctx context.Context, u User, ids []int, tags map[string]Status) (saved *User, err error)
+	List(ctx context.Context, limit int64) (users []*User, at time.Time)
+	// the results without a name are not sent.
+	Ping(ctx context.Context) error
+}
+`,
+	})
+	sigs, ok := r.interfaceMethods("example.com/p/pkg/service", "HelloService")
+	if !ok {
+		t.Fatal("typeResolver.interfaceMethods() did not find HelloService")
+	}
+	p := newPbMessagesOf("example.com/p/pkg/pb", "example.com/p/pkg/endpoint")
+	p.addMethods(sigs, []parser.Method{{Name: "Save"}, {Name: "List"}, {Name: "Ping"}})
+	return p
+}
+
+// funcDecl returns the function `name` of the go source.
+func funcDecl(t *testing.T, src, name string) *ast.FuncDecl {
+	f, err := ps.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatalf("the generated code does not parse: %v\n%s", err, src)
+	}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Name.Name == name {
+			return fd
+		}
+	}
+	t.Fatalf("the function `%s` is not generated:\n%s", name, src)
+	return nil
+}
+
+// returnedFields returns the fields of the composite literal the function
+// `name` of the go source returns last, by key. The values are printed on one
+// line, function literals as `(func() T literal)`.
+func returnedFields(t *testing.T, src, name string) map[string]string {
+	var lit *ast.CompositeLit
+	ast.Inspect(funcDecl(t, src, name).Body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if r, ok := n.(*ast.ReturnStmt); ok && len(r.Results) > 0 {
+			e := r.Results[0]
+			if u, ok := e.(*ast.UnaryExpr); ok {
+				e = u.X
+			}
+			if c, ok := e.(*ast.CompositeLit); ok {
+				lit = c
+			}
+		}
+		return true
+	})
+	if lit == nil {
+		t.Fatalf("`%s` does not return a composite literal:\n%s", name, src)
+	}
+	fields := map[string]string{}
+	for _, e := range lit.Elts {
+		kv := e.(*ast.KeyValueExpr)
+		fields[types.ExprString(kv.Key)] = exprString(kv.Value)
+	}
+	return fields
+}
+
+// exprString prints the expression on one line as types.ExprString does, the
+// fields of composite literals included.
+func exprString(e ast.Expr) string {
+	switch v := e.(type) {
+	case *ast.UnaryExpr:
+		return v.Op.String() + exprString(v.X)
+	case *ast.CallExpr:
+		args := []string{}
+		for _, a := range v.Args {
+			args = append(args, exprString(a))
+		}
+		if v.Ellipsis.IsValid() {
+			return exprString(v.Fun) + "(" + strings.Join(args, ", ") + "...)"
+		}
+		return exprString(v.Fun) + "(" + strings.Join(args, ", ") + ")"
+	case *ast.TypeAssertExpr:
+		if v.Type == nil {
+			return exprString(v.X) + ".(type)"
+		}
+		return exprString(v.X) + ".(" + types.ExprString(v.Type) + ")"
+	}
+	c, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return types.ExprString(e)
+	}
+	elts := []string{}
+	for _, el := range c.Elts {
+		if kv, ok := el.(*ast.KeyValueExpr); ok {
+			elts = append(elts, types.ExprString(kv.Key)+": "+exprString(kv.Value))
+			continue
+		}
+		elts = append(elts, exprString(el))
+	}
+	return types.ExprString(c.Type) + "{" + strings.Join(elts, ", ") + "}"
+}
+
+// loopAssigns returns the assignments of the range loops of the function
+// `name` of the go source, e.x `out[i] = int(e)`.
+func loopAssigns(t *testing.T, src, name string) []string {
+	assigns := []string{}
+	ast.Inspect(funcDecl(t, src, name).Body, func(n ast.Node) bool {
+		r, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		for _, st := range r.Body.List {
+			if as, ok := st.(*ast.AssignStmt); ok {
+				assigns = append(assigns, types.ExprString(as.Lhs[0])+" "+as.Tok.String()+" "+types.ExprString(as.Rhs[0]))
+			}
+		}
+		return true
+	})
+	return assigns
+}
+
+// stmts returns the simple statements of the function `name` of the go source,
+// the ones of its blocks and of its function literals included, each printed
+// on one line (e.x `in := make(chan []byte)` or `case out <- x` as `out <- x`).
+func stmts(t *testing.T, src, name string) []string {
+	list := func(l []ast.Expr) string {
+		s := []string{}
+		for _, e := range l {
+			s = append(s, exprString(e))
+		}
+		return strings.Join(s, ", ")
+	}
+	found := []string{}
+	ast.Inspect(funcDecl(t, src, name).Body, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.AssignStmt:
+			found = append(found, list(v.Lhs)+" "+v.Tok.String()+" "+list(v.Rhs))
+		case *ast.ExprStmt:
+			found = append(found, exprString(v.X))
+		case *ast.SendStmt:
+			found = append(found, exprString(v.Chan)+" <- "+exprString(v.Value))
+		case *ast.DeferStmt:
+			found = append(found, "defer "+exprString(v.Call))
+		case *ast.ReturnStmt:
+			found = append(found, strings.TrimSpace("return "+list(v.Results)))
+		}
+		return true
+	})
+	return found
+}
+
+// fieldLines returns the fields of the message as they are declared.
+func fieldLines(m *proto.Message) []string {
+	lines := []string{}
+	for _, e := range m.Elements {
+		switch f := e.(type) {
+		case *proto.MapField:
+			lines = append(lines, fmt.Sprintf("map<%s, %s> %s = %d", f.KeyType, f.Type, f.Name, f.Sequence))
+		case *proto.NormalField:
+			l := fmt.Sprintf("%s %s = %d", f.Type, f.Name, f.Sequence)
+			if f.Repeated {
+				l = "repeated " + l
+			}
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+func TestPbMessages_messages(t *testing.T) {
+	p := newTestPbMessages(t)
+	tests := []struct {
+		name   string
+		fields []pbField
+		want   []string
+	}{
+		{
+			name:   "SaveRequest",
+			fields: p.requests["Save"],
+			want:   []string{"User u = 1", "repeated int64 ids = 2", "map<string, int64> tags = 3"},
+		},
+		{
+			name:   "SaveReply",
+			fields: p.replies["Save"],
+			want:   []string{"User saved = 1", "string err = 2"},
+		},
+		{
+			name:   "ListReply",
+			fields: p.replies["List"],
+			want:   []string{"repeated User users = 1", "google.protobuf.Timestamp at = 2"},
+		},
+		{
+			name:   "PingReply",
+			fields: p.replies["Ping"],
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := fieldLines(protoMessage(tt.name, tt.fields)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("protoMessage(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	structs := map[string][]string{}
+	for _, m := range p.structMessages() {
+		structs[m.Name] = fieldLines(m)
+	}
+	wantStructs := map[string][]string{
+		"User": {
+			"string id = 1",
+			"Address home = 2",
+			"Address work = 3",
+			"repeated Address previous = 4",
+			"google.protobuf.Timestamp created = 5",
+			"bytes avatar = 6",
+			"int64 status = 7",
+		},
+		"Address": {"string street = 1", "int64 zip = 2"},
+	}
+	if !reflect.DeepEqual(structs, wantStructs) {
+		t.Errorf("pbMessages.structMessages() = %v, want %v", structs, wantStructs)
+	}
+	if got := p.protoImports(); !reflect.DeepEqual(got, []string{timestampImport}) {
+		t.Errorf("pbMessages.protoImports() = %v, want %v", got, []string{timestampImport})
+	}
+}
+
+func TestPbMessages_converters(t *testing.T) {
+	p := newTestPbMessages(t)
+	f := jen.NewFilePath("example.com/p/pkg/grpc")
+	for _, c := range p.converters() {
+		f.Add(c)
+	}
+	for n, body := range map[string][]jen.Code{
+		"decodeSaveRequest":  p.decodeRequest("Save"),
+		"encodeSaveResponse": p.encodeResponse("Save"),
+		"encodeListRequest":  p.encodeRequest("List"),
+		"decodeListResponse": p.decodeResponse("List"),
+		"decodePingRequest":  p.decodeRequest("Ping"),
+	} {
+		f.Func().Id(n).Params(jen.Id("r"), jen.Id("request"), jen.Id("reply").Interface()).Params(jen.Interface(), jen.Error()).Block(body...)
+	}
+	src := f.GoString()
+	// the fields that are not sent (Ignored, private and Notify) are not set.
+	wantFields := map[string]map[string]string{
+		"toPbUser": {
+			"Id":       "in.ID",
+			"Home":     "toPbAddress(in.Home)",
+			"Work":     "toPbAddress(&in.Work)",
+			"Previous": "(func() []*pb.Address literal)()",
+			"Created":  "toPbTime(in.Created)",
+			"Avatar":   "in.Avatar",
+			"Status":   "int64(in.Status)",
+		},
+		"fromPbUser": {
+			"ID":       "in.Id",
+			"Home":     "fromPbAddressPtr(in.Home)",
+			"Work":     "fromPbAddress(in.Work)",
+			"Previous": "(func() []service.Address literal)()",
+			"Created":  "fromPbTime(in.Created)",
+			"Avatar":   "in.Avatar",
+			"Status":   "service.Status(in.Status)",
+		},
+		"toPbAddress":        {"Street": "in.Street", "Zip": "int64(in.Zip)"},
+		"fromPbAddress":      {"Street": "in.Street", "Zip": "int(in.Zip)"},
+		"decodeSaveRequest":  {"U": "fromPbUser(v.U)", "Ids": "(func() []int literal)()", "Tags": "(func() map[string]service.Status literal)()"},
+		"encodeSaveResponse": {"Saved": "toPbUser(v.Saved)", "Err": "errToString(v.Err)"},
+		"encodeListRequest":  {"Limit": "v.Limit"},
+		"decodeListResponse": {"Users": "(func() []*service.User literal)()", "At": "fromPbTime(v.At)"},
+		"decodePingRequest":  {},
+	}
+	for n, want := range wantFields {
+		if got := returnedFields(t, src, n); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s returns %v, want %v", n, got, want)
+		}
+	}
+	wantLoops := map[string][]string{
+		"toPbUser":           {"out[i] = toPbAddress(&e)"},
+		"fromPbUser":         {"out[i] = fromPbAddress(e)"},
+		"decodeSaveRequest":  {"out[i] = int(e)", "out[k] = service.Status(e)"},
+		"decodeListResponse": {"out[i] = fromPbUserPtr(e)"},
+	}
+	for n, want := range wantLoops {
+		if got := loopAssigns(t, src, n); !reflect.DeepEqual(got, want) {
+			t.Errorf("the loops of %s assign %v, want %v", n, got, want)
+		}
+	}
+	// the pointer converter of a struct used by pointer.
+	if body := funcDecl(t, src, "fromPbUserPtr").Body; types.ExprString(body.List[1].(*ast.AssignStmt).Rhs[0]) != "fromPbUser(in)" {
+		t.Errorf("fromPbUserPtr does not convert with fromPbUser:\n%s", src)
+	}
+}
+
+func TestPbMessages_embeddedStructs(t *testing.T) {
+	r := newTestResolver(map[string]string{
+		"pkg/service/service.go": `package service
+
+import "context"
+
+type Base struct {
+	ID   string
+	Kind string
+}
+
+type Audit struct {
+	Kind string
+	By   string
+}
+
+type Name string
+
+type Meta struct {
+	Version int
+}
+
+type User struct {
+	Base
+	Audit
+	*Meta
+	Name
+	Tagged Base ` + "`json:\"tagged\"`" + `
+	By     string
+}
+
+type HelloService interface {
+	Save(ctx context.Context, u User) (err error)
+}
+`,
+	})
+	sigs, ok := r.interfaceMethods("example.com/p/pkg/service", "HelloService")
+	if !ok {
+		t.Fatal("typeResolver.interfaceMethods() did not find HelloService")
+	}
+	p := newPbMessagesOf("example.com/p/pkg/pb", "example.com/p/pkg/endpoint")
+	p.addMethods(sigs, []parser.Method{{Name: "Save"}})
+	structs := map[string][]string{}
+	for _, m := range p.structMessages() {
+		structs[m.Name] = fieldLines(m)
+	}
+	// Kind is ambiguous, By of User hides the one of Audit and the fields of
+	// the embedded pointer are left out.
+	wantStructs := map[string][]string{
+		"User": {"string id = 1", "string name = 2", "Base tagged = 3", "string by = 4"},
+		"Base": {"string id = 1", "string kind = 2"},
+	}
+	if !reflect.DeepEqual(structs, wantStructs) {
+		t.Errorf("pbMessages.structMessages() = %v, want %v", structs, wantStructs)
+	}
+	f := jen.NewFilePath("example.com/p/pkg/grpc")
+	for _, c := range p.converters() {
+		f.Add(c)
+	}
+	// the promoted fields are set in the literal of their embedded struct.
+	src := f.GoString()
+	want := map[string]map[string]string{
+		"toPbUser":   {"Id": "in.Base.ID", "Name": "string(in.Name)", "Tagged": "toPbBase(&in.Tagged)", "By": "in.By"},
+		"fromPbUser": {"Base": "service.Base{ID: in.Id}", "Name": "service.Name(in.Name)", "Tagged": "fromPbBase(in.Tagged)", "By": "in.By"},
+	}
+	for n, w := range want {
+		if got := returnedFields(t, src, n); !reflect.DeepEqual(got, w) {
+			t.Errorf("pbMessages.converters() %s = %v, want %v", n, got, w)
+		}
+	}
+}
+
+func Test_mergeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		want []string
+	}{
+		{
+			name: "empty message",
+			old:  "message User {}",
+			want: []string{"string id = 1", "int64 name = 2", "repeated string tags = 3", "map<string, int64> scores = 4"},
+		},
+		{
+			name: "existing fields keep their numbers",
+			old:  "message User {\n string name = 7;\n string id = 2;\n}",
+			want: []string{"string name = 7", "string id = 2", "repeated string tags = 8", "map<string, int64> scores = 9"},
+		},
+		{
+			name: "reserved numbers and names",
+			old:  "message User {\n reserved 3 to 5;\n reserved \"tags\";\n string id = 1;\n}",
+			want: []string{"string id = 1", "int64 name = 6", "map<string, int64> scores = 7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := protoMessage("User", []pbField{
+				{name: "id", protoType: "string"},
+				{name: "name", protoType: "int64"},
+				{name: "tags", protoType: "string", repeated: true},
+				{name: "scores", protoType: "int64", key: "string"},
+			})
+			old := parseTestProto(t, "syntax = \"proto3\";\n"+tt.old).Elements[1].(*proto.Message)
+			mergeMessage(old, msg)
+			if got := fieldLines(old); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPbMessages_useNested(t *testing.T) {
+	r := newTestResolver(map[string]string{
+		"pkg/service/service.go": `package service
+
+import "context"
+
+type Query_Page struct {
+	Size int32
+}
+
+type HelloService interface {
+	Find(ctx context.Context, page Query_Page, pages []*Query_Page) (n int64, err error)
+}
+`,
+	})
+	sigs, ok := r.interfaceMethods("example.com/p/pkg/service", "HelloService")
+	if !ok {
+		t.Fatal("typeResolver.interfaceMethods() did not find HelloService")
+	}
+	p := newPbMessagesOf("example.com/p/pkg/pb", "example.com/p/pkg/endpoint")
+	p.addMethods(sigs, []parser.Method{{Name: "Find"}})
+	def := parseTestProto(t, `syntax = "proto3";
+message Query {
+ message Page {
+  int32 size = 1;
+ }
+}
+`)
+	p.useNested(protoMessageDefs("", def.Elements, map[string]protoMessageDef{}))
+	if got := fieldLines(protoMessage("FindRequest", p.requests["Find"])); !reflect.DeepEqual(got, []string{"Query.Page page = 1", "repeated Query.Page pages = 2"}) {
+		t.Errorf("the fields of FindRequest = %v, want the nested message used", got)
+	}
+	if got := p.structMessages(); len(got) != 0 {
+		t.Errorf("pbMessages.structMessages() = %v, want the nested message left out", got)
+	}
+	// the converters keep the go name protoc gives the nested message.
+	f := jen.NewFilePath("example.com/p/pkg/grpc")
+	for _, c := range p.converters() {
+		f.Add(c)
+	}
+	src := f.GoString()
+	if got := exprString(funcDecl(t, src, "toPbQuery_Page").Type.Results.List[0].Type); got != "*pb.Query_Page" {
+		t.Errorf("toPbQuery_Page returns %s, want *pb.Query_Page", got)
+	}
+}
+
+func TestPbMessages_restrict(t *testing.T) {
+	p := newTestPbMessages(t)
+	def := parseTestProto(t, `syntax = "proto3";
+message SaveRequest {
+ User u = 1;
+ repeated int64 ids = 2;
+}
+message SaveReply {
+ User saved = 1;
+ string err = 2;
+}
+message User {
+ string id = 1;
+ Address home = 2;
+ string status = 3;
+}
+`)
+	p.restrict(protoMessageDefs("", def.Elements, map[string]protoMessageDef{}))
+	names := func(fields []pbField) []string {
+		s := []string{}
+		for _, f := range fields {
+			s = append(s, f.name)
+		}
+		return s
+	}
+	// the fields the proto file does not declare (or declares with another
+	// type) are not converted, the messages it does not have convert nothing.
+	got := map[string][]string{
+		"SaveRequest": names(p.requests["Save"]),
+		"SaveReply":   names(p.replies["Save"]),
+		"ListReply":   names(p.replies["List"]),
+	}
+	for _, tn := range p.structs {
+		got[p.names[tn]] = names(p.fields[tn])
+	}
+	want := map[string][]string{
+		"SaveRequest": {"u", "ids"},
+		"SaveReply":   {"saved", "err"},
+		"ListReply":   {},
+		"User":        {"id", "home"},
+		"Address":     {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pbMessages.restrict() = %v, want %v", got, want)
+	}
+}
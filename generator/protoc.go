@@ -0,0 +1,459 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/fs"
+	"github.com/spf13/viper"
+)
+
+// ProtocPathModes are the output path modes of the protoc plugins, see the
+// `paths` option of protoc-gen-go. An empty mode does not set the option.
+var ProtocPathModes = []string{"", "import", "source_relative"}
+
+// protocGenerator is the generator recorded in the manifest for the protoc
+// output and the compile script.
+const protocGenerator = "protoc"
+
+// protocPlugin is a protoc plugin, `protoc-gen-<name>` is called with the
+// options `opts` (e.x `go` with `plugins=grpc`).
+type protocPlugin struct {
+	name, opts string
+}
+
+// protocConfig is how the proto files are compiled, it is read from the
+// `gk_protoc*` settings and the proto file options from `gk_proto_options`.
+type protocConfig struct {
+	protoc string
+	// includes are the include paths, relative to the project root unless
+	// they are absolute.
+	includes []string
+	plugins  []protocPlugin
+	paths    string
+	options  map[string]string
+}
+
+// loadProtocConfig reads the protoc settings.
+func loadProtocConfig() (*protocConfig, error) {
+	c := &protocConfig{
+		protoc:   viper.GetString("gk_protoc"),
+		includes: viper.GetStringSlice("gk_protoc_include_paths"),
+		paths:    viper.GetString("gk_protoc_paths"),
+		options:  viper.GetStringMapString("gk_proto_options"),
+	}
+	if c.protoc == "" {
+		return nil, fmt.Errorf("no protoc binary is configured (gk_protoc)")
+	}
+	valid := false
+	for _, m := range ProtocPathModes {
+		valid = valid || m == c.paths
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown protoc path mode `%s` (gk_protoc_paths), use one of %q", c.paths, ProtocPathModes)
+	}
+	for _, p := range viper.GetStringSlice("gk_protoc_plugins") {
+		parts := strings.SplitN(p, ":", 2)
+		pl := protocPlugin{name: strings.TrimPrefix(parts[0], "protoc-gen-")}
+		if len(parts) == 2 {
+			pl.opts = parts[1]
+		}
+		if pl.name == "" {
+			return nil, fmt.Errorf("the protoc plugin `%s` has no name (gk_protoc_plugins)", p)
+		}
+		c.plugins = append(c.plugins, pl)
+	}
+	if len(c.plugins) == 0 {
+		return nil, fmt.Errorf("no protoc plugin is configured (gk_protoc_plugins)")
+	}
+	return c, nil
+}
+
+// loadCheckedProtocConfig reads the protoc settings and checks that the
+// binaries are installed, the proto generators call it before they write
+// anything. The binaries are not checked while testing.
+func loadCheckedProtocConfig() (*protocConfig, error) {
+	c, err := loadProtocConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !viper.GetBool("gk_testing") {
+		if err = c.check(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// CheckProtoc returns an error that lists the protoc binary and the plugins of
+// the protoc settings that are not installed.
+func CheckProtoc() error {
+	c, err := loadProtocConfig()
+	if err != nil {
+		return err
+	}
+	return c.check()
+}
+
+func (c *protocConfig) check() error {
+	missing := []string{}
+	for _, b := range c.binaries() {
+		if _, err := exec.LookPath(b); err != nil {
+			missing = append(missing, b)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s not found in $PATH, install it or change the gk_protoc settings", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// binaries returns protoc and the binaries of the plugins.
+func (c *protocConfig) binaries() []string {
+	b := []string{c.protoc}
+	for _, p := range c.plugins {
+		b = append(b, "protoc-gen-"+p.name)
+	}
+	return b
+}
+
+// args returns the protoc arguments that compile the proto file `file` found
+// in the include paths `includes` to the folder `out`, the plugins that write
+// the files in the folders of their import path write to `importOut`.
+func (c *protocConfig) args(includes []string, file, out, importOut string) []string {
+	args := []string{}
+	for _, i := range includes {
+		args = append(args, "-I", i)
+	}
+	args = append(args, file)
+	for _, p := range c.plugins {
+		opts := p.opts
+		if c.paths != "" && !strings.Contains(opts, "paths=") {
+			if opts != "" {
+				opts += ","
+			}
+			opts += "paths=" + c.paths
+		}
+		o := out
+		if c.pluginPaths(p) != "source_relative" {
+			o = importOut
+		}
+		if opts != "" {
+			opts += ":"
+		}
+		args = append(args, fmt.Sprintf("--%s_out=%s%s", p.name, opts, o))
+	}
+	return args
+}
+
+// pluginPaths returns the path mode of the plugin, its own `paths=` option
+// wins over the settings.
+func (c *protocConfig) pluginPaths(p protocPlugin) string {
+	for _, o := range strings.Split(p.opts, ",") {
+		if strings.HasPrefix(o, "paths=") {
+			return strings.TrimPrefix(o, "paths=")
+		}
+	}
+	return c.paths
+}
+
+// importPaths returns true if a plugin writes the files in the folders of
+// their import path.
+func (c *protocConfig) importPaths() bool {
+	for _, p := range c.plugins {
+		if c.pluginPaths(p) != "source_relative" {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptIncludes returns the include paths of the compile script of the proto
+// files of the folder `dir`, the script runs in that folder.
+func (c *protocConfig) scriptIncludes(dir string) []string {
+	includes := []string{"."}
+	for _, i := range c.includes {
+		if !filepath.IsAbs(i) {
+			rel, err := filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(i))
+			if err == nil {
+				i = filepath.ToSlash(rel)
+			}
+		}
+		includes = append(includes, i)
+	}
+	return includes
+}
+
+// command returns the commands of the compile script of the proto file
+// `pbFilePath` whose go package is `importPath`. Like kit, the script moves the
+// files written in the folders of the import path next to the proto file.
+func (c *protocConfig) command(pbFilePath, importPath string) string {
+	moved := importPath != "" && c.importPaths()
+	importOut := "."
+	if moved {
+		importOut = "$out"
+		if runtime.GOOS == "windows" {
+			importOut = "gk-protoc-out"
+		}
+	}
+	args := c.args(c.scriptIncludes(path.Dir(pbFilePath)), path.Base(pbFilePath), ".", importOut)
+	line := []string{quoteArg(c.protoc)}
+	for _, a := range args {
+		line = append(line, quoteArg(a))
+	}
+	cmd := strings.Join(line, " ")
+	if !moved {
+		return cmd
+	}
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf(
+			"mkdir %[1]s\n%[2]s && xcopy /E /I /Y /Q %[1]s\\%[3]s .\nrmdir /S /Q %[1]s",
+			importOut, cmd, strings.Replace(importPath, "/", "\\", -1),
+		)
+	}
+	return fmt.Sprintf(
+		"out=$(mktemp -d)\ntrap 'rm -rf \"$out\"' EXIT\n%s && cp -R %s .",
+		cmd, quoteArg("$out/"+importPath+"/."),
+	)
+}
+
+// quoteArg quotes the shell argument if it has to be.
+func quoteArg(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"'$&|;<>()*?") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// compileScript returns the compile script of the proto file `pbFilePath` whose
+// source is `src`, it runs the same command as kit in the folder of the proto
+// file.
+func (c *protocConfig) compileScript(pbFilePath, src string) string {
+	plugins := c.binaries()[1:]
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf(`:: THIS FILE IS AUTO GENERATED BY GK-CLI DO NOT EDIT!!
+:: The command follows the gk_protoc settings, run it in this folder.
+::
+:: Install proto3.
+:: https://github.com/google/protobuf/releases
+:: Install the protoc plugins: %s
+::
+:: See also
+::  https://github.com/grpc/grpc-go/tree/master/examples
+
+%s
+`, strings.Join(plugins, ", "), c.command(pbFilePath, goImportPath(src)))
+	}
+	install := `# Install proto3
+# sudo apt-get install -y git autoconf automake libtool curl make g++ unzip
+# git clone https://github.com/google/protobuf.git
+# cd protobuf/
+# ./autogen.sh
+# ./configure
+# make
+# make check
+# sudo make install
+# sudo ldconfig # refresh shared library cache.`
+	if runtime.GOOS == "darwin" {
+		install = `# Install proto3 from source macOS only.
+#  brew install autoconf automake libtool
+#  git clone https://github.com/google/protobuf
+#  ./autogen.sh ; ./configure ; make ; make install`
+	}
+	return fmt.Sprintf(`#!/usr/bin/env sh
+
+# THIS FILE IS AUTO GENERATED BY GK-CLI DO NOT EDIT!!
+# The command follows the gk_protoc settings, run it in this folder.
+#
+%s
+#
+# Install the protoc plugins: %s
+#
+# See also
+#  https://github.com/grpc/grpc-go/tree/master/examples
+
+%s
+`, install, strings.Join(plugins, ", "), c.command(pbFilePath, goImportPath(src)))
+}
+
+// build compiles the proto file `pbFilePath` and writes its compile script
+// `compileFilePath`, the script is generated again when the settings change.
+func (c *protocConfig) build(pbFilePath, compileFilePath string) error {
+	if err := c.compile(pbFilePath); err != nil {
+		return err
+	}
+	src, err := fs.Get().ReadFile(pbFilePath)
+	if err != nil {
+		return err
+	}
+	return fs.Get().WriteFileAs(protocGenerator, compileFilePath, c.compileScript(pbFilePath, src), true)
+}
+
+// compile runs protoc on a copy of the proto file and writes the generated
+// files through the kit filesystem, so they are part of the dry run and of the
+// generation transaction. It runs the command of the compile script and does
+// nothing while testing.
+func (c *protocConfig) compile(pbFilePath string) error {
+	if viper.GetBool("gk_testing") {
+		return nil
+	}
+	if err := c.check(); err != nil {
+		return err
+	}
+	kfs := fs.Get()
+	src, err := kfs.ReadFile(pbFilePath)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir("", "gk-protoc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	in, out := filepath.Join(tmp, "in"), filepath.Join(tmp, "out")
+	for _, d := range []string{in, out} {
+		if err = os.MkdirAll(d, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(in, path.Base(pbFilePath)), []byte(src), 0644); err != nil {
+		return err
+	}
+	root := viper.GetString("gk_folder")
+	if root == "" {
+		root = "."
+	}
+	// the folder of the proto file is still in the include path for the
+	// protos next to it.
+	includes := []string{in, filepath.Join(root, filepath.FromSlash(path.Dir(pbFilePath)))}
+	for _, i := range c.includes {
+		if !filepath.IsAbs(i) {
+			i = filepath.Join(root, filepath.FromSlash(i))
+		}
+		includes = append(includes, i)
+	}
+	importPath := goImportPath(src)
+	cmd := exec.Command(c.protoc, c.args(includes, filepath.Join(in, path.Base(pbFilePath)), out, out)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("protoc failed on `%s`: %s", pbFilePath, err)
+	}
+	return filepath.Walk(out, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		d, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(out, p)
+		if err != nil {
+			return err
+		}
+		if rel, err = outputPath(pbFilePath, filepath.ToSlash(rel), importPath); err != nil {
+			return err
+		}
+		if err = kfs.MkdirAll(path.Dir(rel)); err != nil {
+			return err
+		}
+		return kfs.WriteFileAs(protocGenerator, rel, string(d), true)
+	})
+}
+
+// goImportPath returns the import path of the `go_package` option of the proto
+// source, empty if it has none.
+func goImportPath(src string) string {
+	def, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		return ""
+	}
+	for _, e := range def.Elements {
+		if o, ok := e.(*proto.Option); ok && o.Name == "go_package" {
+			return strings.SplitN(o.Constant.Source, ";", 2)[0]
+		}
+	}
+	return ""
+}
+
+// outputPath returns the path of the file `rel` protoc generated from the
+// proto file `pbFilePath`. Unless the paths are source_relative the plugins
+// put the files in the folders of their import path `importPath`, these files
+// go next to the proto file too and the ones outside of it are an error.
+func outputPath(pbFilePath, rel, importPath string) (string, error) {
+	if importPath != "" && path.Dir(rel) != "." {
+		if !strings.HasPrefix(rel, importPath+"/") {
+			return "", fmt.Errorf("protoc wrote `%s` outside of the import path `%s` of `%s`", rel, importPath, pbFilePath)
+		}
+		rel = strings.TrimPrefix(rel, importPath+"/")
+	}
+	return path.Join(path.Dir(pbFilePath), rel), nil
+}
+
+// applyOptions sets the file options of the settings in the proto file after
+// its imports, the `go_package` option is the import path of the pb package
+// `goPackage` unless it is set.
+func (c *protocConfig) applyOptions(p *proto.Proto, goPackage string) {
+	options := map[string]string{}
+	for k, v := range c.options {
+		options[k] = v
+	}
+	names := []string{}
+	for k := range options {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if _, ok := options["go_package"]; !ok && goPackage != "" {
+		options["go_package"] = goPackage
+		names = append([]string{"go_package"}, names...)
+	}
+	at := 0
+	for i, e := range p.Elements {
+		switch v := e.(type) {
+		case *proto.Syntax, *proto.Package, *proto.Import:
+			at = i + 1
+		case *proto.Option:
+			at = i + 1
+			if val, ok := options[v.Name]; ok {
+				// the default go_package does not replace the one of the file.
+				if _, set := c.options[v.Name]; set {
+					v.Constant = optionLiteral(val)
+				}
+				delete(options, v.Name)
+			}
+		}
+	}
+	added := []proto.Visitee{}
+	for _, n := range names {
+		if val, ok := options[n]; ok {
+			added = append(added, &proto.Option{Name: n, Constant: optionLiteral(val)})
+		}
+	}
+	elements := append([]proto.Visitee{}, p.Elements[:at]...)
+	elements = append(elements, added...)
+	p.Elements = append(elements, p.Elements[at:]...)
+}
+
+// optionLiteral returns the constant of an option value, the values that are
+// not booleans, numbers or enum values are strings.
+func optionLiteral(v string) proto.Literal {
+	if v == "true" || v == "false" {
+		return proto.Literal{Source: v}
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return proto.Literal{Source: v}
+	}
+	if v != "" && strings.ToUpper(v) == v && strings.Trim(v, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") == "" {
+		return proto.Literal{Source: v}
+	}
+	return proto.Literal{Source: v, IsString: true}
+}
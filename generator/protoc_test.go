@@ -0,0 +1,324 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+	"github.com/spf13/viper"
+)
+
+func withProtocSettings(t *testing.T, settings map[string]interface{}) {
+	setDefaults()
+	for k, v := range settings {
+		viper.Set(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range settings {
+			viper.Set(k, nil)
+		}
+	})
+}
+
+func Test_loadProtocConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]interface{}
+		wantPlugins []protocPlugin
+		wantErr     string
+	}{
+		{
+			name:        "defaults",
+			wantPlugins: []protocPlugin{{name: "go", opts: "plugins=grpc"}},
+		},
+		{
+			name: "go-grpc plugin",
+			settings: map[string]interface{}{
+				"gk_protoc_plugins": []string{"go", "protoc-gen-go-grpc:require_unimplemented_servers=false"},
+			},
+			wantPlugins: []protocPlugin{{name: "go"}, {name: "go-grpc", opts: "require_unimplemented_servers=false"}},
+		},
+		{
+			name:     "no plugins",
+			settings: map[string]interface{}{"gk_protoc_plugins": []string{}},
+			wantErr:  "no protoc plugin",
+		},
+		{
+			name:     "plugin without name",
+			settings: map[string]interface{}{"gk_protoc_plugins": []string{":paths=import"}},
+			wantErr:  "has no name",
+		},
+		{
+			name:     "unknown path mode",
+			settings: map[string]interface{}{"gk_protoc_paths": "relative"},
+			wantErr:  "unknown protoc path mode",
+		},
+		{
+			name:     "no protoc",
+			settings: map[string]interface{}{"gk_protoc": ""},
+			wantErr:  "no protoc binary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProtocSettings(t, tt.settings)
+			c, err := loadProtocConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadProtocConfig() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadProtocConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(c.plugins, tt.wantPlugins) {
+				t.Errorf("loadProtocConfig() plugins = %v, want %v", c.plugins, tt.wantPlugins)
+			}
+		})
+	}
+}
+
+func TestProtocConfig_check(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gk-protoc-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, b := range []string{"protoc", "protoc-gen-go"} {
+		if err := os.WriteFile(filepath.Join(dir, b), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	c := &protocConfig{protoc: "protoc", plugins: []protocPlugin{{name: "go"}, {name: "go-grpc"}}}
+	err = c.check()
+	if err == nil || !strings.HasPrefix(err.Error(), "protoc-gen-go-grpc not found") {
+		t.Errorf("protocConfig.check() error = %v, want protoc-gen-go-grpc not found", err)
+	}
+	c.plugins = c.plugins[:1]
+	if err = c.check(); err != nil {
+		t.Errorf("protocConfig.check() error = %v", err)
+	}
+}
+
+func TestProtocConfig_command(t *testing.T) {
+	tests := []struct {
+		name       string
+		c          protocConfig
+		importPath string
+		want       string
+	}{
+		{
+			name: "defaults",
+			c: protocConfig{
+				protoc:   "protoc",
+				includes: []string{"."},
+				plugins:  []protocPlugin{{name: "go", opts: "plugins=grpc"}},
+				paths:    "source_relative",
+			},
+			want: "protoc -I . -I ../../../.. hello.proto --go_out=plugins=grpc,paths=source_relative:.",
+		},
+		{
+			name: "go-grpc without path mode",
+			c: protocConfig{
+				protoc:   "/opt/protoc 3/bin/protoc",
+				includes: []string{"third_party", "/usr/include"},
+				plugins:  []protocPlugin{{name: "go"}, {name: "go-grpc", opts: "paths=import"}},
+			},
+			want: `"/opt/protoc 3/bin/protoc" -I . -I ../../../../third_party -I /usr/include hello.proto --go_out=. --go-grpc_out=paths=import:.`,
+		},
+		{
+			name: "plugin path mode wins",
+			c: protocConfig{
+				protoc:  "protoc",
+				plugins: []protocPlugin{{name: "go-grpc", opts: "paths=import"}},
+				paths:   "source_relative",
+			},
+			want: "protoc -I . hello.proto --go-grpc_out=paths=import:.",
+		},
+		{
+			name: "files moved from the import path",
+			c: protocConfig{
+				protoc:  "protoc",
+				plugins: []protocPlugin{{name: "go"}, {name: "go-grpc", opts: "paths=import"}},
+				paths:   "source_relative",
+			},
+			importPath: "example.com/p/hello/pkg/grpc/pb",
+			want: "out=$(mktemp -d)\n" +
+				"trap 'rm -rf \"$out\"' EXIT\n" +
+				`protoc -I . hello.proto --go_out=paths=source_relative:. "--go-grpc_out=paths=import:$out" && cp -R "$out/example.com/p/hello/pkg/grpc/pb/." .`,
+		},
+		{
+			name: "no file to move",
+			c: protocConfig{
+				protoc:  "protoc",
+				plugins: []protocPlugin{{name: "go"}},
+				paths:   "source_relative",
+			},
+			importPath: "example.com/p/hello/pkg/grpc/pb",
+			want:       "protoc -I . hello.proto --go_out=paths=source_relative:.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.command("hello/pkg/grpc/pb/hello.proto", tt.importPath); got != tt.want {
+				t.Errorf("protocConfig.command() = %v, want %v", got, tt.want)
+			}
+			src := "syntax = \"proto3\";\n"
+			if tt.importPath != "" {
+				src += "option go_package = \"" + tt.importPath + ";pb\";\n"
+			}
+			if got := tt.c.compileScript("hello/pkg/grpc/pb/hello.proto", src); !strings.Contains(got, tt.want+"\n") || !strings.Contains(got, "DO NOT EDIT") {
+				t.Errorf("protocConfig.compileScript() does not run `%s`:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_outputPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		rel        string
+		importPath string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "source relative",
+			rel:        "hello.pb.go",
+			importPath: "example.com/p/hello/pkg/grpc/pb",
+			want:       "hello/pkg/grpc/pb/hello.pb.go",
+		},
+		{
+			name:       "import path",
+			rel:        "example.com/p/hello/pkg/grpc/pb/hello_grpc.pb.go",
+			importPath: "example.com/p/hello/pkg/grpc/pb",
+			want:       "hello/pkg/grpc/pb/hello_grpc.pb.go",
+		},
+		{
+			name:       "other import path",
+			rel:        "example.com/other/pb/hello.pb.go",
+			importPath: "example.com/p/hello/pkg/grpc/pb",
+			wantErr:    true,
+		},
+		{
+			name: "no go package",
+			rel:  "hello.pb.go",
+			want: "hello/pkg/grpc/pb/hello.pb.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outputPath("hello/pkg/grpc/pb/hello.proto", tt.rel, tt.importPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("outputPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("outputPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_goImportPath(t *testing.T) {
+	for src, want := range map[string]string{
+		"syntax = \"proto3\";\noption go_package = \"example.com/p/pb;hellopb\";\n": "example.com/p/pb",
+		"syntax = \"proto3\";\noption go_package = \"example.com/p/pb\";\n":         "example.com/p/pb",
+		"syntax = \"proto3\";\n": "",
+	} {
+		if got := goImportPath(src); got != want {
+			t.Errorf("goImportPath(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestProtocConfig_applyOptions(t *testing.T) {
+	src := `syntax = "proto3";
+
+package pb;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/old/pb";
+option cc_enable_arenas = false;
+
+service Hello {
+}
+`
+	tests := []struct {
+		name    string
+		src     string
+		options map[string]string
+		want    []string
+	}{
+		{
+			name: "new file",
+			options: map[string]string{
+				"java_package": "com.example.hello",
+				"optimize_for": "SPEED",
+			},
+			want: []string{
+				`option go_package = "example.com/p/pkg/grpc/pb";`,
+				`option java_package = "com.example.hello";`,
+				`option optimize_for = SPEED;`,
+			},
+		},
+		{
+			name: "keeps the go package of the file",
+			src:  src,
+			want: []string{
+				`option go_package = "example.com/old/pb";`,
+				`option cc_enable_arenas = false;`,
+			},
+		},
+		{
+			name: "replaces the options of the file",
+			src:  src,
+			options: map[string]string{
+				"go_package":       "example.com/new/pb;hellopb",
+				"cc_enable_arenas": "true",
+			},
+			want: []string{
+				`option go_package = "example.com/new/pb;hellopb";`,
+				`option cc_enable_arenas = true;`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &proto.Proto{Elements: []proto.Visitee{
+				&proto.Syntax{Value: "proto3"},
+				&proto.Package{Name: "pb"},
+				&proto.Service{Name: "Hello"},
+			}}
+			if tt.src != "" {
+				var err error
+				if def, err = proto.NewParser(strings.NewReader(tt.src)).Parse(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := &protocConfig{options: tt.options}
+			c.applyOptions(def, "example.com/p/pkg/grpc/pb")
+			buf := new(bytes.Buffer)
+			protofmt.NewFormatter(buf, " ").Format(def)
+			got := []string{}
+			for _, l := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(l, "option ") {
+					got = append(got, l)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protocConfig.applyOptions() = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(buf.String(), "service Hello") || strings.Index(buf.String(), "option") < strings.Index(buf.String(), "import") || strings.Index(buf.String(), "option") > strings.Index(buf.String(), "service") {
+				t.Errorf("protocConfig.applyOptions() options are not between the imports and the service:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"go/build"
+	ps "go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
+)
+
+// externalNames caches the names of the packages that are not part of the
+// project, they do not change during a run.
+var externalNames = map[string]string{}
+
+// typeResolver resolves the packages of the import paths and the types they
+// declare, the packages of the project are read from the kit filesystem so the
+// files that are not written yet are resolved too.
+type typeResolver struct {
+	fs          *fs.KitFs
+	projectPath string
+	srcDir      string
+	verifier    *packageVerifier
+	names       map[string]string
+}
+
+func newTypeResolver(kfs *fs.KitFs) *typeResolver {
+	projectPath, _ := utils.GetProjectPath()
+	srcDir, _ := utils.GetWorkingDir()
+	return &typeResolver{
+		fs:          kfs,
+		projectPath: projectPath,
+		srcDir:      srcDir,
+		names:       map[string]string{},
+	}
+}
+
+// resolver returns the type resolver of the generator.
+func (b *BaseGenerator) resolver() *typeResolver {
+	if b.res == nil {
+		kfs := b.fs
+		if kfs == nil {
+			kfs = fs.Get()
+		}
+		b.res = newTypeResolver(kfs)
+	}
+	return b.res
+}
+
+// projectDir returns the folder of the project package `importPath`, false if
+// the package is not part of the project.
+func (r *typeResolver) projectDir(importPath string) (string, bool) {
+	if r.projectPath == "" {
+		return "", false
+	}
+	if importPath == r.projectPath {
+		return ".", true
+	}
+	if strings.HasPrefix(importPath, r.projectPath+"/") {
+		return strings.TrimPrefix(importPath, r.projectPath+"/"), true
+	}
+	return "", false
+}
+
+// packageName returns the name of the package `importPath` (e.x `yaml` for
+// `gopkg.in/yaml.v2`), the name is guessed from the path if the package can
+// not be found.
+func (r *typeResolver) packageName(importPath string) string {
+	if n, ok := r.names[importPath]; ok {
+		return n
+	}
+	n := ""
+	if dir, ok := r.projectDir(importPath); ok {
+		n = r.projectPackageName(dir)
+	} else if en, ok := externalNames[importPath]; ok {
+		n = en
+	} else {
+		restore := offlineGo()
+		pkg, err := build.Import(importPath, r.srcDir, 0)
+		restore()
+		if err == nil {
+			n = pkg.Name
+			externalNames[importPath] = n
+		}
+	}
+	if n == "" {
+		n = guessPackageName(importPath)
+		logrus.Debugf("Could not find the package `%s`, its name is guessed `%s`.", importPath, n)
+	}
+	r.names[importPath] = n
+	return n
+}
+
+// projectPackageName returns the name in the package clause of the go files of
+// the project folder `dir`.
+func (r *typeResolver) projectPackageName(dir string) string {
+	infos, err := afero.ReadDir(r.fs.Fs, dir)
+	if err != nil {
+		return ""
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := r.fs.ReadFile(path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if f, err := ps.ParseFile(token.NewFileSet(), "", src, ps.PackageClauseOnly); err == nil {
+			return f.Name.Name
+		}
+	}
+	return ""
+}
+
+// guessPackageName returns the last element of the import path without its
+// major version and the usual `go-` prefix (e.x `yaml` for `gopkg.in/yaml.v2`,
+// `redis` for `github.com/go-redis/redis/v8` and `sqlite3` for
+// `github.com/mattn/go-sqlite3`).
+func guessPackageName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	n := elems[len(elems)-1]
+	if len(elems) > 1 && len(n) > 1 && n[0] == 'v' {
+		if _, err := strconv.Atoi(n[1:]); err == nil {
+			n = elems[len(elems)-2]
+		}
+	}
+	if i := strings.Index(n, ".v"); i > 0 {
+		n = n[:i]
+	}
+	for _, p := range []string{"go-", "go."} {
+		n = strings.TrimPrefix(n, p)
+	}
+	n = strings.TrimSuffix(n, "-go")
+	return strings.NewReplacer("-", "", ".", "").Replace(n)
+}
+
+// resolveImports returns the imports `imp` with the name of the package of the
+// imports that have no alias.
+func (r *typeResolver) resolveImports(imp []parser.NamedTypeValue) []parser.NamedTypeValue {
+	resolved := []parser.NamedTypeValue{}
+	for _, v := range imp {
+		if v.Name == "" {
+			if p, err := strconv.Unquote(v.Type); err == nil {
+				v.Name = r.packageName(p)
+			}
+		}
+		resolved = append(resolved, v)
+	}
+	return resolved
+}
+
+// interfaceMethods returns the signatures of the methods of the interface type
+// `name` declared in the package `importPath`, the embedded interfaces included.
+func (r *typeResolver) interfaceMethods(importPath, name string) (map[string]*types.Signature, bool) {
+	tn, ok := r.lookupType(importPath, name)
+	if !ok {
+		return nil, false
+	}
+	it, ok := tn.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, false
+	}
+	methods := map[string]*types.Signature{}
+	for i := 0; i < it.NumMethods(); i++ {
+		methods[it.Method(i).Name()] = it.Method(i).Type().(*types.Signature)
+	}
+	return methods, true
+}
+
+// lookupType returns the type `name` declared in the package `importPath`.
+func (r *typeResolver) lookupType(importPath, name string) (*types.TypeName, bool) {
+	if r.verifier == nil {
+		r.verifier = newPackageVerifier(r.fs, r.projectPath, r.srcDir)
+	}
+	restore := offlineGo()
+	pkg, err := r.verifier.Import(importPath)
+	restore()
+	if err != nil || pkg == nil {
+		logrus.Debugf("Could not load the package `%s`: %v", importPath, err)
+		return nil, false
+	}
+	tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	return tn, ok
+}
@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/parser"
+	"github.com/spf13/afero"
+)
+
+func Test_guessPackageName(t *testing.T) {
+	tests := map[string]string{
+		"context":                                "context",
+		"example.com/p/pkg/pb":                   "pb",
+		"gopkg.in/yaml.v2":                       "yaml",
+		"github.com/go-redis/redis/v8":           "redis",
+		"github.com/mattn/go-sqlite3":            "sqlite3",
+		"github.com/satori/go.uuid":              "uuid",
+		"github.com/elastic/go-elasticsearch/v8": "elasticsearch",
+	}
+	for p, want := range tests {
+		if got := guessPackageName(p); got != want {
+			t.Errorf("guessPackageName(%s) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func newTestResolver(files map[string]string) *typeResolver {
+	kfs := &fs.KitFs{Fs: afero.NewMemMapFs()}
+	for p, s := range files {
+		afero.WriteFile(kfs.Fs, p, []byte(s), 0644)
+	}
+	r := newTypeResolver(kfs)
+	r.projectPath = "example.com/p"
+	return r
+}
+
+func TestTypeResolver_resolveImports(t *testing.T) {
+	r := newTestResolver(map[string]string{
+		"pkg/api/v1/api.go": "package apiv1\n",
+	})
+	imp := []parser.NamedTypeValue{
+		parser.NewNameType("", `"context"`),
+		parser.NewNameType("", `"example.com/p/pkg/api/v1"`),
+		parser.NewNameType("", `"example.com/p/pkg/pb"`),
+		parser.NewNameType("k", `"github.com/go-kit/kit/log"`),
+	}
+	want := []parser.NamedTypeValue{
+		parser.NewNameType("context", `"context"`),
+		parser.NewNameType("apiv1", `"example.com/p/pkg/api/v1"`),
+		parser.NewNameType("pb", `"example.com/p/pkg/pb"`),
+		parser.NewNameType("k", `"github.com/go-kit/kit/log"`),
+	}
+	if got := r.resolveImports(imp); !reflect.DeepEqual(got, want) {
+		t.Errorf("typeResolver.resolveImports() = %v, want %v", got, want)
+	}
+	if got := importPath("apiv1", want); got != "example.com/p/pkg/api/v1" {
+		t.Errorf("importPath() = %v, want example.com/p/pkg/api/v1", got)
+	}
+	if got := importPath("v1", want); got != "" {
+		t.Errorf("importPath() = %v, want no import", got)
+	}
+}
@@ -0,0 +1,260 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	ps "go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dave/jennifer/jen"
+	"github.com/emicklei/proto"
+	"github.com/hms58/genkit/parser"
+	"github.com/hms58/genkit/utils"
+)
+
+// sameType compares a type from the service interface with a type from a
+// generated file, types defined in the service package are qualified with
+// `service.` outside of it.
+func sameType(svcType, genType string) bool {
+	svcType = strings.Replace(svcType, "...", "[]", 1)
+	genType = strings.Replace(genType, "...", "[]", 1)
+	if svcType == genType {
+		return true
+	}
+	st, err := parser.ParseType(svcType)
+	if err != nil {
+		return false
+	}
+	gt, err := parser.ParseType(genType)
+	if err != nil {
+		return false
+	}
+	unqualify(gt, "service")
+	return st.String() == gt.String()
+}
+
+// unqualify removes the package qualifier `qualifier` from the identifiers of
+// the type, the other qualifiers (e.x `userservice`) are kept.
+func unqualify(t *parser.Type, qualifier string) {
+	if t == nil {
+		return
+	}
+	if t.Kind == parser.KindIdent && t.Package == qualifier {
+		t.Package = ""
+	}
+	for _, c := range append([]*parser.Type{t.Elem, t.Key, t.Value}, t.TypeArgs...) {
+		unqualify(c, qualifier)
+	}
+	for _, l := range [][]parser.Field{t.Params, t.Results, t.Fields, t.Methods} {
+		for _, f := range l {
+			unqualify(f.Type, qualifier)
+		}
+	}
+}
+
+// sameNamedTypes returns true if both lists have the same names and types.
+func sameNamedTypes(svc, gen []parser.NamedTypeValue) bool {
+	if len(svc) != len(gen) {
+		return false
+	}
+	for i := range svc {
+		if svc[i].Name != gen[i].Name || !sameType(svc[i].Type, gen[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSignature returns true if the method `gen` found in a generated file still
+// has the parameters and results of the service method `m`.
+func sameSignature(m parser.Method, gen parser.Method) bool {
+	return sameNamedTypes(m.Parameters, gen.Parameters) && sameNamedTypes(m.Results, gen.Results)
+}
+
+// sameFields returns true if the fields of the generated request/response struct
+// `st` still match the given parameters (the context is never a field).
+func sameFields(params []parser.NamedTypeValue, st parser.Struct) bool {
+	fields := []parser.NamedTypeValue{}
+	for _, p := range params {
+		if p.Type == "context.Context" {
+			continue
+		}
+		fields = append(fields, parser.NewNameType(utils.ToCamelCase(p.Name), p.Type))
+	}
+	return sameNamedTypes(fields, st.Vars)
+}
+
+// reportSignatureChange tells the user about code that was not generated by kit
+// (or was edited) and has to be updated by hand after the signature of a
+// service method changed.
+func reportSignatureChange(method, decl, path string) {
+	logrus.Warnf(
+		"The signature of `%s` changed, `%s` in `%s` is not regenerated, please update it manually.",
+		method,
+		decl,
+		path,
+	)
+}
+
+// removeDecls removes the declarations (and their doc comments) named in `decls`
+// from the source, see parser.DeclKey for the naming.
+func removeDecls(src string, decls []string) (string, error) {
+	if len(decls) == 0 {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	f, err := ps.ParseFile(fset, "", src, ps.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	remove := map[string]bool{}
+	for _, v := range decls {
+		remove[v] = true
+	}
+	type span struct{ start, end int }
+	spans := []span{}
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && len(gd.Specs) > 1 {
+			// grouped declarations are never generated.
+			continue
+		}
+		found := false
+		for _, k := range parser.DeclKey(d) {
+			if remove[k] {
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		start := d.Pos()
+		switch dec := d.(type) {
+		case *ast.FuncDecl:
+			if dec.Doc != nil {
+				start = dec.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if dec.Doc != nil {
+				start = dec.Doc.Pos()
+			}
+		}
+		spans = append(spans, span{fset.Position(start).Offset, fset.Position(d.End()).Offset})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for _, s := range spans {
+		// take the line break and the empty line after the declaration.
+		end := s.end
+		for i := 0; i < 2 && end < len(src) && src[end] == '\n'; i++ {
+			end++
+		}
+		src = src[:s.start] + src[end:]
+	}
+	return src, nil
+}
+
+// removeStaleDecls removes the generated declarations that were marked as
+// stale so they can be generated again.
+func (b *BaseGenerator) removeStaleDecls(src string) (string, error) {
+	if len(b.stale) > 0 {
+		logrus.Infof("Regenerating `%s` because the service signature changed.", strings.Join(b.stale, "`, `"))
+	}
+	return removeDecls(src, b.stale)
+}
+
+// funcBody returns the body of a function with the statements `body` as the
+// parser returns the bodies of the functions of a file in `destPath`.
+func funcBody(destPath string, body []jen.Code) string {
+	f := jen.NewFilePath(destPath)
+	f.Func().Id("f").Params().Block(body...)
+	file, err := parser.NewFileParser().Parse([]byte(f.GoString()))
+	if err != nil || len(file.Methods) == 0 {
+		return ""
+	}
+	return file.Methods[0].Body
+}
+
+// generatedConverter returns true if `body` is the body of a gRPC decoder or
+// encoder as kit generates it: an optional type assertion of the parameter
+// followed by the return of a composite literal, so it can be generated again.
+func generatedConverter(body string) bool {
+	f, err := ps.ParseFile(token.NewFileSet(), "", "package p\nfunc f() {"+body+"}", 0)
+	if err != nil || len(f.Decls) != 1 {
+		return false
+	}
+	list := f.Decls[0].(*ast.FuncDecl).Body.List
+	if len(list) == 2 {
+		as, ok := list[0].(*ast.AssignStmt)
+		if !ok || len(as.Rhs) != 1 {
+			return false
+		}
+		if _, ok := as.Rhs[0].(*ast.TypeAssertExpr); !ok {
+			return false
+		}
+		list = list[1:]
+	}
+	if len(list) != 1 {
+		return false
+	}
+	ret, ok := list[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 2 {
+		return false
+	}
+	x := ret.Results[0]
+	if u, ok := x.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		x = u.X
+	}
+	if _, ok := x.(*ast.CompositeLit); !ok {
+		return false
+	}
+	id, ok := ret.Results[1].(*ast.Ident)
+	return ok && id.Name == "nil"
+}
+
+// reportStaleMessage tells the user about the fields of the existing proto
+// message `old` that do not match the fields `msg` has now, kit does not
+// change the fields of existing messages.
+func reportStaleMessage(old, msg *proto.Message, path string) {
+	fieldTypes := func(m *proto.Message) map[string]string {
+		t := map[string]string{}
+		for _, e := range m.Elements {
+			switch f := e.(type) {
+			case *proto.NormalField:
+				t[f.Name] = f.Type
+				if f.Repeated {
+					t[f.Name] = "repeated " + f.Type
+				}
+			case *proto.MapField:
+				t[f.Name] = "map<" + f.KeyType + ", " + f.Type + ">"
+			}
+		}
+		return t
+	}
+	got, want := fieldTypes(old), fieldTypes(msg)
+	diff := []string{}
+	for n, t := range want {
+		switch o, ok := got[n]; {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("`%s %s` is missing", t, n))
+		case o != t:
+			diff = append(diff, fmt.Sprintf("`%s` is a `%s` instead of a `%s`", n, o, t))
+		}
+	}
+	for n := range got {
+		if _, ok := want[n]; !ok {
+			diff = append(diff, fmt.Sprintf("`%s` is not used anymore", n))
+		}
+	}
+	if len(diff) == 0 {
+		return
+	}
+	sort.Strings(diff)
+	logrus.Warnf(
+		"The message `%s` in `%s` does not match the service anymore (%s), please update it manually.",
+		old.Name,
+		path,
+		strings.Join(diff, ", "),
+	)
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/hms58/genkit/parser"
+)
+
+func Test_removeDecls(t *testing.T) {
+	src := `package endpoint
+
+// FooRequest collects the request parameters for the Foo method.
+type FooRequest struct {
+	S string
+}
+
+// MakeFooEndpoint returns an endpoint that invokes Foo on the service.
+func MakeFooEndpoint() {}
+
+// Foo implements Service.
+func (e Endpoints) Foo() {}
+
+// Bar implements Service.
+func (e *Endpoints) Bar() {}
+`
+	tests := []struct {
+		name  string
+		decls []string
+		want  string
+	}{
+		{
+			name:  "Test nothing to remove",
+			decls: nil,
+			want:  src,
+		},
+		{
+			name:  "Test remove type and function",
+			decls: []string{"FooRequest", "MakeFooEndpoint"},
+			want: `package endpoint
+
+// Foo implements Service.
+func (e Endpoints) Foo() {}
+
+// Bar implements Service.
+func (e *Endpoints) Bar() {}
+`,
+		},
+		{
+			name:  "Test remove methods",
+			decls: []string{"Endpoints.Foo", "Endpoints.Bar"},
+			want: `package endpoint
+
+// FooRequest collects the request parameters for the Foo method.
+type FooRequest struct {
+	S string
+}
+
+// MakeFooEndpoint returns an endpoint that invokes Foo on the service.
+func MakeFooEndpoint() {}
+
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := removeDecls(src, tt.decls)
+			if err != nil {
+				t.Fatalf("removeDecls() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("removeDecls() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sameSignature(t *testing.T) {
+	m := parser.Method{
+		Name: "Foo",
+		Parameters: []parser.NamedTypeValue{
+			parser.NewNameType("ctx", "context.Context"),
+			parser.NewNameType("u", "User"),
+		},
+		Results: []parser.NamedTypeValue{
+			parser.NewNameType("err", "error"),
+		},
+	}
+	tests := []struct {
+		name string
+		gen  parser.Method
+		want bool
+	}{
+		{
+			name: "Test same signature",
+			gen:  m,
+			want: true,
+		},
+		{
+			name: "Test service qualified type",
+			gen: parser.Method{
+				Parameters: []parser.NamedTypeValue{
+					parser.NewNameType("ctx", "context.Context"),
+					parser.NewNameType("u", "service.User"),
+				},
+				Results: m.Results,
+			},
+			want: true,
+		},
+		{
+			name: "Test renamed parameter",
+			gen: parser.Method{
+				Parameters: []parser.NamedTypeValue{
+					parser.NewNameType("ctx", "context.Context"),
+					parser.NewNameType("user", "User"),
+				},
+				Results: m.Results,
+			},
+			want: false,
+		},
+		{
+			name: "Test new result",
+			gen: parser.Method{
+				Parameters: m.Parameters,
+				Results:    []parser.NamedTypeValue{},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameSignature(m, tt.gen); got != tt.want {
+				t.Errorf("sameSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sameType(t *testing.T) {
+	tests := []struct {
+		svcType string
+		genType string
+		want    bool
+	}{
+		{"User", "service.User", true},
+		{"...User", "[]service.User", true},
+		{"map[string]*User", "map[string]*service.User", true},
+		{"userservice.User", "userservice.User", true},
+		{"userUser", "userservice.User", false},
+		{"User", "userservice.User", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.svcType+" "+tt.genType, func(t *testing.T) {
+			if got := sameType(tt.svcType, tt.genType); got != tt.want {
+				t.Errorf("sameType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generatedConverter(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"assertion and literal", "\n\tv := r.(*pb.FooRequest)\n\treturn endpoint.FooRequest{S: v.S}, nil\n", true},
+		{"pointer literal", "\n\tv := r.(endpoint.FooResponse)\n\treturn &pb.FooReply{S: v.S}, nil\n", true},
+		{"empty literal", "\n\treturn endpoint.FooRequest{}, nil\n", true},
+		{"stub", "\n\treturn nil, errors.New(\"'Foo' Decoder is not impelemented\")\n", false},
+		{"validation", "\n\tv := r.(*pb.FooRequest)\n\treturn endpoint.FooRequest{S: v.S}, validate(v)\n", false},
+		{"more statements", "\n\tv := r.(*pb.FooRequest)\n\tlog.Println(v)\n\treturn endpoint.FooRequest{S: v.S}, nil\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generatedConverter(tt.body); got != tt.want {
+				t.Errorf("generatedConverter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	ps "go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hms58/genkit/fs"
+	"github.com/hms58/genkit/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// errVerify is returned when the generated code does not compile.
+var errVerify = errors.New("the generated code does not compile, no file was written (use --no-verify to skip the check)")
+
+// packageVerifier type-checks the packages of the project as they are in the
+// kit filesystem, so the files that are not yet on disk are checked too.
+type packageVerifier struct {
+	fs          *fs.KitFs
+	fset        *token.FileSet
+	projectPath string
+	srcDir      string
+	external    types.ImporterFrom
+	ctx         build.Context
+	pkgs        map[string]*types.Package
+	checking    map[string]bool
+	// errors are the compile errors of the checked packages, skipped the
+	// packages that could not be checked and unresolved the imports that
+	// could not be loaded, the code that uses them is not checked.
+	errors     []error
+	skipped    map[string]error
+	unresolved map[string]error
+}
+
+func newPackageVerifier(kfs *fs.KitFs, projectPath, srcDir string) *packageVerifier {
+	v := &packageVerifier{
+		fs:          kfs,
+		fset:        token.NewFileSet(),
+		projectPath: projectPath,
+		srcDir:      srcDir,
+		ctx:         build.Default,
+		pkgs:        map[string]*types.Package{},
+		checking:    map[string]bool{},
+		skipped:     map[string]error{},
+		unresolved:  map[string]error{},
+	}
+	v.external = importer.ForCompiler(v.fset, "source", nil).(types.ImporterFrom)
+	v.ctx.OpenFile = func(p string) (io.ReadCloser, error) {
+		return v.fs.Fs.Open(p)
+	}
+	return v
+}
+
+// verifyPackages type-checks the packages of the go `files` (and the project
+// packages they import), every compile error is logged with its position. Only
+// the errors in `files` fail the verification, the others were already there.
+func verifyPackages(kfs *fs.KitFs, projectPath, srcDir string, files []string) error {
+	defer offlineGo()()
+	v := newPackageVerifier(kfs, projectPath, srcDir)
+	changed := map[string]bool{}
+	seen := map[string]bool{}
+	dirs := []string{}
+	for _, f := range files {
+		changed[f] = true
+		if d := path.Dir(f); !ignoredDir(d) && !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	for _, d := range dirs {
+		v.check(d)
+	}
+	skipped := []string{}
+	for d := range v.skipped {
+		skipped = append(skipped, d)
+	}
+	sort.Strings(skipped)
+	for _, d := range skipped {
+		logrus.Warnf("Could not verify `%s`: %s", d, v.skipped[d])
+	}
+	unresolved := []string{}
+	for p := range v.unresolved {
+		unresolved = append(unresolved, p)
+	}
+	sort.Strings(unresolved)
+	for _, p := range unresolved {
+		logrus.Warnf("Could not verify the code that uses `%s`: %s", p, v.unresolved[p])
+	}
+	failed := false
+	for _, err := range v.errors {
+		if changed[v.errorFile(err)] {
+			logrus.Error(err)
+			failed = true
+		} else {
+			logrus.Warn(err)
+		}
+	}
+	if failed {
+		return errVerify
+	}
+	return nil
+}
+
+// generating is true while a generator runs, the generators it runs leave the
+// verification and the write of the files to it.
+var generating bool
+
+// generateVerified runs `generate` in a transaction, the files are written to
+// disk only if it succeeds and the generated code compiles (unless
+// `--no-verify`).
+func (b *BaseGenerator) generateVerified(generate func() error) (err error) {
+	if generating {
+		return generate()
+	}
+	generating = true
+	defer func() { generating = false }()
+	// the files written before the generator runs are not verified.
+	before := map[string]string{}
+	for _, c := range b.fs.Changes() {
+		before[c.Path] = c.New
+	}
+	b.fs.Begin()
+	if err = generate(); err == nil && !viper.GetBool("gk_no_verify") {
+		err = b.verify(before)
+	}
+	if err != nil {
+		if err != errVerify && b.fs.InTransaction() {
+			logrus.Error("The generation failed, no file was written.")
+		}
+		b.fs.Rollback()
+		return err
+	}
+	return b.fs.Commit()
+}
+
+// verify type-checks the packages of the go files that changed during the run,
+// `before` is the content of the files written before it.
+func (b *BaseGenerator) verify(before map[string]string) error {
+	files := []string{}
+	for _, c := range b.fs.Changes() {
+		changed := c.Status == fs.StatusCreated || c.Status == fs.StatusModified
+		if s, ok := before[c.Path]; ok && s == c.New {
+			changed = false
+		}
+		if changed && strings.HasSuffix(c.Path, ".go") {
+			files = append(files, filepath.ToSlash(c.Path))
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	projectPath, err := utils.GetProjectPath()
+	if err != nil {
+		return err
+	}
+	srcDir, err := utils.GetWorkingDir()
+	if err != nil {
+		return err
+	}
+	logrus.Info("Type checking the generated code.")
+	return verifyPackages(b.fs, projectPath, srcDir, files)
+}
+
+// offlineGo makes the go command neither download modules nor edit go.mod
+// until the returned function is called.
+func offlineGo() (restore func()) {
+	env := map[string]string{"GOFLAGS": "-mod=readonly", "GOPROXY": "off"}
+	old := map[string]*string{}
+	for k, val := range env {
+		if o, ok := os.LookupEnv(k); ok {
+			old[k] = &o
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, val)
+	}
+	return func() {
+		for k, o := range old {
+			if o != nil {
+				os.Setenv(k, *o)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+	}
+}
+
+// errorFile returns the file of a compile error.
+func (v *packageVerifier) errorFile(err error) string {
+	switch e := err.(type) {
+	case types.Error:
+		return v.fset.Position(e.Pos).Filename
+	case scanner.ErrorList:
+		if len(e) > 0 {
+			return e[0].Pos.Filename
+		}
+	}
+	return ""
+}
+
+// Import implements types.Importer.
+func (v *packageVerifier) Import(p string) (*types.Package, error) {
+	return v.ImportFrom(p, "", 0)
+}
+
+// ImportFrom implements types.ImporterFrom, the project packages are read from
+// the kit filesystem and the others are loaded from source.
+func (v *packageVerifier) ImportFrom(p, _ string, _ types.ImportMode) (*types.Package, error) {
+	if p == v.projectPath || strings.HasPrefix(p, v.projectPath+"/") {
+		dir := strings.TrimPrefix(strings.TrimPrefix(p, v.projectPath), "/")
+		if dir == "" {
+			dir = "."
+		}
+		return v.check(dir)
+	}
+	return v.external.ImportFrom(p, v.srcDir, 0)
+}
+
+// check type-checks the package in `dir`.
+func (v *packageVerifier) check(dir string) (*types.Package, error) {
+	if pkg, ok := v.pkgs[dir]; ok {
+		return pkg, nil
+	}
+	if err, ok := v.skipped[dir]; ok {
+		return nil, err
+	}
+	if v.checking[dir] {
+		return nil, fmt.Errorf("import cycle through `%s`", dir)
+	}
+	v.checking[dir] = true
+	defer delete(v.checking, dir)
+	files, err := v.parseDir(dir)
+	if err != nil {
+		v.skipped[dir] = err
+		return nil, err
+	}
+	importErrs := []types.Error{}
+	pkgErrors := []error{}
+	conf := types.Config{
+		Importer: v,
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok && strings.HasPrefix(te.Msg, "could not import") {
+				importErrs = append(importErrs, te)
+				return
+			}
+			pkgErrors = append(pkgErrors, err)
+		},
+	}
+	info := &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	pkg, _ := conf.Check(path.Join(v.projectPath, dir), v.fset, files, info)
+	if len(importErrs) > 0 {
+		pkgErrors = v.dropUnresolved(files, info, importErrs, pkgErrors)
+	}
+	v.errors = append(v.errors, pkgErrors...)
+	v.pkgs[dir] = pkg
+	return pkg, nil
+}
+
+// dropUnresolved returns the errors `errs` without the ones on the lines that
+// use a package that could not be imported, they are not reliable. The soft
+// errors (e.x `declared and not used`) are dropped too: the arguments of a
+// call through a package that could not be imported are not checked, so their
+// variables look unused.
+func (v *packageVerifier) dropUnresolved(files []*ast.File, info *types.Info, importErrs []types.Error, errs []error) []error {
+	failed := map[string]bool{}
+	for _, te := range importErrs {
+		pos := v.fset.Position(te.Pos)
+		for _, f := range files {
+			for _, imp := range f.Imports {
+				ip := v.fset.Position(imp.Pos())
+				if ip.Filename != pos.Filename || ip.Line != pos.Line {
+					continue
+				}
+				if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+					failed[p] = true
+					if _, ok := v.unresolved[p]; !ok {
+						v.unresolved[p] = errors.New(te.Msg)
+					}
+				}
+			}
+		}
+	}
+	lines := map[string]bool{}
+	for id, obj := range info.Uses {
+		if pn, ok := obj.(*types.PkgName); ok && failed[pn.Imported().Path()] {
+			pos := v.fset.Position(id.Pos())
+			lines[fmt.Sprintf("%s:%d", pos.Filename, pos.Line)] = true
+		}
+	}
+	kept := []error{}
+	for _, err := range errs {
+		if te, ok := err.(types.Error); ok {
+			pos := v.fset.Position(te.Pos)
+			if te.Soft || lines[fmt.Sprintf("%s:%d", pos.Filename, pos.Line)] {
+				continue
+			}
+		}
+		kept = append(kept, err)
+	}
+	return kept
+}
+
+// parseDir parses the go files of the package in `dir`, the tests and the
+// files excluded by build constraints are ignored.
+func (v *packageVerifier) parseDir(dir string) ([]*ast.File, error) {
+	infos, err := afero.ReadDir(v.fs.Fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []*ast.File{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		p := path.Join(dir, name)
+		if b, _ := v.fs.Exists(p); !b {
+			continue
+		}
+		if ok, err := v.ctx.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		src, err := v.fs.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		f, err := ps.ParseFile(v.fset, p, src, 0)
+		if err != nil {
+			v.errors = append(v.errors, err)
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no go files in `%s`", dir)
+	}
+	return files, nil
+}
+
+// ignoredDir returns true if go ignores the folder, it or one of its parents
+// starts with `_` or `.` (e.x `_orphaned`).
+func ignoredDir(dir string) bool {
+	for _, e := range strings.Split(dir, "/") {
+		if e != "." && (strings.HasPrefix(e, "_") || strings.HasPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/hms58/genkit/fs"
+	"github.com/spf13/afero"
+)
+
+func Test_verifyPackages(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		changed []string
+		wantErr bool
+	}{
+		{
+			name: "Compiles",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport (\n\t\"strings\"\n\n\t\"example.com/p/b\"\n)\n\nfunc A() string { return strings.ToUpper(b.B) }\n",
+				"b/b.go": "package b\n\nconst B = \"b\"\n",
+			},
+			changed: []string{"a/a.go"},
+		},
+		{
+			name: "Error in a changed imported package",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/p/b\"\n\nvar A = b.B\n",
+				"b/b.go": "package b\n\nconst B = c\n",
+			},
+			changed: []string{"a/a.go", "b/b.go"},
+			wantErr: true,
+		},
+		{
+			name: "Error in an unchanged file",
+			files: map[string]string{
+				"a/a.go": "package a\n\nvar A = 1\n",
+				"a/b.go": "package a\n\nvar B int = \"b\"\n",
+			},
+			changed: []string{"a/a.go"},
+		},
+		{
+			name: "Wrong type",
+			files: map[string]string{
+				"a/a.go": "package a\n\nfunc A() int { return \"a\" }\n",
+			},
+			changed: []string{"a/a.go"},
+			wantErr: true,
+		},
+		{
+			name: "Missing import is skipped",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/missing\"\n\nvar A int = missing.B\n",
+			},
+			changed: []string{"a/a.go"},
+		},
+		{
+			name: "Error next to a missing import",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/missing\"\n\nvar A int = missing.B\n\nvar C int = \"c\"\n",
+			},
+			changed: []string{"a/a.go"},
+			wantErr: true,
+		},
+		{
+			name: "Variable passed through a missing import",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/missing\"\n\ntype E struct{ F missing.F }\n\nfunc (e E) G() {\n\tv := 1\n\te.F(v)\n}\n",
+			},
+			changed: []string{"a/a.go"},
+		},
+		{
+			name: "Error in a package that imports a broken package",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/p/b\"\n\nvar A = b.B\n\nfunc C() int { return \"c\" }\n",
+				"b/b.go": "package b\n\nimport \"example.com/missing\"\n\nvar B = missing.B\n",
+			},
+			changed: []string{"a/a.go"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kfs := &fs.KitFs{Fs: afero.NewMemMapFs()}
+			for p, s := range tt.files {
+				afero.WriteFile(kfs.Fs, p, []byte(s), 0644)
+			}
+			if err := verifyPackages(kfs, "example.com/p", "", tt.changed); (err != nil) != tt.wantErr {
+				t.Errorf("verifyPackages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBaseGenerator_generateVerified(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{"Compiles", "package a\n\nvar A int = 1\n", false},
+		{"Does not compile", "package a\n\nvar A int = \"a\"\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BaseGenerator{fs: &fs.KitFs{Fs: afero.NewMemMapFs()}}
+			b.fs.MkdirAll("a")
+			// the nested generator leaves the write to the outer one.
+			nested := &BaseGenerator{fs: b.fs}
+			err := b.generateVerified(func() error {
+				return nested.generateVerified(func() error {
+					if b, _ := nested.fs.Exists("a/a.go"); b {
+						t.Error("generateVerified() the nested generator wrote a file")
+					}
+					return nested.writeFile("a/a.go", tt.src, true)
+				})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("generateVerified() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok, _ := afero.Exists(b.fs.Fs, "a/a.go"); ok == tt.wantErr {
+				t.Errorf("generateVerified() wrote the file = %v, want %v", ok, !tt.wantErr)
+			}
+		})
+	}
+}
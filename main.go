@@ -1,16 +1,14 @@
 package main
 
 import (
-	"os"
 	"path"
+	"path/filepath"
 	"runtime"
-	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/hms58/genkit/cmd"
 	"github.com/hms58/genkit/generator"
 	"github.com/hms58/genkit/utils"
-	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
 
@@ -18,30 +16,25 @@ func main() {
 	setDefaults()
 	viper.AutomaticEnv()
 
-	pwd, err := os.Getwd()
+	pwd, err := utils.GetWorkingDir()
 	if err != nil {
 		logrus.Error(err)
 		return
 	}
-	inGosrc := false
-	gopathEnv := utils.GetGOPATH()
-	gopaths := strings.Split(gopathEnv, ":")
-	logrus.Info("GOPATH = ", gopathEnv)
-	for _, gopath := range gopaths {
-		gosrc := gopath + afero.FilePathSeparator + "src" + afero.FilePathSeparator
-		if strings.HasPrefix(pwd, gosrc) {
-			inGosrc = true
-			viper.Set("GOPATH", gopath)
-			break
-		}
-	}
-
-	if !inGosrc {
-		logrus.Error("The project must be in the $GOPATH/src folder for the generator to work.")
-		logrus.Error(pwd, "\t| ", gopaths)
+	root, modulePath, err := utils.GetModule()
+	if err != nil {
+		logrus.Error(err)
 		return
 	}
-	// logrus.Info(viper.GetString("GOPATH"))
+	if root != "" {
+		logrus.Info("Module = ", modulePath)
+	} else if gosrc := utils.GetGOPATHSrc(pwd); gosrc != "" {
+		// GOPATH mode, remember which GOPATH entry the project lives in.
+		viper.Set("GOPATH", filepath.Dir(filepath.Clean(gosrc)))
+		logrus.Info("GOPATH = ", viper.GetString("GOPATH"))
+	} else {
+		logrus.Warn("The project is not inside a go module (go.mod) nor in the $GOPATH/src folder.")
+	}
 
 	cmd.Execute()
 }
@@ -67,6 +60,7 @@ func setDefaults() {
 	viper.SetDefault("gk_http_file_name", "handler.go")
 	viper.SetDefault("gk_http_path_file_name", "path.go")
 	viper.SetDefault("gk_http_base_file_name", "handler_gen.go")
+	viper.SetDefault("gk_http_gateway_file_name", "gateway_gen.go")
 	viper.SetDefault("gk_cmd_base_file_name", "service_gen.go")
 	viper.SetDefault("gk_cmd_svc_file_name", "service.go")
 	viper.SetDefault("gk_http_client_file_name", "http.go")
@@ -74,6 +68,7 @@ func setDefaults() {
 	viper.SetDefault("gk_grpc_pb_file_name", "%s.proto")
 	viper.SetDefault("gk_grpc_base_file_name", "handler_gen.go")
 	viper.SetDefault("gk_grpc_file_name", "handler.go")
+	viper.SetDefault("gk_grpc_convert_file_name", "convert_gen.go")
 
 	// add 2018.07.05
 	viper.SetDefault("gk_gdg_comm_path_format", path.Join("%s", "pkg", "utils"))
@@ -89,5 +84,12 @@ func setDefaults() {
 		viper.SetDefault("gk_grpc_compile_file_name", "compile.sh")
 	}
 	viper.SetDefault("gk_service_struct_prefix", "basic")
+	viper.SetDefault("gk_protoc", "protoc")
+	viper.SetDefault("gk_protoc_include_paths", []string{"."})
+	viper.SetDefault("gk_protoc_plugins", []string{"go:plugins=grpc"})
+	viper.SetDefault("gk_protoc_paths", "source_relative")
+	viper.SetDefault("gk_proto_options", map[string]string{})
+	viper.SetDefault("gk_grpc_health", false)
+	viper.SetDefault("gk_grpc_gateway", false)
 
 }
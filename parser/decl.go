@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// DeclKey returns the key used to find a declaration, `Type.Method` for methods
+// and the name for functions, types, variables and constants.
+func DeclKey(d ast.Decl) []string {
+	switch dec := d.(type) {
+	case *ast.FuncDecl:
+		if dec.Recv == nil || len(dec.Recv.List) == 0 {
+			return []string{dec.Name.Name}
+		}
+		tp := dec.Recv.List[0].Type
+		if st, ok := tp.(*ast.StarExpr); ok {
+			tp = st.X
+		}
+		if id, ok := tp.(*ast.Ident); ok {
+			return []string{id.Name + "." + dec.Name.Name}
+		}
+	case *ast.GenDecl:
+		keys := []string{}
+		for _, sp := range dec.Specs {
+			switch s := sp.(type) {
+			case *ast.TypeSpec:
+				keys = append(keys, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					keys = append(keys, n.Name)
+				}
+			}
+		}
+		return keys
+	}
+	return nil
+}
+
+// DeclKeys returns the declaration keys of the file, see DeclKey.
+func DeclKeys(f *ast.File) (keys []string) {
+	for _, d := range f.Decls {
+		keys = append(keys, DeclKey(d)...)
+	}
+	return keys
+}
+
+// ParseDeclKeys parses the go source and returns its declaration keys.
+func ParseDeclKeys(src string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "src.go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	return DeclKeys(f), nil
+}
+
+// ParseDeclDocs parses the go source and returns the doc comment of its
+// declarations by key (see DeclKey), as returned by ast.CommentGroup.Text.
+func ParseDeclDocs(src string) (map[string]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "src.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]string{}
+	for _, d := range f.Decls {
+		switch dec := d.(type) {
+		case *ast.FuncDecl:
+			for _, k := range DeclKey(d) {
+				docs[k] = dec.Doc.Text()
+			}
+		case *ast.GenDecl:
+			for _, sp := range dec.Specs {
+				doc := dec.Doc
+				keys := []string{}
+				switch s := sp.(type) {
+				case *ast.TypeSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					keys = append(keys, s.Name.Name)
+				case *ast.ValueSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					for _, n := range s.Names {
+						keys = append(keys, n.Name)
+					}
+				}
+				for _, k := range keys {
+					docs[k] = doc.Text()
+				}
+			}
+		}
+	}
+	return docs, nil
+}
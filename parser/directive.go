@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"time"
+)
+
+// DirectivePrefix is the prefix of the directives of the service methods
+// e.x `// kit:http GET /users/{id}`.
+const DirectivePrefix = "kit:"
+
+// The directives of the service methods.
+const (
+	DirectiveHTTP       = "http"
+	DirectiveGRPCStream = "grpc-stream"
+	DirectiveSkip       = "skip"
+	DirectiveDeprecated = "deprecated"
+	DirectiveTimeout    = "timeout"
+)
+
+// The streaming kinds of `kit:grpc-stream`.
+const (
+	StreamClient = "client"
+	StreamServer = "server"
+	StreamBidi   = "bidi"
+)
+
+// The targets of `kit:skip`, a `kit:skip` without targets skips the transports
+// and the client.
+const (
+	SkipHTTP       = "transport=http"
+	SkipGRPC       = "transport=grpc"
+	SkipClient     = "client"
+	SkipMiddleware = "middleware"
+)
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Directives are the `kit:` directives of a service method.
+type Directives struct {
+	// HTTPMethod and HTTPPath are the route of `kit:http GET /users/{id}`.
+	HTTPMethod string
+	HTTPPath   string
+	// GRPCStream is the streaming kind of `kit:grpc-stream`.
+	GRPCStream string
+	// Skip are the targets of `kit:skip`.
+	Skip []string
+	// Deprecated is true for `kit:deprecated`, Deprecation is the text that
+	// follows the directive e.x `use Bar instead`.
+	Deprecated  bool
+	Deprecation string
+	// Timeout is the duration of `kit:timeout 2s`.
+	Timeout time.Duration
+}
+
+// Skips returns true if the code of `target` (see SkipHTTP) is not generated.
+func (d Directives) Skips(target string) bool {
+	for _, s := range d.Skip {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDirective returns true if the comment line (with or without the `//`) is
+// a `kit:` directive.
+func IsDirective(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "//")), DirectivePrefix)
+}
+
+// ParseDirectives parses the `kit:` directives of the comments.
+func ParseDirectives(comments ...*ast.CommentGroup) (Directives, error) {
+	d := Directives{}
+	seen := map[string]bool{}
+	for _, cg := range comments {
+		if cg == nil {
+			continue
+		}
+		for _, c := range cg.List {
+			if !IsDirective(c.Text) {
+				continue
+			}
+			line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			args := strings.Fields(strings.TrimPrefix(line, DirectivePrefix))
+			if len(args) == 0 {
+				return d, fmt.Errorf("empty directive `%s`", line)
+			}
+			name := args[0]
+			if seen[name] {
+				return d, fmt.Errorf("duplicate directive `%s%s`", DirectivePrefix, name)
+			}
+			seen[name] = true
+			if err := d.parse(name, args[1:]); err != nil {
+				return d, fmt.Errorf("invalid directive `%s`: %s", line, err)
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *Directives) parse(name string, args []string) error {
+	switch name {
+	case DirectiveHTTP:
+		if len(args) != 2 {
+			return fmt.Errorf("expected `%shttp <METHOD> <path>`", DirectivePrefix)
+		}
+		if !contains(httpMethods, args[0]) {
+			return fmt.Errorf("unknown HTTP method `%s`", args[0])
+		}
+		if !strings.HasPrefix(args[1], "/") {
+			return fmt.Errorf("the path `%s` does not start with `/`", args[1])
+		}
+		d.HTTPMethod, d.HTTPPath = args[0], args[1]
+	case DirectiveGRPCStream:
+		if len(args) != 1 || !contains([]string{StreamClient, StreamServer, StreamBidi}, args[0]) {
+			return fmt.Errorf("expected `%sgrpc-stream client|server|bidi`", DirectivePrefix)
+		}
+		d.GRPCStream = args[0]
+	case DirectiveSkip:
+		if len(args) == 0 {
+			d.Skip = []string{SkipHTTP, SkipGRPC, SkipClient}
+		}
+		for _, a := range args {
+			if strings.HasPrefix(a, "transport=") {
+				for _, t := range strings.Split(strings.TrimPrefix(a, "transport="), ",") {
+					if t != "http" && t != "grpc" {
+						return fmt.Errorf("unknown transport `%s`", t)
+					}
+					d.Skip = append(d.Skip, "transport="+t)
+				}
+				continue
+			}
+			if a != SkipClient && a != SkipMiddleware {
+				return fmt.Errorf("unknown target `%s`", a)
+			}
+			d.Skip = append(d.Skip, a)
+		}
+	case DirectiveDeprecated:
+		d.Deprecated = true
+		d.Deprecation = strings.Join(args, " ")
+	case DirectiveTimeout:
+		if len(args) != 1 {
+			return fmt.Errorf("expected `%stimeout <duration>`", DirectivePrefix)
+		}
+		t, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		if t <= 0 {
+			return fmt.Errorf("the timeout `%s` is not positive", args[0])
+		}
+		d.Timeout = t
+	default:
+		return fmt.Errorf("unknown directive")
+	}
+	return nil
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"go/ast"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    Directives
+		wantErr string
+	}{
+		{
+			name:  "Test no directive",
+			lines: []string{"// Foo does foo.", "// kit is not a directive."},
+			want:  Directives{},
+		},
+		{
+			name:  "Test http",
+			lines: []string{"// kit:http GET /users/{id}"},
+			want:  Directives{HTTPMethod: "GET", HTTPPath: "/users/{id}"},
+		},
+		{
+			name:  "Test grpc-stream without space",
+			lines: []string{"//kit:grpc-stream server"},
+			want:  Directives{GRPCStream: StreamServer},
+		},
+		{
+			name:  "Test skip",
+			lines: []string{"// kit:skip"},
+			want:  Directives{Skip: []string{SkipHTTP, SkipGRPC, SkipClient}},
+		},
+		{
+			name:  "Test skip targets",
+			lines: []string{"// kit:skip transport=http,grpc middleware"},
+			want:  Directives{Skip: []string{SkipHTTP, SkipGRPC, SkipMiddleware}},
+		},
+		{
+			name:  "Test deprecated and timeout",
+			lines: []string{"// kit:deprecated use Bar instead", "// kit:timeout 1m30s"},
+			want:  Directives{Deprecated: true, Deprecation: "use Bar instead", Timeout: 90 * time.Second},
+		},
+		{name: "Test empty", lines: []string{"// kit:"}, wantErr: "empty directive"},
+		{name: "Test unknown", lines: []string{"// kit:cache 1m"}, wantErr: "unknown directive"},
+		{name: "Test duplicate", lines: []string{"// kit:timeout 1s", "// kit:timeout 2s"}, wantErr: "duplicate directive"},
+		{name: "Test http method", lines: []string{"// kit:http FETCH /users"}, wantErr: "unknown HTTP method"},
+		{name: "Test http path", lines: []string{"// kit:http GET users"}, wantErr: "does not start with"},
+		{name: "Test http arguments", lines: []string{"// kit:http GET"}, wantErr: "expected"},
+		{name: "Test stream kind", lines: []string{"// kit:grpc-stream both"}, wantErr: "expected"},
+		{name: "Test skip transport", lines: []string{"// kit:skip transport=nats"}, wantErr: "unknown transport"},
+		{name: "Test skip target", lines: []string{"// kit:skip server"}, wantErr: "unknown target"},
+		{name: "Test timeout", lines: []string{"// kit:timeout soon"}, wantErr: "invalid directive"},
+		{name: "Test negative timeout", lines: []string{"// kit:timeout -1s"}, wantErr: "not positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := &ast.CommentGroup{}
+			for _, l := range tt.lines {
+				cg.List = append(cg.List, &ast.Comment{Text: l})
+			}
+			got, err := ParseDirectives(cg, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseDirectives() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDirectives() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDirectives() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileParser_ParseDirectives(t *testing.T) {
+	f, err := NewFileParser().Parse([]byte(`package service
+
+type HelloService interface {
+	// Foo greets.
+	// kit:http GET /foo
+	Foo(ctx context.Context, s string) (rs string, err error)
+	Bar(ctx context.Context) (err error) // kit:skip client
+}
+`))
+	if err != nil {
+		t.Fatalf("FileParser.Parse() error = %v", err)
+	}
+	m := f.Interfaces[0].Methods
+	if m[0].Directives.HTTPMethod != "GET" || m[0].Directives.HTTPPath != "/foo" {
+		t.Errorf("Foo directives = %+v, want kit:http GET /foo", m[0].Directives)
+	}
+	if !m[1].Directives.Skips(SkipClient) || m[1].Directives.Skips(SkipHTTP) {
+		t.Errorf("Bar directives = %+v, want kit:skip client", m[1].Directives)
+	}
+	_, err = NewFileParser().Parse([]byte(`package service
+
+type HelloService interface {
+	// kit:htpp GET /foo
+	Foo(ctx context.Context) (err error)
+}
+`))
+	if err == nil || !strings.Contains(err.Error(), "HelloService.Foo") {
+		t.Errorf("FileParser.Parse() error = %v, want the unknown directive of HelloService.Foo", err)
+	}
+}
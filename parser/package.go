@@ -0,0 +1,347 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// The kinds of the named types of a package.
+const (
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	// KindAlias is a type alias e.x `type A = B`.
+	KindAlias = "alias"
+	// KindEnum is a type with constants of that type e.x `type Status int`.
+	KindEnum = "enum"
+	// KindFunc is a function type e.x `type Middleware func(Service) Service`.
+	KindFunc = "func"
+	// KindNamed is any other type definition e.x `type Names []string`.
+	KindNamed = "named"
+)
+
+// Package represents all the go files of a package.
+type Package struct {
+	Name string
+	// Files are the names of the parsed files.
+	Files []string
+	// Types are the named types of the package by name.
+	Types map[string]*NamedType
+	// Methods are the functions and methods of the package.
+	Methods []Method
+}
+
+// NamedType stores the information of a type declared in a package.
+type NamedType struct {
+	Name    string
+	Kind    string
+	Comment string
+	// File is the file the type is declared in.
+	File string
+	// Type is the type expression of aliases, enums, func and named types.
+	Type      string
+	Struct    Struct
+	Interface Interface
+	FuncType  FuncType
+	// Values are the constants of enums.
+	Values []NamedTypeValue
+}
+
+// PackageParser is the parser used by kit to parse go packages.
+type PackageParser struct {
+	fp *FileParser
+}
+
+// NewPackageParser returns a new package parser.
+func NewPackageParser() *PackageParser {
+	return &PackageParser{fp: NewFileParser()}
+}
+
+// Parse parses the go sources of a package, `files` maps the file names to
+// their content. The test files should not be part of `files`.
+func (pp *PackageParser) Parse(files map[string][]byte) (*Package, error) {
+	pkg := &Package{Types: map[string]*NamedType{}}
+	names := []string{}
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	constants := []NamedTypeValue{}
+	fset := token.NewFileSet()
+	for _, n := range names {
+		pf, err := parser.ParseFile(fset, n, files[n], parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.Name == "" {
+			pkg.Name = pf.Name.Name
+		} else if pkg.Name != pf.Name.Name {
+			return nil, fmt.Errorf("found packages %s and %s in %s", pkg.Name, pf.Name.Name, n)
+		}
+		pkg.Files = append(pkg.Files, n)
+		specs := []ast.Spec{}
+		for _, imp := range pf.Imports {
+			specs = append(specs, imp)
+		}
+		imports := pp.fp.parseImports(specs)
+		for _, d := range pf.Decls {
+			switch dec := d.(type) {
+			case *ast.FuncDecl:
+				pkg.Methods = append(pkg.Methods, pp.parseFunc(dec))
+			case *ast.GenDecl:
+				switch dec.Tok {
+				case token.TYPE:
+					for _, sp := range dec.Specs {
+						if tsp, ok := sp.(*ast.TypeSpec); ok {
+							t, err := pp.parseTypeSpec(tsp, dec.Doc, imports)
+							if err != nil {
+								return nil, fmt.Errorf("%s: %s", n, err)
+							}
+							t.File = n
+							pkg.Types[t.Name] = t
+						}
+					}
+				case token.CONST:
+					constants = append(constants, pp.parseTypedConstants(dec)...)
+				}
+			}
+		}
+	}
+	for _, c := range constants {
+		if t, ok := pkg.Types[c.Type]; ok && (t.Kind == KindNamed || t.Kind == KindEnum) {
+			t.Kind = KindEnum
+			t.Values = append(t.Values, c)
+		}
+	}
+	return pkg, nil
+}
+
+func (pp *PackageParser) parseFunc(dec *ast.FuncDecl) Method {
+	st := NamedTypeValue{}
+	if dec.Recv != nil {
+		if recv := pp.fp.parseFieldListAsNamedTypes(dec.Recv); len(recv) > 0 {
+			st = recv[0]
+		}
+	}
+	m := NewMethod(
+		dec.Name.Name,
+		st,
+		"",
+		pp.fp.parseFieldListAsNamedTypes(dec.Type.Params),
+		pp.fp.parseFieldListAsNamedTypes(dec.Type.Results),
+	)
+	if dec.Doc != nil {
+		m.Comment = dec.Doc.Text()
+	}
+	return m
+}
+
+func (pp *PackageParser) parseTypeSpec(tsp *ast.TypeSpec, doc *ast.CommentGroup, imports []NamedTypeValue) (t *NamedType, err error) {
+	t = &NamedType{Name: tsp.Name.Name, Kind: KindNamed}
+	if tsp.Doc != nil {
+		doc = tsp.Doc
+	}
+	if doc != nil {
+		t.Comment = doc.Text()
+	}
+	if tsp.Assign.IsValid() {
+		t.Kind = KindAlias
+		t.Type = pp.fp.getTypeFromExp(tsp.Type)
+		return t, nil
+	}
+	switch tp := tsp.Type.(type) {
+	case *ast.StructType:
+		t.Kind = KindStruct
+		t.Struct = pp.fp.parseStruct(t.Name, tp)
+		t.Struct.Comment = t.Comment
+	case *ast.InterfaceType:
+		t.Kind = KindInterface
+		if t.Interface, err = pp.fp.parseInterface(t.Name, tp, imports); err != nil {
+			return nil, err
+		}
+		t.Interface.Comment = t.Comment
+	case *ast.FuncType:
+		t.Kind = KindFunc
+		t.FuncType = FuncType{
+			Name:       t.Name,
+			Parameters: pp.fp.parseFieldListAsNamedTypes(tp.Params),
+			Results:    pp.fp.parseFieldListAsNamedTypes(tp.Results),
+		}
+	default:
+		t.Type = pp.fp.getTypeFromExp(tsp.Type)
+	}
+	return t, nil
+}
+
+// parseTypedConstants returns the constants that have a type, in a group the
+// constants without a type and a value have the type of the previous one
+// (e.x `iota` enums).
+func (pp *PackageParser) parseTypedConstants(dec *ast.GenDecl) []NamedTypeValue {
+	constants := []NamedTypeValue{}
+	for _, c := range pp.fp.parseConstants(dec.Specs) {
+		if c.Type != "" {
+			constants = append(constants, c)
+		}
+	}
+	return constants
+}
+
+// Lookup returns the named type of a type expression declared in the package,
+// pointers, slices, variadic and the `qualifier.` prefix are ignored so
+// `*service.User` and `[]User` both return `User`.
+func (p *Package) Lookup(tp string, qualifier string) (*NamedType, bool) {
+	for {
+		switch {
+		case strings.HasPrefix(tp, "*"):
+			tp = tp[1:]
+		case strings.HasPrefix(tp, "[]"):
+			tp = tp[2:]
+		case strings.HasPrefix(tp, "..."):
+			tp = tp[3:]
+		default:
+			if qualifier != "" {
+				tp = strings.TrimPrefix(tp, qualifier+".")
+			}
+			t, ok := p.Types[tp]
+			return t, ok
+		}
+	}
+}
+
+// Interface returns the interface named `name`.
+func (p *Package) Interface(name string) (Interface, bool) {
+	if t, ok := p.Types[name]; ok && t.Kind == KindInterface {
+		return t.Interface, true
+	}
+	return Interface{}, false
+}
+
+// Struct returns the struct named `name`.
+func (p *Package) Struct(name string) (Struct, bool) {
+	if t, ok := p.Types[name]; ok && t.Kind == KindStruct {
+		return t.Struct, true
+	}
+	return Struct{}, false
+}
+
+// HasMethod returns true if the function (or the method of `recv` if it is not
+// empty) named `name` is declared in the package.
+func (p *Package) HasMethod(recv string, name string) bool {
+	for _, m := range p.Methods {
+		if m.Name == name && strings.TrimPrefix(m.Struct.Type, "*") == recv {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandInterface returns the interface with the methods of the interfaces it
+// embeds, they can be declared in the package or in the packages `load`
+// returns by import path (nil if the package can not be loaded e.x the
+// packages outside of the project). The local types of the methods of other
+// packages are qualified with the package qualifier of the embedded interface.
+// The methods declared in the interface take precedence over the embedded ones.
+func (p *Package) ExpandInterface(iface Interface, load func(importPath string) (*Package, error)) (Interface, error) {
+	x := &interfaceExpander{load: load, pkgs: map[string]*Package{}, visited: map[string]bool{"." + iface.Name: true}}
+	methods, err := x.expand(p, iface, "")
+	if err != nil {
+		return Interface{}, err
+	}
+	iface.Methods = methods
+	return iface, nil
+}
+
+type interfaceExpander struct {
+	load    func(importPath string) (*Package, error)
+	pkgs    map[string]*Package
+	visited map[string]bool
+}
+
+// expand returns the methods of `iface` of the package `pkg`, `qualifier` is
+// the package qualifier of `pkg` in the package of the expanded interface.
+func (x *interfaceExpander) expand(pkg *Package, iface Interface, qualifier string) ([]Method, error) {
+	methods := []Method{}
+	names := map[string]bool{}
+	for _, m := range iface.Methods {
+		if qualifier != "" {
+			m = qualifyMethod(m, pkg, qualifier)
+		}
+		names[m.Name] = true
+		methods = append(methods, m)
+	}
+	for _, e := range iface.Embedded {
+		epkg, eq := pkg, qualifier
+		if e.Package != "" {
+			if e.Import == "" {
+				logrus.Warnf("The import of the embedded interface `%s` of `%s` was not found, it is ignored.", e, iface.Name)
+				continue
+			}
+			var err error
+			if epkg, err = x.pkg(e.Import); err != nil {
+				return nil, err
+			}
+			eq = e.Package
+		}
+		key := eq + "." + e.Name
+		if x.visited[key] {
+			continue
+		}
+		x.visited[key] = true
+		var ei Interface
+		ok := false
+		if epkg != nil {
+			ei, ok = epkg.Interface(e.Name)
+		}
+		if !ok {
+			logrus.Warnf("The embedded interface `%s` of `%s` was not found, its methods are ignored.", e, iface.Name)
+			continue
+		}
+		em, err := x.expand(epkg, ei, eq)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range em {
+			if !names[m.Name] {
+				names[m.Name] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+	return methods, nil
+}
+
+func (x *interfaceExpander) pkg(importPath string) (*Package, error) {
+	if p, ok := x.pkgs[importPath]; ok {
+		return p, nil
+	}
+	p, err := x.load(importPath)
+	if err != nil {
+		return nil, err
+	}
+	x.pkgs[importPath] = p
+	return p, nil
+}
+
+// qualifyMethod qualifies the types of the parameters and results of `m` that
+// are declared in `pkg` with `qualifier`.
+func qualifyMethod(m Method, pkg *Package, qualifier string) Method {
+	qualify := func(l []NamedTypeValue) []NamedTypeValue {
+		q := []NamedTypeValue{}
+		for _, p := range l {
+			if t, err := p.TypeExpr(); err == nil {
+				t.qualify(pkg, qualifier)
+				p.Type = t.String()
+			}
+			q = append(q, p)
+		}
+		return q
+	}
+	m.Parameters = qualify(m.Parameters)
+	m.Results = qualify(m.Results)
+	return m
+}
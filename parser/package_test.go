@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageParser_Parse(t *testing.T) {
+	files := map[string][]byte{
+		"service.go": []byte(`package service
+
+import "context"
+
+// UserService describes the service.
+type UserService interface {
+	Get(ctx context.Context, id ID) (u *User, err error)
+}
+
+type basicUserService struct{}
+
+func NewBasicUserService() UserService {
+	return &basicUserService{}
+}
+
+func (b *basicUserService) Get(ctx context.Context, id ID) (u *User, err error) {
+	return nil, nil
+}
+`),
+		"types.go": []byte(`package service
+
+// User is a user.
+type User struct {
+	Name   string
+	Status Status
+}
+
+type ID = string
+
+// Status is the status of a user.
+type Status int
+
+const (
+	StatusActive Status = iota
+	StatusBlocked
+)
+
+const Max = 10
+
+type Names []string
+
+type Middleware func(UserService) UserService
+`),
+	}
+	pkg, err := NewPackageParser().Parse(files)
+	if err != nil {
+		t.Fatalf("PackageParser.Parse() error = %v", err)
+	}
+	if pkg.Name != "service" || !reflect.DeepEqual(pkg.Files, []string{"service.go", "types.go"}) {
+		t.Errorf("PackageParser.Parse() = %v %v, want service with both files", pkg.Name, pkg.Files)
+	}
+	kinds := map[string]string{
+		"UserService":      KindInterface,
+		"basicUserService": KindStruct,
+		"User":             KindStruct,
+		"ID":               KindAlias,
+		"Status":           KindEnum,
+		"Names":            KindNamed,
+		"Middleware":       KindFunc,
+	}
+	for n, k := range kinds {
+		if tp, ok := pkg.Types[n]; !ok || tp.Kind != k {
+			t.Errorf("PackageParser.Parse() type %s = %+v, want kind %s", n, tp, k)
+		}
+	}
+	if len(pkg.Types) != len(kinds) {
+		t.Errorf("PackageParser.Parse() found %d types, want %d", len(pkg.Types), len(kinds))
+	}
+	want := []NamedTypeValue{
+		NewNameTypeValue("StatusActive", "Status", "0"),
+		NewNameTypeValue("StatusBlocked", "Status", "1"),
+	}
+	if got := pkg.Types["Status"].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageParser.Parse() Status values = %v, want %v", got, want)
+	}
+	if got := pkg.Types["User"].File; got != "types.go" {
+		t.Errorf("PackageParser.Parse() User file = %v, want types.go", got)
+	}
+	if tp, ok := pkg.Lookup("*service.User", "service"); !ok || len(tp.Struct.Vars) != 2 {
+		t.Errorf("Package.Lookup() = %+v, want the User struct", tp)
+	}
+	if _, ok := pkg.Lookup("[]string", ""); ok {
+		t.Error("Package.Lookup() found a builtin type")
+	}
+	if !pkg.HasMethod("basicUserService", "Get") || !pkg.HasMethod("", "NewBasicUserService") || pkg.HasMethod("", "Get") {
+		t.Error("Package.HasMethod() did not find the declared functions")
+	}
+}
+
+func TestPackage_ExpandInterface(t *testing.T) {
+	pkg, err := NewPackageParser().Parse(map[string][]byte{"service.go": []byte(`package service
+
+import (
+	"context"
+
+	adm "example.com/p/common/admin"
+)
+
+type HelloService interface {
+	Hello(ctx context.Context) error
+	// Ping is declared here and in the admin interface.
+	Ping(ctx context.Context) error
+	HealthService
+	adm.Admin
+	fmt.Stringer
+}
+
+type HealthService interface {
+	Health(ctx context.Context) (ok bool)
+}
+`)})
+	if err != nil {
+		t.Fatalf("PackageParser.Parse() error = %v", err)
+	}
+	admin, err := NewPackageParser().Parse(map[string][]byte{"admin.go": []byte(`package admin
+
+type Admin interface {
+	Ping(ctx context.Context) error
+	Stats(ctx context.Context, req StatsReq) (rsp *Stats, err error)
+	Base
+}
+
+type Base interface {
+	Version() (v map[string]Version)
+}
+
+type StatsReq struct{}
+type Stats struct{}
+type Version string
+`)})
+	if err != nil {
+		t.Fatalf("PackageParser.Parse() error = %v", err)
+	}
+	iface, _ := pkg.Interface("HelloService")
+	if want := []EmbeddedInterface{{Name: "HealthService"}, {Name: "Admin", Package: "adm", Import: "example.com/p/common/admin"}, {Name: "Stringer", Package: "fmt"}}; !reflect.DeepEqual(iface.Embedded, want) {
+		t.Errorf("Interface.Embedded = %v, want %v", iface.Embedded, want)
+	}
+	got, err := pkg.ExpandInterface(iface, func(importPath string) (*Package, error) {
+		if importPath == "example.com/p/common/admin" {
+			return admin, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Package.ExpandInterface() error = %v", err)
+	}
+	want := []string{
+		"Hello(ctx context.Context) (e0 error)",
+		"Ping(ctx context.Context) (e0 error)",
+		"Health(ctx context.Context) (ok bool)",
+		"Stats(ctx context.Context, req adm.StatsReq) (rsp *adm.Stats, err error)",
+		"Version() (v map[string]adm.Version)",
+	}
+	if len(got.Methods) != len(want) {
+		t.Fatalf("Package.ExpandInterface() = %v, want %v", got.Methods, want)
+	}
+	for i, m := range got.Methods {
+		if s := methodString(m); s != want[i] {
+			t.Errorf("Package.ExpandInterface() method %d = %v, want %v", i, s, want[i])
+		}
+	}
+	if got.Methods[1].Comment == "" {
+		t.Error("Package.ExpandInterface() did not keep the declared method")
+	}
+}
+
+func methodString(m Method) string {
+	l := func(ntv []NamedTypeValue) string {
+		s := []string{}
+		for _, p := range ntv {
+			s = append(s, p.Name+" "+p.Type)
+		}
+		return strings.Join(s, ", ")
+	}
+	return m.Name + "(" + l(m.Parameters) + ") (" + l(m.Results) + ")"
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
@@ -64,7 +68,9 @@ func (fp *FileParser) Parse(src []byte) (*File, error) {
 			case token.VAR:
 				f.Vars = append(f.Vars, fp.parseVars(dec.Specs)...)
 			case token.TYPE:
-				fp.parseType(dec.Specs, &f)
+				if err := fp.parseType(dec.Specs, &f); err != nil {
+					return nil, err
+				}
 			default:
 				logrus.Info("Skipping unknown Token Type")
 			}
@@ -73,7 +79,7 @@ func (fp *FileParser) Parse(src []byte) (*File, error) {
 	//fmt.Println(f.String())
 	return &f, nil
 }
-func (fp *FileParser) parseType(ds []ast.Spec, f *File) {
+func (fp *FileParser) parseType(ds []ast.Spec, f *File) error {
 	for _, sp := range ds {
 		tsp, ok := sp.(*ast.TypeSpec)
 		if !ok {
@@ -82,15 +88,13 @@ func (fp *FileParser) parseType(ds []ast.Spec, f *File) {
 		}
 		switch tsp.Type.(type) {
 		case *ast.InterfaceType:
-			ift := tsp.Type.(*ast.InterfaceType)
-			mth := fp.parseFieldListAsMethods(ift.Methods)
-			intr := NewInterface(tsp.Name.Name, mth)
-			intr.Methods = mth
+			intr, err := fp.parseInterface(tsp.Name.Name, tsp.Type.(*ast.InterfaceType), f.Imports)
+			if err != nil {
+				return err
+			}
 			f.Interfaces = append(f.Interfaces, intr)
 		case *ast.StructType:
-			st := tsp.Type.(*ast.StructType)
-			str := NewStruct(tsp.Name.Name, fp.parseFieldListAsNamedTypes(st.Fields))
-			f.Structures = append(f.Structures, str)
+			f.Structures = append(f.Structures, fp.parseStruct(tsp.Name.Name, tsp.Type.(*ast.StructType)))
 		case *ast.FuncType:
 			st := tsp.Type.(*ast.FuncType)
 			f.FuncType = FuncType{
@@ -102,6 +106,7 @@ func (fp *FileParser) parseType(ds []ast.Spec, f *File) {
 			logrus.Info("Skipping unknown type")
 		}
 	}
+	return nil
 }
 func (fp *FileParser) parseImports(ds []ast.Spec) []NamedTypeValue {
 	imports := []NamedTypeValue{}
@@ -122,6 +127,9 @@ func (fp *FileParser) parseImports(ds []ast.Spec) []NamedTypeValue {
 	}
 	return imports
 }
+
+// parseVars returns every variable of the specs, a variable has a value if
+// the spec has one value per name (not e.x `var a, b = f()`).
 func (fp *FileParser) parseVars(ds []ast.Spec) []NamedTypeValue {
 	vars := []NamedTypeValue{}
 	for _, sp := range ds {
@@ -130,54 +138,111 @@ func (fp *FileParser) parseVars(ds []ast.Spec) []NamedTypeValue {
 			logrus.Debug("Var spec is not ValueSpec type, odd, skipping")
 			continue
 		}
-		tp, ok := vsp.Type.(*ast.Ident)
-		if len(vsp.Values) > 0 {
-			fst := token.NewFileSet()
-			bt := bytes.NewBufferString("")
-			err := format.Node(bt, fst, vsp.Values[0])
-			bd := bt.String()
-			if err != nil {
-				logrus.Panic(err)
-			}
-			if !ok {
-				vars = append(vars, NewNameTypeValue(vsp.Names[0].Name, "", bd))
+		tp := ""
+		if vsp.Type != nil {
+			tp = fp.getTypeFromExp(vsp.Type)
+		}
+		for i, n := range vsp.Names {
+			if n.Name == "_" {
 				continue
 			}
-			vars = append(vars, NewNameTypeValue(tp.Name, vsp.Names[0].Name, bd))
-		} else {
-			if !ok {
-				vars = append(vars, NewNameType(vsp.Names[0].Name, ""))
-				continue
+			vl := ""
+			if len(vsp.Values) == len(vsp.Names) {
+				vl = nodeString(vsp.Values[i])
 			}
-			vars = append(vars, NewNameType(tp.Name, vsp.Names[0].Name))
+			vars = append(vars, NewNameTypeValue(n.Name, tp, vl))
 		}
 	}
 	return vars
 }
+
+// parseConstants returns every constant of the specs of a const declaration,
+// the specs without a type and values repeat the type and the values of the
+// previous spec. The values are evaluated when they only use literals and
+// `iota` e.x `1001` for the second constant of `iota + 1000`, see constValue.
 func (fp *FileParser) parseConstants(ds []ast.Spec) []NamedTypeValue {
 	constants := []NamedTypeValue{}
-	for _, sp := range ds {
+	var tp ast.Expr
+	var values []ast.Expr
+	for i, sp := range ds {
 		vsp, ok := sp.(*ast.ValueSpec)
 		if !ok {
 			logrus.Debug("Constant spec is not ValueSpec type, odd, skipping")
 			continue
 		}
-		fst := token.NewFileSet()
-		bt := bytes.NewBufferString("")
-		err := format.Node(bt, fst, vsp.Values[0])
-		bd := bt.String()
-		if err != nil {
-			logrus.Panic(err)
+		if vsp.Type != nil || len(vsp.Values) > 0 {
+			tp, values = vsp.Type, vsp.Values
 		}
-		tp, ok := vsp.Type.(*ast.Ident)
-		if !ok {
-			constants = append(constants, NewNameTypeValue(vsp.Names[0].Name, "", bd))
-			continue
+		t := ""
+		if tp != nil {
+			t = fp.getTypeFromExp(tp)
+		}
+		for j, n := range vsp.Names {
+			if n.Name == "_" {
+				continue
+			}
+			vl := ""
+			if j < len(values) {
+				vl = constValue(values[j], i)
+			}
+			constants = append(constants, NewNameTypeValue(n.Name, t, vl))
 		}
-		constants = append(constants, NewNameTypeValue(tp.Name, vsp.Names[0].Name, bd))
 	}
 	return constants
 }
+
+// constValue returns the value of the constant expression `e` of the spec
+// `iota` of a const declaration. The expressions that only use literals are
+// evaluated, the others are kept with `iota` replaced.
+func constValue(e ast.Expr, iota int) string {
+	// replace iota in a copy of the expression, the repeated specs share it.
+	cp, err := parser.ParseExpr(nodeString(e))
+	if err != nil {
+		return nodeString(e)
+	}
+	ast.Inspect(cp, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			id.Name = strconv.Itoa(iota)
+		}
+		return true
+	})
+	src := nodeString(cp)
+	if _, ok := cp.(*ast.BasicLit); ok {
+		// a literal is its value as it is written.
+		return src
+	}
+	tv, err := types.Eval(token.NewFileSet(), nil, token.NoPos, src)
+	if err != nil || tv.Value == nil {
+		// e.x `Base + iota`, the other constants are not known here.
+		return src
+	}
+	if v, ok := exactConstant(tv.Value); ok {
+		return v
+	}
+	return src
+}
+
+// exactConstant returns the go source of the constant value `v`, it returns
+// false if it can not be written exactly (e.x `1.0 / 3`).
+func exactConstant(v constant.Value) (string, bool) {
+	switch v.Kind() {
+	case constant.Float:
+		f, exact := constant.Float64Val(v)
+		if !exact {
+			return "", false
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// keep the constant a float.
+			s += ".0"
+		}
+		return s, true
+	case constant.Complex, constant.Unknown:
+		return "", false
+	}
+	return v.ExactString(), true
+}
+
 func (fp *FileParser) parseFieldListAsNamedTypes(list *ast.FieldList) []NamedTypeValue {
 	ntv := []NamedTypeValue{}
 	if list != nil {
@@ -192,13 +257,7 @@ func (fp *FileParser) parseFieldListAsNamedTypes(list *ast.FieldList) []NamedTyp
 			}
 			if len(names) == 0 {
 				// Anonymous named type, give it a default name
-				if strings.HasPrefix(typ, "[]") {
-					names = append(names, utils.ToLowerFirstCamelCase(typ[2:3]+fmt.Sprintf("%d", i)))
-				} else if strings.HasPrefix(typ, "*") {
-					names = append(names, utils.ToLowerFirstCamelCase(typ[1:2]+fmt.Sprintf("%d", i)))
-				} else {
-					names = append(names, utils.ToLowerFirstCamelCase(typ[:1]+fmt.Sprintf("%d", i)))
-				}
+				names = append(names, utils.ToLowerFirstCamelCase(defaultName(p.Type)+fmt.Sprintf("%d", i)))
 			}
 			for _, name := range names {
 				namedType := NewNameType(name, typ)
@@ -209,54 +268,100 @@ func (fp *FileParser) parseFieldListAsNamedTypes(list *ast.FieldList) []NamedTyp
 	}
 	return ntv
 }
+func (fp *FileParser) parseStruct(name string, st *ast.StructType) Struct {
+	str := NewStruct(name, fp.parseFieldListAsNamedTypes(st.Fields))
+	str.Fields = newFields(st.Fields)
+	return str
+}
 func (fp *FileParser) getTypeFromExp(e ast.Expr) string {
-	tp := ""
-	switch k := e.(type) {
-	case *ast.Ident:
-		tp = k.Name
-	case *ast.SelectorExpr:
-		logrus.Debug("Type Selector, i.e. a third-party type")
-		selectorIdent := fp.getTypeFromExp(k.X)
-		tp = fmt.Sprintf(fmt.Sprintf("%s.%s", selectorIdent, k.Sel.Name))
-	case *ast.StarExpr:
-		starIndent := fp.getTypeFromExp(k.X)
-		tp = "*" + starIndent
-	case *ast.ArrayType:
-		arrIndent := fp.getTypeFromExp(k.Elt)
-		tp = "[]" + arrIndent
-	case *ast.MapType:
-		key := fp.getTypeFromExp(k.Key)
-		value := fp.getTypeFromExp(k.Value)
-		tp = "map[" + key + "]" + value
-	case *ast.InterfaceType:
-		tp = "interface{}"
-	case *ast.Ellipsis:
-		t := fp.getTypeFromExp(k.Elt)
-		tp = "..." + t
-	default:
-		logrus.Info("Type Expresion not supported")
-		return ""
+	return NewType(e).String()
+}
+func (fp *FileParser) parseInterface(name string, it *ast.InterfaceType, imports []NamedTypeValue) (Interface, error) {
+	mth, err := fp.parseFieldListAsMethods(it.Methods)
+	if err != nil {
+		return Interface{}, fmt.Errorf("%s.%s", name, err)
 	}
-	return tp
+	intr := NewInterface(name, mth)
+	for _, p := range it.Methods.List {
+		if len(p.Names) > 0 {
+			continue
+		}
+		t := NewType(p.Type)
+		if t.Kind != KindIdent || len(t.TypeArgs) > 0 {
+			logrus.Infof("Skipping the embedded type `%s` of `%s`", t, name)
+			continue
+		}
+		e := EmbeddedInterface{Name: t.Name, Package: t.Package}
+		if e.Package != "" {
+			e.Import = importOf(e.Package, imports)
+		}
+		intr.Embedded = append(intr.Embedded, e)
+	}
+	return intr, nil
 }
-func (fp *FileParser) parseFieldListAsMethods(list *ast.FieldList) []Method {
+
+// importOf returns the import path of the package qualifier `name`, the
+// packages imported without a name are expected to be named after the last
+// element of their path (without the version suffix e.x `yaml` for
+// `gopkg.in/yaml.v2`).
+func importOf(name string, imports []NamedTypeValue) string {
+	for _, imp := range imports {
+		pth, err := strconv.Unquote(imp.Type)
+		if err != nil {
+			continue
+		}
+		if imp.Name == name {
+			return pth
+		}
+		base := path.Base(pth)
+		if i := strings.Index(base, "."); i > 0 {
+			base = base[:i]
+		}
+		if imp.Name == "" && base == name {
+			return pth
+		}
+	}
+	return ""
+}
+
+// parseFieldListAsMethods parses the methods of an interface, the errors of the
+// `kit:` directives start with the name of the method.
+func (fp *FileParser) parseFieldListAsMethods(list *ast.FieldList) ([]Method, error) {
 	mth := []Method{}
 	if list != nil {
 		for _, p := range list.List {
 			switch t := p.Type.(type) {
 			case *ast.FuncType:
 				m := Method{
-					Name: p.Names[0].Name,
+					Name:    p.Names[0].Name,
+					Comment: p.Doc.Text(),
 				}
+				if m.Comment == "" {
+					// e.x `Foo(ctx context.Context) error // Foo does foo.`
+					m.Comment = p.Comment.Text()
+				}
+				d, err := ParseDirectives(p.Doc, p.Comment)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", m.Name, err)
+				}
+				m.Directives = d
 				m.Parameters = fp.parseFieldListAsNamedTypes(t.Params)
 				m.Results = fp.parseFieldListAsNamedTypes(t.Results)
 				mth = append(mth, m)
-			default:
-				logrus.Info("Skipping unknown type")
 			}
 		}
 	}
-	return mth
+	return mth, nil
+}
+
+// defaultName returns the first letter of the named type of `e` (or of its
+// kind e.x `c` for channels) to name anonymous parameters.
+func defaultName(e ast.Expr) string {
+	t := NewType(e).Base()
+	if t.Kind == KindIdent && t.Name != "" {
+		return t.Name[:1]
+	}
+	return t.Kind[:1]
 }
 
 // NewFileParser returns a new parser.
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"go/parser"
+	"reflect"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -136,3 +139,167 @@ func TestFileParser_ParseMiddlewareFuncType(t *testing.T) {
 		})
 	})
 }
+
+func TestFileParser_ParseMethodComment(t *testing.T) {
+	f, err := NewFileParser().Parse([]byte(`package main
+
+type S interface {
+	// Foo does foo.
+	//
+	// It never fails.
+	Foo(ctx context.Context) error
+	Bar(ctx context.Context) error // Bar does bar.
+	Baz(ctx context.Context) error
+}
+`))
+	if err != nil {
+		t.Fatalf("FileParser.Parse() error = %v", err)
+	}
+	want := []string{"Foo does foo.\n\nIt never fails.\n", "Bar does bar.\n", ""}
+	for i, m := range f.Interfaces[0].Methods {
+		if m.Comment != want[i] {
+			t.Errorf("method %s comment = %q, want %q", m.Name, m.Comment, want[i])
+		}
+	}
+}
+
+func TestFileParser_ParseStructFields(t *testing.T) {
+	f, err := NewFileParser().Parse([]byte("package main\n\n" +
+		"type User struct {\n" +
+		"\t// Name of the user.\n" +
+		"\tName string `json:\"name,omitempty\" validate:\"required\"`\n" +
+		"\tA, B int // A and B.\n" +
+		"\t*pb.Base\n" +
+		"\tMeta `json:\"meta\"`\n" +
+		"\tSkip string `json:\"-\"`\n" +
+		"\tDash string `json:\"-,\"`\n" +
+		"\tprivate bool `json:\"private\"`\n" +
+		"}\n"))
+	if err != nil {
+		t.Fatalf("FileParser.Parse() error = %v", err)
+	}
+	want := []struct {
+		name, tp, tag, doc string
+		jsonName           string
+		encoded            bool
+	}{
+		{"Name", "string", "`json:\"name,omitempty\" validate:\"required\"`", "Name of the user.\n", "name", true},
+		{"A", "int", "", "A and B.\n", "A", true},
+		{"B", "int", "", "A and B.\n", "B", true},
+		// the fields of an embedded struct are promoted.
+		{"", "*pb.Base", "", "", "", true},
+		{"", "Meta", "`json:\"meta\"`", "", "meta", true},
+		{"Skip", "string", "`json:\"-\"`", "", "", false},
+		{"Dash", "string", "`json:\"-,\"`", "", "-", true},
+		{"private", "bool", "`json:\"private\"`", "", "", false},
+	}
+	st := f.Structures[0]
+	if len(st.Fields) != len(want) || len(st.Vars) != len(want) {
+		t.Fatalf("Struct.Fields = %v, want %d fields", st.Fields, len(want))
+	}
+	for i, w := range want {
+		fd := st.Fields[i]
+		if fd.Name != w.name || fd.Type.String() != w.tp || fd.Tag != w.tag || fd.Doc != w.doc {
+			t.Errorf("Struct.Fields[%d] = %q %q %q %q, want %q %q %q %q", i, fd.Name, fd.Type, fd.Tag, fd.Doc, w.name, w.tp, w.tag, w.doc)
+		}
+		if name, ok := fd.JSONName(); name != w.jsonName || ok != w.encoded {
+			t.Errorf("Field.JSONName() of %d = %q %v, want %q %v", i, name, ok, w.jsonName, w.encoded)
+		}
+	}
+	if v := st.Fields[0].StructTag().Get("validate"); v != "required" {
+		t.Errorf("Field.StructTag() validate = %v, want required", v)
+	}
+}
+
+func TestFileParser_ParseGroupedConstantsVars(t *testing.T) {
+	f, err := NewFileParser().Parse([]byte(`package utils
+
+const (
+	KErrCode_OK = comm.KErrCode_OK
+
+	KErrCodeNotFound ErrCode = iota + 1000
+	KErrCodeDenied
+	_
+	KErrCodeTimeout
+	KErrCodeMax = comm.KErrCodeMax
+)
+
+const A, B = 1, "b"
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+var (
+	x, y    int
+	m, n    = 1, 2
+	r, w    = io.Pipe()
+	names []string
+)
+`))
+	if err != nil {
+		t.Fatalf("FileParser.Parse() error = %v", err)
+	}
+	wantConstants := []NamedTypeValue{
+		NewNameTypeValue("KErrCode_OK", "", "comm.KErrCode_OK"),
+		NewNameTypeValue("KErrCodeNotFound", "ErrCode", "1001"),
+		NewNameTypeValue("KErrCodeDenied", "ErrCode", "1002"),
+		NewNameTypeValue("KErrCodeTimeout", "ErrCode", "1004"),
+		NewNameTypeValue("KErrCodeMax", "", "comm.KErrCodeMax"),
+		NewNameTypeValue("A", "", "1"),
+		NewNameTypeValue("B", "", `"b"`),
+		NewNameTypeValue("KB", "", "1024"),
+		NewNameTypeValue("MB", "", "1048576"),
+	}
+	if !reflect.DeepEqual(f.Constants, wantConstants) {
+		t.Errorf("FileParser.Parse() constants = %v, want %v", f.Constants, wantConstants)
+	}
+	wantVars := []NamedTypeValue{
+		NewNameType("x", "int"),
+		NewNameType("y", "int"),
+		NewNameTypeValue("m", "", "1"),
+		NewNameTypeValue("n", "", "2"),
+		NewNameType("r", ""),
+		NewNameType("w", ""),
+		NewNameType("names", "[]string"),
+	}
+	if !reflect.DeepEqual(f.Vars, wantVars) {
+		t.Errorf("FileParser.Parse() vars = %v, want %v", f.Vars, wantVars)
+	}
+}
+
+func Test_constValue(t *testing.T) {
+	tests := []struct {
+		expr string
+		iota int
+		want string
+	}{
+		{expr: "iota", iota: 3, want: "3"},
+		{expr: "iota * 1.5", iota: 1, want: "1.5"},
+		{expr: "Base + iota", iota: 2, want: "Base + 2"},
+		{expr: "Status(iota)", iota: 1, want: "Status(1)"},
+		{expr: "1 << 3", iota: 1, want: "8"},
+		{expr: "0x10", iota: 1, want: "0x10"},
+		{expr: "1 << 100", iota: 0, want: "1267650600228229401496703205376"},
+		{expr: "2.0 * iota", iota: 1, want: "2.0"},
+		{expr: "0.5 + 0.25", iota: 0, want: "0.75"},
+		{expr: "1.5 * 4", iota: 0, want: "6.0"},
+		{expr: "1e30 * 10", iota: 0, want: "1e30 * 10"},
+		{expr: "1.0 / 3", iota: 0, want: "1.0 / 3"},
+		{expr: "(1 << 62) * 4.0 / 3", iota: 0, want: "(1 << 62) * 4.0 / 3"},
+		{expr: `"a" + "` + strings.Repeat("b", 80) + `"`, iota: 0, want: `"a` + strings.Repeat("b", 80) + `"`},
+		{expr: "!false", iota: 0, want: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := constValue(e, tt.iota); got != tt.want {
+				t.Errorf("constValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// ParseProtoDocs parses the proto source and returns the comments of its
+// services, rpcs and messages by key e.x `service Hello`, `rpc Foo` and
+// `message FooReq`, see ProtoCommentText.
+func ParseProtoDocs(src string) (map[string]string, error) {
+	def, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]string{}
+	for _, e := range def.Elements {
+		switch el := e.(type) {
+		case *proto.Service:
+			docs["service "+el.Name] = ProtoCommentText(el.Comment)
+			for _, se := range el.Elements {
+				if r, ok := se.(*proto.RPC); ok {
+					docs["rpc "+r.Name] = ProtoCommentText(r.Comment)
+				}
+			}
+		case *proto.Message:
+			docs["message "+el.Name] = ProtoCommentText(el.Comment)
+		}
+	}
+	return docs, nil
+}
+
+// ProtoCommentText returns the text of a proto comment like
+// ast.CommentGroup.Text does for go, the first space of the lines and the
+// leading and trailing empty lines are removed.
+func ProtoCommentText(c *proto.Comment) string {
+	if c == nil {
+		return ""
+	}
+	lines := []string{}
+	for _, l := range c.Lines {
+		lines = append(lines, strings.TrimRight(strings.TrimPrefix(l, " "), " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
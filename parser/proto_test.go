@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProtoDocs(t *testing.T) {
+	got, err := ParseProtoDocs(`syntax = "proto3";
+
+// Hello greets.
+service Hello {
+    // Foo does foo.
+    // It never fails.
+    rpc Foo (FooReq) returns (FooRsp);
+    rpc Bar (BarReq) returns (BarRsp);
+}
+
+// Foo
+//
+// Foo does foo.
+message FooReq {}
+`)
+	if err != nil {
+		t.Fatalf("ParseProtoDocs() error = %v", err)
+	}
+	want := map[string]string{
+		"service Hello":  "Hello greets.\n",
+		"rpc Foo":        "Foo does foo.\nIt never fails.\n",
+		"rpc Bar":        "",
+		"message FooReq": "Foo\n\nFoo does foo.\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseProtoDocs() = %q, want %q", got, want)
+	}
+}
+
+func TestParseDeclDocs(t *testing.T) {
+	got, err := ParseDeclDocs(`package a
+
+// A does a.
+func A() {}
+
+type (
+	// B is b.
+	B struct{}
+	C int
+)
+
+// S does s.
+func (s *S) Do() {}
+`)
+	if err != nil {
+		t.Fatalf("ParseDeclDocs() error = %v", err)
+	}
+	want := map[string]string{"A": "A does a.\n", "B": "B is b.\n", "C": "", "S.Do": "S does s.\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDeclDocs() = %q, want %q", got, want)
+	}
+}
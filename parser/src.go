@@ -19,6 +19,9 @@ type Struct struct {
 	Name    string
 	Comment string
 	Vars    []NamedTypeValue
+	// Fields are the fields of the struct with their tags and docs, embedded
+	// fields have no name.
+	Fields []Field
 }
 
 // FuncType is used to store e.x (type Middleware func(a)a) types
@@ -33,6 +36,28 @@ type Interface struct {
 	Name    string
 	Comment string
 	Methods []Method
+	// Embedded are the interfaces embedded in the interface, see
+	// Package.ExpandInterface.
+	Embedded []EmbeddedInterface
+}
+
+// EmbeddedInterface stores the information of an interface embedded in
+// another interface.
+type EmbeddedInterface struct {
+	// Name and Package are the name and the package qualifier of the interface
+	// e.x `Admin` and `common` for `common.Admin`.
+	Name    string
+	Package string
+	// Import is the import path of the package qualifier.
+	Import string
+}
+
+// String returns the type expression of the embedded interface.
+func (e EmbeddedInterface) String() string {
+	if e.Package == "" {
+		return e.Name
+	}
+	return e.Package + "." + e.Name
 }
 
 // Method stores go method information.
@@ -43,6 +68,8 @@ type Method struct {
 	Body       string
 	Parameters []NamedTypeValue
 	Results    []NamedTypeValue
+	// Directives are the `kit:` directives of the interface methods.
+	Directives Directives
 }
 
 // NamedTypeValue  is used to store any type of name type = value ( e.x  var a = 2)
@@ -60,6 +87,11 @@ func NewNameType(name string, tp string) NamedTypeValue {
 	}
 }
 
+// TypeExpr returns the model of the type of the named type.
+func (n NamedTypeValue) TypeExpr() (*Type, error) {
+	return ParseType(n.Type)
+}
+
 // NewNameTypeValue create a NamedTypeValue with a value.
 func NewNameTypeValue(name string, tp string, vl string) NamedTypeValue {
 	return NamedTypeValue{
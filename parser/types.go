@@ -0,0 +1,340 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The kinds of type expressions, struct, interface and func types use the kinds
+// of the named types.
+const (
+	// KindIdent is a named type e.x `int` or `context.Context`.
+	KindIdent    = "ident"
+	KindPointer  = "pointer"
+	KindSlice    = "slice"
+	KindArray    = "array"
+	KindMap      = "map"
+	KindChan     = "chan"
+	KindVariadic = "variadic"
+)
+
+// Type is the structured model of a type expression.
+type Type struct {
+	Kind string
+	// Name and Package are the name and the package qualifier of identifiers,
+	// `context.Context` has the name `Context` and the package `context`.
+	Name    string
+	Package string
+	// TypeArgs are the type arguments of instantiated generic types.
+	TypeArgs []*Type
+	// Elem is the element type of pointers, slices, arrays, channels and
+	// variadic parameters.
+	Elem *Type
+	// Key and Value are the types of maps.
+	Key   *Type
+	Value *Type
+	// Len is the length of arrays e.x `4` or `N`.
+	Len string
+	// Dir is the direction of channels.
+	Dir ast.ChanDir
+	// Params and Results are the parameters of func types, anonymous
+	// parameters have no name.
+	Params  []Field
+	Results []Field
+	// Fields are the fields of struct types, embedded fields have no name.
+	Fields []Field
+	// Methods are the methods and the embedded types of interface types.
+	Methods []Field
+}
+
+// Field is a named type in a parameter list, a struct or an interface.
+type Field struct {
+	Name string
+	Type *Type
+	// Tag is the tag of struct fields.
+	Tag string
+	// Doc is the doc comment of struct fields, or their line comment if they
+	// have no doc.
+	Doc string
+}
+
+// StructTag returns the tag of the struct field without its quotes.
+func (f Field) StructTag() reflect.StructTag {
+	tag, err := strconv.Unquote(f.Tag)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(tag)
+}
+
+// JSONName returns the name of the struct field in its JSON encoding, it
+// returns false if encoding/json ignores the field. An embedded field without
+// a json name has no name of its own: encoding/json promotes the fields of an
+// embedded struct, the caller knows if the type is one.
+func (f Field) JSONName() (string, bool) {
+	if f.Name != "" && !ast.IsExported(f.Name) {
+		// unexported fields are not encoded, even with a tag.
+		return "", false
+	}
+	tag := f.StructTag().Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// ParseType parses the type expression `tp`, `tp` can be the type of a
+// variadic parameter e.x `...string`.
+func ParseType(tp string) (*Type, error) {
+	if strings.HasPrefix(tp, "...") {
+		t, err := ParseType(tp[3:])
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: KindVariadic, Elem: t}, nil
+	}
+	e, err := parser.ParseExpr(tp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type `%s`: %s", tp, err)
+	}
+	return NewType(e), nil
+}
+
+// NewType returns the model of the type expression `e`, parenthesised types
+// are unwrapped.
+func NewType(e ast.Expr) *Type {
+	switch k := e.(type) {
+	case *ast.Ident:
+		return &Type{Kind: KindIdent, Name: k.Name}
+	case *ast.SelectorExpr:
+		t := NewType(k.X)
+		return &Type{Kind: KindIdent, Name: k.Sel.Name, Package: t.String()}
+	case *ast.ParenExpr:
+		return NewType(k.X)
+	case *ast.StarExpr:
+		return &Type{Kind: KindPointer, Elem: NewType(k.X)}
+	case *ast.ArrayType:
+		if k.Len == nil {
+			return &Type{Kind: KindSlice, Elem: NewType(k.Elt)}
+		}
+		return &Type{Kind: KindArray, Len: nodeString(k.Len), Elem: NewType(k.Elt)}
+	case *ast.Ellipsis:
+		return &Type{Kind: KindVariadic, Elem: NewType(k.Elt)}
+	case *ast.MapType:
+		return &Type{Kind: KindMap, Key: NewType(k.Key), Value: NewType(k.Value)}
+	case *ast.ChanType:
+		return &Type{Kind: KindChan, Dir: k.Dir, Elem: NewType(k.Value)}
+	case *ast.FuncType:
+		return &Type{Kind: KindFunc, Params: newFields(k.Params), Results: newFields(k.Results)}
+	case *ast.StructType:
+		return &Type{Kind: KindStruct, Fields: newFields(k.Fields)}
+	case *ast.InterfaceType:
+		return &Type{Kind: KindInterface, Methods: newFields(k.Methods)}
+	case *ast.IndexExpr:
+		t := NewType(k.X)
+		t.TypeArgs = []*Type{NewType(k.Index)}
+		return t
+	case *ast.IndexListExpr:
+		t := NewType(k.X)
+		for _, a := range k.Indices {
+			t.TypeArgs = append(t.TypeArgs, NewType(a))
+		}
+		return t
+	}
+	// not a type expression (e.x a constraint union), keep the source.
+	return &Type{Kind: KindIdent, Name: nodeString(e)}
+}
+
+func newFields(list *ast.FieldList) []Field {
+	fields := []Field{}
+	if list == nil {
+		return fields
+	}
+	for _, f := range list.List {
+		t := NewType(f.Type)
+		tag := ""
+		if f.Tag != nil {
+			tag = f.Tag.Value
+		}
+		doc := f.Doc.Text()
+		if doc == "" {
+			// e.x `Name string // Name of the user.`
+			doc = f.Comment.Text()
+		}
+		if len(f.Names) == 0 {
+			fields = append(fields, Field{Type: t, Tag: tag, Doc: doc})
+		}
+		for _, n := range f.Names {
+			fields = append(fields, Field{Name: n.Name, Type: t, Tag: tag, Doc: doc})
+		}
+	}
+	return fields
+}
+
+// String returns the go source of the type.
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindIdent:
+		s := t.Name
+		if t.Package != "" {
+			s = t.Package + "." + s
+		}
+		if len(t.TypeArgs) > 0 {
+			args := []string{}
+			for _, a := range t.TypeArgs {
+				args = append(args, a.String())
+			}
+			s += "[" + strings.Join(args, ", ") + "]"
+		}
+		return s
+	case KindPointer:
+		return "*" + t.Elem.String()
+	case KindSlice:
+		return "[]" + t.Elem.String()
+	case KindArray:
+		return "[" + t.Len + "]" + t.Elem.String()
+	case KindVariadic:
+		return "..." + t.Elem.String()
+	case KindMap:
+		return "map[" + t.Key.String() + "]" + t.Value.String()
+	case KindChan:
+		elem := t.Elem.String()
+		if t.Dir == ast.SEND|ast.RECV && t.Elem.Kind == KindChan && t.Elem.Dir == ast.RECV {
+			// `chan <-chan int` would be a send only channel of `chan int`.
+			elem = "(" + elem + ")"
+		}
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + elem
+		case ast.RECV:
+			return "<-chan " + elem
+		}
+		return "chan " + elem
+	case KindFunc:
+		return "func" + t.signature()
+	case KindStruct:
+		if len(t.Fields) == 0 {
+			return "struct{}"
+		}
+		fields := []string{}
+		for _, f := range t.Fields {
+			s := f.Type.String()
+			if f.Name != "" {
+				s = f.Name + " " + s
+			}
+			if f.Tag != "" {
+				s += " " + f.Tag
+			}
+			fields = append(fields, s)
+		}
+		return "struct{ " + strings.Join(fields, "; ") + " }"
+	case KindInterface:
+		if len(t.Methods) == 0 {
+			return "interface{}"
+		}
+		methods := []string{}
+		for _, m := range t.Methods {
+			if m.Name == "" {
+				methods = append(methods, m.Type.String())
+			} else {
+				methods = append(methods, m.Name+m.Type.signature())
+			}
+		}
+		return "interface{ " + strings.Join(methods, "; ") + " }"
+	}
+	return t.Name
+}
+
+// signature returns the parameters and the results of a func type.
+func (t *Type) signature() string {
+	s := "(" + fieldList(t.Params) + ")"
+	switch {
+	case len(t.Results) == 1 && t.Results[0].Name == "":
+		s += " " + t.Results[0].Type.String()
+	case len(t.Results) > 0:
+		s += " (" + fieldList(t.Results) + ")"
+	}
+	return s
+}
+
+func fieldList(fields []Field) string {
+	l := []string{}
+	for _, f := range fields {
+		if f.Name == "" {
+			l = append(l, f.Type.String())
+		} else {
+			l = append(l, f.Name+" "+f.Type.String())
+		}
+	}
+	return strings.Join(l, ", ")
+}
+
+// Base returns the named type of pointers, slices, arrays and variadic
+// parameters e.x `User` for `*[]User`, or the type itself.
+func (t *Type) Base() *Type {
+	for t.Kind == KindPointer || t.Kind == KindSlice || t.Kind == KindArray || t.Kind == KindVariadic {
+		t = t.Elem
+	}
+	return t
+}
+
+// Find returns the first type of kind `kind` in the type, its elements, keys,
+// values and struct fields (e.x the channel of `map[string]chan int`).
+func (t *Type) Find(kind string) (*Type, bool) {
+	if t == nil {
+		return nil, false
+	}
+	if t.Kind == kind {
+		return t, true
+	}
+	for _, c := range []*Type{t.Elem, t.Key, t.Value} {
+		if f, ok := c.Find(kind); ok {
+			return f, true
+		}
+	}
+	for _, fd := range t.Fields {
+		if f, ok := fd.Type.Find(kind); ok {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// qualify adds the package qualifier `qualifier` to the identifiers of the
+// types declared in `pkg`.
+func (t *Type) qualify(pkg *Package, qualifier string) {
+	if t == nil {
+		return
+	}
+	if t.Kind == KindIdent && t.Package == "" {
+		if _, ok := pkg.Types[t.Name]; ok {
+			t.Package = qualifier
+		}
+	}
+	for _, c := range append([]*Type{t.Elem, t.Key, t.Value}, t.TypeArgs...) {
+		c.qualify(pkg, qualifier)
+	}
+	for _, l := range [][]Field{t.Params, t.Results, t.Fields, t.Methods} {
+		for _, f := range l {
+			f.Type.qualify(pkg, qualifier)
+		}
+	}
+}
+
+func nodeString(n ast.Node) string {
+	bt := bytes.NewBufferString("")
+	if err := format.Node(bt, token.NewFileSet(), n); err != nil {
+		return ""
+	}
+	return bt.String()
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		tp   string
+		want string
+		kind string
+		// base is the named type of the pointers, slices and arrays.
+		base string
+	}{
+		{tp: "int", kind: KindIdent, base: "int"},
+		{tp: "context.Context", kind: KindIdent, base: "context.Context"},
+		{tp: "*[]*pb.User", kind: KindPointer, base: "pb.User"},
+		{tp: "[4]byte", kind: KindArray, base: "byte"},
+		{tp: "[N]User", kind: KindArray, base: "User"},
+		{tp: "...string", kind: KindVariadic, base: "string"},
+		{tp: "map[string][]pb.User", kind: KindMap},
+		{tp: "chan int", kind: KindChan},
+		{tp: "<-chan int", kind: KindChan},
+		{tp: "chan<- error", kind: KindChan},
+		{tp: "chan (<-chan int)", kind: KindChan},
+		{tp: "func(int, string) error", kind: KindFunc},
+		{tp: "func(a, b int) (n int, err error)", want: "func(a int, b int) (n int, err error)", kind: KindFunc},
+		{tp: "struct{}", kind: KindStruct},
+		{tp: "struct{ Name string `json:\"name\"`; *User }", kind: KindStruct},
+		{tp: "interface{}", kind: KindInterface},
+		{tp: "interface{ Get(id string) (*User, error); fmt.Stringer }", kind: KindInterface},
+		{tp: "(*User)", want: "*User", kind: KindPointer, base: "User"},
+		{tp: "List[pb.User]", kind: KindIdent, base: "List[pb.User]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tp, func(t *testing.T) {
+			got, err := ParseType(tt.tp)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.tp
+			}
+			if got.String() != want {
+				t.Errorf("ParseType().String() = %v, want %v", got.String(), want)
+			}
+			if got.Kind != tt.kind {
+				t.Errorf("ParseType().Kind = %v, want %v", got.Kind, tt.kind)
+			}
+			if tt.base != "" && got.Base().String() != tt.base {
+				t.Errorf("ParseType().Base() = %v, want %v", got.Base(), tt.base)
+			}
+		})
+	}
+	if _, err := ParseType("[]"); err == nil {
+		t.Error("ParseType() did not fail on an invalid type")
+	}
+}
+
+func TestType_Model(t *testing.T) {
+	tp, _ := ParseType("map[ct.Key]<-chan *pb.User")
+	if tp.Key.Package != "ct" || tp.Key.Name != "Key" {
+		t.Errorf("map key = %+v, want ct.Key", tp.Key)
+	}
+	if tp.Value.Kind != KindChan || tp.Value.Dir != ast.RECV || tp.Value.Elem.Elem.Package != "pb" {
+		t.Errorf("map value = %+v, want a receive only channel of *pb.User", tp.Value)
+	}
+	if c, ok := tp.Find(KindChan); !ok || c != tp.Value {
+		t.Errorf("Type.Find() = %v, want the channel", c)
+	}
+	tp, _ = ParseType("struct{ C chan int }")
+	if _, ok := tp.Find(KindChan); !ok {
+		t.Error("Type.Find() did not find the channel of the struct field")
+	}
+}
+
+func TestFileParser_getTypeFromExp(t *testing.T) {
+	f, err := NewFileParser().Parse([]byte(`package main
+
+type S interface {
+	Foo(ctx context.Context, c chan int, f func(int) error, s struct{ A int }, a [4]byte, p (*pb.User), m map[string]*[]pb.User) (<-chan int, error)
+}
+`))
+	if err != nil {
+		t.Fatalf("FileParser.Parse() error = %v", err)
+	}
+	want := []NamedTypeValue{
+		NewNameType("ctx", "context.Context"),
+		NewNameType("c", "chan int"),
+		NewNameType("f", "func(int) error"),
+		NewNameType("s", "struct{ A int }"),
+		NewNameType("a", "[4]byte"),
+		NewNameType("p", "*pb.User"),
+		NewNameType("m", "map[string]*[]pb.User"),
+	}
+	m := f.Interfaces[0].Methods[0]
+	for i, p := range want {
+		if i >= len(m.Parameters) || m.Parameters[i] != p {
+			t.Errorf("parameter %d = %v, want %v", i, m.Parameters, p)
+			break
+		}
+	}
+	results := []NamedTypeValue{NewNameType("c0", "<-chan int"), NewNameType("e1", "error")}
+	for i, p := range results {
+		if i >= len(m.Results) || m.Results[i] != p {
+			t.Errorf("result %d = %v, want %v", i, m.Results, p)
+			break
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigFileName is the name of the project configuration file.
+const ConfigFileName = ".genkit.yaml"
+
+// runtimeSettings are the `gk_*` settings that are set by the generators
+// themselves or from the root command flags (see SetRuntimeSetting), they do
+// not belong in the project config.
+var runtimeSettings = map[string]bool{
+	"gk_force_override": true,
+	"gk_testing":        true,
+}
+
+// SetRuntimeSetting keeps the setting `key` out of the project config, it is
+// set from a flag that only tunes the current run.
+func SetRuntimeSetting(key string) {
+	runtimeSettings[key] = true
+}
+
+// FindConfigFile looks for the project config file starting from the working
+// directory up to the module root (or only the working directory if the project
+// is not inside a go module), it returns an empty string if there is none.
+func FindConfigFile() (string, error) {
+	dir, err := GetWorkingDir()
+	if err != nil {
+		return "", err
+	}
+	root := FindModuleRoot(dir)
+	if root == "" {
+		root = dir
+	}
+	for {
+		p := filepath.Join(dir, ConfigFileName)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p, nil
+		}
+		if dir == root || filepath.Dir(dir) == dir {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// LoadConfig loads the project config file into viper if there is one,
+// environment variables still take precedence over the file.
+func LoadConfig() error {
+	p, err := FindConfigFile()
+	if err != nil || p == "" {
+		return err
+	}
+	viper.SetConfigFile(p)
+	return viper.MergeInConfig()
+}
+
+// Settings returns the effective layout and naming settings (the `gk_*` keys
+// without the ones set from command flags).
+func Settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	for _, k := range viper.AllKeys() {
+		if !strings.HasPrefix(k, "gk_") || runtimeSettings[k] {
+			continue
+		}
+		settings[k] = viper.Get(k)
+	}
+	return settings
+}
+
+// ConfigSource returns the effective settings in the project config file format.
+func ConfigSource() (string, error) {
+	d, err := yaml.Marshal(Settings())
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer viper.Reset()
+	dir, err := ioutil.TempDir("", "gk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	nested := filepath.Join(dir, "foo", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "foo", GoModFileName):  GoModSource("foo"),
+		filepath.Join(dir, "foo", ConfigFileName): "gk_service_path_format: '%s/svc'\ngk_service_struct_prefix: my\n",
+	}
+	for p, s := range files {
+		if err := ioutil.WriteFile(p, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(nested); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetDefault("gk_service_path_format", "%s/pkg/service")
+	viper.SetDefault("gk_service_struct_prefix", "basic")
+	viper.SetDefault("gk_http_file_name", "handler.go")
+	viper.Set("gk_testing", true)
+	SetRuntimeSetting("gk_on_conflict")
+	viper.Set("gk_on_conflict", "skip")
+	viper.SetDefault("gk_grpc_health", false)
+	viper.Set("gk_grpc_gateway", true)
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"gk_service_path_format":   "%s/svc",
+		"gk_service_struct_prefix": "my",
+		"gk_http_file_name":        "handler.go",
+		"gk_grpc_health":           false,
+		"gk_grpc_gateway":          true,
+	}
+	got := Settings()
+	if len(got) != len(want) {
+		t.Errorf("Settings() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Settings()[%s] = %v, want %v", k, got[k], v)
+		}
+	}
+}
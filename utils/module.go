@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// GoModFileName is the name of the go modules manifest.
+const GoModFileName = "go.mod"
+
+// ErrNoProject is returned when the working directory is neither inside a go
+// module nor inside the $GOPATH/src folder.
+var ErrNoProject = errors.New("the project must be inside a go module (go.mod) or in the $GOPATH/src folder")
+
+// GetWorkingDir returns the folder the generators work in, this is the current
+// directory joined with the `--folder` flag if it was set.
+func GetWorkingDir() (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if viper.GetString("gk_folder") != "" {
+		pwd = filepath.Join(pwd, viper.GetString("gk_folder"))
+	}
+	return pwd, nil
+}
+
+// FindModuleRoot walks up from `dir` until it finds a folder that contains a
+// go.mod file, it returns an empty string if there is none.
+func FindModuleRoot(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, GoModFileName)); err == nil && !fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ModulePath returns the module path declared in the given go.mod content or
+// an empty string if the module directive is missing.
+func ModulePath(mod []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(mod))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 || f[0] != "module" {
+			continue
+		}
+		if f[1][0] == '"' || f[1][0] == '`' {
+			p, err := strconv.Unquote(f[1])
+			if err != nil {
+				return ""
+			}
+			return p
+		}
+		return f[1]
+	}
+	return ""
+}
+
+// GoVersion returns the go version of the go directive of the given go.mod
+// content (e.x `1.21`) or an empty string if the go directive is missing.
+func GoVersion(mod []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(mod))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if f := strings.Fields(line); len(f) == 2 && f[0] == "go" {
+			return f[1]
+		}
+	}
+	return ""
+}
+
+// GoVersionBefore tells if the go version `v` (e.x `1.21` or `1.21.3`) is
+// older than go 1.`minor`, the versions that do not parse are not.
+func GoVersionBefore(v string, minor int) bool {
+	s := strings.SplitN(v, ".", 3)
+	if len(s) < 2 || s[0] != "1" {
+		return false
+	}
+	m, err := strconv.Atoi(strings.TrimRightFunc(s[1], func(r rune) bool { return r < '0' || r > '9' }))
+	return err == nil && m < minor
+}
+
+// ModuleGoVersion returns the go version of the go module the working
+// directory belongs to, it is empty if there is no go.mod or no go directive.
+func ModuleGoVersion() (string, error) {
+	pwd, err := GetWorkingDir()
+	if err != nil {
+		return "", err
+	}
+	root := FindModuleRoot(pwd)
+	if root == "" {
+		return "", nil
+	}
+	mod, err := ioutil.ReadFile(filepath.Join(root, GoModFileName))
+	if err != nil {
+		return "", err
+	}
+	return GoVersion(mod), nil
+}
+
+// GetModule returns the root folder and the module path of the go module the
+// working directory belongs to, both are empty if no go.mod was found.
+func GetModule() (root string, modulePath string, err error) {
+	pwd, err := GetWorkingDir()
+	if err != nil {
+		return "", "", err
+	}
+	root = FindModuleRoot(pwd)
+	if root == "" {
+		return "", "", nil
+	}
+	mod, err := ioutil.ReadFile(filepath.Join(root, GoModFileName))
+	if err != nil {
+		return "", "", err
+	}
+	modulePath = ModulePath(mod)
+	if modulePath == "" {
+		return "", "", fmt.Errorf("`%s` does not declare a module path", filepath.Join(root, GoModFileName))
+	}
+	return root, modulePath, nil
+}
+
+// GetGOPATHSrc returns the `$GOPATH/src` folder that contains `dir`
+// or an empty string if `dir` is not inside any of the GOPATH entries.
+func GetGOPATHSrc(dir string) string {
+	for _, gopath := range filepath.SplitList(GetGOPATH()) {
+		if gopath == "" {
+			continue
+		}
+		gosrc := filepath.Join(gopath, "src") + string(filepath.Separator)
+		if strings.HasPrefix(dir+string(filepath.Separator), gosrc) {
+			return gosrc
+		}
+	}
+	return ""
+}
+
+// InGoModule returns true if the working directory is inside a go module.
+func InGoModule() bool {
+	root, _, err := GetModule()
+	return err == nil && root != ""
+}
+
+// GetProjectPath returns the import path of the working directory.
+//
+// If the working directory is inside a go module the path is built from the
+// module path, otherwise we fall back to the $GOPATH/src layout.
+func GetProjectPath() (string, error) {
+	pwd, err := GetWorkingDir()
+	if err != nil {
+		return "", err
+	}
+	root, modulePath, err := GetModule()
+	if err != nil {
+		return "", err
+	}
+	if root != "" {
+		rel, err := filepath.Rel(root, pwd)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(modulePath, filepath.ToSlash(rel)), nil
+	}
+	gosrc := GetGOPATHSrc(pwd)
+	if gosrc == "" {
+		return "", ErrNoProject
+	}
+	return filepath.ToSlash(strings.TrimPrefix(pwd, gosrc)), nil
+}
+
+// GetImportPath returns the import path of the folder described by the
+// given viper path format setting (e.x `gk_service_path_format`) for a service.
+func GetImportPath(format string, name string) (string, error) {
+	projectPath, err := GetProjectPath()
+	if err != nil {
+		return "", err
+	}
+	p := fmt.Sprintf(viper.GetString(format), ToLowerSnakeCase2(name))
+	return path.Join(projectPath, strings.Replace(p, "\\", "/", -1)), nil
+}
+
+// GoModSource returns the content of a new go.mod file for the given module path.
+func GoModSource(modulePath string) string {
+	v := "1.12"
+	if rv := runtime.Version(); strings.HasPrefix(rv, "go") {
+		if s := strings.SplitN(strings.TrimPrefix(rv, "go"), ".", 3); len(s) >= 2 {
+			v = s[0] + "." + s[1]
+		}
+	}
+	return fmt.Sprintf("module %s\n\ngo %s\n", modulePath, v)
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModulePath(t *testing.T) {
+	tests := []struct {
+		name string
+		mod  string
+		want string
+	}{
+		{
+			name: "Test simple module directive",
+			mod:  "module github.com/foo/bar\n\ngo 1.12\n",
+			want: "github.com/foo/bar",
+		},
+		{
+			name: "Test quoted module path",
+			mod:  "module \"github.com/foo/bar\"\n",
+			want: "github.com/foo/bar",
+		},
+		{
+			name: "Test module directive after comments",
+			mod:  "// my module\n\nmodule github.com/foo/bar // the path\n\nrequire github.com/go-kit/kit v0.8.0\n",
+			want: "github.com/foo/bar",
+		},
+		{
+			name: "Test quoted module path with a comment",
+			mod:  "module\t\"github.com/foo/bar\" // the path\n",
+			want: "github.com/foo/bar",
+		},
+		{
+			name: "Test directive starting with module",
+			mod:  "modulefoo x\nmodule github.com/foo/bar\n",
+			want: "github.com/foo/bar",
+		},
+		{
+			name: "Test missing module directive",
+			mod:  "go 1.12\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ModulePath([]byte(tt.mod)); got != tt.want {
+				t.Errorf("ModulePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		mod  string
+		want string
+	}{
+		{
+			name: "Test go directive",
+			mod:  "module github.com/foo/bar\n\ngo 1.21 // the minimum\n",
+			want: "1.21",
+		},
+		{
+			name: "Test patch version",
+			mod:  "module github.com/foo/bar\n\ngo 1.22.3\n\ntoolchain go1.23.0\n",
+			want: "1.22.3",
+		},
+		{
+			name: "Test missing go directive",
+			mod:  "module github.com/foo/bar\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GoVersion([]byte(tt.mod)); got != tt.want {
+				t.Errorf("GoVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoVersionBefore(t *testing.T) {
+	tests := []struct {
+		v    string
+		want bool
+	}{
+		{v: "1.21", want: true},
+		{v: "1.9", want: true},
+		{v: "1.22", want: false},
+		{v: "1.22.3", want: false},
+		{v: "1.23rc1", want: false},
+		{v: "", want: false},
+		{v: "2.0", want: false},
+	}
+	for _, tt := range tests {
+		if got := GoVersionBefore(tt.v, 22); got != tt.want {
+			t.Errorf("GoVersionBefore(%q, 22) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestFindModuleRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	nested := filepath.Join(dir, "foo", "pkg", "service")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo", GoModFileName), []byte(GoModSource("foo")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{
+			name: "Test module root folder",
+			dir:  filepath.Join(dir, "foo"),
+			want: filepath.Join(dir, "foo"),
+		},
+		{
+			name: "Test nested folder",
+			dir:  nested,
+			want: filepath.Join(dir, "foo"),
+		},
+		{
+			name: "Test folder outside of the module",
+			dir:  dir,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindModuleRoot(tt.dir); got != tt.want {
+				t.Errorf("FindModuleRoot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"runtime"
 	"strings"
 
-	"fmt"
-
 	"github.com/alioygur/godash"
 	"github.com/spf13/viper"
 	"golang.org/x/tools/imports"
@@ -60,138 +58,37 @@ func GoImportsSource(path string, s string) (string, error) {
 
 // GetServiceImportPath returns the import path of the service interface.
 func GetServiceImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	svcPath := fmt.Sprintf(viper.GetString("gk_service_path_format"), ToLowerSnakeCase2(name))
-
-	svcPath = strings.Replace(svcPath, "\\", "/", -1)
-	serviceImport := projectPath + "/" + svcPath
-	return serviceImport, nil
+	return GetImportPath("gk_service_path_format", name)
 }
 
 // GetCmdServiceImportPath returns the import path of the cmd service (used by cmd/main.go).
 func GetCmdServiceImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	svcPath := fmt.Sprintf(viper.GetString("gk_cmd_service_path_format"), ToLowerSnakeCase2(name))
-
-	svcPath = strings.Replace(svcPath, "\\", "/", -1)
-	serviceImport := projectPath + "/" + svcPath
-	return serviceImport, nil
+	return GetImportPath("gk_cmd_service_path_format", name)
 }
 
 // GetEndpointImportPath returns the import path of the service endpoints.
 func GetEndpointImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	epPath := fmt.Sprintf(viper.GetString("gk_endpoint_path_format"), ToLowerSnakeCase2(name))
-
-	epPath = strings.Replace(epPath, "\\", "/", -1)
-	endpointImport := projectPath + "/" + epPath
-	return endpointImport, nil
+	return GetImportPath("gk_endpoint_path_format", name)
 }
 
 // GetGRPCTransportImportPath returns the import path of the service grpc transport.
 func GetGRPCTransportImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	epPath := fmt.Sprintf(viper.GetString("gk_grpc_path_format"), ToLowerSnakeCase2(name))
-
-	epPath = strings.Replace(epPath, "\\", "/", -1)
-	endpointImport := projectPath + "/" + epPath
-	return endpointImport, nil
+	return GetImportPath("gk_grpc_path_format", name)
 }
 
 // GetPbImportPath returns the import path of the generated service grpc pb.
 func GetPbImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	epPath := fmt.Sprintf(viper.GetString("gk_grpc_pb_path_format"), ToLowerSnakeCase2(name))
-
-	epPath = strings.Replace(epPath, "\\", "/", -1)
-	endpointImport := projectPath + "/" + epPath
-	return endpointImport, nil
+	return GetImportPath("gk_grpc_pb_path_format", name)
 }
 
 // GetHTTPTransportImportPath returns the import path of the service http transport.
 func GetHTTPTransportImportPath(name string) (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	epPath := fmt.Sprintf(viper.GetString("gk_http_path_format"), ToLowerSnakeCase2(name))
-
-	epPath = strings.Replace(epPath, "\\", "/", -1)
-	httpImports := projectPath + "/" + epPath
-	return httpImports, nil
+	return GetImportPath("gk_http_path_format", name)
 }
 
 // GetDockerFileProjectPath returns the path of the project.
 func GetDockerFileProjectPath() (string, error) {
-	gosrc := GetGOPATH() + "/src/"
-	gosrc = strings.Replace(gosrc, "\\", "/", -1)
-	pwd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	if viper.GetString("gk_folder") != "" {
-		pwd += "/" + viper.GetString("gk_folder")
-	}
-	pwd = strings.Replace(pwd, "\\", "/", -1)
-	projectPath := strings.Replace(pwd, gosrc, "", 1)
-	return projectPath, nil
+	return GetProjectPath()
 }
 
 // GetGOPATH returns the gopath.